@@ -0,0 +1,107 @@
+package kdl
+
+import "strings"
+
+// At returns the node at a path of names of nested nodes separated by dots, e.g. "server.listen",
+// following the first child with each name. A dot or a backslash in a name is escaped with a backslash,
+// e.g. `files.app\.log` for the node "app.log" in "files".
+// The node can be modified in place.
+//
+// An empty path is not a path to any node.
+func (d *Document) At(path string) (*Node, bool) {
+	return d.AtPath(splitPath(path)...)
+}
+
+// AtPath returns the node at a path of names of nested nodes, like At does, without the need for escaping.
+func (d *Document) AtPath(names ...Identifier) (*Node, bool) {
+	if len(names) == 0 {
+		return nil, false
+	}
+	n, ok := d.Child(names[0])
+	for _, name := range names[1:] {
+		if !ok {
+			break
+		}
+		n, ok = n.Child(name)
+	}
+	return n, ok
+}
+
+// AtAll returns all the nodes at a path like the one for At, following all the children with each name,
+// in the order of the document.
+func (d *Document) AtAll(path string) NodeSet {
+	names := splitPath(path)
+	if len(names) == 0 {
+		return nil
+	}
+	nodes := d.Children(names[0])
+	for _, name := range names[1:] {
+		nodes = nodes.Children(name)
+	}
+	return nodes
+}
+
+// AtValue returns the value at a path like the one for At.
+// If there is a node at the path, the value is its first argument.
+// Otherwise, the last name of the path is a property of the node at the rest of the path,
+// e.g. "server.listen.port" is either the argument of `port` or the property port of `listen`.
+func (d *Document) AtValue(path string) (Value, bool) {
+	names := splitPath(path)
+	if n, ok := d.AtPath(names...); ok {
+		return n.Arg(0)
+	}
+	if len(names) < 2 {
+		return newInvalidValue(), false
+	}
+	n, ok := d.AtPath(names[:len(names)-1]...)
+	if !ok {
+		return newInvalidValue(), false
+	}
+	return n.PropValue(names[len(names)-1])
+}
+
+// AtString returns the value at a path, like AtValue does, if it is a string.
+func (d *Document) AtString(path string) (string, bool) {
+	v, _ := d.AtValue(path)
+	return v.asString()
+}
+
+// AtInt returns the value at a path, like AtValue does, if it is an integer that fits in an int64.
+func (d *Document) AtInt(path string) (int64, bool) {
+	v, _ := d.AtValue(path)
+	return v.asInt64()
+}
+
+// AtFloat returns the value at a path, like AtValue does, as the nearest float64, if it is a number.
+func (d *Document) AtFloat(path string) (float64, bool) {
+	v, _ := d.AtValue(path)
+	return v.asFloat64()
+}
+
+// AtBool returns the value at a path, like AtValue does, if it is a boolean.
+func (d *Document) AtBool(path string) (bool, bool) {
+	v, _ := d.AtValue(path)
+	return v.asBool()
+}
+
+// splitPath splits a path into names at the dots not escaped with a backslash.
+func splitPath(path string) []Identifier {
+	if path == "" {
+		return nil
+	}
+	var names []Identifier
+	var name strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			i++
+			name.WriteByte(path[i])
+		case path[i] == '.':
+			names = append(names, Identifier(name.String()))
+			name.Reset()
+		default:
+			name.WriteByte(path[i])
+		}
+	}
+	return append(names, Identifier(name.String()))
+}
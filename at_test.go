@@ -0,0 +1,116 @@
+package kdl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const atDocument = `server {
+	listen 80 host="localhost" {
+		port 8080
+	}
+	listen 443
+	tls #true
+	"app.log" "/var/log/app.log"
+}
+server {
+	listen 8443
+}
+empty
+`
+
+func TestDocumentAt(t *testing.T) {
+	doc, err := ParseString(atDocument, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	n, ok := doc.At("server.listen")
+	assert.True(t, ok)
+	assert.Same(t, &doc.Nodes[0].Children[0], n)
+
+	n, ok = doc.At("server.listen.port")
+	assert.True(t, ok)
+	assert.Same(t, &doc.Nodes[0].Children[0].Children[0], n)
+
+	n, ok = doc.At(`server.app\.log`)
+	assert.True(t, ok)
+	assert.Same(t, &doc.Nodes[0].Children[3], n)
+	n, ok = doc.AtPath("server", "app.log")
+	assert.True(t, ok)
+	assert.Same(t, &doc.Nodes[0].Children[3], n)
+
+	for _, path := range []string{"", "missing", "missing.listen", "server.missing.port", "server.listen.host", "server.app.log"} {
+		n, ok = doc.At(path)
+		assert.False(t, ok, path)
+		assert.Nil(t, n, path)
+	}
+	n, ok = doc.AtPath()
+	assert.False(t, ok)
+	assert.Nil(t, n)
+}
+
+func TestDocumentAtAll(t *testing.T) {
+	doc, err := ParseString(atDocument, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	ports := doc.AtAll("server.listen").Args()
+	var ints []int64
+	for _, p := range ports {
+		i, _ := p.asInt64()
+		ints = append(ints, i)
+	}
+	assert.Equal(t, []int64{80, 443, 8443}, ints)
+	assert.Empty(t, doc.AtAll(""))
+	assert.Empty(t, doc.AtAll("server.missing.port"))
+}
+
+func TestDocumentAtValue(t *testing.T) {
+	doc, err := ParseString(atDocument, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	port, ok := doc.AtInt("server.listen.port")
+	assert.True(t, ok)
+	assert.Equal(t, int64(8080), port)
+
+	// The last segment may be a property
+	host, ok := doc.AtString("server.listen.host")
+	assert.True(t, ok)
+	assert.Equal(t, "localhost", host)
+
+	tls, ok := doc.AtBool("server.tls")
+	assert.True(t, ok)
+	assert.True(t, tls)
+
+	f, ok := doc.AtFloat("server.listen")
+	assert.True(t, ok)
+	assert.Equal(t, 80.0, f)
+
+	path, ok := doc.AtString(`server.app\.log`)
+	assert.True(t, ok)
+	assert.Equal(t, "/var/log/app.log", path)
+
+	// Only the last segment may be a property
+	_, ok = doc.AtValue("server.listen.host.name")
+	assert.False(t, ok)
+	// A node without arguments has no value
+	_, ok = doc.AtValue("empty")
+	assert.False(t, ok)
+	_, ok = doc.AtValue("")
+	assert.False(t, ok)
+	_, ok = doc.AtValue("missing.listen.port")
+	assert.False(t, ok)
+	// The types must match
+	_, ok = doc.AtInt("server.listen.host")
+	assert.False(t, ok)
+	_, ok = doc.AtString("server.tls")
+	assert.False(t, ok)
+}
+
+func TestSplitPath(t *testing.T) {
+	assert.Nil(t, splitPath(""))
+	assert.Equal(t, []Identifier{"a"}, splitPath("a"))
+	assert.Equal(t, []Identifier{"a", "b", "c"}, splitPath("a.b.c"))
+	assert.Equal(t, []Identifier{"a.b", "c"}, splitPath(`a\.b.c`))
+	assert.Equal(t, []Identifier{`a\b`, ""}, splitPath(`a\\b.`))
+	assert.Equal(t, []Identifier{`a\`}, splitPath(`a\`))
+}
@@ -0,0 +1,255 @@
+package kdl
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ConversionError describes a property or an argument of a node
+// that is missing or cannot be converted to the requested Go type.
+//
+// Use errors.Is to check for the underlying cause, e.g. ErrMissingValue or ErrIntegerOverflow.
+type ConversionError struct {
+	Source string       // Name of the document of the node, e.g. the path of its file. See Node.Source.
+	Node   Identifier   // Name of the node.
+	Field  string       // Which value has been requested, e.g. `property "port"` or "argument 0".
+	Found  string       // Type of the value found, with its type hint, e.g. "(u8)integer". Empty if it is missing.
+	Want   reflect.Type // The requested Go type.
+	Err    error        // The underlying cause.
+}
+
+func (e *ConversionError) Error() string {
+	prefix := "node " + strconv.Quote(string(e.Node)) + ", " + e.Field
+	if e.Source != "" {
+		prefix = e.Source + ": " + prefix
+	}
+	if errors.Is(e.Err, ErrMissingValue) {
+		return prefix + " is missing, want " + e.Want.String()
+	}
+	return prefix + ": found " + e.Found + ", want " + e.Want.String() + ": " + e.Err.Error()
+}
+
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
+var errUnsupportedType = errors.New("the Go type is not supported")
+
+var (
+	valueType    = reflect.TypeOf(Value{})
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+// Prop converts a property of the node to T.
+//
+// T can be a string, a bool, any integer or float type, time.Time, time.Duration, []byte,
+// a Value or a pointer to any of these, in which case a null converts to nil.
+// Integers are converted to floats, but not the other way around.
+//
+// Times are parsed from strings as RFC 3339, or as "2006-01-02" and "15:04:05"
+// with a (date) or (time) type hint respectively.
+// Durations are parsed from strings by time.ParseDuration, or from numbers
+// with a unit as a type hint, e.g. (ms)500. Supported units are the same as in time.ParseDuration.
+// Bytes are decoded from strings with a (base64) type hint, or are the UTF-8 bytes of other strings.
+//
+// The error is a ConversionError.
+func Prop[T any](n *Node, name string) (T, error) {
+	v, ok := n.PropValue(Identifier(name))
+	return convertField[T](n, "property "+strconv.Quote(name), v, ok)
+}
+
+// Arg converts the argument of the node at index i to T, the same way as Prop does.
+// A negative index counts from the end, so -1 is the last argument.
+//
+// The error is a ConversionError.
+func Arg[T any](n *Node, i int) (T, error) {
+	v, ok := n.Arg(i)
+	return convertField[T](n, "argument "+strconv.Itoa(i), v, ok)
+}
+
+func convertField[T any](n *Node, field string, v Value, ok bool) (T, error) {
+	var out T
+	dst := reflect.ValueOf(&out).Elem()
+	if !ok {
+		return out, &ConversionError{Source: n.source, Node: n.Name, Field: field, Want: dst.Type(), Err: ErrMissingValue}
+	}
+	if err := convertValue(v, dst); err != nil {
+		return out, &ConversionError{Source: n.source, Node: n.Name, Field: field, Found: describeValue(v), Want: dst.Type(), Err: err}
+	}
+	return out, nil
+}
+
+// describeValue names the type of a Value for error messages, e.g. "(u8)integer".
+func describeValue(v Value) string {
+	if hint, ok := v.TypeHint.Get(); ok {
+		return "(" + string(hint) + ")" + v.Type.String()
+	}
+	return v.Type.String()
+}
+
+// convertValue stores a Value in dst, which must be settable.
+// The errors are not wrapped, so that the caller can describe where the Value comes from.
+func convertValue(v Value, dst reflect.Value) error {
+	t := dst.Type()
+	switch t {
+	case valueType:
+		dst.Set(reflect.ValueOf(v))
+		return nil
+	case timeType:
+		tm, err := convertTime(v)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(tm))
+		return nil
+	case durationType:
+		d, err := convertDuration(v)
+		if err != nil {
+			return err
+		}
+		dst.SetInt(int64(d))
+		return nil
+	case bytesType:
+		b, err := convertBytes(v)
+		if err != nil {
+			return err
+		}
+		dst.SetBytes(b)
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		if v.Type == TypeNull {
+			dst.Set(reflect.Zero(t))
+			return nil
+		}
+		elem := reflect.New(t.Elem())
+		if err := convertValue(v, elem.Elem()); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	case reflect.String:
+		s, ok := v.asString()
+		if !ok {
+			return ErrWrongType
+		}
+		dst.SetString(s)
+		return nil
+	case reflect.Bool:
+		b, ok := v.asBool()
+		if !ok {
+			return ErrWrongType
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type != TypeInteger {
+			return ErrWrongType
+		}
+		i := v.IntegerValue()
+		if !i.IsInt64() || dst.OverflowInt(i.Int64()) {
+			return ErrIntegerOverflow
+		}
+		dst.SetInt(i.Int64())
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Type != TypeInteger {
+			return ErrWrongType
+		}
+		i := v.IntegerValue()
+		if !i.IsUint64() || dst.OverflowUint(i.Uint64()) {
+			return ErrIntegerOverflow
+		}
+		dst.SetUint(i.Uint64())
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := convertFloat(v)
+		if err != nil {
+			return err
+		}
+		if !math.IsInf(f, 0) && dst.OverflowFloat(f) {
+			return ErrFloatOverflow
+		}
+		dst.SetFloat(f)
+		return nil
+	}
+	return errUnsupportedType
+}
+
+// convertFloat returns a number as a float64.
+// Infinities and NaNs are kept, but finite numbers too large for a float64 are an error.
+func convertFloat(v Value) (float64, error) {
+	var f *big.Float
+	switch v.Type {
+	case TypeInteger:
+		f = new(big.Float).SetInt(v.IntegerValue())
+	case TypeFloat:
+		if v.IsNaN() {
+			return math.NaN(), nil
+		}
+		f = v.FloatValue()
+	default:
+		return 0, ErrWrongType
+	}
+	f64, _ := f.Float64()
+	if math.IsInf(f64, 0) && !f.IsInf() {
+		return 0, ErrFloatOverflow
+	}
+	return f64, nil
+}
+
+func convertTime(v Value) (time.Time, error) {
+	s, ok := v.asString()
+	if !ok {
+		return time.Time{}, ErrWrongType
+	}
+	layout := time.RFC3339
+	if hint, ok := v.TypeHint.Get(); ok {
+		switch hint {
+		case "date":
+			layout = time.DateOnly
+		case "time":
+			layout = time.TimeOnly
+		}
+	}
+	return time.Parse(layout, s)
+}
+
+func convertDuration(v Value) (time.Duration, error) {
+	if s, ok := v.asString(); ok {
+		return time.ParseDuration(s)
+	}
+	unit, ok := v.TypeHint.Get()
+	if !ok || (v.Type != TypeInteger && v.Type != TypeFloat) {
+		return 0, ErrWrongType
+	}
+	if v.Type == TypeInteger {
+		// Parsing the digits keeps the precision of large numbers of nanoseconds
+		return time.ParseDuration(v.IntegerValue().String() + string(unit))
+	}
+	f, err := convertFloat(v)
+	if err != nil {
+		return 0, err
+	}
+	return time.ParseDuration(strconv.FormatFloat(f, 'f', -1, 64) + string(unit))
+}
+
+func convertBytes(v Value) ([]byte, error) {
+	s, ok := v.asString()
+	if !ok {
+		return nil, ErrWrongType
+	}
+	if hint, ok := v.TypeHint.Get(); ok && hint == "base64" {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return []byte(s), nil
+}
@@ -0,0 +1,231 @@
+package kdl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// errAnyCause marks the cases of TestConvertValue that fail with an error of the standard library.
+var errAnyCause = errors.New("any error")
+
+func TestConvertValue(t *testing.T) {
+	type myString string
+	date := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		kdl  string // Argument of a node, in KDL 2.0
+		into any    // Pointer to the requested type
+		want any    // The converted value or an error
+	}{
+		{`"text"`, new(string), "text"},
+		{`"text"`, new(myString), myString("text")},
+		{`1`, new(string), ErrWrongType},
+		{`#null`, new(string), ErrWrongType},
+		{`#true`, new(bool), true},
+		{`#false`, new(bool), false},
+		{`"true"`, new(bool), ErrWrongType},
+
+		{`-128`, new(int8), int8(-128)},
+		{`128`, new(int8), ErrIntegerOverflow},
+		{`255`, new(uint8), uint8(255)},
+		{`-1`, new(uint), ErrIntegerOverflow},
+		{`9223372036854775807`, new(int64), int64(math.MaxInt64)},
+		{`9223372036854775808`, new(int64), ErrIntegerOverflow},
+		{`0xFFFF_FFFF_FFFF_FFFF`, new(uint64), uint64(math.MaxUint64)},
+		{`(u8)7`, new(int), 7},
+		{`1.0`, new(int), ErrWrongType},
+		{`"1"`, new(int), ErrWrongType},
+
+		{`1.5`, new(float64), 1.5},
+		{`2`, new(float64), 2.0},
+		{`-1.5`, new(float32), float32(-1.5)},
+		{`1e300`, new(float64), 1e300},
+		{`1e39`, new(float32), ErrFloatOverflow},
+		{`1e309`, new(float64), ErrFloatOverflow},
+		{`#inf`, new(float64), math.Inf(1)},
+		{`#-inf`, new(float32), float32(math.Inf(-1))},
+		{`"1.5"`, new(float64), ErrWrongType},
+
+		{`"2024-02-29T00:00:00Z"`, new(time.Time), date},
+		{`(date-time)"2024-02-29T01:00:00+01:00"`, new(time.Time), date},
+		{`(date)"2024-02-29"`, new(time.Time), date},
+		{`(time)"13:14:15.5"`, new(time.Time), time.Date(0, 1, 1, 13, 14, 15, 5e8, time.UTC)},
+		{`"2024-02-29"`, new(time.Time), errAnyCause},
+		{`1709164800`, new(time.Time), ErrWrongType},
+
+		{`"1h30m"`, new(time.Duration), 90 * time.Minute},
+		{`(ms)500`, new(time.Duration), 500 * time.Millisecond},
+		{`(s)1.5`, new(time.Duration), 1500 * time.Millisecond},
+		{`(ns)9223372036854775807`, new(time.Duration), time.Duration(math.MaxInt64)},
+		{`500`, new(time.Duration), ErrWrongType},
+		{`(lightyears)1`, new(time.Duration), errAnyCause},
+		{`"soon"`, new(time.Duration), errAnyCause},
+
+		{`"text"`, new(*string), ptr("text")},
+		{`#null`, new(*string), (*string)(nil)},
+		{`#null`, new(**int), (**int)(nil)},
+		{`2`, new(**int), ptr(ptr(2))},
+		{`"2"`, new(*int), ErrWrongType},
+		{`(ms)1`, new(*time.Duration), ptr(time.Millisecond)},
+
+		{`(u8)#null`, new(Value), NewNullValue(Hint("u8"))},
+		{`1`, new(*Value), ptr(NewIntegerValue(big.NewInt(1), NoHint()))},
+		{`#null`, new(*Value), (*Value)(nil)},
+		{`1`, new(chan int), errUnsupportedType},
+		{`1`, new(any), errUnsupportedType},
+	}
+	for _, c := range cases {
+		doc, err := ParseString("node "+c.kdl+"\n", WithVersion(Version2))
+		if !assert.NoError(t, err, c.kdl) {
+			continue
+		}
+		dst := reflect.ValueOf(c.into).Elem()
+		err = convertValue(doc.Nodes[0].Args[0], dst)
+
+		switch want := c.want.(type) {
+		case error:
+			if want == errAnyCause {
+				assert.Error(t, err, c.kdl)
+			} else {
+				assert.ErrorIs(t, err, want, c.kdl)
+			}
+		case time.Time:
+			if assert.NoError(t, err, c.kdl) {
+				assert.True(t, want.Equal(dst.Interface().(time.Time)), "%s: %v", c.kdl, dst.Interface())
+			}
+		default:
+			if assert.NoError(t, err, c.kdl) {
+				assert.Equal(t, describeValues(c.want), describeValues(dst.Interface()), c.kdl)
+			}
+		}
+	}
+}
+
+// describeValues replaces Values with their descriptions,
+// as Values holding the same number do not have to be equal.
+func describeValues(v any) any {
+	switch v := v.(type) {
+	case Value:
+		return fmt.Sprint(describeValue(v), " ", v.RawValue)
+	case *Value:
+		if v == nil {
+			return v
+		}
+		return fmt.Sprint(describeValue(*v), " ", v.RawValue)
+	}
+	return v
+}
+
+func TestPropAndArg(t *testing.T) {
+	doc, err := ParseString("server \"main\" 8080 port=300 timeout=(ms)250 host=null\n")
+	assert.NoError(t, err)
+	n := &doc.Nodes[0]
+
+	port, err := Prop[uint16](n, "port")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(300), port)
+
+	timeout, err := Prop[time.Duration](n, "timeout")
+	assert.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, timeout)
+
+	host, err := Prop[*string](n, "host")
+	assert.NoError(t, err)
+	assert.Nil(t, host)
+
+	name, err := Arg[string](n, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "main", name)
+
+	last, err := Arg[float64](n, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, 8080.0, last)
+
+	_, err = Prop[int8](n, "port")
+	assert.ErrorIs(t, err, ErrIntegerOverflow)
+	assert.EqualError(t, err, `node "server", property "port": found integer, want int8: integer overflows the requested type`)
+
+	_, err = Prop[string](n, "timeout")
+	assert.ErrorIs(t, err, ErrWrongType)
+	assert.EqualError(t, err, `node "server", property "timeout": found (ms)integer, want string: value has the wrong type`)
+
+	_, err = Prop[string](n, "missing")
+	assert.ErrorIs(t, err, ErrMissingValue)
+	assert.EqualError(t, err, `node "server", property "missing" is missing, want string`)
+
+	_, err = Arg[*int](n, 2)
+	assert.ErrorIs(t, err, ErrMissingValue)
+	assert.EqualError(t, err, `node "server", argument 2 is missing, want *int`)
+
+	var convErr *ConversionError
+	_, err = Arg[bool](n, -2)
+	if assert.ErrorAs(t, err, &convErr) {
+		assert.Equal(t, Identifier("server"), convErr.Node)
+		assert.Equal(t, "argument -2", convErr.Field)
+		assert.Equal(t, "string", convErr.Found)
+		assert.Equal(t, reflect.TypeOf(false), convErr.Want)
+	}
+}
+
+func TestConvertValueOfRoundTrip(t *testing.T) {
+	moment := time.Date(2024, 2, 29, 13, 14, 15, 5e8, time.FixedZone("", 3600))
+	n := NewNode("n")
+	assert.NoError(t, n.AddArgs([]byte{0, 1, 255}, moment, 90*time.Second, "plain"))
+
+	b, err := Arg[[]byte](&n, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 255}, b)
+	tm, err := Arg[time.Time](&n, 1)
+	assert.NoError(t, err)
+	assert.True(t, moment.Equal(tm))
+	d, err := Arg[time.Duration](&n, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, d)
+	b, err = Arg[[]byte](&n, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("plain"), b)
+
+	n.AddArgValue(NewStringValue("not base64!", Hint("base64")))
+	_, err = Arg[[]byte](&n, 4)
+	assert.Error(t, err)
+}
+
+func TestConversionErrorSource(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf.d/10-defaults.kdl": {Data: []byte("server port=80\n")},
+		"conf.d/20-user.kdl":     {Data: []byte("server {\n\tlisten port=\"http\"\n}\n")},
+	}
+	docs, err := ParseFS(fsys, "conf.d/*.kdl")
+	if !assert.NoError(t, err) || !assert.Len(t, docs, 2) {
+		return
+	}
+	merged := Concat(&docs[0], &docs[1])
+
+	_, err = Prop[int](&merged.Nodes[0], "port")
+	assert.NoError(t, err)
+	_, err = Prop[int](&merged.Nodes[1].Children[0], "port")
+	assert.ErrorIs(t, err, ErrWrongType)
+	assert.EqualError(t, err, `conf.d/20-user.kdl: node "listen", property "port": found string, want int: value has the wrong type`)
+
+	var convErr *ConversionError
+	if assert.ErrorAs(t, err, &convErr) {
+		assert.Equal(t, "conf.d/20-user.kdl", convErr.Source)
+	}
+
+	// Nodes not read from files have no source
+	n := NewNode("listen")
+	_, err = Prop[int](&n, "port")
+	assert.EqualError(t, err, `node "listen", property "port" is missing, want int`)
+}
@@ -0,0 +1,168 @@
+package kdl
+
+import (
+	"bufio"
+	"context"
+	"io"
+)
+
+// Decoder reads the top-level nodes of a document one at a time,
+// without keeping the whole document in memory.
+// Children of a node are still read eagerly, together with their parent.
+//
+// A Decoder is not safe for concurrent use.
+type Decoder struct {
+	buf     *bufio.Reader // The buffer of the input, unless it is buffered already.
+	r       reader
+	err     error // The error that stopped the decoding, if any.
+	pending *Node // A node read while recovering from errors, to be returned after them.
+}
+
+// NewDecoder creates a new Decoder reading from r.
+//
+// If r is a bufio.Reader, it is read from directly and only advanced past the nodes decoded so far,
+// so that the caller can go on reading whatever follows them, like with ParseReader.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	return newDecoder(r, newParseConfig(ParseOptions{}, opts))
+}
+
+// NewDecoder creates a new Decoder reading from r, using these options.
+//
+// If AllErrors is enabled, each error recovered from is returned by a separate call to Decode,
+// before the node that follows it.
+func (o ParseOptions) NewDecoder(r io.Reader) *Decoder {
+	return newDecoder(r, newParseConfig(o, nil))
+}
+
+func newDecoder(r io.Reader, cfg parseConfig) *Decoder {
+	d := &Decoder{}
+	d.r = wrapReader(d.buffer(r))
+	d.r.cfg = cfg
+	return d
+}
+
+// buffer returns a buffered reader of r, reusing the buffer of the Decoder if needed.
+// A reader that is buffered already, e.g. a bufio.Reader, is read from directly,
+// so that it is only advanced past the nodes that have been decoded.
+func (d *Decoder) buffer(r io.Reader) innerReader {
+	if inner, ok := r.(innerReader); ok {
+		return callbackBuffer{inner}
+	}
+	if d.buf == nil {
+		d.buf = bufio.NewReader(callbackReader{r})
+	} else {
+		d.buf.Reset(callbackReader{r})
+	}
+	return d.buf
+}
+
+// Reset discards the state of the Decoder and makes it read from r instead,
+// keeping the options. The internal buffers are reused, which saves allocations
+// when decoding many small documents.
+func (d *Decoder) Reset(r io.Reader) {
+	d.r.reset(d.buffer(r))
+	d.err = nil
+	d.pending = nil
+}
+
+// Decode reads the next top-level node of the document.
+// Nodes commented out with a slashdash are skipped.
+//
+// At the end of the document, Decode returns io.EOF.
+// Once Decode returns any other error, all subsequent calls return that error too.
+func (d *Decoder) Decode() (Node, error) {
+
+	if err := d.popRecoveredError(); err != nil {
+		return Node{}, err
+	}
+
+	if d.pending != nil {
+		node := *d.pending
+		d.pending = nil
+		return node, nil
+	}
+
+	if d.err != nil {
+		return Node{}, d.err
+	}
+
+	node, done, err := readNextNode(&d.r)
+	if err != nil {
+		d.err = finishError(err, &d.r)
+	} else if done {
+		d.err = io.EOF
+	} else if len(d.r.errs) > 0 {
+		d.pending = &node
+	} else {
+		return node, nil
+	}
+
+	if err := d.popRecoveredError(); err != nil {
+		return Node{}, err
+	}
+
+	return Node{}, d.err
+}
+
+// DecodeContext reads the next top-level node of the document like Decode,
+// giving up once the context is canceled.
+//
+// The context is checked between the nodes and while skipping comments,
+// so a read that blocks is not interrupted. After a cancellation, the Decoder cannot be used anymore.
+func (d *Decoder) DecodeContext(ctx context.Context) (Node, error) {
+	d.r.setContext(ctx)
+	defer d.r.setContext(context.Background())
+	return d.Decode()
+}
+
+// popRecoveredError returns the oldest error the parser has recovered from, if any.
+func (d *Decoder) popRecoveredError() error {
+	errs := d.r.errs
+	if len(errs) == 0 {
+		return nil
+	}
+	err := errs[0]
+	errs[0] = nil
+	d.r.errs = errs[1:]
+	return err
+}
+
+// Select returns an iterator over the nodes matching a selector, at any depth,
+// in the rest of the document read by this Decoder. The syntax of the selector is the same as for Document.Select.
+//
+// The top-level nodes are decoded one at a time, like Decode does, and discarded as soon as they have been searched,
+// so that only the largest of them has to fit in memory. The matching nodes are yielded as copies,
+// which stay valid after the iteration moves on. If a node matches together with some of its descendants,
+// all of them are yielded, the ancestor first.
+//
+// Errors are yielded like Decode returns them, with an empty Node. The iteration stops at the end of the document,
+// or after an error that cannot be recovered from. A malformed selector makes a QueryError returned instead.
+func (d *Decoder) Select(selector string) (func(yield func(Node, error) bool), error) {
+	p := &queryParser{src: selector, simple: true}
+	q, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(Node, error) bool) {
+		for {
+			node, err := d.Decode()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				if !yield(Node{}, err) || d.err != nil {
+					return
+				}
+				continue
+			}
+
+			doc := Document{Nodes: []Node{node}}
+			for _, n := range q.find(&doc) {
+				if !yield(*n, nil) {
+					return
+				}
+			}
+		}
+	}, nil
+}
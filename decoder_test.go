@@ -0,0 +1,333 @@
+package kdl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoderReadsNodesOneByOne(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`foo 1
+/-bar 2
+baz {
+	quox 3
+}; last`))
+
+	n, err := d.Decode()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "foo", n.Name)
+
+	n, err = d.Decode()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "baz", n.Name)
+	if assert.Len(t, n.Children, 1) {
+		assert.EqualValues(t, "quox", n.Children[0].Name)
+	}
+
+	n, err = d.Decode()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "last", n.Name)
+
+	_, err = d.Decode()
+	assert.ErrorIs(t, err, io.EOF)
+	_, err = d.Decode()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestDecoderErrorIsSticky(t *testing.T) {
+	d := NewDecoder(strings.NewReader("foo\nbar baz\nquox"))
+
+	n, err := d.Decode()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "foo", n.Name)
+
+	_, err = d.Decode()
+	assert.ErrorIs(t, err, errUnexpectedBareIdentifier)
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 2, pe.Line)
+	}
+
+	_, err = d.Decode()
+	assert.ErrorIs(t, err, errUnexpectedBareIdentifier)
+}
+
+func TestDecoderReturnsRecoveredErrors(t *testing.T) {
+	d := ParseOptions{AllErrors: true}.NewDecoder(strings.NewReader("foo\nbar baz\n; quox\n"))
+
+	names := make([]Identifier, 0)
+	errs := make([]error, 0)
+	for {
+		n, err := d.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			errs = append(errs, err)
+		} else {
+			names = append(names, n.Name)
+		}
+	}
+
+	assert.Equal(t, []Identifier{"foo", "quox"}, names)
+	if assert.Len(t, errs, 2) {
+		assert.ErrorIs(t, errs[0], errUnexpectedBareIdentifier)
+		assert.ErrorIs(t, errs[1], errUnexpectedSemicolon)
+	}
+}
+
+// generatedDocument lazily produces a document consisting of many similar nodes.
+type generatedDocument struct {
+	remaining int
+	buf       bytes.Buffer
+}
+
+func (g *generatedDocument) Read(p []byte) (int, error) {
+	for g.buf.Len() < len(p) && g.remaining > 0 {
+		g.buf.WriteString("node 1 \"two\" three=3.0 {\n\tchild\n}\n")
+		g.remaining--
+	}
+	if g.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return g.buf.Read(p)
+}
+
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+func TestDecoderMemoryStaysFlat(t *testing.T) {
+	const count = 100_000
+	d := NewDecoder(&generatedDocument{remaining: count})
+
+	var halfway uint64
+	decoded := 0
+	for {
+		n, err := d.Decode()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, n.Children, 1)
+
+		decoded++
+		if decoded == count/2 {
+			halfway = heapInUse()
+		}
+	}
+
+	assert.Equal(t, count, decoded)
+	assert.Less(t, heapInUse(), halfway+(1<<20))
+}
+
+func TestDecoderDecodeContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := NewDecoder(strings.NewReader("a 1\nb 2\n"))
+
+	n, err := d.DecodeContext(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "a", n.Name)
+
+	cancel()
+	_, err = d.DecodeContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 2, pe.Line)
+	}
+
+	_, err = d.Decode()
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDecoderReset(t *testing.T) {
+	d := ParseOptions{MaxNodes: 2}.NewDecoder(strings.NewReader("a {\n\tb\n"))
+	_, err := d.Decode()
+	assert.ErrorIs(t, err, ErrUnclosedChildren)
+
+	d.Reset(strings.NewReader("c\nd 1"))
+	n, err := d.Decode()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "c", n.Name)
+
+	n, err = d.Decode()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "d", n.Name)
+
+	_, err = d.Decode()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// The options are kept, but the counters start over
+	d.Reset(strings.NewReader("e\nf\ng\n"))
+	_, err = d.Decode()
+	assert.NoError(t, err)
+	_, err = d.Decode()
+	assert.NoError(t, err)
+	_, err = d.Decode()
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 3, pe.Line)
+	}
+}
+
+const inputSnippet = "server \"localhost\" port=8080 {\n\ttls true\n}\n"
+
+func decodeAll(b *testing.B, d *Decoder) {
+	for {
+		_, err := d.Decode()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoderFresh(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		decodeAll(b, NewDecoder(strings.NewReader(inputSnippet)))
+	}
+}
+
+func BenchmarkDecoderReset(b *testing.B) {
+	b.ReportAllocs()
+	sr := strings.NewReader(inputSnippet)
+	d := NewDecoder(sr)
+	for i := 0; i < b.N; i++ {
+		sr.Reset(inputSnippet)
+		d.Reset(sr)
+		decodeAll(b, d)
+	}
+}
+
+func TestDecoderReadsBufferedReaderDirectly(t *testing.T) {
+	input := "first 1\nsecond 2\n---\nnot a KDL document"
+	br := bufio.NewReader(strings.NewReader(input))
+	d := NewDecoder(br)
+
+	for _, name := range []string{"first", "second"} {
+		node, err := d.Decode()
+		if assert.NoError(t, err) {
+			assert.EqualValues(t, name, node.Name)
+		}
+	}
+
+	// The caller can read whatever follows the nodes
+	rest, err := io.ReadAll(br)
+	assert.NoError(t, err)
+	assert.Equal(t, input[d.r.offset:], string(rest))
+	assert.Equal(t, "---\nnot a KDL document", string(rest))
+
+	// Resetting the decoder does not touch the reader it has been reading from
+	d.Reset(strings.NewReader("third"))
+	node, err := d.Decode()
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, "third", node.Name)
+	}
+}
+
+// eventLog lazily produces a log of events, with every hundredth an error with a nested error too.
+type eventLog struct {
+	written, count int
+	buf            bytes.Buffer
+}
+
+func (g *eventLog) Read(p []byte) (int, error) {
+	for g.buf.Len() < len(p) && g.written < g.count {
+		if g.written%100 == 0 {
+			fmt.Fprintf(&g.buf, "event %d type=\"error\" {\n\tcause {\n\t\tevent type=\"error\"\n\t}\n}\n", g.written)
+		} else {
+			fmt.Fprintf(&g.buf, "event %d type=\"info\" {\n\tmessage \"all good\"\n}\n", g.written)
+		}
+		g.written++
+	}
+	if g.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return g.buf.Read(p)
+}
+
+func TestDecoderSelect(t *testing.T) {
+	const count = 100_000
+	d := NewDecoder(&eventLog{count: count})
+	events, err := d.Select(`event[type="error"]`)
+	assert.NoError(t, err)
+
+	var halfway uint64
+	var topLevel, nested int
+	events(func(n Node, err error) bool {
+		if !assert.NoError(t, err) {
+			return false
+		}
+		if _, ok := n.FirstArgInt(); ok {
+			topLevel++
+		} else {
+			nested++
+		}
+		if topLevel == count/200 && nested == topLevel {
+			halfway = heapInUse()
+		}
+		return true
+	})
+
+	assert.Equal(t, count/100, topLevel)
+	assert.Equal(t, count/100, nested)
+	assert.Less(t, heapInUse(), halfway+(1<<20))
+}
+
+func TestDecoderSelectStops(t *testing.T) {
+	d := NewDecoder(strings.NewReader("a 1\nb { a 2; }\na 3\n"))
+	nodes, err := d.Select("a")
+	assert.NoError(t, err)
+	var args []int64
+	nodes(func(n Node, err error) bool {
+		assert.NoError(t, err)
+		i, _ := n.FirstArgInt()
+		args = append(args, i)
+		return len(args) < 2
+	})
+	assert.Equal(t, []int64{1, 2}, args)
+
+	// The rest is left to decode
+	n, err := d.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, Identifier("a"), n.Name)
+}
+
+func TestDecoderSelectErrors(t *testing.T) {
+	_, err := NewDecoder(strings.NewReader("a\n")).Select("a[")
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+
+	d := ParseOptions{AllErrors: true}.NewDecoder(strings.NewReader("a 1\nfoo bar\na 2\na {\n"))
+	nodes, err := d.Select("a")
+	assert.NoError(t, err)
+	var names []Identifier
+	var errs []error
+	nodes(func(n Node, err error) bool {
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			names = append(names, n.Name)
+		}
+		return true
+	})
+	assert.Equal(t, []Identifier{"a", "a"}, names)
+	if assert.Len(t, errs, 2) {
+		assert.ErrorIs(t, errs[0], errUnexpectedBareIdentifier)
+		assert.ErrorIs(t, errs[1], ErrUnclosedChildren)
+	}
+}
@@ -1,8 +1,31 @@
 package kdl
 
+import (
+	"sort"
+	"strconv"
+
+	"golang.org/x/exp/slices"
+)
+
 // Document is a top-level unit of the KDL format.
 type Document struct {
 	Nodes []Node
+
+	// Version is the version of the specification the document has been parsed with.
+	// It is zero for documents that have not been parsed.
+	Version Version
+
+	// SourceName is the path of the file the document has been read from, if any. See ParseFile.
+	SourceName string
+
+	// EndComments are the comments after the last top-level node, see Node.LeadingComments.
+	EndComments []string
+
+	// format is how the document has been written, if it has been read with ParseOptions.KeepFormat.
+	format *documentFormat
+
+	// parsed is the configuration the document has been parsed with, if it has positions. See Reparse.
+	parsed *parseConfig
 }
 
 // NewDocument creates a new Document.
@@ -20,3 +43,208 @@ type nodeParent interface {
 func (d *Document) AddChild(n Node) {
 	d.Nodes = append(d.Nodes, n)
 }
+
+// InsertChild inserts a top-level node at index i, shifting the nodes from i onwards.
+// It panics if i is out of the range from 0 to len(d.Nodes), both inclusive, like slice indexing does.
+func (d *Document) InsertChild(i int, n Node) {
+	d.Nodes = insertNode(d.Nodes, i, n)
+}
+
+// InsertChildBefore inserts a top-level node just before the first one with the name.
+// It returns false, without inserting the node, if there is no top-level node with the name.
+func (d *Document) InsertChildBefore(name Identifier, n Node) bool {
+	i := indexOfNode(d.Nodes, name)
+	if i < 0 {
+		return false
+	}
+	d.InsertChild(i, n)
+	return true
+}
+
+// InsertChildAfter inserts a top-level node just after the first one with the name.
+// It returns false, without inserting the node, if there is no top-level node with the name.
+func (d *Document) InsertChildAfter(name Identifier, n Node) bool {
+	i := indexOfNode(d.Nodes, name)
+	if i < 0 {
+		return false
+	}
+	d.InsertChild(i+1, n)
+	return true
+}
+
+// SortChildren orders the top-level nodes of this Document with the less function.
+// The sort is stable: the nodes that are equal keep their order.
+func (d *Document) SortChildren(less func(a, b *Node) bool) {
+	sortNodes(d.Nodes, less)
+}
+
+// SortChildrenByName orders the top-level nodes of this Document by their names, comparing them byte by byte.
+// The nodes with the same name keep their order.
+func (d *Document) SortChildrenByName() {
+	sortNodes(d.Nodes, lessByName)
+}
+
+// ReplaceChild replaces the top-level node at index i.
+// It panics if i is out of range, like slice indexing does.
+func (d *Document) ReplaceChild(i int, n Node) {
+	d.Nodes[i] = n
+}
+
+// Child returns the first top-level node with the name, if there is one.
+// The node can be modified in place.
+func (d *Document) Child(name Identifier) (*Node, bool) {
+	return findChild(d.Nodes, name)
+}
+
+// Children returns all the top-level nodes with the name, in the order of the document.
+// The nodes can be modified in place.
+func (d *Document) Children(name Identifier) NodeSet {
+	return findChildren(d.Nodes, name)
+}
+
+// RemoveChildren removes all the top-level nodes with the name, keeping the order of the rest.
+// It returns how many have been removed.
+func (d *Document) RemoveChildren(name Identifier) int {
+	var removed int
+	d.Nodes, removed = removeNodes(d.Nodes, name)
+	return removed
+}
+
+// RenameAll renames all the nodes of this Document with the name, at any depth, like Node.Rename does.
+// It returns how many nodes have been renamed, or ErrInvalidName without renaming any.
+func (d *Document) RenameAll(name, newName Identifier) (int, error) {
+	if err := validateName(newName); err != nil {
+		return 0, err
+	}
+	renamed := d.FindAllNamed(name)
+	for _, n := range renamed {
+		n.Name = newName
+	}
+	return len(renamed), nil
+}
+
+// Append appends copies of the top-level nodes of other to this Document,
+// so that changing either of the documents afterwards does not change the other one.
+//
+// If other has a SourceName, the appended nodes at any depth record it as their Source, unless they have one already,
+// so that the nodes of merged documents can still be told apart, e.g. in error messages.
+// The Version and the SourceName of this Document are left as they are.
+func (d *Document) Append(other *Document) {
+	appended := cloneNodes(other.Nodes)
+	if other.SourceName != "" {
+		setSource(appended, other.SourceName)
+	}
+	d.Nodes = append(d.Nodes, appended...)
+}
+
+// setSource sets the source of the nodes without one, at any depth.
+func setSource(nodes []Node, source string) {
+	for i := range nodes {
+		if nodes[i].source == "" {
+			nodes[i].source = source
+		}
+		setSource(nodes[i].Children, source)
+	}
+}
+
+// Concat creates a new Document with copies of the top-level nodes of the documents, in order, like Append does.
+// The Version of the new Document is the Version of the documents if they all have the same one, and zero otherwise.
+func Concat(docs ...*Document) *Document {
+	concat := NewDocument()
+	for i, doc := range docs {
+		if i == 0 {
+			concat.Version = doc.Version
+		} else if doc.Version != concat.Version {
+			concat.Version = 0
+		}
+		concat.Append(doc)
+	}
+	return &concat
+}
+
+// Clone returns a deep copy of this Document, which shares no mutable state with it. See Node.Clone.
+func (d *Document) Clone() Document {
+	clone := *d
+	clone.Nodes = cloneNodes(d.Nodes)
+	clone.EndComments = slices.Clone(d.EndComments)
+	return clone
+}
+
+// removeNodes filters out the nodes with the name in place, like removeNodesFunc does.
+func removeNodes(nodes []Node, name Identifier) ([]Node, int) {
+	return removeNodesFunc(nodes, func(n *Node) bool { return n.Name == name })
+}
+
+// removeNodesFunc filters out the nodes matching the predicate in place,
+// clearing the vacated end of the slice, so that the removed nodes can be collected.
+// The predicate is given each node at its original address.
+func removeNodesFunc(nodes []Node, remove func(*Node) bool) ([]Node, int) {
+	kept := 0
+	for i := range nodes {
+		if !remove(&nodes[i]) {
+			nodes[kept] = nodes[i]
+			kept++
+		}
+	}
+	for i := kept; i < len(nodes); i++ {
+		nodes[i] = Node{}
+	}
+	return nodes[:kept], len(nodes) - kept
+}
+
+func sortNodes(nodes []Node, less func(a, b *Node) bool) {
+	sort.SliceStable(nodes, func(i, j int) bool { return less(&nodes[i], &nodes[j]) })
+}
+
+func lessByName(a, b *Node) bool {
+	return a.Name < b.Name
+}
+
+// cloneNodes deep copies the nodes, keeping a nil slice nil.
+func cloneNodes(nodes []Node) []Node {
+	if nodes == nil {
+		return nil
+	}
+	clones := make([]Node, len(nodes))
+	for i := range nodes {
+		clones[i] = nodes[i].Clone()
+	}
+	return clones
+}
+
+// indexOfNode returns the index of the first node with the name, or -1 if there is none.
+func indexOfNode(nodes []Node, name Identifier) int {
+	for i := range nodes {
+		if nodes[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertNode inserts a node at index i, panicking if it is out of range.
+func insertNode(nodes []Node, i int, n Node) []Node {
+	if i < 0 || i > len(nodes) {
+		panic("kdl: index " + strconv.Itoa(i) + " out of range for " + strconv.Itoa(len(nodes)) + " nodes")
+	}
+	return slices.Insert(nodes, i, n)
+}
+
+// findChild returns the first node with the name, comparing the names byte by byte.
+func findChild(nodes []Node, name Identifier) (*Node, bool) {
+	if i := indexOfNode(nodes, name); i >= 0 {
+		return &nodes[i], true
+	}
+	return nil, false
+}
+
+// findChildren returns all the nodes with the name, comparing the names byte by byte.
+func findChildren(nodes []Node, name Identifier) NodeSet {
+	var found NodeSet
+	for i := range nodes {
+		if nodes[i].Name == name {
+			found = append(found, &nodes[i])
+		}
+	}
+	return found
+}
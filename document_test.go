@@ -0,0 +1,242 @@
+package kdl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentChild(t *testing.T) {
+	doc, err := ParseString("server 1\nclient\nserver 2 {\n\tclient\n}\n")
+	assert.NoError(t, err)
+
+	server, ok := doc.Child("server")
+	if assert.True(t, ok) {
+		assert.Equal(t, int64(1), server.Args[0].IntegerValue().Int64())
+
+		// The node belongs to the document
+		server.AddArgValue(NewStringValue("more", NoHint()))
+		assert.Len(t, doc.Nodes[0].Args, 2)
+	}
+
+	// Names are compared exactly
+	for _, name := range []Identifier{"missing", "Server", "server ", ""} {
+		node, ok := doc.Child(name)
+		assert.False(t, ok, name)
+		assert.Nil(t, node, name)
+	}
+
+	_, ok = (&Document{}).Child("server")
+	assert.False(t, ok)
+}
+
+func TestDocumentChildren(t *testing.T) {
+	doc, err := ParseString("server 1\nclient\nserver 2 {\n\tserver 3\n}\n\"\" 4\n")
+	assert.NoError(t, err)
+
+	servers := doc.Children("server")
+	if assert.Len(t, servers, 2) {
+		assert.Equal(t, int64(1), servers[0].Args[0].IntegerValue().Int64())
+		assert.Equal(t, int64(2), servers[1].Args[0].IntegerValue().Int64())
+
+		servers[1].Name = "renamed"
+		assert.EqualValues(t, "renamed", doc.Nodes[2].Name)
+	}
+
+	assert.Len(t, doc.Children("client"), 1)
+	assert.Empty(t, doc.Children("missing"))
+
+	// A node can be named with an empty string
+	empty := doc.Children("")
+	if assert.Len(t, empty, 1) {
+		assert.Equal(t, int64(4), empty[0].Args[0].IntegerValue().Int64())
+	}
+}
+
+func TestDocumentClone(t *testing.T) {
+	const input = "(t)base 1 2.5 \"s\" key=10 other=1.5 {\n\tchild 3 nested=4 {\n\t\tgrandchild 5\n\t}\n}\nleaf\n"
+	doc, err := ParseString(input)
+	assert.NoError(t, err)
+	doc.SourceName = "base.kdl"
+
+	clone := doc.Clone()
+	assert.Equal(t, doc.Version, clone.Version)
+	assert.Equal(t, "base.kdl", clone.SourceName)
+	written, err := clone.WriteString()
+	assert.NoError(t, err)
+	original, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, original, written)
+
+	// Mutate every level of the clone, including the numbers in place
+	base := &clone.Nodes[0]
+	base.Name = "changed"
+	base.TypeHint = Hint("u")
+	base.Args[0].IntegerValue().SetInt64(100)
+	base.Args[1].FloatValue().SetFloat64(100)
+	base.Args[2] = NewStringValue("changed", NoHint())
+	base.AddArg(4)
+	base.Props["key"].IntegerValue().SetInt64(100)
+	base.Props["other"].FloatValue().SetFloat64(100)
+	base.SetProp("added", 1)
+	child := &base.Children[0]
+	child.Args[0].IntegerValue().SetInt64(100)
+	child.Props["nested"].IntegerValue().SetInt64(100)
+	child.Children[0].Args[0].IntegerValue().SetInt64(100)
+	child.Children[0].AddChild(NewNode("added"))
+	child.AddChild(NewNode("added"))
+	base.AddChild(NewNode("added"))
+	clone.AddChild(NewNode("added"))
+	clone.Nodes[1].AddArg(1)
+
+	after, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, original, after)
+	assert.Len(t, doc.Nodes, 2)
+	assert.Nil(t, doc.Nodes[1].Args)
+	assert.Nil(t, doc.Nodes[1].Children)
+}
+
+func TestDocumentRemoveChildren(t *testing.T) {
+	doc, err := ParseString("debug\nserver {\n\tdebug\n}\ndebug 1\n")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, doc.RemoveChildren("missing"))
+	assert.Equal(t, 2, doc.RemoveChildren("debug"))
+	assert.Equal(t, []Identifier{"server"}, childNames(doc.Nodes))
+	// Only the top-level nodes
+	assert.Len(t, doc.Nodes[0].Children, 1)
+
+	assert.Equal(t, 1, doc.RemoveChildren("server"))
+	assert.Empty(t, doc.Nodes)
+}
+
+func TestDocumentInsertAndReplaceChildren(t *testing.T) {
+	doc, err := ParseString("b\nd {\n\tb\n}\n")
+	assert.NoError(t, err)
+
+	doc.InsertChild(0, NewNode("a"))
+	assert.True(t, doc.InsertChildBefore("d", NewNode("c")))
+	assert.True(t, doc.InsertChildAfter("d", NewNode("e")))
+	assert.True(t, doc.InsertChildAfter("e", NewNode("f")))
+	assert.False(t, doc.InsertChildBefore("missing", NewNode("x")))
+	assert.False(t, doc.InsertChildAfter("missing", NewNode("x")))
+	doc.ReplaceChild(1, NewNode("B"))
+	assert.Equal(t, []Identifier{"a", "B", "c", "d", "e", "f"}, childNames(doc.Nodes))
+
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nB\nc\nd {\n    b\n}\ne\nf\n", written)
+	reparsed, err := ParseString(written)
+	assert.NoError(t, err)
+	assert.True(t, doc.Equal(&reparsed))
+
+	assert.Panics(t, func() { doc.InsertChild(7, NewNode("x")) })
+	assert.Panics(t, func() { doc.ReplaceChild(6, NewNode("x")) })
+}
+
+func TestDocumentSortChildren(t *testing.T) {
+	doc, err := ParseString("b 1\na {\n\tz\n\ty\n}\nb 2\n")
+	assert.NoError(t, err)
+
+	doc.SortChildrenByName()
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	// Only the top-level nodes
+	assert.Equal(t, "a {\n    z\n    y\n}\nb 1\nb 2\n", written)
+
+	doc.SortChildren(func(a, b *Node) bool { return a.Name > b.Name })
+	assert.Equal(t, []Identifier{"b", "b", "a"}, childNames(doc.Nodes))
+	i, _ := doc.Nodes[0].FirstArgInt()
+	assert.Equal(t, int64(1), i)
+}
+
+func TestDocumentRenameAll(t *testing.T) {
+	doc, err := ParseString("item 1\ngroup {\n\titem 2\n\tnested {\n\t\titem 3\n\t}\n}\nitems\n")
+	assert.NoError(t, err)
+
+	renamed, err := doc.RenameAll("item", "entry point")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, renamed)
+	assert.Empty(t, doc.FindAllNamed("item"))
+	assert.Equal(t, []int64{1, 2, 3}, firstArgs(doc.FindAllNamed("entry point")))
+	assert.Equal(t, Identifier("items"), doc.Nodes[2].Name)
+
+	renamed, err = doc.RenameAll("missing", "x")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, renamed)
+
+	renamed, err = doc.RenameAll("entry point", "\x00")
+	assert.ErrorIs(t, err, ErrInvalidName)
+	assert.Equal(t, 0, renamed)
+	assert.Len(t, doc.FindAllNamed("entry point"), 3)
+}
+
+func TestDocumentAppend(t *testing.T) {
+	defaults, err := ParseString("port 80\nlog level=\"info\" {\n\tfile \"/var/log/app\"\n}\n")
+	assert.NoError(t, err)
+	defaults.SourceName = "defaults.kdl"
+	user, err := ParseString("port 8080\n")
+	assert.NoError(t, err)
+
+	doc := NewDocument()
+	doc.Append(&defaults)
+	doc.Append(&user)
+	assert.Equal(t, []Identifier{"port", "log", "port"}, childNames(doc.Nodes))
+	assert.Equal(t, "defaults.kdl", doc.Nodes[0].Source())
+	assert.Equal(t, "defaults.kdl", doc.Nodes[1].Source())
+	assert.Equal(t, "defaults.kdl", doc.Nodes[1].Children[0].Source())
+	assert.Equal(t, "", doc.Nodes[2].Source())
+	assert.Empty(t, doc.SourceName)
+
+	// The nodes keep the source they already have
+	merged := NewDocument()
+	merged.SourceName = "merged.kdl"
+	merged.Append(&doc)
+	assert.Equal(t, "defaults.kdl", merged.Nodes[0].Source())
+	assert.Equal(t, "", merged.Nodes[2].Source())
+	other := NewDocument()
+	other.Append(&merged)
+	assert.Equal(t, "merged.kdl", other.Nodes[2].Source())
+
+	// The documents do not share nodes
+	defaults.Nodes[0].Args[0] = NewStringValue("changed", NoHint())
+	defaults.Nodes[1].SetProp("level", "debug")
+	defaults.Nodes[1].Children[0].Name = "changed"
+	defaults.AddChild(NewNode("added"))
+	assert.Equal(t, "port 80\nlog level=\"info\" {\n    file \"/var/log/app\"\n}\nport 8080\n", doc.String())
+
+	doc.Nodes[2].Args[0] = NewStringValue("changed", NoHint())
+	port, _ := user.Nodes[0].FirstArgInt()
+	assert.Equal(t, int64(8080), port)
+
+	// The source is kept by copies
+	clone := doc.Clone()
+	assert.Equal(t, "defaults.kdl", clone.Nodes[0].Source())
+}
+
+func TestConcat(t *testing.T) {
+	a, err := ParseString("a\n", WithVersion(Version2))
+	assert.NoError(t, err)
+	a.SourceName = "a.kdl"
+	b, err := ParseString("b\nc\n", WithVersion(Version2))
+	assert.NoError(t, err)
+	b.SourceName = "b.kdl"
+
+	doc := Concat(&a, &b)
+	assert.Equal(t, []Identifier{"a", "b", "c"}, childNames(doc.Nodes))
+	assert.Equal(t, []string{"a.kdl", "b.kdl", "b.kdl"}, []string{doc.Nodes[0].Source(), doc.Nodes[1].Source(), doc.Nodes[2].Source()})
+	assert.Equal(t, Version2, doc.Version)
+	assert.Empty(t, doc.SourceName)
+
+	b.Nodes[0].Name = "changed"
+	assert.Equal(t, Identifier("b"), doc.Nodes[1].Name)
+
+	v1, err := ParseString("d\n", WithVersion(Version1))
+	assert.NoError(t, err)
+	assert.Equal(t, Version(0), Concat(&a, &v1, &b).Version)
+
+	empty := Concat()
+	assert.NotNil(t, empty.Nodes)
+	assert.Empty(t, empty.Nodes)
+}
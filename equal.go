@@ -0,0 +1,131 @@
+package kdl
+
+import "math/big"
+
+// EqualOptions tells how to compare Values, Nodes and Documents.
+// The zero value compares everything, like the Equal methods do.
+type EqualOptions struct {
+	// IgnoreTypeHints makes the comparison skip the type hints of nodes and values.
+	IgnoreTypeHints bool
+
+	// IgnoreNumberKinds makes integers equal to floats of the same value, e.g. 1 and 1.0.
+	IgnoreNumberKinds bool
+}
+
+// Equal reports whether the Values hold the same type, value and type hint.
+//
+// Numbers are compared by their value, not by the way they have been written, e.g. 0x10 is equal to 16.
+// An integer is never equal to a float. NaNs are equal to each other.
+func (v Value) Equal(other Value) bool {
+	return EqualOptions{}.Values(v, other)
+}
+
+// Equal reports whether the Nodes have the same name, type hint, arguments in order,
+// properties and children in order, compared recursively.
+func (n *Node) Equal(other *Node) bool {
+	return EqualOptions{}.Nodes(n, other)
+}
+
+// Equal reports whether the Documents have equal nodes, in order.
+// The Version and SourceName of the Documents are not compared.
+func (d *Document) Equal(other *Document) bool {
+	return EqualOptions{}.Documents(d, other)
+}
+
+// Values reports whether the Values are equal, like Value.Equal does, according to the options.
+func (o EqualOptions) Values(a, b Value) bool {
+	if !o.IgnoreTypeHints && !equalHints(a.TypeHint, b.TypeHint) {
+		return false
+	}
+	if a.Type != b.Type {
+		isNumber := func(v Value) bool { return v.Type == TypeInteger || v.Type == TypeFloat }
+		return o.IgnoreNumberKinds && isNumber(a) && isNumber(b) && equalFloats(bigFloat(a), bigFloat(b))
+	}
+	switch a.Type {
+	case TypeBool:
+		return a.BoolValue() == b.BoolValue()
+	case TypeString:
+		return a.StringValue() == b.StringValue()
+	case TypeInteger:
+		return a.IntegerValue().Cmp(b.IntegerValue()) == 0
+	case TypeFloat:
+		return equalFloats(a.FloatValue(), b.FloatValue())
+	}
+	return true
+}
+
+// Nodes reports whether the Nodes are equal, like Node.Equal does, according to the options.
+func (o EqualOptions) Nodes(a, b *Node) bool {
+	if a.Name != b.Name || len(a.Args) != len(b.Args) || len(a.Children) != len(b.Children) {
+		return false
+	}
+	if !o.IgnoreTypeHints && !equalHints(a.TypeHint, b.TypeHint) {
+		return false
+	}
+	for i := range a.Args {
+		if !o.Values(a.Args[i], b.Args[i]) {
+			return false
+		}
+	}
+	if !o.props(a, b) {
+		return false
+	}
+	return o.nodes(a.Children, b.Children)
+}
+
+// Documents reports whether the Documents are equal, like Document.Equal does, according to the options.
+func (o EqualOptions) Documents(a, b *Document) bool {
+	return len(a.Nodes) == len(b.Nodes) && o.nodes(a.Nodes, b.Nodes)
+}
+
+func (o EqualOptions) nodes(a, b []Node) bool {
+	for i := range a {
+		if !o.Nodes(&a[i], &b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// props compares the properties of the Nodes, skipping the ones in an invalid state like HasProp does.
+func (o EqualOptions) props(a, b *Node) bool {
+	count := 0
+	for key, value := range a.Props {
+		if value.Type == TypeInvalid {
+			continue
+		}
+		other, ok := b.PropValue(key)
+		if !ok || !o.Values(value, other) {
+			return false
+		}
+		count++
+	}
+	for _, value := range b.Props {
+		if value.Type != TypeInvalid {
+			count--
+		}
+	}
+	return count == 0
+}
+
+func equalHints(a, b TypeHint) bool {
+	hintA, okA := a.Get()
+	hintB, okB := b.Get()
+	return okA == okB && hintA == hintB
+}
+
+// bigFloat returns a number as a big.Float, without losing the precision of integers. NaN is nil.
+func bigFloat(v Value) *big.Float {
+	if v.Type == TypeInteger {
+		return new(big.Float).SetInt(v.IntegerValue())
+	}
+	return v.FloatValue()
+}
+
+// equalFloats compares numbers by their value, with NaNs (nil) equal to each other.
+func equalFloats(a, b *big.Float) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Cmp(b) == 0
+}
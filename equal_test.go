@@ -0,0 +1,145 @@
+package kdl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parseValue parses a single argument written in KDL 2.0.
+func parseValue(t *testing.T, text string) Value {
+	doc, err := ParseString("node "+text+"\n", WithVersion(Version2))
+	if !assert.NoError(t, err, text) {
+		return newInvalidValue()
+	}
+	return doc.Nodes[0].Args[0]
+}
+
+func TestValueEqual(t *testing.T) {
+	cases := []struct {
+		a, b        string
+		equal       bool // With the default options
+		ignoreHints bool // With IgnoreTypeHints
+		ignoreKinds bool // With IgnoreNumberKinds
+	}{
+		{`#null`, `#null`, true, true, true},
+		{`#null`, `#false`, false, false, false},
+		{`#null`, `""`, false, false, false},
+		{`#null`, `0`, false, false, false},
+		{`#true`, `#true`, true, true, true},
+		{`#true`, `#false`, false, false, false},
+		{`#true`, `"#true"`, false, false, false},
+		{`"s"`, `#"s"#`, true, true, true},
+		{`"s"`, `"S"`, false, false, false},
+		{`""`, `0`, false, false, false},
+		{`1`, `1`, true, true, true},
+		{`16`, `0x10`, true, true, true},
+		{`1_000`, `1000`, true, true, true},
+		{`1`, `2`, false, false, false},
+		{`1`, `1.0`, false, false, true},
+		{`1`, `1.5`, false, false, false},
+		{`12345678901234567890123`, `12345678901234567890123`, true, true, true},
+		{`12345678901234567890123`, `12345678901234567890124`, false, false, false},
+		{`1180591620717411303424`, `1180591620717411303424.0`, false, false, true},
+		{`1.5`, `15e-1`, true, true, true},
+		{`1.5`, `1.25`, false, false, false},
+		{`#nan`, `#nan`, true, true, true},
+		{`#nan`, `1.0`, false, false, false},
+		{`#nan`, `1`, false, false, false},
+		{`#inf`, `#inf`, true, true, true},
+		{`#inf`, `#-inf`, false, false, false},
+		{`(u8)1`, `(u8)1`, true, true, true},
+		{`(u8)1`, `(i8)1`, false, true, false},
+		{`(u8)1`, `1`, false, true, false},
+		{`("")1`, `1`, false, true, false},
+		{`(u8)1`, `(i8)2`, false, false, false},
+		{`(f32)1.0`, `1.0`, false, true, false},
+	}
+	for _, c := range cases {
+		a, b := parseValue(t, c.a), parseValue(t, c.b)
+		for _, pair := range [][2]Value{{a, b}, {b, a}} {
+			msg := c.a + " and " + c.b
+			assert.Equal(t, c.equal, pair[0].Equal(pair[1]), msg)
+			assert.Equal(t, c.ignoreHints, EqualOptions{IgnoreTypeHints: true}.Values(pair[0], pair[1]), msg)
+			assert.Equal(t, c.ignoreKinds, EqualOptions{IgnoreNumberKinds: true}.Values(pair[0], pair[1]), msg)
+		}
+	}
+
+	assert.True(t, newInvalidValue().Equal(newInvalidValue()))
+	assert.False(t, newInvalidValue().Equal(NewNullValue(NoHint())))
+}
+
+func TestNodeEqual(t *testing.T) {
+	cases := []struct {
+		a, b        string
+		equal       bool // With the default options
+		ignoreHints bool // With IgnoreTypeHints
+	}{
+		{`node`, `node`, true, true},
+		{`node`, `"node"`, true, true},
+		{`node`, `other`, false, false},
+		{`(t)node`, `node`, false, true},
+		{`node 1 2`, `node 1 2`, true, true},
+		{`node 1 2`, `node 2 1`, false, false},
+		{`node 1`, `node 1 2`, false, false},
+		{`node (u8)1`, `node 1`, false, true},
+		{`node a=1 b=2`, `node b=2 a=1`, true, true},
+		{`node a=1 b=2`, `node a=1 b=3`, false, false},
+		{`node a=1`, `node a=1 b=2`, false, false},
+		{`node a=1`, `node b=1`, false, false},
+		{`node a=1 a=2`, `node a=2`, true, true},
+		{`node a=(u8)1`, `node a=1`, false, true},
+		{`node a=#null`, `node`, false, false},
+		{`node 1`, `node a=1`, false, false},
+		{`node { a; b; }`, `node { a; b; }`, true, true},
+		{`node { a; b; }`, `node { b; a; }`, false, false},
+		{`node { a; }`, `node { a; b; }`, false, false},
+		{`node {}`, `node`, true, true},
+		{`node { a { b 1; }; }`, `node { a { b 2; }; }`, false, false},
+		{`node { a { (t)b; }; }`, `node { a { b; }; }`, false, true},
+		{`node 1 /- 2 { a; }`, `node 1 { a; }`, true, true},
+	}
+	for _, c := range cases {
+		docA, err := ParseString(c.a+"\n", WithVersion(Version2))
+		assert.NoError(t, err, c.a)
+		docB, err := ParseString(c.b+"\n", WithVersion(Version2))
+		assert.NoError(t, err, c.b)
+		a, b := &docA.Nodes[0], &docB.Nodes[0]
+		for _, pair := range [][2]*Node{{a, b}, {b, a}} {
+			msg := c.a + " and " + c.b
+			assert.Equal(t, c.equal, pair[0].Equal(pair[1]), msg)
+			assert.Equal(t, c.ignoreHints, EqualOptions{IgnoreTypeHints: true}.Nodes(pair[0], pair[1]), msg)
+		}
+	}
+
+	// Properties in an invalid state are not there, like for HasProp
+	a, b := NewNode("node"), NewNode("node")
+	a.Props = map[Identifier]Value{"gone": newInvalidValue()}
+	assert.True(t, a.Equal(&b))
+	assert.True(t, b.Equal(&a))
+}
+
+func TestDocumentEqual(t *testing.T) {
+	a, err := ParseString("a 1\nb {\n\tc\n}\n")
+	assert.NoError(t, err)
+	b, err := ParseString("/- kdl-version 2\na 0x1 // comment\nb { c; }\n", WithVersion(Version2))
+	assert.NoError(t, err)
+	b.SourceName = "b.kdl"
+
+	// Neither the version, nor the source are compared
+	assert.True(t, a.Equal(&b))
+	assert.True(t, b.Equal(&a))
+
+	clone := a.Clone()
+	clone.AddChild(NewNode("d"))
+	assert.False(t, a.Equal(&clone))
+	assert.False(t, clone.Equal(&a))
+
+	clone = a.Clone()
+	clone.Nodes[1].Children[0].Name = "d"
+	assert.False(t, a.Equal(&clone))
+
+	empty, other := NewDocument(), Document{}
+	assert.True(t, empty.Equal(&other))
+	assert.False(t, empty.Equal(&a))
+}
@@ -0,0 +1,136 @@
+package kdl
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrorCode identifies a specific kind of failure to parse a document.
+// Unlike error messages, the codes are stable and can be used by tooling.
+type ErrorCode int
+
+const (
+	CodeUnknown ErrorCode = iota // The error does not come from the parser itself, e.g. it is an I/O error.
+
+	CodeUnexpectedEOF                    // The document ended abruptly.
+	CodeInvalidEncoding                  // The document is not valid UTF-8.
+	CodeUnexpectedSemicolon              // A ';' does not terminate any node.
+	CodeUnexpectedRightBrace             // A '}' does not close any children block.
+	CodeUnexpectedLineContinuation       // A '\' appears outside of a node.
+	CodeSignificantAfterLineContinuation // Something other than a comment follows a '\' on the same line.
+	CodeUnexpectedSlashdash              // A '/-' does not precede anything it could comment out.
+	CodeBareIdentifier                   // A bare identifier is used as a value.
+	CodeInvalidIdentifier                // An identifier contains illegal characters.
+	CodeUnexpectedTokenAfterIdentifier   // An identifier is not followed by a '=' nor a terminator.
+	CodeUnexpectedTokenAfterValue        // A value is not followed by a terminator.
+	CodeExpectedValue                    // Something that is not a value appears where a value is expected.
+	CodeUnclosedTypeHint                 // A type hint is not closed with a ')'.
+	CodeUnterminatedString               // A string is not closed before the end of the document.
+	CodeBadEscape                        // A string contains an invalid escape sequence.
+	CodeBadNumber                        // A number literal is malformed.
+	CodeUnterminatedComment              // A multiline comment is not closed before the end of the document.
+	CodeUnclosedChildren                 // A children block is not closed before the end of the document.
+	CodeWhitespaceAroundEquals           // A property has whitespace around its '=', which only KDL 2.0 allows.
+	CodeDepthExceeded                    // Children blocks are nested deeper than allowed.
+	CodeLimitExceeded                    // The document has more nodes or bytes than allowed.
+	CodeInternal                         // The parser has panicked, see InternalError.
+	CodeVersionSyntax                    // The syntax belongs to another version of the specification, e.g. #true in KDL 1.0.
+	CodeBadIndentation                   // A line of a multi-line string is not indented like its closing quotes.
+	CodeDisallowedChar                   // A character not allowed in documents appears outside of an escape sequence.
+	CodeWhitespaceInTypeHint             // A type hint has whitespace inside of its parentheses or after them, in KDL 1.0.
+	CodeDuplicateProperty                // A node has the same property twice, see ParseOptions.DuplicateProps.
+	CodeHintOnPropertyKey                // The key of a property has a type hint, e.g. (hint)key=1.
+	CodeEntryAfterChildren               // An argument or a property follows the children block, in KDL 2.0.
+)
+
+var errorCodeNames = [...]string{
+	CodeUnknown:                          "Unknown",
+	CodeUnexpectedEOF:                    "UnexpectedEOF",
+	CodeInvalidEncoding:                  "InvalidEncoding",
+	CodeUnexpectedSemicolon:              "UnexpectedSemicolon",
+	CodeUnexpectedRightBrace:             "UnexpectedRightBrace",
+	CodeUnexpectedLineContinuation:       "UnexpectedLineContinuation",
+	CodeSignificantAfterLineContinuation: "SignificantAfterLineContinuation",
+	CodeUnexpectedSlashdash:              "UnexpectedSlashdash",
+	CodeBareIdentifier:                   "BareIdentifier",
+	CodeInvalidIdentifier:                "InvalidIdentifier",
+	CodeUnexpectedTokenAfterIdentifier:   "UnexpectedTokenAfterIdentifier",
+	CodeUnexpectedTokenAfterValue:        "UnexpectedTokenAfterValue",
+	CodeExpectedValue:                    "ExpectedValue",
+	CodeUnclosedTypeHint:                 "UnclosedTypeHint",
+	CodeUnterminatedString:               "UnterminatedString",
+	CodeBadEscape:                        "BadEscape",
+	CodeBadNumber:                        "BadNumber",
+	CodeUnterminatedComment:              "UnterminatedComment",
+	CodeUnclosedChildren:                 "UnclosedChildren",
+	CodeWhitespaceAroundEquals:           "WhitespaceAroundEquals",
+	CodeDepthExceeded:                    "DepthExceeded",
+	CodeLimitExceeded:                    "LimitExceeded",
+	CodeInternal:                         "Internal",
+	CodeVersionSyntax:                    "VersionSyntax",
+	CodeBadIndentation:                   "BadIndentation",
+	CodeDisallowedChar:                   "DisallowedChar",
+	CodeWhitespaceInTypeHint:             "WhitespaceInTypeHint",
+	CodeDuplicateProperty:                "DuplicateProperty",
+	CodeHintOnPropertyKey:                "HintOnPropertyKey",
+	CodeEntryAfterChildren:               "EntryAfterChildren",
+}
+
+// String returns the name of the code, e.g. "UnexpectedSemicolon".
+func (c ErrorCode) String() string {
+	if c >= 0 && int(c) < len(errorCodeNames) {
+		return errorCodeNames[c]
+	}
+	return "ErrorCode(" + strconv.Itoa(int(c)) + ")"
+}
+
+// codedError attaches an ErrorCode to an error.
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+// withCode attaches an ErrorCode to an error.
+func withCode(code ErrorCode, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// hasErrorCode checks if an error, or any of the errors joined in it, has the ErrorCode.
+func hasErrorCode(err error, code ErrorCode) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if hasErrorCode(e, code) {
+				return true
+			}
+		}
+		return false
+	}
+	return errorCodeOf(err) == code
+}
+
+// errorCodeOf determines the ErrorCode of an error returned by the parser.
+func errorCodeOf(err error) ErrorCode {
+
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+
+	if errors.Is(err, ErrInvalidEncoding) {
+		return CodeInvalidEncoding
+	}
+
+	if errors.Is(err, ErrUnexpectedEOF) {
+		return CodeUnexpectedEOF
+	}
+
+	return CodeUnknown
+}
@@ -0,0 +1,76 @@
+package kdl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var documentsByErrorCode = map[ErrorCode]string{
+	CodeUnexpectedEOF:                    "(foo",
+	CodeInvalidEncoding:                  "fo\xffo",
+	CodeUnexpectedSemicolon:              "foo\n;",
+	CodeUnexpectedRightBrace:             "foo\n}",
+	CodeUnexpectedLineContinuation:       "\\\nfoo",
+	CodeSignificantAfterLineContinuation: "foo \\ bar",
+	CodeUnexpectedSlashdash:              "foo /-",
+	CodeBareIdentifier:                   "foo bar",
+	CodeInvalidIdentifier:                "fo=o",
+	CodeUnexpectedTokenAfterIdentifier:   `foo "bar"baz`,
+	CodeUnexpectedTokenAfterValue:        `foo null"bar"`,
+	CodeExpectedValue:                    "foo bar=;",
+	CodeUnclosedTypeHint:                 "(\"foo\"bar)baz",
+	CodeUnterminatedString:               `foo "bar`,
+	CodeBadEscape:                        `foo "\q"`,
+	CodeBadNumber:                        "foo 1.2.3",
+	CodeUnterminatedComment:              "foo /* bar",
+	CodeUnclosedChildren:                 "foo {\n  bar",
+	CodeWhitespaceAroundEquals:           "foo bar = 1",
+	CodeDepthExceeded:                    strings.Repeat("foo {", DefaultMaxDepth+1),
+	CodeLimitExceeded:                    "foo; bar",
+	CodeVersionSyntax:                    "foo #true",
+	CodeBadIndentation:                   "foo \"\"\"\n  bar\n baz\n  \"\"\"",
+	CodeDisallowedChar:                   "foo \"\u202e\"",
+	CodeWhitespaceInTypeHint:             "foo ( u8)1",
+	CodeDuplicateProperty:                "foo bar=1 bar=2",
+	CodeHintOnPropertyKey:                "foo (hint)bar=1",
+	CodeEntryAfterChildren:               "foo {} bar=1",
+}
+
+// optionsByErrorCode lists the options needed for a test document to fail, if any.
+var optionsByErrorCode = map[ErrorCode]ParseOptions{
+	CodeLimitExceeded:      {MaxNodes: 1},
+	CodeBadIndentation:     {Version: Version2},
+	CodeDuplicateProperty:  {DuplicateProps: DuplicatePropsError},
+	CodeEntryAfterChildren: {Version: Version2},
+}
+
+func TestEveryErrorCodeIsProduced(t *testing.T) {
+	for code := CodeUnknown + 1; int(code) < len(errorCodeNames); code++ {
+		if code == CodeInternal {
+			// Not caused by any document, see TestReaderPanicBecomesError
+			continue
+		}
+
+		input, ok := documentsByErrorCode[code]
+		if !assert.True(t, ok, "no test document for code %v", code) {
+			continue
+		}
+
+		_, err := optionsByErrorCode[code].ParseString(input)
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe, "document for code %v", code) {
+			assert.Equal(t, code, pe.Code, "document for code %v produced %v", code, err)
+		}
+	}
+}
+
+func TestErrorCodeNames(t *testing.T) {
+	assert.Equal(t, "UnexpectedSemicolon", CodeUnexpectedSemicolon.String())
+	assert.Equal(t, "Unknown", CodeUnknown.String())
+	assert.Equal(t, "ErrorCode(-1)", ErrorCode(-1).String())
+	for i, name := range errorCodeNames {
+		assert.NotEmpty(t, name, "code %d has no name", i)
+	}
+}
@@ -2,9 +2,12 @@ package kdl
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 var (
@@ -19,18 +22,165 @@ var (
 	ErrUnexpectedEOF = io.ErrUnexpectedEOF
 	// ErrInvalidValueType happens when a raw value cannot be cast to a kdl.Value.
 	ErrInvalidValueType = errors.New("cannot transform to a valid kdl.Value type")
+	// ErrIntegerOverflow happens when an integer Value does not fit in the requested Go type.
+	ErrIntegerOverflow = errors.New("integer overflows the requested type")
+	// ErrFloatOverflow happens when a Number is too large to be a float64.
+	ErrFloatOverflow = errors.New("number overflows float64")
+	// ErrNotInteger happens when a Number with a fractional part is converted to an integer.
+	ErrNotInteger = errors.New("number is not an integer")
+	// ErrMissingValue happens when Prop or Arg asks for a property or an argument that a node does not have.
+	ErrMissingValue = errors.New("value is missing")
+	// ErrWrongType happens when a Value holds a type that cannot be converted to the requested Go type,
+	// e.g. a string is asked for as an int.
+	ErrWrongType = errors.New("value has the wrong type")
+	// ErrChildrenModified happens when the callback of Walk changes the children of a node
+	// while they are being walked.
+	ErrChildrenModified = errors.New("children of a node have been modified during the walk")
+	// ErrArgIndex happens when an argument is set or inserted at an index out of range.
+	ErrArgIndex = errors.New("argument index out of range")
+	// ErrInvalidName happens when a node is renamed to a name that cannot be written to a document.
+	ErrInvalidName = errors.New("invalid node name")
+	// ErrNoPositions happens when Document.Reparse or SpliceValues is given a document parsed
+	// without ParseOptions.KeepPositions.
+	ErrNoPositions = errors.New("document has been parsed without positions")
+	// ErrDocumentModified happens when Document.SpliceValues is given a document whose nodes, arguments
+	// or properties have been added, removed or moved since it has been parsed.
+	ErrDocumentModified = errors.New("document has been modified since it has been parsed")
+	// ErrNotInVersion happens when a document is written with a value that its version of the specification
+	// cannot express, e.g. an infinite float in KDL 1.0.
+	ErrNotInVersion = errors.New("value cannot be written in the version of the document")
+	// ErrInvalidQuery is a base error for when a query passed to Document.Query is malformed.
+	// The error is a QueryError, which tells where in the query the problem is.
+	ErrInvalidQuery = errors.New("invalid query")
+	// ErrUnterminatedComment happens when a multiline comment is not closed
+	// before the end of the document. It is reported at the position of the outermost "/*".
+	ErrUnterminatedComment = withCode(CodeUnterminatedComment, unexpectedEOFError("unterminated comment started"))
+	// ErrUnclosedChildren happens when the document ends inside of a children block.
+	// It is reported at the position of the innermost unclosed "{".
+	ErrUnclosedChildren = withCode(CodeUnclosedChildren, unexpectedEOFError("unclosed children block opened: expected '}' before the end of the document"))
+	// ErrDepthExceeded happens when children blocks are nested deeper than ParseOptions.MaxDepth allows.
+	// It is reported at the position of the first "{" over the limit.
+	ErrDepthExceeded = withCode(CodeDepthExceeded, errors.New("children blocks nested too deeply"))
+	// ErrLimitExceeded happens when a document goes over ParseOptions.MaxNodes, ParseOptions.MaxInputBytes
+	// or ParseOptions.MaxStringLen.
+	// The error is a LimitExceededError, which tells which limit has been hit.
+	ErrLimitExceeded = withCode(CodeLimitExceeded, errors.New("limit exceeded"))
+	// ErrDuplicateProperty happens when a node has the same property twice
+	// and ParseOptions.DuplicateProps is DuplicatePropsError.
+	// The error is a DuplicatePropertyError, reported at the position of the second key.
+	ErrDuplicateProperty = withCode(CodeDuplicateProperty, fmt.Errorf("%w: duplicate property", ErrInvalidSyntax))
 )
 
-// ErrWithPosition wraps an error,
+// InternalError describes a panic inside of the parser, caused by a bug or a misbehaving io.Reader.
+// It matches ErrInvalidSyntax, so that it is handled like any other malformed document.
+//
+// When reporting the bug, please include the Stack.
+type InternalError struct {
+	Value any    // The value the parser has panicked with.
+	Stack []byte // The stack trace of the panic.
+}
+
+func (e *InternalError) Error() string {
+	return fmt.Sprintf("internal parser error: %v (this is a bug, please report it)", e.Value)
+}
+
+func (e *InternalError) Unwrap() error {
+	return errInternal
+}
+
+var errInternal = withCode(CodeInternal, ErrInvalidSyntax)
+
+// unexpectedEOFError matches ErrUnexpectedEOF, but provides a more specific message.
+type unexpectedEOFError string
+
+func (e unexpectedEOFError) Error() string {
+	return string(e)
+}
+
+func (e unexpectedEOFError) Is(target error) bool {
+	return target == ErrUnexpectedEOF
+}
+
+// depthExceededError describes a children block nested over the limit.
+type depthExceededError struct {
+	limit int
+}
+
+func (e *depthExceededError) Error() string {
+	return ErrDepthExceeded.Error() + ": the limit is " + strconv.Itoa(e.limit)
+}
+
+func (e *depthExceededError) Unwrap() error {
+	return ErrDepthExceeded
+}
+
+// LimitExceededError describes a document going over one of the limits set in ParseOptions.
+type LimitExceededError struct {
+	Limit string // Name of the option, e.g. "MaxNodes".
+	Max   int    // Value of the option.
+}
+
+func (e *LimitExceededError) Error() string {
+	return ErrLimitExceeded.Error() + ": " + e.Limit + " is " + strconv.Itoa(e.Max)
+}
+
+func (e *LimitExceededError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// DuplicatePropertyError describes a property set twice on the same node.
+type DuplicatePropertyError struct {
+	Key Identifier // Name of the property.
+
+	// Position of the key where the property has been set first.
+	FirstOffset int
+	FirstLine   int
+	FirstColumn int
+}
+
+func (e *DuplicatePropertyError) Error() string {
+	return ErrDuplicateProperty.Error() + " " + strconv.Quote(string(e.Key)) +
+		", first set at line " + strconv.Itoa(e.FirstLine) + ", column " + strconv.Itoa(e.FirstColumn)
+}
+
+func (e *DuplicatePropertyError) Unwrap() error {
+	return ErrDuplicateProperty
+}
+
+// ParseError describes a failure to parse a document,
 // adding information where in the document did it occur.
-type ErrWithPosition struct {
-	Err    error // The original error.
-	Line   int   // Line where the error occurred, 1-indexed.
-	Column int   // Column where the error occurred, 0-indexed.
+//
+// Use errors.Is to check for the underlying cause, e.g. ErrInvalidSyntax.
+type ParseError struct {
+	Err    error      // The original error.
+	Code   ErrorCode  // Identifies the kind of the error.
+	Offset int        // Byte offset where the error occurred, 0-indexed.
+	Line   int        // Line where the error occurred, 1-indexed.
+	Column int        // Column (in runes) where the error occurred, 1-indexed.
+	Node   Identifier // Name of the node being parsed, if known.
+
+	// Path lists the names of the nodes enclosing the error, outermost first.
+	// The last element is always Node. Deeply nested paths are truncated from the front.
+	Path []Identifier
+	// pathTruncated is true if some of the outermost nodes have been dropped from Path.
+	pathTruncated bool
+
+	// SourceLine is the text of the offending line.
+	// It is only captured if ParseOptions.ErrorSourceContext is enabled.
+	SourceLine string
+
+	// File is the path of the file the document has been read from, if known. See ParseFile.
+	// If set, the error reads like "config.kdl:37:12: unexpected ';'", as is common for compilers.
+	File string
 }
 
+// ErrWithPosition is the former name of ParseError.
+//
+// Deprecated: use ParseError instead.
+type ErrWithPosition = ParseError
+
 // Error formats an error message.
-func (e *ErrWithPosition) Error() string {
+func (e *ParseError) Error() string {
 
 	innerMsg := "null"
 	err := e.Err
@@ -38,23 +188,275 @@ func (e *ErrWithPosition) Error() string {
 		innerMsg = err.Error()
 	}
 
+	// "invalid syntax: foo" becomes "kdl: invalid syntax at line 1, column 2: foo"
+	head, tail, found := strings.Cut(innerMsg, ": ")
+
 	var s strings.Builder
-	s.Grow(len(innerMsg) + 24)
-	s.WriteString(innerMsg)
-	s.WriteString(" [line ")
+	s.Grow(len(innerMsg) + len(e.File) + 32)
+
+	// or "config.kdl:1:2: foo", if the file is known
+	if e.File != "" {
+		s.WriteString(e.File)
+		s.WriteByte(':')
+		s.WriteString(strconv.Itoa(e.Line))
+		s.WriteByte(':')
+		s.WriteString(strconv.Itoa(e.Column))
+		s.WriteString(": ")
+		writeErrorPath(&s, e)
+		if found && head == ErrInvalidSyntax.Error() {
+			s.WriteString(tail)
+		} else {
+			s.WriteString(innerMsg)
+		}
+		writeSourceContext(&s, e.SourceLine, e.Column)
+		return s.String()
+	}
+
+	s.WriteString("kdl: ")
+	s.WriteString(head)
+	s.WriteString(" at line ")
 	s.WriteString(strconv.Itoa(e.Line))
 	s.WriteString(", column ")
 	s.WriteString(strconv.Itoa(e.Column))
-	s.WriteString("]")
+	if found {
+		s.WriteString(": ")
+		writeErrorPath(&s, e)
+		s.WriteString(tail)
+	} else if len(e.Path) > 0 {
+		s.WriteString(": ")
+		writeErrorPath(&s, e)
+		s.WriteString(innerMsg)
+	}
+	writeSourceContext(&s, e.SourceLine, e.Column)
 	return s.String()
 }
 
+// writeErrorPath renders the breadcrumb of nodes enclosing the error, e.g. `in node "foo" > "bar": `.
+func writeErrorPath(s *strings.Builder, e *ParseError) {
+
+	if len(e.Path) == 0 {
+		return
+	}
+
+	s.WriteString("in node ")
+	if e.pathTruncated {
+		s.WriteString("... > ")
+	}
+	for i, name := range e.Path {
+		if i > 0 {
+			s.WriteString(" > ")
+		}
+		s.WriteString(strconv.Quote(string(name)))
+	}
+	s.WriteString(": ")
+}
+
+// writeSourceContext renders the offending line with a caret under the provided column.
+func writeSourceContext(s *strings.Builder, line string, column int) {
+
+	if len(line) == 0 || column < 1 {
+		return
+	}
+
+	s.WriteString("\n    ")
+	s.WriteString(line)
+	s.WriteString("\n    ")
+
+	// Mirror the tabs of the line, so that the caret lines up regardless of tab width
+	i := 1
+	for _, ch := range line {
+		if i >= column {
+			break
+		}
+		switch {
+		case ch == '\t':
+			s.WriteByte('\t')
+		case isWideRune(ch):
+			s.WriteString("  ")
+		default:
+			s.WriteByte(' ')
+		}
+		i++
+	}
+	s.WriteByte('^')
+}
+
 // Unwrap returns the original error.
-func (e *ErrWithPosition) Unwrap() error {
+func (e *ParseError) Unwrap() error {
 	return e.Err
 }
 
-// addErrPosInfo wraps an error, adding position information from context.
+// addErrPosInfo wraps an error, adding position information from context,
+// unless it already has been positioned more precisely.
 func addErrPosInfo(err error, r *reader) error {
-	return &ErrWithPosition{Err: err, Line: r.line, Column: r.pos}
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &ParseError{Err: err, Code: errorCodeOf(err), Offset: r.offset, Line: r.line, Column: r.column + 1}
+}
+
+// setErrorFile records the path of the file a document has been read from in its parse errors,
+// including all of the errors joined together by error recovery.
+func setErrorFile(err error, path string) {
+	switch e := err.(type) {
+	case *ParseError:
+		e.File = path
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			setErrorFile(err, path)
+		}
+	}
+}
+
+// finishError adds all the information about the context to an error returned from the parser.
+func finishError(err error, r *reader) error {
+	err = addErrPosInfo(err, r)
+	if r.cfg.ErrorSourceContext {
+		addErrSourceLine(err, r)
+	}
+	return err
+}
+
+// isRecoverable checks if parsing can resume after an error, skipping the broken node.
+//
+// An error caused by an unexpected end of data is not recoverable,
+// as it is not possible to tell where the offending construct should have ended.
+func isRecoverable(err error) bool {
+	return errors.Is(err, ErrInvalidSyntax) && !errors.Is(err, ErrUnexpectedEOF)
+}
+
+// addErrSourceLine attaches the text of the offending line to a ParseError, if it is still known.
+func addErrSourceLine(err error, r *reader) {
+
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		return
+	}
+
+	var line []byte
+	switch pe.Line {
+	case r.line:
+		line = r.currentLineText()
+	case r.line - 1:
+		line = r.prevLine
+	default:
+		return
+	}
+
+	// The caret cannot point past the retained text
+	if utf8.RuneCount(line) < pe.Column-1 {
+		return
+	}
+
+	pe.SourceLine = string(line)
+}
+
+// errorAt wraps an error, adding the provided position information.
+//
+// io.EOF is returned as-is, since the callers compare against it directly.
+// Errors that already carry a position are not wrapped again.
+func errorAt(err error, p position) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &ParseError{Err: err, Code: errorCodeOf(err), Offset: p.offset, Line: p.line, Column: p.column}
+}
+
+// maxErrorPathLength limits how many enclosing nodes are recorded in a ParseError.
+const maxErrorPathLength = 8
+
+// errorInNode records the name of a node enclosing the error in a ParseError.
+// It is expected to be called while the error propagates up, innermost node first.
+func errorInNode(err error, name Identifier) error {
+
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		return err
+	}
+
+	if len(pe.Path) == 0 {
+		pe.Node = name
+	}
+
+	if len(pe.Path) >= maxErrorPathLength {
+		pe.pathTruncated = true
+		return err
+	}
+
+	pe.Path = append(pe.Path, "")
+	copy(pe.Path[1:], pe.Path)
+	pe.Path[0] = name
+	return err
+}
+
+// recoverPanic converts a panic of the parser into an InternalError, positioned where the reader has stopped.
+// It must be deferred directly. Panics of a user-provided io.Reader are passed on.
+func (r *reader) recoverPanic(err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if p, ok := v.(callbackPanic); ok {
+		panic(p.value)
+	}
+	*err = errorAt(&InternalError{Value: v, Stack: debug.Stack()}, r.pos())
+}
+
+// callbackReader marks the panics of a user-provided io.Reader,
+// so that they are not mistaken for the ones of the parser.
+type callbackReader struct {
+	r io.Reader
+}
+
+// callbackPanic carries a value a user-provided io.Reader has panicked with.
+type callbackPanic struct {
+	value any
+}
+
+func (c callbackReader) Read(p []byte) (int, error) {
+	defer markCallbackPanic()
+	return c.r.Read(p)
+}
+
+// markCallbackPanic marks a panic of a user-provided io.Reader as such. It must be deferred directly.
+func markCallbackPanic() {
+	if v := recover(); v != nil {
+		panic(callbackPanic{value: v})
+	}
+}
+
+// callbackBuffer marks the panics of a user-provided reader that is buffered already,
+// e.g. a bufio.Reader, and that the parser reads from directly.
+type callbackBuffer struct {
+	innerReader
+}
+
+func (c callbackBuffer) Read(p []byte) (int, error) {
+	defer markCallbackPanic()
+	return c.innerReader.Read(p)
+}
+
+func (c callbackBuffer) ReadByte() (byte, error) {
+	defer markCallbackPanic()
+	return c.innerReader.ReadByte()
+}
+
+func (c callbackBuffer) ReadRune() (rune, int, error) {
+	defer markCallbackPanic()
+	return c.innerReader.ReadRune()
+}
+
+func (c callbackBuffer) Discard(n int) (int, error) {
+	defer markCallbackPanic()
+	return c.innerReader.Discard(n)
+}
+
+func (c callbackBuffer) Peek(n int) ([]byte, error) {
+	defer markCallbackPanic()
+	return c.innerReader.Peek(n)
 }
@@ -0,0 +1,326 @@
+package kdl
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertErrorAt(t *testing.T, input string, line, column int) *ParseError {
+	t.Helper()
+	_, err := ParseString(input)
+	var pe *ParseError
+	if assert.True(t, errors.As(err, &pe), "expected a positioned error, got %v", err) {
+		assert.Equal(t, line, pe.Line, "line of %v", err)
+		assert.Equal(t, column, pe.Column, "column of %v", err)
+	}
+	return pe
+}
+
+func TestErrorMessageContainsPosition(t *testing.T) {
+	_, err := ParseString("foo\nbar 1 ;;")
+	assert.ErrorIs(t, err, ErrInvalidSyntax)
+	assert.EqualError(t, err, "kdl: invalid syntax at line 2, column 8: unexpected ';' not terminating a node")
+}
+
+func TestErrorPositionAfterMultilineString(t *testing.T) {
+	assertErrorAt(t, "foo \"bar\nbaz\nquox\" ;;", 3, 8)
+	assertErrorAt(t, "foo r#\"bar\n\"baz\"\n\"# qu=ox", 3, 7)
+}
+
+func TestErrorPositionAfterBlockComment(t *testing.T) {
+	assertErrorAt(t, "foo /* a\n * b\n */ bar", 3, 5)
+	assertErrorAt(t, "/* a /* b\n */ c\n*/ node }", 3, 9)
+}
+
+func TestErrorPositionAfterLineContinuation(t *testing.T) {
+	assertErrorAt(t, "foo 1 \\\n    2 \\\n    bar", 3, 5)
+	assertErrorAt(t, "foo 1 \\ // comment\n  \"bar\"x", 2, 8)
+}
+
+func TestErrorPositionCountsCRLFOnce(t *testing.T) {
+	assertErrorAt(t, "foo\r\nbar\r\n// comment\r\n\r\n  ;", 5, 3)
+	assertErrorAt(t, "foo\rbar\r\r;", 4, 1)
+}
+
+func TestErrorPositionCountsRunes(t *testing.T) {
+	assertErrorAt(t, "ノード \"ß\" ;;", 1, 10)
+}
+
+func TestUnterminatedQuotedStringIsNotReadAsValue(t *testing.T) {
+	_, err := ParseString(`foo "bar`)
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+}
+
+func TestParseErrorSupportsIsAndAs(t *testing.T) {
+	cases := []struct {
+		input  string
+		cause  error
+		offset int
+		line   int
+		column int
+		node   Identifier
+	}{
+		{"foo\n;", errUnexpectedSemicolon, 4, 2, 1, ""},
+		{"foo 1\n}", errUnexpectedRightBracket, 6, 2, 1, ""},
+		{"foo {\n  bar baz\n}", errUnexpectedBareIdentifier, 12, 2, 7, "bar"},
+		{"foo {\n  bar \"baz\n}", errUnexpectedEOFInsideString, 12, 2, 7, "bar"},
+	}
+
+	for _, c := range cases {
+		_, err := ParseString(c.input)
+		assert.ErrorIs(t, err, c.cause)
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe) {
+			assert.Equal(t, c.offset, pe.Offset, "offset of %v", err)
+			assert.Equal(t, c.line, pe.Line, "line of %v", err)
+			assert.Equal(t, c.column, pe.Column, "column of %v", err)
+			assert.Equal(t, c.node, pe.Node, "node of %v", err)
+		}
+	}
+
+	_, err := ParseString("foo\n;")
+	assert.ErrorIs(t, err, ErrInvalidSyntax)
+}
+
+func TestParseErrorRecordsInnermostNode(t *testing.T) {
+	_, err := ParseString("foo {\n  bar {\n    baz 1 2 3=\n  }\n}")
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.EqualValues(t, "baz", pe.Node)
+	}
+
+	_, err = ParseString("foo {\n  ;\n}")
+	if assert.ErrorAs(t, err, &pe) {
+		assert.EqualValues(t, "foo", pe.Node)
+	}
+}
+
+func TestErrorSourceContextIsOptIn(t *testing.T) {
+	_, err := ParseString("foo\nbar 1 ;;\nbaz")
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Empty(t, pe.SourceLine)
+	}
+}
+
+func TestErrorSourceContextShowsCaret(t *testing.T) {
+	opts := ParseOptions{ErrorSourceContext: true}
+
+	_, err := opts.ParseString("foo\nbar 1 ;;\nbaz")
+	assert.EqualError(t, err, "kdl: invalid syntax at line 2, column 8: unexpected ';' not terminating a node\n"+
+		"    bar 1 ;;\n"+
+		"           ^")
+
+	_, err = opts.ParseString("foo {\n\tbar\t1 ;;\n}")
+	assert.EqualError(t, err, "kdl: invalid syntax at line 2, column 9: in node \"foo\": unexpected ';' not terminating a node\n"+
+		"    \tbar\t1 ;;\n"+
+		"    \t   \t   ^")
+}
+
+func TestErrorSourceContextHandlesMultiByteRunes(t *testing.T) {
+	opts := ParseOptions{ErrorSourceContext: true}
+
+	_, err := opts.ParseString("ノード \"ß\" ;;")
+	assert.EqualError(t, err, "kdl: invalid syntax at line 1, column 10: unexpected ';' not terminating a node\n"+
+		"    ノード \"ß\" ;;\n"+
+		"                ^")
+}
+
+func TestErrorSourceContextAfterLineBreak(t *testing.T) {
+	opts := ParseOptions{ErrorSourceContext: true}
+
+	_, err := opts.ParseString("foo\r\nbar /-\r\nbaz")
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, "bar /-", pe.SourceLine)
+		assert.Equal(t, 5, pe.Column)
+	}
+}
+
+func TestErrorSourceContextIsTruncated(t *testing.T) {
+	opts := ParseOptions{ErrorSourceContext: true}
+
+	long := strings.Repeat("a", 2*maxRetainedLineLength)
+	_, err := opts.ParseString("foo \"" + long + "\" ;;")
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Empty(t, pe.SourceLine)
+	}
+
+	_, err = opts.ParseString("foo ;; " + long)
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Len(t, pe.SourceLine, maxRetainedLineLength)
+	}
+}
+
+func TestParseErrorListsEnclosingNodes(t *testing.T) {
+	_, err := ParseString(`package {
+	dependencies {
+		foo "bar"baz
+	}
+}`)
+	assert.ErrorIs(t, err, errUnexpectedTokenAfterIdentifier)
+	assert.EqualError(t, err, `kdl: invalid syntax at line 3, column 12: `+
+		`in node "package" > "dependencies" > "foo": unexpected token after identifier`)
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, []Identifier{"package", "dependencies", "foo"}, pe.Path)
+		assert.EqualValues(t, "foo", pe.Node)
+	}
+}
+
+func TestParseErrorTruncatesDeepPaths(t *testing.T) {
+	depth := 2 * maxErrorPathLength
+	input := strings.Repeat("a {\n", depth) + "b ;;" + strings.Repeat("}\n", depth)
+
+	_, err := ParseString(input)
+	assert.ErrorIs(t, err, errUnexpectedSemicolon)
+	assert.Contains(t, err.Error(), `in node ... > "a" > "a"`)
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Len(t, pe.Path, maxErrorPathLength)
+		assert.EqualValues(t, "a", pe.Node)
+	}
+}
+
+func TestUnterminatedStringPointsAtOpeningQuote(t *testing.T) {
+	input := "foo {\n  bar \"baz\n}\n" + strings.Repeat("quox 1 2 3\n", 20)
+
+	_, err := ParseString(input)
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+	assert.EqualError(t, err, `kdl: unterminated string started at line 2, column 7: `+
+		`in node "foo" > "bar": did you forget to close it?`)
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, CodeUnterminatedString, pe.Code)
+		assert.Equal(t, 12, pe.Offset)
+	}
+}
+
+func TestUnterminatedRawStringPointsAtOpeningQuote(t *testing.T) {
+	cases := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"foo r#\"bar\"\nbaz", 1, 5},
+		{"foo\nbar key=r##\"baz\"#", 2, 9},
+		{"r\"foo", 1, 1},
+		{"(r#\"foo\"\nbar", 1, 2},
+	}
+
+	for _, c := range cases {
+		pe := assertErrorAt(t, c.input, c.line, c.column)
+		if pe != nil {
+			assert.Equal(t, CodeUnterminatedString, pe.Code)
+			assert.ErrorIs(t, pe, ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestUnterminatedCommentPointsAtOpener(t *testing.T) {
+	_, err := ParseString("foo 1 /* bar\nbaz")
+	assert.ErrorIs(t, err, ErrUnterminatedComment)
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+	assert.EqualError(t, err, `kdl: unterminated comment started at line 1, column 7: `+
+		`in node "foo": did you forget to close it?`)
+}
+
+func TestUnterminatedNestedCommentPointsAtOutermostOpener(t *testing.T) {
+	_, err := ParseString("foo\n/* bar /* baz\n/* quox */ */")
+	assert.ErrorIs(t, err, ErrUnterminatedComment)
+	assert.EqualError(t, err, `kdl: unterminated comment started at line 2, column 1: did you forget to close it?`)
+
+	_, err = ParseString("/* a /* b /* c */")
+	assert.ErrorIs(t, err, ErrUnterminatedComment)
+	assert.EqualError(t, err, `kdl: unterminated comment started at line 1, column 1: 2 nested comments left open`)
+}
+
+func TestUnclosedChildrenPointsAtInnermostBrace(t *testing.T) {
+	cases := []struct {
+		input  string
+		line   int
+		column int
+		path   []Identifier
+	}{
+		{"foo {", 1, 5, []Identifier{"foo"}},
+		{"foo {\n  bar 1\n", 1, 5, []Identifier{"foo"}},
+		{"foo {\n  bar {\n    baz\n  }\n  quox {\n", 5, 8, []Identifier{"foo", "quox"}},
+		{"a {\n  b {\n    c { // d\n  }\n", 2, 5, []Identifier{"a", "b"}},
+		{"a { b { c {}", 1, 7, []Identifier{"a", "b"}},
+	}
+
+	for _, c := range cases {
+		pe := assertErrorAt(t, c.input, c.line, c.column)
+		if pe != nil {
+			assert.ErrorIs(t, pe, ErrUnclosedChildren)
+			assert.ErrorIs(t, pe, ErrUnexpectedEOF)
+			assert.Equal(t, CodeUnclosedChildren, pe.Code)
+			assert.Equal(t, c.path, pe.Path)
+		}
+	}
+
+	_, err := ParseString("foo {\n  bar\n")
+	assert.EqualError(t, err, `kdl: unclosed children block opened at line 1, column 5: `+
+		`in node "foo": expected '}' before the end of the document`)
+}
+
+// negativeReader violates the io.Reader contract by returning a negative count.
+type negativeReader struct{}
+
+func (negativeReader) Read(p []byte) (int, error) {
+	return -1, nil
+}
+
+// panickingReader panics on the first read.
+type panickingReader struct{}
+
+func (panickingReader) Read(p []byte) (int, error) {
+	panic("boom")
+}
+
+func TestReaderPanicBecomesError(t *testing.T) {
+	_, err := ParseReader(negativeReader{})
+	assert.ErrorIs(t, err, ErrInvalidSyntax)
+
+	var ie *InternalError
+	if assert.ErrorAs(t, err, &ie) {
+		assert.NotEmpty(t, ie.Stack)
+	}
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, CodeInternal, pe.Code)
+		assert.Equal(t, 1, pe.Line)
+	}
+
+	d := NewDecoder(negativeReader{})
+	_, err = d.Decode()
+	assert.ErrorAs(t, err, &ie)
+	_, err = d.Decode()
+	assert.ErrorAs(t, err, &ie)
+}
+
+func TestUserReaderPanicPropagates(t *testing.T) {
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = ParseReader(panickingReader{})
+	})
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = NewDecoder(panickingReader{}).Decode()
+	})
+
+	// Also when the parser reads from a buffer of the caller
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = ParseReader(bufio.NewReader(panickingReader{}))
+	})
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = NewDecoder(bufio.NewReader(panickingReader{})).Decode()
+	})
+}
@@ -0,0 +1,148 @@
+package kdl
+
+// Find returns the first descendant of this Node matching the predicate, in the order of Descendants,
+// without visiting the nodes after it. The node can be modified in place.
+func (n *Node) Find(pred func(*Node) bool) (*Node, bool) {
+	return find(n.Descendants(), pred)
+}
+
+// FindNamed returns the first descendant of this Node with the name, in the order of Descendants.
+func (n *Node) FindNamed(name Identifier) (*Node, bool) {
+	return n.Find(func(d *Node) bool { return d.Name == name })
+}
+
+// FindAll returns the descendants of this Node matching the predicate,
+// in the order of Descendants: depth-first, in the order of the document.
+func (n *Node) FindAll(pred func(*Node) bool) NodeSet {
+	return findAll(n.Descendants(), pred)
+}
+
+// FindAllNamed returns the descendants of this Node with the name, in the order of Descendants.
+func (n *Node) FindAllNamed(name Identifier) NodeSet {
+	return n.FindAll(func(d *Node) bool { return d.Name == name })
+}
+
+// Find returns the first node of this Document, at any depth, matching the predicate,
+// in the order of Descendants, without visiting the nodes after it. The node can be modified in place.
+func (d *Document) Find(pred func(*Node) bool) (*Node, bool) {
+	return find(d.Descendants(), pred)
+}
+
+// FindNamed returns the first node of this Document with the name, at any depth, in the order of Descendants.
+func (d *Document) FindNamed(name Identifier) (*Node, bool) {
+	return d.Find(func(n *Node) bool { return n.Name == name })
+}
+
+// FindAll returns the nodes of this Document, at any depth, matching the predicate,
+// in the order of Descendants: depth-first, in the order of the document.
+func (d *Document) FindAll(pred func(*Node) bool) NodeSet {
+	return findAll(d.Descendants(), pred)
+}
+
+// FindAllNamed returns the nodes of this Document with the name, at any depth, in the order of Descendants.
+func (d *Document) FindAllNamed(name Identifier) NodeSet {
+	return d.FindAll(func(n *Node) bool { return n.Name == name })
+}
+
+func find(seq func(yield func(*Node) bool), pred func(*Node) bool) (*Node, bool) {
+	var found *Node
+	seq(func(n *Node) bool {
+		if pred(n) {
+			found = n
+			return false
+		}
+		return true
+	})
+	return found, found != nil
+}
+
+func findAll(seq func(yield func(*Node) bool), pred func(*Node) bool) NodeSet {
+	var found NodeSet
+	seq(func(n *Node) bool {
+		if pred(n) {
+			found = append(found, n)
+		}
+		return true
+	})
+	return found
+}
+
+// NodesWithHint returns the descendants of this Node with the type annotation,
+// or with any type annotation if hint is empty, in the order of Descendants.
+func (n *Node) NodesWithHint(hint Identifier) NodeSet {
+	return n.FindAll(func(d *Node) bool { return matchesHint(d.TypeHint, hint) })
+}
+
+// NodesWithHint returns the nodes of this Document, at any depth, with the type annotation,
+// or with any type annotation if hint is empty, in the order of Descendants.
+func (d *Document) NodesWithHint(hint Identifier) NodeSet {
+	return d.FindAll(func(n *Node) bool { return matchesHint(n.TypeHint, hint) })
+}
+
+// ValueLocation points at an argument or a property of a Node.
+type ValueLocation struct {
+	Node *Node
+	Arg  int        // The index of the argument, or -1 for a property.
+	Prop Identifier // The key of the property, if Arg is -1.
+}
+
+// Value returns the value at the location.
+func (l ValueLocation) Value() Value {
+	if l.Arg >= 0 {
+		return l.Node.Args[l.Arg]
+	}
+	return l.Node.Props[l.Prop]
+}
+
+// Set replaces the value at the location, e.g. to redact it.
+func (l ValueLocation) Set(v Value) {
+	if l.Arg >= 0 {
+		l.Node.Args[l.Arg] = v
+		return
+	}
+	l.Node.SetPropValue(l.Prop, v)
+}
+
+// ValuesWithHint returns the locations of the arguments and the properties, of this Node and of its descendants,
+// with the type annotation, or with any type annotation if hint is empty.
+// The nodes are in the order of Descendants, after this Node, with the arguments of each before its properties.
+func (n *Node) ValuesWithHint(hint Identifier) []ValueLocation {
+	found := valuesWithHint(nil, n, hint)
+	n.Descendants()(func(d *Node) bool {
+		found = valuesWithHint(found, d, hint)
+		return true
+	})
+	return found
+}
+
+// ValuesWithHint returns the locations of the arguments and the properties of the nodes of this Document,
+// at any depth, with the type annotation, or with any type annotation if hint is empty.
+// The nodes are in the order of Descendants, with the arguments of each before its properties.
+func (d *Document) ValuesWithHint(hint Identifier) []ValueLocation {
+	var found []ValueLocation
+	d.Descendants()(func(n *Node) bool {
+		found = valuesWithHint(found, n, hint)
+		return true
+	})
+	return found
+}
+
+func valuesWithHint(found []ValueLocation, n *Node, hint Identifier) []ValueLocation {
+	for i := range n.Args {
+		if matchesHint(n.Args[i].TypeHint, hint) {
+			found = append(found, ValueLocation{Node: n, Arg: i})
+		}
+	}
+	for _, key := range n.orderedPropKeys() {
+		if matchesHint(n.Props[key].TypeHint, hint) {
+			found = append(found, ValueLocation{Node: n, Arg: -1, Prop: key})
+		}
+	}
+	return found
+}
+
+// matchesHint checks if a type annotation is present, and the same as the hint, unless the hint is empty.
+func matchesHint(h TypeHint, hint Identifier) bool {
+	name, ok := h.Get()
+	return ok && (hint == "" || name == hint)
+}
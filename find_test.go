@@ -0,0 +1,186 @@
+package kdl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const findDocument = `item 1
+group {
+	item 2
+	nested {
+		item 3 {
+			item 4
+		}
+	}
+	item 5
+}
+item 6
+`
+
+// firstArgs lists the first argument of each of the nodes.
+func firstArgs(nodes []*Node) []int64 {
+	var args []int64
+	for _, n := range nodes {
+		i, _ := n.FirstArgInt()
+		args = append(args, i)
+	}
+	return args
+}
+
+func TestDocumentFindAll(t *testing.T) {
+	doc, err := ParseString(findDocument)
+	assert.NoError(t, err)
+
+	// Pre-order, in the order of the document
+	items := doc.FindAllNamed("item")
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6}, firstArgs(items))
+	items[3].AddArg("changed")
+	assert.Len(t, doc.Nodes[1].Children[1].Children[0].Children[0].Args, 2)
+
+	withChildren := doc.FindAll(func(n *Node) bool { return len(n.Children) > 0 })
+	if assert.Len(t, withChildren, 3) {
+		assert.Equal(t, Identifier("group"), withChildren[0].Name)
+		assert.Equal(t, Identifier("nested"), withChildren[1].Name)
+		assert.Same(t, items[2], withChildren[2])
+	}
+
+	assert.Empty(t, doc.FindAllNamed("missing"))
+	assert.Empty(t, doc.FindAll(func(*Node) bool { return false }))
+	empty := NewDocument()
+	assert.Empty(t, empty.FindAllNamed("item"))
+}
+
+func TestNodeFindAll(t *testing.T) {
+	doc, err := ParseString(findDocument)
+	assert.NoError(t, err)
+	group := &doc.Nodes[1]
+
+	assert.Equal(t, []int64{2, 3, 4, 5}, firstArgs(group.FindAllNamed("item")))
+	assert.Empty(t, group.FindAllNamed("group"))
+
+	// The node itself is not one of its descendants
+	item := &group.Children[1].Children[0]
+	assert.Equal(t, []int64{4}, firstArgs(item.FindAllNamed("item")))
+	assert.Len(t, item.FindAll(func(*Node) bool { return true }), 1)
+}
+
+func TestFind(t *testing.T) {
+	doc, err := ParseString("profile name=\"a\"\ngroup {\n\tprofile name=\"b\" default=true\n\tprofile name=\"c\" default=true\n}\n")
+	assert.NoError(t, err)
+
+	visited := 0
+	isDefault := func(n *Node) bool {
+		visited++
+		d, _ := n.PropBool("default")
+		return n.Name == "profile" && d
+	}
+	found, ok := doc.Find(isDefault)
+	if assert.True(t, ok) {
+		name, _ := found.PropString("name")
+		assert.Equal(t, "b", name)
+		assert.Equal(t, 3, visited)
+
+		found.SetProp("default", false)
+		found, ok = doc.Find(isDefault)
+		assert.True(t, ok)
+		name, _ = found.PropString("name")
+		assert.Equal(t, "c", name)
+	}
+
+	found, ok = doc.FindNamed("profile")
+	assert.True(t, ok)
+	assert.Same(t, &doc.Nodes[0], found)
+
+	found, ok = doc.Nodes[1].FindNamed("profile")
+	assert.True(t, ok)
+	assert.Same(t, &doc.Nodes[1].Children[0], found)
+
+	found, ok = doc.FindNamed("missing")
+	assert.False(t, ok)
+	assert.Nil(t, found)
+	_, ok = doc.Nodes[0].Find(func(*Node) bool { return true })
+	assert.False(t, ok)
+}
+
+const hintDocument = `(secret)password "hunter2"
+database user="admin" password=(secret)"s3cr3t" {
+	(secret)token (b64)"dG9rZW4=" "plain"
+	replica {
+		(secret)key (secret)"k" timeout=(ms)50
+	}
+}
+(note)comment
+`
+
+func TestNodesWithHint(t *testing.T) {
+	doc, err := ParseString(hintDocument, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	secrets := doc.NodesWithHint("secret")
+	assert.Equal(t, NodeSet{&doc.Nodes[0], &doc.Nodes[1].Children[0], &doc.Nodes[1].Children[1].Children[0]}, secrets)
+	assert.Len(t, doc.NodesWithHint(""), 4)
+	assert.Empty(t, doc.NodesWithHint("b64"))
+
+	assert.Equal(t, NodeSet{&doc.Nodes[1].Children[0], &doc.Nodes[1].Children[1].Children[0]}, doc.Nodes[1].NodesWithHint("secret"))
+}
+
+func TestValuesWithHint(t *testing.T) {
+	doc, err := ParseString(hintDocument, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	database := &doc.Nodes[1]
+	token := &database.Children[0]
+	key := &database.Children[1].Children[0]
+
+	secrets := doc.ValuesWithHint("secret")
+	assert.Equal(t, []ValueLocation{
+		{Node: database, Arg: -1, Prop: "password"},
+		{Node: key, Arg: 0},
+	}, secrets)
+	assert.Equal(t, []ValueLocation{
+		{Node: database, Arg: -1, Prop: "password"},
+		{Node: token, Arg: 0},
+		{Node: key, Arg: 0},
+		{Node: key, Arg: -1, Prop: "timeout"},
+	}, doc.ValuesWithHint(""))
+	assert.Equal(t, []ValueLocation{{Node: key, Arg: 0}}, database.Children[1].ValuesWithHint("secret"))
+	assert.Equal(t, secrets, database.ValuesWithHint("secret"))
+
+	assert.Equal(t, "s3cr3t", secrets[0].Value().StringValue())
+	assert.Equal(t, "k", secrets[1].Value().StringValue())
+
+	// Redaction
+	for _, l := range secrets {
+		l.Set(NewStringValue("***", NoHint()))
+	}
+	password, _ := database.PropString("password")
+	assert.Equal(t, "***", password)
+	k, _ := key.FirstArgString()
+	assert.Equal(t, "***", k)
+	assert.Equal(t, []Identifier{"user", "password"}, database.PropKeys())
+}
+
+func BenchmarkFind(b *testing.B) {
+	doc := NewDocument()
+	doc.AddChild(NewNode("target"))
+	for i := 0; i < 10000; i++ {
+		doc.AddChild(NewNode("other"))
+	}
+
+	b.Run("Find", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, ok := doc.FindNamed("target"); !ok {
+				b.Fatal("not found")
+			}
+		}
+	})
+	b.Run("FindAll", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if len(doc.FindAllNamed("target")) != 1 {
+				b.Fatal("not found")
+			}
+		}
+	})
+}
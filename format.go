@@ -0,0 +1,430 @@
+package kdl
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// documentFormat is how a Document has been written, see ParseOptions.KeepFormat.
+type documentFormat struct {
+	text string // The whole document.
+	end  int    // End of the last node, where the text after all the nodes starts.
+	unit string // The indentation added at each level of nesting.
+}
+
+// nodeFormat is how a Node has been written, see ParseOptions.KeepFormat.
+// The parts of the node are offsets into the text of the document, which is shared by all of its nodes.
+//
+// Along with them, it keeps the parts of the node as they have been read,
+// so that the writer can tell which ones have been modified since, and only write those anew.
+type nodeFormat struct {
+	text string
+
+	before     int // Start of the text before the node: the end of the previous node, or of the '{' before it.
+	start      int // Start of the node, at its type hint or name.
+	nameEnd    int
+	entries    []entryFormat
+	brace      int // Offset of the '{' of the children block, or -1 if there is none.
+	closeStart int // End of the last child, where the text before the '}' starts.
+	end        int // End of the node, before the line break after it.
+
+	hint            TypeHint
+	name            Identifier
+	args            int
+	leadingComments []string
+	blankLines      int
+	trailingComment string
+	comments        bool // Whether the comments have been read into the node, see ParseOptions.KeepComments.
+}
+
+// entryFormat is how an argument or a property has been written.
+type entryFormat struct {
+	start, end int
+	valueStart int        // Offset of the value of a property, after its '=', or -1 for an argument.
+	key        Identifier // Key of a property.
+	index      int        // Index of an argument.
+	value      Value      // A copy of the value when it was read.
+	overridden bool       // Whether a property has been set again later on, see DuplicatePropsLastWins.
+}
+
+func newDocumentFormat(text string, d *Document) *documentFormat {
+	end := placeNodes(d.Nodes, 0)
+	unit, ok := indentUnit(d.Nodes)
+	if !ok {
+		unit = "    "
+	}
+	return &documentFormat{text: text, end: end, unit: unit}
+}
+
+// placeNodes makes the text before each of the nodes start where the previous one ends,
+// returning the end of the last node.
+func placeNodes(nodes []Node, start int) int {
+	end := start
+	for i := range nodes {
+		if f := nodes[i].format; f != nil {
+			f.before = end
+			end = f.end
+		}
+	}
+	return end
+}
+
+// indentUnit guesses the indentation of a level of nesting from the first children block
+// whose nodes are on lines of their own, as the indentation they have on top of the one of their parent.
+// Other lines, e.g. the ones of multi-line strings or after a line continuation, are not looked at.
+func indentUnit(nodes []Node) (string, bool) {
+	// A line break is never an indentation, so it stands for the nodes not being on lines of their own
+	const unknown = "\n"
+	for i := range nodes {
+		if f := nodes[i].format; f != nil {
+			before := f.text[f.before:f.start]
+			if f.before == 0 {
+				// The start of the document is the start of a line too
+				before = "\n" + before
+			}
+			parent := indentOf(before, unknown)
+			child := siblingIndent(nodes[i].Children, unknown)
+			if parent != unknown && child != unknown && len(child) > len(parent) && strings.HasPrefix(child, parent) {
+				return child[len(parent):], true
+			}
+		}
+		if unit, ok := indentUnit(nodes[i].Children); ok {
+			return unit, true
+		}
+	}
+	return "", false
+}
+
+func newNodeFormat(r *reader, node *Node, start int) *nodeFormat {
+	return &nodeFormat{
+		text:            r.text,
+		start:           start,
+		nameEnd:         r.offset,
+		brace:           -1,
+		hint:            node.TypeHint,
+		name:            node.Name,
+		leadingComments: node.LeadingComments,
+		blankLines:      node.BlankLinesBefore,
+		comments:        r.cfg.KeepComments,
+	}
+}
+
+func (f *nodeFormat) addEntry(start, end, valueStart, index int, v Value) {
+	f.entries = append(f.entries, entryFormat{start: start, end: end, valueStart: valueStart, index: index, value: v.Clone()})
+	if valueStart < 0 {
+		f.args++
+	}
+}
+
+func (f *nodeFormat) addProp(key Identifier, start, valueStart, end int, v Value) {
+	for i := range f.entries {
+		if f.entries[i].valueStart >= 0 && f.entries[i].key == key {
+			f.entries[i].overridden = true
+		}
+	}
+	f.addEntry(start, end, valueStart, -1, v)
+	f.entries[len(f.entries)-1].key = key
+}
+
+func (f *nodeFormat) setEnd(node *Node, end int) {
+	f.end = end
+	f.trailingComment = node.TrailingComment
+}
+
+// closeBlock records where the text after the children starts, once the children block has been read.
+func (f *nodeFormat) closeBlock(node *Node) {
+	f.closeStart = placeNodes(node.Children, f.brace+1)
+}
+
+// sameHead reports whether the type hint, name, arguments and properties of the node
+// are the same as when it was read.
+func (f *nodeFormat) sameHead(n *Node) bool {
+	if n.Name != f.name || !equalHints(n.TypeHint, f.hint) || len(n.Args) != f.args {
+		return false
+	}
+	props := 0
+	for _, e := range f.entries {
+		if e.overridden {
+			continue
+		}
+		v, ok := e.current(n)
+		if !ok || !sameValue(v, e.value) {
+			return false
+		}
+		if e.valueStart >= 0 {
+			props++
+		}
+	}
+	return props == len(n.Props)
+}
+
+// hasProp reports whether the node has been read with the property.
+func (f *nodeFormat) hasProp(key Identifier) bool {
+	for _, e := range f.entries {
+		if e.valueStart >= 0 && e.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// current returns the value of the entry in the node now, if it still has one.
+func (e *entryFormat) current(n *Node) (Value, bool) {
+	if e.valueStart >= 0 {
+		v, ok := n.Props[e.key]
+		return v, ok
+	}
+	if e.index < len(n.Args) {
+		return n.Args[e.index], true
+	}
+	return Value{}, false
+}
+
+// sameValue reports whether a value is unchanged, including the kind of the number.
+func sameValue(a, b Value) bool {
+	return EqualOptions{}.Values(a, b)
+}
+
+// leadingText returns the text to write before the node: the line break after the previous node,
+// the blank lines, the comments and the indentation.
+func (f *nodeFormat) leadingText(n *Node, indent string) string {
+	before := f.text[f.before:f.start]
+	if n.BlankLinesBefore == f.blankLines && slices.Equal(n.LeadingComments, f.leadingComments) {
+		return before
+	}
+
+	// The comments have been modified, so they are written anew
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	indent = indentOf(before, indent)
+	var b strings.Builder
+	blankLines := n.BlankLinesBefore
+	if f.comments {
+		if lineStart > 0 {
+			b.WriteByte('\n')
+		}
+	} else {
+		// The comments in the text are not in the node, so they are kept
+		b.WriteString(before[:lineStart])
+		blankLines -= f.blankLines
+	}
+	for i := 0; i < blankLines; i++ {
+		b.WriteByte('\n')
+	}
+	for _, comment := range n.LeadingComments {
+		b.WriteString(indent)
+		b.WriteString(comment)
+		b.WriteByte('\n')
+	}
+	b.WriteString(indent)
+	return b.String()
+}
+
+// indentOf returns the indentation at the end of the text before a node, or the fallback if there is none.
+func indentOf(before, fallback string) string {
+	indent := before[strings.LastIndexByte(before, '\n')+1:]
+	if strings.Trim(indent, " \t") != "" || !strings.Contains(before, "\n") && before != "" {
+		return fallback
+	}
+	return indent
+}
+
+// siblingIndent guesses the indentation of new nodes from the first of their siblings that has been read.
+func siblingIndent(nodes []Node, fallback string) string {
+	for i := range nodes {
+		if f := nodes[i].format; f != nil && strings.Contains(f.text[f.before:f.start], "\n") {
+			return indentOf(f.text[f.before:f.start], fallback)
+		}
+	}
+	return fallback
+}
+
+// writeFormattedDocument writes a Document read with ParseOptions.KeepFormat,
+// copying the text of everything that has not been modified since.
+func writeFormattedDocument(w *writer, d *Document) error {
+	f := d.format
+	w.unit, w.version = f.unit, d.Version
+	if err := writeFormattedNodes(w, d.Nodes, siblingIndent(d.Nodes, ""), f.text, 0, true); err != nil {
+		return err
+	}
+	_, err := w.writer.WriteString(f.text[f.end:])
+	return err
+}
+
+// writeFormattedNodes writes the nodes of a block, which starts at the provided offset of the text.
+// The nodes that have not been read are written on lines of their own, with the provided indentation.
+func writeFormattedNodes(w *writer, nodes []Node, indent string, text string, start int, top bool) error {
+	prevEnd := start
+	for i := range nodes {
+		n := &nodes[i]
+		f := n.format
+		first := top && i == 0
+		if f == nil {
+			if !first {
+				if err := w.writer.WriteByte('\n'); err != nil {
+					return err
+				}
+			}
+			w.indent, w.depth = indent, 0
+			if err := writeNode(w, n); err != nil {
+				return err
+			}
+			prevEnd = -1
+			continue
+		}
+
+		before := f.leadingText(n, indent)
+		if f.text != text || f.before != prevEnd {
+			// The node has been moved, so it might need a line break of its own
+			trimmed := strings.TrimLeft(before, " \t")
+			switch {
+			case first:
+				before = strings.TrimLeft(before, "\r\n")
+			case !strings.HasPrefix(trimmed, "\n") && !strings.HasPrefix(trimmed, "\r"):
+				before = "\n" + indent + trimmed
+			}
+		}
+		if _, err := w.writer.WriteString(before); err != nil {
+			return err
+		}
+		if err := writeFormattedNode(w, n, indentOf(f.text[f.before:f.start], indent)); err != nil {
+			return err
+		}
+		text, prevEnd = f.text, f.end
+	}
+	return nil
+}
+
+// writeFormattedNode writes a node that has been read with ParseOptions.KeepFormat, after the text before it.
+func writeFormattedNode(w *writer, n *Node, indent string) error {
+	f := n.format
+	text := f.text
+
+	headEnd := f.nameEnd
+	if len(f.entries) > 0 {
+		headEnd = f.entries[len(f.entries)-1].end
+	}
+	if f.sameHead(n) {
+		if _, err := w.writer.WriteString(text[f.start:headEnd]); err != nil {
+			return err
+		}
+	} else if err := writeFormattedHead(w, n); err != nil {
+		return err
+	}
+
+	restEnd := f.end
+	if f.brace >= 0 {
+		restEnd = f.brace
+	}
+	if f.brace < 0 && (len(n.Children) > 0 || len(n.EndComments) > 0) {
+		// A new children block goes right after the arguments and properties,
+		// before anything else on the line of the node
+		w.indent, w.depth = indent, 0
+		if err := writeChildren(w, n); err != nil {
+			return err
+		}
+	}
+	if _, err := w.writer.WriteString(text[headEnd:restEnd]); err != nil {
+		return err
+	}
+
+	if f.brace >= 0 {
+		if err := w.writer.WriteByte('{'); err != nil {
+			return err
+		}
+		childIndent := siblingIndent(n.Children, indent+w.unit)
+		if err := writeFormattedNodes(w, n.Children, childIndent, text, f.brace+1, false); err != nil {
+			return err
+		}
+		if _, err := w.writer.WriteString(text[f.closeStart:f.end]); err != nil {
+			return err
+		}
+	}
+
+	if f.trailingComment == "" && n.TrailingComment != "" {
+		if err := writeSpace(w); err != nil {
+			return err
+		}
+		if _, err := w.writer.WriteString(n.TrailingComment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFormattedHead writes the type hint, name, arguments and properties of a node,
+// copying the text of the ones that have not been modified.
+func writeFormattedHead(w *writer, n *Node) error {
+	f := n.format
+	text := f.text
+
+	if n.Name == f.name && equalHints(n.TypeHint, f.hint) {
+		if _, err := w.writer.WriteString(text[f.start:f.nameEnd]); err != nil {
+			return err
+		}
+	} else {
+		if err := writeTypeHint(w, n.TypeHint, n.hintQuotes); err != nil {
+			return err
+		}
+		if err := writeIdentifierAs(w, n.Name, n.nameQuotes); err != nil {
+			return err
+		}
+	}
+
+	prevEnd := f.nameEnd
+	for i := range f.entries {
+		e := &f.entries[i]
+		before := text[prevEnd:e.start]
+		prevEnd = e.end
+		v, ok := e.current(n)
+		if e.overridden || !ok {
+			continue
+		}
+		if sameValue(v, e.value) {
+			if _, err := w.writer.WriteString(before + text[e.start:e.end]); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := w.writer.WriteString(before); err != nil {
+			return err
+		}
+		if e.valueStart >= 0 {
+			if _, err := w.writer.WriteString(text[e.start:e.valueStart]); err != nil {
+				return err
+			}
+		}
+		if err := writeValue(w, &v); err != nil {
+			return err
+		}
+	}
+
+	// New arguments and properties go after the others
+	for i := f.args; i < len(n.Args); i++ {
+		if err := writeSpace(w); err != nil {
+			return err
+		}
+		if err := writeValue(w, &n.Args[i]); err != nil {
+			return err
+		}
+	}
+	for _, key := range n.orderedPropKeys() {
+		if f.hasProp(key) {
+			continue
+		}
+		value := n.Props[key]
+		if err := writeSpace(w); err != nil {
+			return err
+		}
+		if err := writeIdentifierAs(w, key, n.keyQuotes[key]); err != nil {
+			return err
+		}
+		if err := w.writer.WriteByte('='); err != nil {
+			return err
+		}
+		if err := writeValue(w, &value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package kdl
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFidelityCorpus(t *testing.T) {
+	cases := []struct {
+		file     string
+		selector string
+		key      Identifier
+		value    interface{}
+		want     string // The only line expected to change.
+	}{
+		{"package.kdl", "dependencies > tokio", "default-features", true, `    tokio "1.28"   default-features=#true features="rt,macros"`},
+		{"server.kdl", "http > server", "listen", 8443, "\tserver listen=8443 ssl=#true {"},
+		{"pipeline.kdl", `stage[0 = "lint"]`, "allow-failure", true, `  stage "lint" allow-failure=#true {`},
+		{"compose.kdl", "services > web", "restart", "unless-stopped", `    web image="nginx:1.25" restart="unless-stopped" {`},
+		{"settings.kdl", "editor > font", "size", 14.0, `  font family="JetBrains Mono" size=14.0 ligatures=#true`},
+		{"i18n.kdl", `locale[0 = "en-US"]`, "default", true, `locale "en-US" default=#true {`},
+		{"deployment.kdl", "resources > limits", "memory", "1Gi", "\t\t\tlimits cpu=\"1\" memory=\"1Gi\""},
+		{"logging.kdl", `filter[0 = "http.access"]`, "level", "warn", `    filter "http.access" level="warn"`},
+		{"schema.kdl", `column[0 = "id"]`, "primary", false, `  column "id" (uuid)"" primary=#false`},
+		{"flags.kdl", `flag[0 = "search-v2"]`, "rollout", 0.5, `    flag "search-v2"    enabled=#true  rollout=0.5 owner="search-team" // ramping up`},
+	}
+	for _, c := range cases {
+		t.Run(c.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "fidelity", c.file))
+			assert.NoError(t, err)
+			doc, err := ParseBytes(data, WithFidelity(true), WithVersion(Version2))
+			assert.NoError(t, err)
+			assert.Equal(t, string(data), doc.String())
+
+			found, err := doc.Select(c.selector)
+			assert.NoError(t, err)
+			if !assert.NotEmpty(t, found) {
+				return
+			}
+			found[0].SetProp(c.key, c.value)
+
+			before := strings.Split(string(data), "\n")
+			after := strings.Split(doc.String(), "\n")
+			if !assert.Len(t, after, len(before)) {
+				return
+			}
+			var changed []string
+			for i := range before {
+				if before[i] != after[i] {
+					changed = append(changed, after[i])
+				}
+			}
+			assert.Equal(t, []string{c.want}, changed)
+		})
+	}
+}
+
+func TestFidelityEdits(t *testing.T) {
+	const src = `// Top
+a 1 /* one */ 0x10 k=#"raw"#
+
+b {
+	c; d
+	e "x" // last
+}
+`
+	parse := func() Document {
+		doc, err := ParseString(src, WithFidelity(true), WithVersion(Version2))
+		assert.NoError(t, err)
+		return doc
+	}
+
+	// Removed, replaced and added values
+	doc := parse()
+	doc.Nodes[0].Args = []Value{NewIntegerValue(big.NewInt(2), NoHint()), doc.Nodes[0].Args[1], NewStringValue("new", NoHint())}
+	doc.Nodes[0].RemoveProp("k")
+	assert.Equal(t, "// Top\na 2 /* one */ 0x10 \"new\"\n\nb {\n\tc; d\n\te \"x\" // last\n}\n", doc.String())
+
+	// New nodes are indented like their siblings
+	doc = parse()
+	doc.Nodes[1].AddChild(NewNode("f"))
+	doc.Nodes[0].AddChild(NewNode("g"))
+	doc.AddChild(NewNode("h"))
+	assert.Equal(t, "// Top\na 1 /* one */ 0x10 k=#\"raw\"# {\n\tg\n}\n\nb {\n\tc; d\n\te \"x\" // last\n\tf\n}\nh\n", doc.String())
+
+	// Moved and removed nodes keep their text, on lines of their own
+	doc = parse()
+	b := &doc.Nodes[1]
+	b.Children = []Node{b.Children[1], b.Children[2], b.Children[0]}
+	doc.Nodes = []Node{doc.Nodes[1], doc.Nodes[0]}
+	assert.Equal(t, "b {\n\td\n\te \"x\" // last\n\tc;\n}\n// Top\na 1 /* one */ 0x10 k=#\"raw\"#\n", doc.String())
+
+	// Renamed nodes keep their values
+	doc = parse()
+	assert.NoError(t, doc.Nodes[1].Children[2].Rename("renamed node"))
+	assert.Equal(t, strings.Replace(src, `e "x"`, `"renamed node" "x"`, 1), doc.String())
+
+	// The result is valid
+	reparsed, err := ParseString(doc.String(), WithVersion(Version2))
+	assert.NoError(t, err)
+	assert.True(t, reparsed.Equal(&doc))
+}
+
+func TestFidelityIndentsLikeChildren(t *testing.T) {
+	cases := map[string]string{
+		// Neither a line continuation nor a multi-line string is a level of nesting
+		"node 1 \\\n   other=3 {\n  x\n}\nnew\n":                    "node 1 \\\n   other=3 {\n  x\n}\nnew {\n  child\n}\n",
+		"node \"\"\"\n      text\n      \"\"\"\na {\n\tb\n}\nnew\n": "node \"\"\"\n      text\n      \"\"\"\na {\n\tb\n}\nnew {\n\tchild\n}\n",
+		// Nor is the indentation of a whole block
+		"  a {\n     b\n  }\nnew\n": "  a {\n     b\n  }\nnew {\n   child\n}\n",
+		// Without any, the default is used
+		"a; b {c}\nnew\n": "a; b {c}\nnew {\n    child\n}\n",
+	}
+	for src, want := range cases {
+		doc, err := ParseString(src, WithFidelity(true), WithVersion(Version2))
+		if !assert.NoError(t, err, src) {
+			continue
+		}
+		doc.Nodes[len(doc.Nodes)-1].AddChild(NewNode("child"))
+		assert.Equal(t, want, doc.String(), src)
+	}
+}
+
+func TestFidelityNeedsUntranscodedInput(t *testing.T) {
+	doc, err := ParseString("a  1\n", WithFidelity(true))
+	assert.NoError(t, err)
+	assert.Equal(t, "a  1\n", doc.String())
+
+	// Without the option, the document is written anew
+	doc, err = ParseString("a  1\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "a 1\n", doc.String())
+
+	doc, err = ParseBytes([]byte("\xff\xfea\x00 \x00 \x001\x00\n\x00"), WithFidelity(true))
+	assert.NoError(t, err)
+	assert.Equal(t, "a 1\n", doc.String())
+}
@@ -81,6 +81,8 @@ import (
 			w.WriteString("\n\toutput := `")
 			w.Write(output)
 			w.WriteString("`\n")
+			// The expected documents have their properties sorted by name
+			w.WriteString("\tsortProps(&doc)\n")
 			w.WriteString("\twritten, err := doc.WriteString()\n")
 			w.WriteString("\tassert.NoError(t, err)\n")
 			w.WriteString("\tassert.Equal(t, output, written)\n")
@@ -0,0 +1,87 @@
+package kdl
+
+// The iterators below have the same types as iter.Seq and iter.Seq2,
+// so that they can be ranged over and passed to the standard iterator helpers in Go 1.23 and newer,
+// while the module keeps supporting older versions of Go.
+
+// EachChild iterates over the children of this Node, in the order of the document.
+// The children can be modified in place.
+//
+// The iterator walks the Children as they were when the iteration started:
+// children added or removed by the loop body are not taken into account.
+func (n *Node) EachChild() func(yield func(*Node) bool) {
+	return func(yield func(*Node) bool) {
+		children := n.Children
+		for i := range children {
+			if !yield(&children[i]) {
+				return
+			}
+		}
+	}
+}
+
+// EachArg iterates over the arguments of this Node with their indexes.
+//
+// The iterator walks the Args as they were when the iteration started:
+// arguments added or removed by the loop body are not taken into account.
+func (n *Node) EachArg() func(yield func(int, Value) bool) {
+	return func(yield func(int, Value) bool) {
+		for i, arg := range n.Args {
+			if !yield(i, arg) {
+				return
+			}
+		}
+	}
+}
+
+// EachProp iterates over the properties of this Node, in the order of PropKeys, like they are written.
+//
+// The names are collected when the iteration starts. A property removed by the loop body
+// before it has been reached is skipped, while properties added by it are not visited.
+// The values are read when they are reached.
+func (n *Node) EachProp() func(yield func(Identifier, Value) bool) {
+	return func(yield func(Identifier, Value) bool) {
+		keys := n.orderedPropKeys()
+		for _, key := range keys {
+			value, ok := n.Props[key]
+			if !ok || value.Type == TypeInvalid {
+				continue
+			}
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// Descendants iterates over the children of this Node and all of their descendants,
+// depth-first in the order of the document (pre-order), like Walk does. The Node itself is not included.
+//
+// Modifying the nodes is subject to the same rules as for Document.Descendants.
+func (n *Node) Descendants() func(yield func(*Node) bool) {
+	return descendants(&n.Children)
+}
+
+// Descendants iterates over all the nodes of this Document depth-first, in the order of the document
+// (pre-order), like Walk does. The nodes can be modified in place.
+//
+// The loop body can change the Children of the node it has just been given,
+// but changing the children of any of its ancestors, or the Nodes of the Document,
+// makes the iterator panic with ErrChildrenModified.
+func (d *Document) Descendants() func(yield func(*Node) bool) {
+	return descendants(&d.Nodes)
+}
+
+func descendants(nodes *[]Node) func(yield func(*Node) bool) {
+	return func(yield func(*Node) bool) {
+		_, err := walkNodes(nil, nodes, func(_ []*Node, n *Node) WalkAction {
+			if !yield(n) {
+				return WalkStop
+			}
+			return WalkContinue
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+//go:build go1.23
+
+package kdl
+
+import (
+	"iter"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEachChild(t *testing.T) {
+	doc, err := ParseString("parent {\n\ta\n\tb\n\tc\n}\n")
+	assert.NoError(t, err)
+	parent := &doc.Nodes[0]
+
+	var names []Identifier
+	for child := range parent.EachChild() {
+		names = append(names, child.Name)
+		child.AddArg(1)
+	}
+	assert.Equal(t, []Identifier{"a", "b", "c"}, names)
+	assert.Len(t, parent.Children[2].Args, 1)
+
+	// Breaking early, then adding children in the loop body
+	names = nil
+	for child := range parent.EachChild() {
+		names = append(names, child.Name)
+		if child.Name == "b" {
+			break
+		}
+		parent.AddChild(NewNode("added"))
+	}
+	assert.Equal(t, []Identifier{"a", "b"}, names)
+	assert.Len(t, parent.Children, 4)
+
+	leaf := NewNode("leaf")
+	for range leaf.EachChild() {
+		t.Fail()
+	}
+}
+
+func TestEachArg(t *testing.T) {
+	doc, err := ParseString("node 1 \"two\" 3\n")
+	assert.NoError(t, err)
+	n := &doc.Nodes[0]
+
+	var got []int
+	for i, arg := range n.EachArg() {
+		got = append(got, i)
+		assert.Equal(t, arg.Type, n.Args[i].Type)
+		n.AddArg(4)
+	}
+	assert.Equal(t, []int{0, 1, 2}, got)
+	assert.Len(t, n.Args, 6)
+
+	var seq iter.Seq2[int, Value] = n.EachArg()
+	for i := range seq {
+		if i == 1 {
+			break
+		}
+	}
+}
+
+func TestEachProp(t *testing.T) {
+	doc, err := ParseString("node c=3 a=1 b=2 d=4\n")
+	assert.NoError(t, err)
+	n := &doc.Nodes[0]
+
+	// In the order of the document
+	keys := slices.Collect(func(yield func(Identifier) bool) {
+		for key := range n.EachProp() {
+			if !yield(key) {
+				return
+			}
+		}
+	})
+	assert.Equal(t, []Identifier{"c", "a", "b", "d"}, keys)
+
+	// Removed properties are skipped, added ones are not visited, changed ones are read anew
+	keys = nil
+	for key, value := range n.EachProp() {
+		keys = append(keys, key)
+		if key == "c" {
+			n.RemoveProp("b")
+			n.SetProp("aa", 0)
+			n.SetProp("a", "changed")
+		}
+		if key == "a" {
+			assert.Equal(t, "changed", value.StringValue())
+		}
+	}
+	assert.Equal(t, []Identifier{"c", "a", "d"}, keys)
+
+	keys = nil
+	for key := range n.EachProp() {
+		keys = append(keys, key)
+		break
+	}
+	assert.Equal(t, []Identifier{"c"}, keys)
+
+	empty := NewNode("empty")
+	for range empty.EachProp() {
+		t.Fail()
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	doc, err := ParseString(walkDocument)
+	assert.NoError(t, err)
+
+	var names []Identifier
+	for n := range doc.Descendants() {
+		names = append(names, n.Name)
+	}
+	assert.Equal(t, []Identifier{"a", "b", "c", "d", "e", "f"}, names)
+
+	names = nil
+	for n := range doc.Descendants() {
+		names = append(names, n.Name)
+		if n.Name == "c" {
+			break
+		}
+	}
+	assert.Equal(t, []Identifier{"a", "b", "c"}, names)
+
+	// The children of the node just given can be changed
+	names = nil
+	for n := range doc.Descendants() {
+		names = append(names, n.Name)
+		if n.Name == "d" {
+			n.AddChild(NewNode("added"))
+		}
+	}
+	assert.Equal(t, []Identifier{"a", "b", "c", "d", "added", "e", "f"}, names)
+
+	// The children of its ancestors cannot
+	assert.PanicsWithValue(t, ErrChildrenModified, func() {
+		for n := range doc.Descendants() {
+			if n.Name == "c" {
+				doc.Nodes[0].AddChild(NewNode("added"))
+			}
+		}
+	})
+}
+
+func TestNodeDescendants(t *testing.T) {
+	doc, err := ParseString(walkDocument)
+	assert.NoError(t, err)
+
+	var names []Identifier
+	for n := range doc.Nodes[0].Descendants() {
+		names = append(names, n.Name)
+	}
+	assert.Equal(t, []Identifier{"b", "c", "d"}, names)
+
+	for n := range doc.Nodes[0].Descendants() {
+		if n.Name == "b" {
+			break
+		}
+	}
+
+	leaf := NewNode("leaf")
+	for range leaf.Descendants() {
+		t.Fail()
+	}
+
+	assert.PanicsWithValue(t, ErrChildrenModified, func() {
+		for n := range doc.Nodes[0].Descendants() {
+			if n.Name == "c" {
+				doc.Nodes[0].AddChild(NewNode("added"))
+			}
+		}
+	})
+}
@@ -219,13 +219,23 @@ func structIntoNode(c *marshalContext, s reflect.Value, n *Node) error {
 
 func valueToKDLValue(v reflect.Value) (Value, error) {
 
+	if v.CanInterface() {
+		if val, err := ValueOf(v.Interface()); err == nil {
+			return val, nil
+		}
+	}
+
+	if v.Type() == reflect.TypeOf(Number("")) {
+		return Number(v.String()).value()
+	}
+
 	switch v.Kind() {
 	case reflect.String:
 		return NewStringValue(v.Interface().(string), NoHint()), nil
 	case reflect.Bool:
 		return NewBoolValue(v.Interface().(bool), NoHint()), nil
 	case reflect.Float32, reflect.Float64:
-		return NewFloatValue(big.NewFloat(v.Float()), NoHint()), nil
+		return NewFloat64Value(v.Float(), NoHint()), nil
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return NewIntegerValue(big.NewInt(v.Int()), NoHint()), nil
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
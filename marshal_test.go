@@ -3,6 +3,7 @@ package kdl
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -56,3 +57,20 @@ func TestValueConverts(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, s, v.StringValue())
 }
+
+func TestValueConvertsLikeValueOf(t *testing.T) {
+	v, err := valueToKDLValue(reflect.ValueOf([]byte("hi!")))
+	assert.NoError(t, err)
+	assert.Equal(t, "aGkh", v.StringValue())
+	assert.Equal(t, Hint("base64"), v.TypeHint)
+
+	v, err = valueToKDLValue(reflect.ValueOf(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)))
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-02-29T00:00:00Z", v.StringValue())
+
+	// Named types are still converted by their kind
+	type myInt int
+	v, err = valueToKDLValue(reflect.ValueOf(myInt(3)))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, v.IntegerValue().Int64())
+}
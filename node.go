@@ -1,14 +1,54 @@
 package kdl
 
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
 // Node is an object in a KDL Document.
 type Node struct {
 	TypeHint TypeHint             // Optional hint about the type of this node.
 	Name     Identifier           // Name of the node.
 	Args     []Value              // Ordered arguments of the node.
-	Props    map[Identifier]Value // Unordered properties of the node. CAN BE NIL.
+	Props    map[Identifier]Value // Properties of the node. CAN BE NIL. See PropKeys for their order.
 	Children []Node               // Ordered children of the node.
+
+	// Comments around the node, each kept as written, including the // or the /* */.
+	// The parser only reads them with ParseOptions.KeepComments.
+	// An empty one stands for a blank line between or after them, recorded with ParseOptions.KeepBlankLines.
+	LeadingComments []string // Comments on the lines before the node, written on separate lines.
+	TrailingComment string   // Comments on the line of the node, written after it.
+	EndComments     []string // Comments after the last child, written at the end of the children block.
+
+	// BlankLinesBefore is how many blank lines are written before the node and its leading comments.
+	// The parser records up to MaxBlankLines of them with ParseOptions.KeepBlankLines.
+	BlankLinesBefore int
+
+	// propOrder is the order the properties have been set in by SetPropValue. It can have stale keys
+	// and lack the keys added to Props directly, so it is only used through orderedPropKeys.
+	propOrder []Identifier
+
+	// source is the SourceName of the Document the node has been read from. See Source.
+	source string
+
+	// format is how the node has been written, if it has been read with ParseOptions.KeepFormat.
+	format *nodeFormat
+
+	// position is where the node has been read from, if recorded. See Position.
+	position *nodePosition
+
+	// How the name, the type hint and the keys of the properties have been written, if not the usual way.
+	nameQuotes, hintQuotes quoting
+	keyQuotes              map[Identifier]quoting
 }
 
+// MaxBlankLines is the most blank lines before a node that the parser records, see ParseOptions.KeepBlankLines.
+const MaxBlankLines = 2
+
 // NewNode creates a new KDL node.
 func NewNode(name string) Node {
 	return Node{
@@ -16,6 +56,86 @@ func NewNode(name string) Node {
 	}
 }
 
+// Rename changes the name of this Node. It is the one place to change names through,
+// so that anything derived from them can be kept up to date.
+//
+// Any name can be written, quoted if it is not a valid bare identifier, including the empty name "".
+// ErrInvalidName is returned instead for names that are not valid UTF-8 or have characters
+// that cannot appear in a document, e.g. most control characters.
+func (n *Node) Rename(name Identifier) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	n.Name = name
+	return nil
+}
+
+// Source returns the name of the document this Node has been read from, e.g. the path of its file.
+// It is set on all the nodes of the documents parsed by ParseFile and ParseFS,
+// and by Document.Append for the nodes without one yet, and is kept by copies of the node.
+// It is empty for the nodes of other documents and the nodes created by the program.
+func (n *Node) Source() string {
+	return n.source
+}
+
+// SetLeadingComment replaces the comments before this Node with the text, written as line comments.
+// Each line of the text gets a "// " comment of its own, so that no text can end the comments early.
+// Characters that cannot appear in a document are replaced with U+FFFD.
+func (n *Node) SetLeadingComment(text string) {
+	n.LeadingComments = lineComments(text)
+}
+
+// AddLeadingComment adds the text to the comments before this Node, after the ones it already has,
+// like SetLeadingComment does.
+func (n *Node) AddLeadingComment(text string) {
+	n.LeadingComments = append(n.LeadingComments, lineComments(text)...)
+}
+
+// SetTrailingComment replaces the comment after this Node with the text, written as a line comment
+// on the line of the node. The line breaks in the text are replaced with spaces.
+// An empty text removes the comment.
+func (n *Node) SetTrailingComment(text string) {
+	if text == "" {
+		n.TrailingComment = ""
+		return
+	}
+	n.TrailingComment = "// " + strings.Join(commentLines(text), " ")
+}
+
+// lineComments turns text into line comments, one for each of its lines.
+func lineComments(text string) []string {
+	lines := commentLines(text)
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = "//"
+		} else {
+			lines[i] = "// " + line
+		}
+	}
+	return lines
+}
+
+// commentLines splits text into lines at any of the line breaks of KDL,
+// replacing the characters that cannot appear in a comment.
+func commentLines(text string) []string {
+	var lines []string
+	var line strings.Builder
+	for i, ch := range text {
+		switch {
+		case ch == '\n' && i > 0 && text[i-1] == '\r':
+			continue
+		case isNewLine(ch):
+			lines = append(lines, line.String())
+			line.Reset()
+		case isDisallowedChar(ch):
+			line.WriteRune(utf8.RuneError)
+		default:
+			line.WriteRune(ch)
+		}
+	}
+	return append(lines, line.String())
+}
+
 // AddArg adds an element as an order-sensitive argument of this Node.
 func (n *Node) AddArg(arg interface{}) error {
 	v, err := ValueOf(arg)
@@ -26,16 +146,218 @@ func (n *Node) AddArg(arg interface{}) error {
 	return nil
 }
 
+// AddArgs adds elements as order-sensitive arguments of this Node, converting them with ValueOf.
+// If any of them cannot be converted, none are added.
+func (n *Node) AddArgs(args ...interface{}) error {
+	values := make([]Value, len(args))
+	for i, arg := range args {
+		v, err := ValueOf(arg)
+		if err != nil {
+			return fmt.Errorf("argument %d: %w", i, err)
+		}
+		values[i] = v
+	}
+	n.AppendArgs(values...)
+	return nil
+}
+
 // AddArgValue adds a Value as an order-sensitive argument of this Node.
 func (n *Node) AddArgValue(arg Value) {
 	n.Args = append(n.Args, arg)
 }
 
+// AppendArgs adds Values as order-sensitive arguments of this Node, after the existing ones.
+func (n *Node) AppendArgs(args ...Value) {
+	n.Args = append(n.Args, args...)
+}
+
+// SetArg replaces the argument at index i with the Value, as is, including its type hint.
+// Unlike Arg, it does not accept negative indexes: if i is out of range, ErrArgIndex is returned.
+func (n *Node) SetArg(i int, arg Value) error {
+	if i < 0 || i >= len(n.Args) {
+		return argIndexError(n, i)
+	}
+	n.Args[i] = arg
+	return nil
+}
+
+// InsertArg inserts a Value as the argument at index i, shifting the arguments from i onwards.
+// i can be from 0 to len(n.Args), both inclusive. Otherwise, ErrArgIndex is returned.
+func (n *Node) InsertArg(i int, arg Value) error {
+	if i < 0 || i > len(n.Args) {
+		return argIndexError(n, i)
+	}
+	n.Args = slices.Insert(n.Args, i, arg)
+	return nil
+}
+
+func argIndexError(n *Node, i int) error {
+	return fmt.Errorf("%w: %d, node %q has %d arguments", ErrArgIndex, i, string(n.Name), len(n.Args))
+}
+
+// Arg returns the argument of this Node at index i, if it has one.
+// A negative index counts from the end, so -1 is the last argument.
+// Unlike the typed getters, it returns arguments set to null too.
+func (n *Node) Arg(i int) (Value, bool) {
+	if i < 0 {
+		i += len(n.Args)
+	}
+	if i < 0 || i >= len(n.Args) {
+		return newInvalidValue(), false
+	}
+	return n.Args[i], true
+}
+
+// ArgString returns the argument at index i, as in Arg, if it is a string.
+func (n *Node) ArgString(i int) (string, bool) {
+	v, _ := n.Arg(i)
+	return v.asString()
+}
+
+// ArgInt returns the argument at index i, as in Arg, if it is an integer that fits in an int64.
+// Floats are not accepted, even if they are whole, e.g. 1.0.
+func (n *Node) ArgInt(i int) (int64, bool) {
+	v, _ := n.Arg(i)
+	return v.asInt64()
+}
+
+// ArgFloat returns the argument at index i, as in Arg, as the nearest float64, if it is a number.
+// Integers are accepted too.
+func (n *Node) ArgFloat(i int) (float64, bool) {
+	v, _ := n.Arg(i)
+	return v.asFloat64()
+}
+
+// ArgBool returns the argument at index i, as in Arg, if it is a boolean.
+func (n *Node) ArgBool(i int) (bool, bool) {
+	v, _ := n.Arg(i)
+	return v.asBool()
+}
+
+// FirstArgString is a shortcut for ArgString(0).
+func (n *Node) FirstArgString() (string, bool) {
+	return n.ArgString(0)
+}
+
+// FirstArgInt is a shortcut for ArgInt(0).
+func (n *Node) FirstArgInt() (int64, bool) {
+	return n.ArgInt(0)
+}
+
+// FirstArgFloat is a shortcut for ArgFloat(0).
+func (n *Node) FirstArgFloat() (float64, bool) {
+	return n.ArgFloat(0)
+}
+
+// FirstArgBool is a shortcut for ArgBool(0).
+func (n *Node) FirstArgBool() (bool, bool) {
+	return n.ArgBool(0)
+}
+
 // AddChild adds another Node as an order-sensitive child of this Node.
 func (n *Node) AddChild(child Node) {
 	n.Children = append(n.Children, child)
 }
 
+// AddChildren adds nodes as children of this Node, after the existing ones.
+func (n *Node) AddChildren(children ...Node) {
+	n.Children = append(n.Children, children...)
+}
+
+// InsertChild inserts a child at index i, shifting the children from i onwards.
+// It panics if i is out of the range from 0 to len(n.Children), both inclusive, like slice indexing does.
+func (n *Node) InsertChild(i int, child Node) {
+	n.Children = insertNode(n.Children, i, child)
+}
+
+// InsertChildBefore inserts a child just before the first child with the name.
+// It returns false, without inserting the child, if there is no child with the name.
+func (n *Node) InsertChildBefore(name Identifier, child Node) bool {
+	i := indexOfNode(n.Children, name)
+	if i < 0 {
+		return false
+	}
+	n.InsertChild(i, child)
+	return true
+}
+
+// InsertChildAfter inserts a child just after the first child with the name.
+// It returns false, without inserting the child, if there is no child with the name.
+func (n *Node) InsertChildAfter(name Identifier, child Node) bool {
+	i := indexOfNode(n.Children, name)
+	if i < 0 {
+		return false
+	}
+	n.InsertChild(i+1, child)
+	return true
+}
+
+// SortChildren orders the children of this Node with the less function.
+// The sort is stable: the children that are equal keep their order.
+func (n *Node) SortChildren(less func(a, b *Node) bool) {
+	sortNodes(n.Children, less)
+}
+
+// SortChildrenByName orders the children of this Node by their names, comparing them byte by byte.
+// The children with the same name keep their order.
+func (n *Node) SortChildrenByName() {
+	sortNodes(n.Children, lessByName)
+}
+
+// ReplaceChild replaces the child at index i.
+// It panics if i is out of range, like slice indexing does.
+func (n *Node) ReplaceChild(i int, child Node) {
+	n.Children[i] = child
+}
+
+// Child returns the first child of this Node with the name, if there is one.
+// The child can be modified in place.
+func (n *Node) Child(name Identifier) (*Node, bool) {
+	return findChild(n.Children, name)
+}
+
+// ChildrenNamed returns all the children of this Node with the name, in the order of the document.
+// The children can be modified in place.
+//
+// It is the counterpart of Document.Children, named differently as Node has a Children field.
+func (n *Node) ChildrenNamed(name Identifier) NodeSet {
+	return findChildren(n.Children, name)
+}
+
+// Clone returns a deep copy of this Node, which shares no mutable state with it:
+// the arguments, properties and children are all copied.
+func (n *Node) Clone() Node {
+	clone := Node{
+		TypeHint:         n.TypeHint,
+		Name:             n.Name,
+		LeadingComments:  slices.Clone(n.LeadingComments),
+		TrailingComment:  n.TrailingComment,
+		EndComments:      slices.Clone(n.EndComments),
+		BlankLinesBefore: n.BlankLinesBefore,
+		source:           n.source,
+		format:           n.format,
+		position:         n.position,
+		nameQuotes:       n.nameQuotes,
+		hintQuotes:       n.hintQuotes,
+		keyQuotes:        maps.Clone(n.keyQuotes),
+	}
+	if n.Args != nil {
+		clone.Args = make([]Value, len(n.Args))
+		for i, arg := range n.Args {
+			clone.Args[i] = arg.Clone()
+		}
+	}
+	if n.Props != nil {
+		clone.Props = make(map[Identifier]Value, len(n.Props))
+		for key, value := range n.Props {
+			clone.Props[key] = value.Clone()
+		}
+		clone.propOrder = slices.Clone(n.propOrder)
+	}
+	clone.Children = cloneNodes(n.Children)
+	return clone
+}
+
 // GetProp returns a property of this Node.
 func (n *Node) GetProp(key Identifier) Value {
 	props := n.Props
@@ -58,6 +380,38 @@ func (n *Node) HasProp(key Identifier) bool {
 	return val.Type != TypeInvalid
 }
 
+// PropValue returns a property of this Node, if it has one.
+// Unlike the typed getters, it returns properties set to null too.
+func (n *Node) PropValue(key Identifier) (Value, bool) {
+	v, ok := n.Props[key]
+	if !ok || v.Type == TypeInvalid {
+		return newInvalidValue(), false
+	}
+	return v, true
+}
+
+// PropString returns a property of this Node, if it is a string.
+func (n *Node) PropString(key Identifier) (string, bool) {
+	return n.Props[key].asString()
+}
+
+// PropInt returns a property of this Node, if it is an integer that fits in an int64.
+// Floats are not accepted, even if they are whole, e.g. 1.0.
+func (n *Node) PropInt(key Identifier) (int64, bool) {
+	return n.Props[key].asInt64()
+}
+
+// PropFloat returns a property of this Node as the nearest float64, if it is a number.
+// Integers are accepted too.
+func (n *Node) PropFloat(key Identifier) (float64, bool) {
+	return n.Props[key].asFloat64()
+}
+
+// PropBool returns a property of this Node, if it is a boolean.
+func (n *Node) PropBool(key Identifier) (bool, bool) {
+	return n.Props[key].asBool()
+}
+
 // SetProp sets or replaces a property of this Node.
 func (n *Node) SetProp(key Identifier, value interface{}) error {
 
@@ -70,21 +424,142 @@ func (n *Node) SetProp(key Identifier, value interface{}) error {
 	return nil
 }
 
+// SetProps sets or replaces properties of this Node, converting them with ValueOf.
+// The new properties are added sorted by their names, so that the order does not depend on the order of the map.
+// If any of them cannot be converted, none are set.
+func (n *Node) SetProps(props map[Identifier]interface{}) error {
+	keys := maps.Keys(props)
+	slices.Sort(keys)
+	values := make([]Value, len(keys))
+	for i, key := range keys {
+		v, err := ValueOf(props[key])
+		if err != nil {
+			return fmt.Errorf("property %q: %w", string(key), err)
+		}
+		values[i] = v
+	}
+	for i, key := range keys {
+		n.SetPropValue(key, values[i])
+	}
+	return nil
+}
+
 // SetPropValue sets or replaces a property of this Node.
+// A new property goes after the existing ones, while a replaced one keeps its place.
 func (n *Node) SetPropValue(key Identifier, value Value) {
 	props := n.Props
-	if props != nil {
-		props[key] = value
-	} else {
-		n.Props = map[Identifier]Value{key: value}
+	if props == nil {
+		props = make(map[Identifier]Value)
+		n.Props = props
+	}
+	if _, exists := props[key]; !exists {
+		// The key can be left over in the order if it has been deleted from Props directly
+		if len(n.propOrder) != len(props) {
+			if i := slices.Index(n.propOrder, key); i >= 0 {
+				n.propOrder = slices.Delete(n.propOrder, i, i+1)
+			}
+		}
+		n.propOrder = append(n.propOrder, key)
 	}
+	props[key] = value
 }
 
 // RemoveProp removes a property from this Node.
-func (n *Node) RemoveProp(key Identifier) {
-	props := n.Props
-	if props == nil {
-		return
+// It returns false if there has been no such property.
+func (n *Node) RemoveProp(key Identifier) bool {
+	found := n.HasProp(key)
+	delete(n.Props, key)
+	delete(n.keyQuotes, key)
+	if i := slices.Index(n.propOrder, key); i >= 0 {
+		n.propOrder = slices.Delete(n.propOrder, i, i+1)
+	}
+	return found
+}
+
+// SortProps orders the properties of this Node by their names, e.g. before writing it.
+func (n *Node) SortProps() {
+	keys := n.orderedPropKeys()
+	slices.Sort(keys)
+	n.propOrder = keys
+}
+
+// PropKeys returns the names of the properties of this Node, in order:
+// the properties read from a document or set with SetProp come in the order they have been set in,
+// and are followed by the ones added to Props directly, sorted by their names.
+func (n *Node) PropKeys() []Identifier {
+	keys := n.orderedPropKeys()
+	return slices.DeleteFunc(keys, func(key Identifier) bool { return n.Props[key].Type == TypeInvalid })
+}
+
+// orderedPropKeys returns the keys of Props, including the ones in an invalid state, in the order of PropKeys.
+func (n *Node) orderedPropKeys() []Identifier {
+	if len(n.Props) == 0 {
+		return nil
+	}
+	keys := make([]Identifier, 0, len(n.Props))
+	seen := make(map[Identifier]struct{}, len(n.Props))
+	for _, key := range n.propOrder {
+		if _, ok := n.Props[key]; !ok {
+			continue
+		}
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == len(n.Props) {
+		return keys
+	}
+	// Keys added to the map directly
+	ordered := len(keys)
+	for key := range n.Props {
+		if _, ok := seen[key]; !ok {
+			keys = append(keys, key)
+		}
 	}
-	delete(props, key)
+	slices.Sort(keys[ordered:])
+	return keys
+}
+
+// RemoveArg removes the argument at index i, as in Arg, shifting the next arguments back.
+// It returns false if there has been no such argument.
+func (n *Node) RemoveArg(i int) bool {
+	if i < 0 {
+		i += len(n.Args)
+	}
+	if i < 0 || i >= len(n.Args) {
+		return false
+	}
+	last := len(n.Args) - 1
+	copy(n.Args[i:], n.Args[i+1:])
+	n.Args[last] = Value{}
+	n.Args = n.Args[:last]
+	return true
+}
+
+// RemoveChild removes the child at index i, shifting the next children back.
+// A negative index counts from the end, so -1 is the last child.
+// It returns false if there has been no such child.
+//
+// Pointers to the children after the removed one, e.g. from Child, point to their new neighbours afterwards.
+func (n *Node) RemoveChild(i int) bool {
+	if i < 0 {
+		i += len(n.Children)
+	}
+	if i < 0 || i >= len(n.Children) {
+		return false
+	}
+	last := len(n.Children) - 1
+	copy(n.Children[i:], n.Children[i+1:])
+	n.Children[last] = Node{}
+	n.Children = n.Children[:last]
+	return true
+}
+
+// RemoveChildren removes all the children with the name, keeping the order of the rest.
+// It returns how many have been removed.
+func (n *Node) RemoveChildren(name Identifier) int {
+	var removed int
+	n.Children, removed = removeNodes(n.Children, name)
+	return removed
 }
@@ -1,6 +1,7 @@
 package kdl
 
 import (
+	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,3 +17,538 @@ func TestProp(t *testing.T) {
 	n.RemoveProp("bar")
 	assert.False(t, n.HasProp("bar"))
 }
+
+func TestNodeChild(t *testing.T) {
+	doc, err := ParseString("parent {\n\tdup 1\n\tother\n\tdup 2 {\n\t\tdup 3\n\t}\n\t\"\" 4\n}\n")
+	assert.NoError(t, err)
+	parent := &doc.Nodes[0]
+
+	// The first one of the duplicates
+	dup, ok := parent.Child("dup")
+	if assert.True(t, ok) {
+		assert.Equal(t, int64(1), dup.Args[0].IntegerValue().Int64())
+		dup.Args[0] = NewStringValue("changed", NoHint())
+		assert.Equal(t, "changed", doc.Nodes[0].Children[0].Args[0].StringValue())
+	}
+
+	empty, ok := parent.Child("")
+	if assert.True(t, ok) {
+		assert.Equal(t, int64(4), empty.Args[0].IntegerValue().Int64())
+	}
+
+	for _, name := range []Identifier{"missing", "DUP", "parent"} {
+		child, ok := parent.Child(name)
+		assert.False(t, ok, name)
+		assert.Nil(t, child, name)
+	}
+
+	leaf := NewNode("leaf")
+	_, ok = leaf.Child("dup")
+	assert.False(t, ok)
+}
+
+func TestNodeChildrenNamed(t *testing.T) {
+	doc, err := ParseString("parent {\n\tdup 1\n\tother\n\tdup 2 {\n\t\tdup 3\n\t}\n}\n")
+	assert.NoError(t, err)
+	parent := &doc.Nodes[0]
+
+	// Only the direct children, in the order of the document
+	dups := parent.ChildrenNamed("dup")
+	if assert.Len(t, dups, 2) {
+		assert.Equal(t, int64(1), dups[0].Args[0].IntegerValue().Int64())
+		assert.Equal(t, int64(2), dups[1].Args[0].IntegerValue().Int64())
+		assert.Len(t, dups[1].ChildrenNamed("dup"), 1)
+
+		dups[1].AddChild(NewNode("added"))
+		assert.Len(t, doc.Nodes[0].Children[2].Children, 2)
+	}
+
+	assert.Empty(t, parent.ChildrenNamed(""))
+	assert.Empty(t, parent.ChildrenNamed("Dup"))
+}
+
+func TestNodeTypedProps(t *testing.T) {
+	doc, err := ParseString("n \"null\"=null bool=true string=\"1\" int=2 big=9223372036854775808 float=1.5 whole=3.0\n")
+	assert.NoError(t, err)
+	n := &doc.Nodes[0]
+
+	type result struct {
+		value  any
+		exists bool
+	}
+	get := map[string]func(Identifier) result{
+		"PropValue": func(k Identifier) result {
+			v, ok := n.PropValue(k)
+			return result{v.Type, ok}
+		},
+		"PropString": func(k Identifier) result { v, ok := n.PropString(k); return result{v, ok} },
+		"PropInt":    func(k Identifier) result { v, ok := n.PropInt(k); return result{v, ok} },
+		"PropFloat":  func(k Identifier) result { v, ok := n.PropFloat(k); return result{v, ok} },
+		"PropBool":   func(k Identifier) result { v, ok := n.PropBool(k); return result{v, ok} },
+	}
+	expected := map[Identifier]map[string]result{
+		"null": {
+			"PropValue": {TypeNull, true}, "PropString": {"", false}, "PropInt": {int64(0), false},
+			"PropFloat": {0.0, false}, "PropBool": {false, false},
+		},
+		"bool": {
+			"PropValue": {TypeBool, true}, "PropString": {"", false}, "PropInt": {int64(0), false},
+			"PropFloat": {0.0, false}, "PropBool": {true, true},
+		},
+		"string": {
+			"PropValue": {TypeString, true}, "PropString": {"1", true}, "PropInt": {int64(0), false},
+			"PropFloat": {0.0, false}, "PropBool": {false, false},
+		},
+		"int": {
+			"PropValue": {TypeInteger, true}, "PropString": {"", false}, "PropInt": {int64(2), true},
+			"PropFloat": {2.0, true}, "PropBool": {false, false},
+		},
+		"big": {
+			"PropValue": {TypeInteger, true}, "PropString": {"", false}, "PropInt": {int64(0), false},
+			"PropFloat": {9223372036854775808.0, true}, "PropBool": {false, false},
+		},
+		"float": {
+			"PropValue": {TypeFloat, true}, "PropString": {"", false}, "PropInt": {int64(0), false},
+			"PropFloat": {1.5, true}, "PropBool": {false, false},
+		},
+		"whole": {
+			"PropValue": {TypeFloat, true}, "PropString": {"", false}, "PropInt": {int64(0), false},
+			"PropFloat": {3.0, true}, "PropBool": {false, false},
+		},
+		"missing": {
+			"PropValue": {TypeInvalid, false}, "PropString": {"", false}, "PropInt": {int64(0), false},
+			"PropFloat": {0.0, false}, "PropBool": {false, false},
+		},
+	}
+	for key, byGetter := range expected {
+		for getter, want := range byGetter {
+			assert.Equal(t, want, get[getter](key), "%s(%q)", getter, key)
+		}
+	}
+}
+
+func TestNodeArgs(t *testing.T) {
+	doc, err := ParseString("title \"Hello\" 42 1.5 true null\n")
+	assert.NoError(t, err)
+	n := &doc.Nodes[0]
+
+	s, ok := n.ArgString(0)
+	assert.True(t, ok)
+	assert.Equal(t, "Hello", s)
+	s, ok = n.FirstArgString()
+	assert.True(t, ok)
+	assert.Equal(t, "Hello", s)
+	_, ok = n.ArgString(1)
+	assert.False(t, ok)
+
+	i, ok := n.ArgInt(1)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), i)
+	_, ok = n.ArgInt(2)
+	assert.False(t, ok)
+	_, ok = n.FirstArgInt()
+	assert.False(t, ok)
+
+	f, ok := n.ArgFloat(1)
+	assert.True(t, ok)
+	assert.Equal(t, 42.0, f)
+	f, ok = n.ArgFloat(2)
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, f)
+	_, ok = n.FirstArgFloat()
+	assert.False(t, ok)
+
+	b, ok := n.ArgBool(3)
+	assert.True(t, ok)
+	assert.True(t, b)
+	_, ok = n.FirstArgBool()
+	assert.False(t, ok)
+
+	// Null is an argument, but not of any of the typed kinds
+	v, ok := n.Arg(4)
+	assert.True(t, ok)
+	assert.Equal(t, TypeNull, v.Type)
+	_, ok = n.ArgBool(4)
+	assert.False(t, ok)
+	_, ok = n.ArgString(4)
+	assert.False(t, ok)
+}
+
+func TestNodeArgIndexes(t *testing.T) {
+	doc, err := ParseString("n 0 1 2\nempty\n")
+	assert.NoError(t, err)
+	n := &doc.Nodes[0]
+
+	for i, want := range map[int]int64{0: 0, 2: 2, -1: 2, -3: 0} {
+		got, ok := n.ArgInt(i)
+		assert.True(t, ok, i)
+		assert.Equal(t, want, got, i)
+	}
+	for _, i := range []int{3, -4, 100, -100} {
+		v, ok := n.Arg(i)
+		assert.False(t, ok, i)
+		assert.Equal(t, TypeInvalid, v.Type, i)
+		_, ok = n.ArgInt(i)
+		assert.False(t, ok, i)
+	}
+
+	empty := &doc.Nodes[1]
+	for _, i := range []int{0, -1} {
+		_, ok := empty.Arg(i)
+		assert.False(t, ok, i)
+	}
+	_, ok := empty.FirstArgString()
+	assert.False(t, ok)
+}
+
+func TestNodeRemoveProp(t *testing.T) {
+	n := NewNode("node")
+	assert.False(t, n.RemoveProp("missing"))
+
+	n.SetProp("a", 1)
+	n.SetProp("b", 2)
+	assert.True(t, n.RemoveProp("a"))
+	assert.False(t, n.RemoveProp("a"))
+	assert.False(t, n.HasProp("a"))
+	assert.True(t, n.HasProp("b"))
+	assert.True(t, n.RemoveProp("b"))
+	assert.Empty(t, n.Props)
+}
+
+func TestNodeRemoveArg(t *testing.T) {
+	doc, err := ParseString("node 0 1 2 3\n")
+	assert.NoError(t, err)
+	n := &doc.Nodes[0]
+	args := n.Args[:4]
+	ints := func() []int64 {
+		var out []int64
+		for i := range n.Args {
+			v, _ := n.ArgInt(i)
+			out = append(out, v)
+		}
+		return out
+	}
+
+	assert.False(t, n.RemoveArg(4))
+	assert.False(t, n.RemoveArg(-5))
+	assert.Equal(t, []int64{0, 1, 2, 3}, ints())
+
+	// The first one, with the vacated end cleared
+	assert.True(t, n.RemoveArg(0))
+	assert.Equal(t, []int64{1, 2, 3}, ints())
+	assert.Equal(t, TypeInvalid, args[3].Type)
+
+	// The last one
+	assert.True(t, n.RemoveArg(-1))
+	assert.Equal(t, []int64{1, 2}, ints())
+	assert.True(t, n.RemoveArg(1))
+	assert.Equal(t, []int64{1}, ints())
+
+	// The only one
+	assert.True(t, n.RemoveArg(0))
+	assert.Empty(t, n.Args)
+	assert.False(t, n.RemoveArg(0))
+	assert.False(t, n.RemoveArg(-1))
+}
+
+// childNames lists the names of the nodes in order.
+func childNames(nodes []Node) []Identifier {
+	names := []Identifier{}
+	for _, n := range nodes {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+func TestNodeRemoveChild(t *testing.T) {
+	doc, err := ParseString("parent {\n\ta\n\tb\n\tc\n\td\n}\n")
+	assert.NoError(t, err)
+	parent := &doc.Nodes[0]
+	children := parent.Children[:4]
+
+	assert.False(t, parent.RemoveChild(4))
+	assert.False(t, parent.RemoveChild(-5))
+
+	assert.True(t, parent.RemoveChild(0))
+	assert.Equal(t, []Identifier{"b", "c", "d"}, childNames(parent.Children))
+	assert.Equal(t, Identifier(""), children[3].Name)
+
+	assert.True(t, parent.RemoveChild(-1))
+	assert.Equal(t, []Identifier{"b", "c"}, childNames(parent.Children))
+	assert.True(t, parent.RemoveChild(1))
+	assert.Equal(t, []Identifier{"b"}, childNames(parent.Children))
+
+	assert.True(t, parent.RemoveChild(0))
+	assert.Empty(t, parent.Children)
+	assert.False(t, parent.RemoveChild(0))
+}
+
+func TestNodeRemoveChildren(t *testing.T) {
+	doc, err := ParseString("parent {\n\tdebug\n\ta\n\tdebug\n\tb\n\tdebug {\n\t\tc\n\t}\n}\n")
+	assert.NoError(t, err)
+	parent := &doc.Nodes[0]
+	children := parent.Children[:5]
+
+	assert.Equal(t, 0, parent.RemoveChildren("missing"))
+	assert.Equal(t, 3, parent.RemoveChildren("debug"))
+	assert.Equal(t, []Identifier{"a", "b"}, childNames(parent.Children))
+	for _, vacated := range children[2:] {
+		assert.Equal(t, Identifier(""), vacated.Name)
+		assert.Nil(t, vacated.Children)
+	}
+	assert.Equal(t, 0, parent.RemoveChildren("debug"))
+
+	assert.Equal(t, 1, parent.RemoveChildren("b"))
+	assert.Equal(t, 1, parent.RemoveChildren("a"))
+	assert.Empty(t, parent.Children)
+
+	leaf := NewNode("leaf")
+	assert.Equal(t, 0, leaf.RemoveChildren("a"))
+}
+
+func TestNodeInsertAndReplaceChildren(t *testing.T) {
+	doc, err := ParseString("parent {\n\tb\n\td\n}\n")
+	assert.NoError(t, err)
+	parent := &doc.Nodes[0]
+
+	parent.InsertChild(0, NewNode("a"))
+	parent.InsertChild(len(parent.Children), NewNode("f"))
+	assert.True(t, parent.InsertChildBefore("d", NewNode("c")))
+	assert.True(t, parent.InsertChildAfter("d", NewNode("e")))
+	assert.False(t, parent.InsertChildBefore("missing", NewNode("x")))
+	assert.False(t, parent.InsertChildAfter("missing", NewNode("x")))
+	assert.Equal(t, []Identifier{"a", "b", "c", "d", "e", "f"}, childNames(parent.Children))
+
+	replacement := NewNode("B")
+	replacement.AddArg(1)
+	parent.ReplaceChild(1, replacement)
+
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, "parent {\n    a\n    B 1\n    c\n    d\n    e\n    f\n}\n", written)
+	reparsed, err := ParseString(written)
+	assert.NoError(t, err)
+	assert.True(t, doc.Equal(&reparsed))
+
+	// Only the first child with the name is a target
+	dup := NewNode("dup")
+	dup.AddChild(NewNode("x"))
+	dup.AddChild(NewNode("x"))
+	assert.True(t, dup.InsertChildAfter("x", NewNode("y")))
+	assert.Equal(t, []Identifier{"x", "y", "x"}, childNames(dup.Children))
+
+	leaf := NewNode("leaf")
+	leaf.InsertChild(0, NewNode("only"))
+	assert.Equal(t, []Identifier{"only"}, childNames(leaf.Children))
+
+	assert.PanicsWithValue(t, "kdl: index 2 out of range for 1 nodes", func() { leaf.InsertChild(2, NewNode("x")) })
+	assert.Panics(t, func() { leaf.InsertChild(-1, NewNode("x")) })
+	assert.Panics(t, func() { leaf.ReplaceChild(1, NewNode("x")) })
+	assert.Panics(t, func() { leaf.ReplaceChild(-1, NewNode("x")) })
+}
+
+func TestNodeArgMutation(t *testing.T) {
+	doc, err := ParseString("node 1 (u8)2 3\n")
+	assert.NoError(t, err)
+	n := &doc.Nodes[0]
+
+	// The new Value is taken as is, without the type hint of the old one
+	assert.NoError(t, n.SetArg(1, NewStringValue("two", NoHint())))
+	assert.NoError(t, n.SetArg(2, NewStringValue("three", Hint("t"))))
+	assert.NoError(t, n.InsertArg(0, NewIntegerValue(big.NewInt(0), NoHint())))
+	assert.NoError(t, n.InsertArg(4, NewBoolValue(true, NoHint())))
+	assert.NoError(t, n.InsertArg(2, NewNullValue(NoHint())))
+	n.AppendArgs(NewStringValue("a", NoHint()), NewStringValue("b", NoHint()))
+	n.AppendArgs()
+
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, "node 0 1 null \"two\" (t)\"three\" true \"a\" \"b\"\n", written)
+
+	for _, i := range []int{-1, 8, 100} {
+		err := n.SetArg(i, NewNullValue(NoHint()))
+		assert.ErrorIs(t, err, ErrArgIndex, i)
+	}
+	for _, i := range []int{-1, 9} {
+		err := n.InsertArg(i, NewNullValue(NoHint()))
+		assert.ErrorIs(t, err, ErrArgIndex, i)
+	}
+	assert.EqualError(t, n.SetArg(-1, NewNullValue(NoHint())), `argument index out of range: -1, node "node" has 8 arguments`)
+	assert.Len(t, n.Args, 8)
+
+	empty := NewNode("empty")
+	assert.ErrorIs(t, empty.SetArg(0, NewNullValue(NoHint())), ErrArgIndex)
+	assert.NoError(t, empty.InsertArg(0, NewNullValue(NoHint())))
+	assert.Len(t, empty.Args, 1)
+}
+
+func TestNodePropOrder(t *testing.T) {
+	n := NewNode("node")
+	assert.Empty(t, n.PropKeys())
+
+	n.SetProp("width", 1)
+	n.SetProp("height", 2)
+	n.SetProp("depth", 3)
+	assert.Equal(t, []Identifier{"width", "height", "depth"}, n.PropKeys())
+
+	// Replacing keeps the place, removing and setting again moves to the end
+	n.SetProp("width", 10)
+	assert.Equal(t, []Identifier{"width", "height", "depth"}, n.PropKeys())
+	assert.True(t, n.RemoveProp("width"))
+	assert.Equal(t, []Identifier{"height", "depth"}, n.PropKeys())
+	n.SetProp("width", 1)
+	assert.Equal(t, []Identifier{"height", "depth", "width"}, n.PropKeys())
+
+	// Properties added to the map directly go last, sorted
+	n.Props["b"] = NewNullValue(NoHint())
+	n.Props["a"] = NewNullValue(NoHint())
+	delete(n.Props, "depth")
+	n.Props["gone"] = newInvalidValue()
+	assert.Equal(t, []Identifier{"height", "width", "a", "b"}, n.PropKeys())
+	n.SetProp("depth", 3)
+	assert.Equal(t, []Identifier{"height", "width", "depth", "a", "b"}, n.PropKeys())
+
+	clone := n.Clone()
+	clone.SetProp("z", 1)
+	assert.Equal(t, []Identifier{"height", "width", "depth", "a", "b"}, n.PropKeys())
+	assert.Equal(t, []Identifier{"height", "width", "depth", "z", "a", "b"}, clone.PropKeys())
+
+	literal := Node{Name: "literal", Props: map[Identifier]Value{"y": NewNullValue(NoHint()), "x": NewNullValue(NoHint())}}
+	assert.Equal(t, []Identifier{"x", "y"}, literal.PropKeys())
+}
+
+func TestNodeSortChildren(t *testing.T) {
+	doc, err := ParseString("parent {\n\tc 1\n\ta 1\n\tb\n\ta 2\n\tc 2\n}\n")
+	assert.NoError(t, err)
+	parent := &doc.Nodes[0]
+
+	// Ties keep their order
+	parent.SortChildrenByName()
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, "parent {\n    a 1\n    a 2\n    b\n    c 1\n    c 2\n}\n", written)
+
+	// Already sorted
+	parent.SortChildrenByName()
+	again, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, written, again)
+
+	// By the number of arguments, descending
+	parent.SortChildren(func(a, b *Node) bool { return len(a.Args) > len(b.Args) })
+	assert.Equal(t, []Identifier{"a", "a", "c", "c", "b"}, childNames(parent.Children))
+
+	leaf := NewNode("leaf")
+	leaf.SortChildrenByName()
+	assert.Empty(t, leaf.Children)
+}
+
+func TestNodeSortProps(t *testing.T) {
+	doc, err := ParseString("node c=3 a=1 b=2 {\n\tchild z=1 y=2\n}\n")
+	assert.NoError(t, err)
+	n := &doc.Nodes[0]
+
+	n.SortProps()
+	assert.Equal(t, []Identifier{"a", "b", "c"}, n.PropKeys())
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	// Only the properties of the node itself
+	assert.Equal(t, "node a=1 b=2 c=3 {\n    child z=1 y=2\n}\n", written)
+
+	// Already sorted, then with keys added in different ways
+	n.SortProps()
+	assert.Equal(t, []Identifier{"a", "b", "c"}, n.PropKeys())
+	n.SetProp("0", 0)
+	n.Props["aa"] = NewNullValue(NoHint())
+	assert.Equal(t, []Identifier{"a", "b", "c", "0", "aa"}, n.PropKeys())
+	n.SortProps()
+	assert.Equal(t, []Identifier{"0", "a", "aa", "b", "c"}, n.PropKeys())
+	n.SetProp("1", 1)
+	assert.Equal(t, []Identifier{"0", "a", "aa", "b", "c", "1"}, n.PropKeys())
+
+	empty := NewNode("empty")
+	empty.SortProps()
+	assert.Empty(t, empty.PropKeys())
+}
+
+func TestNodeBulkConstruction(t *testing.T) {
+	n := NewNode("node")
+	assert.NoError(t, n.AddArgs("s", 1, uint8(2), 1.5, true, nil, []byte("hi!")))
+	assert.NoError(t, n.AddArgs())
+	assert.NoError(t, n.SetProps(map[Identifier]interface{}{"z": 1, "a": "x", "m": NewNullValue(Hint("t"))}))
+	n.AddChildren(NewNode("a"), NewNode("b"))
+	n.AddChildren()
+
+	doc := Document{Nodes: []Node{n}}
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, "node \"s\" 1 2 1.5 true null (base64)\"aGkh\" a=\"x\" m=(t)null z=1 {\n    a\n    b\n}\n", written)
+
+	// Nothing is added if any of the values is not supported
+	err = n.AddArgs(1, struct{}{})
+	assert.ErrorIs(t, err, ErrInvalidValueType)
+	assert.EqualError(t, err, "argument 1: "+ErrInvalidValueType.Error())
+	assert.Len(t, n.Args, 7)
+	err = n.SetProps(map[Identifier]interface{}{"new": 1, "bad": struct{}{}})
+	assert.ErrorIs(t, err, ErrInvalidValueType)
+	assert.False(t, n.HasProp("new"))
+}
+
+func TestNodeRename(t *testing.T) {
+	doc := NewDocument()
+	doc.AddChild(NewNode("old"))
+	n := &doc.Nodes[0]
+
+	cases := map[Identifier]string{
+		"new":        "new\n",
+		"with space": "\"with space\"\n",
+		"true":       "\"true\"\n",
+		"1st":        "\"1st\"\n",
+		"tab\there":  "\"tab\\there\"\n",
+		"bell\b":     "\"bell\\b\"\n",
+		// The empty name is valid, if quoted
+		"": "\"\"\n",
+	}
+	for name, want := range cases {
+		assert.NoError(t, n.Rename(name), name)
+		assert.Equal(t, name, n.Name)
+		assert.Equal(t, want, doc.String())
+
+		parsed, err := ParseString(doc.String())
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, name, parsed.Nodes[0].Name)
+		}
+	}
+
+	before := n.Name
+	for _, name := range []Identifier{"nul\x00", "\xff", "bom\ufeff", "rtl\u202e"} {
+		assert.ErrorIs(t, n.Rename(name), ErrInvalidName, name)
+		assert.Equal(t, before, n.Name)
+	}
+}
+
+func TestNodeComments(t *testing.T) {
+	doc := NewDocument()
+	a := NewNode("a")
+	a.SetLeadingComment("First line\r\nsecond */ line\n\nbell\b")
+	a.AddLeadingComment("added")
+	a.SetTrailingComment("trailing\nnote /* */")
+	b := NewNode("b")
+	b.SetTrailingComment("to be removed")
+	b.SetTrailingComment("")
+	a.AddChild(b)
+	doc.AddChild(a)
+
+	assert.Equal(t, []string{"// First line", "// second */ line", "//", "// bell�", "// added"}, a.LeadingComments)
+	assert.Equal(t, "// trailing note /* */", a.TrailingComment)
+	assert.Empty(t, b.TrailingComment)
+	const want = "// First line\n// second */ line\n//\n// bell�\n// added\na {\n    b\n} // trailing note /* */\n"
+	assert.Equal(t, want, doc.String())
+
+	parsed, err := ParseString(doc.String(), WithComments(true))
+	if assert.NoError(t, err) {
+		assert.Equal(t, a.LeadingComments, parsed.Nodes[0].LeadingComments)
+		assert.Equal(t, a.TrailingComment, parsed.Nodes[0].TrailingComment)
+		assert.Equal(t, want, parsed.String())
+	}
+}
@@ -0,0 +1,77 @@
+package kdl
+
+// NodeSet is a list of nodes, e.g. found in a Document, with methods to narrow it down in a chain:
+//
+//	doc.Children("server").Filter(isEnabled).Children("listen").Args()
+//
+// The nodes are pointers into the tree, so they can be modified in place.
+// All the methods can be called on an empty or nil NodeSet, in which case they return empty results,
+// so that optional parts of a document need no special handling.
+type NodeSet []*Node
+
+// Len returns the number of nodes in the set.
+func (s NodeSet) Len() int {
+	return len(s)
+}
+
+// First returns the first node of the set, if there is one.
+func (s NodeSet) First() (*Node, bool) {
+	if len(s) == 0 {
+		return nil, false
+	}
+	return s[0], true
+}
+
+// Named returns the nodes of the set with the name, in order.
+func (s NodeSet) Named(name Identifier) NodeSet {
+	return s.Filter(func(n *Node) bool { return n.Name == name })
+}
+
+// Filter returns the nodes of the set matching the predicate, in order.
+func (s NodeSet) Filter(pred func(*Node) bool) NodeSet {
+	var filtered NodeSet
+	for _, n := range s {
+		if pred(n) {
+			filtered = append(filtered, n)
+		}
+	}
+	return filtered
+}
+
+// Children returns the children with the name of all the nodes of the set,
+// in the order of the set, then in the order of the document.
+func (s NodeSet) Children(name Identifier) NodeSet {
+	var children NodeSet
+	for _, n := range s {
+		children = append(children, findChildren(n.Children, name)...)
+	}
+	return children
+}
+
+// Args returns the arguments of all the nodes of the set, in order.
+func (s NodeSet) Args() []Value {
+	var args []Value
+	for _, n := range s {
+		args = append(args, n.Args...)
+	}
+	return args
+}
+
+// Props returns the values of the property of the nodes of the set, in order.
+// The nodes without the property are skipped.
+func (s NodeSet) Props(name Identifier) []Value {
+	var values []Value
+	for _, n := range s {
+		if v, ok := n.PropValue(name); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Each calls fn for each of the nodes of the set, in order.
+func (s NodeSet) Each(fn func(*Node)) {
+	for _, n := range s {
+		fn(n)
+	}
+}
@@ -0,0 +1,93 @@
+package kdl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const nodeSetDocument = `server "public" tls=#true {
+	listen 443
+	listen 8443
+}
+server "internal" {
+	listen 8080
+}
+server "legacy" tls=#false {
+	listen 80
+}
+logging level="debug"
+`
+
+func hasTrueProp(name Identifier) func(*Node) bool {
+	return func(n *Node) bool {
+		b, _ := n.PropBool(name)
+		return b
+	}
+}
+
+func ExampleNodeSet() {
+	doc, _ := ParseString(nodeSetDocument, WithVersion(Version2))
+
+	ports := doc.Children("server").Filter(hasTrueProp("tls")).Children("listen").Args()
+	for _, port := range ports {
+		fmt.Println(port.IntegerValue())
+	}
+
+	// Optional sections need no checks
+	fmt.Println(len(doc.Children("metrics").Children("endpoint").Props("path")))
+	// Output:
+	// 443
+	// 8443
+	// 0
+}
+
+func TestNodeSet(t *testing.T) {
+	doc, err := ParseString(nodeSetDocument, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	servers := doc.Children("server")
+	assert.Equal(t, 3, servers.Len())
+
+	first, ok := servers.First()
+	assert.True(t, ok)
+	assert.Same(t, &doc.Nodes[0], first)
+
+	assert.Equal(t, []string{"public", "internal", "legacy"}, stringValues(servers.Args()))
+	assert.Len(t, servers.Props("tls"), 2)
+	assert.Equal(t, 4, servers.Children("listen").Len())
+	assert.Equal(t, 0, servers.Named("logging").Len())
+
+	everything := doc.FindAll(func(*Node) bool { return true })
+	assert.Equal(t, 3, everything.Named("server").Len())
+	assert.Equal(t, "debug", everything.Named("logging").Props("level")[0].StringValue())
+
+	// The nodes are in the tree
+	servers.Each(func(n *Node) { n.SetProp("checked", true) })
+	assert.True(t, doc.Nodes[2].HasProp("checked"))
+	assert.False(t, doc.Nodes[3].HasProp("checked"))
+}
+
+func TestEmptyNodeSet(t *testing.T) {
+	for _, s := range []NodeSet{nil, {}} {
+		assert.Equal(t, 0, s.Len())
+		n, ok := s.First()
+		assert.False(t, ok)
+		assert.Nil(t, n)
+		assert.Empty(t, s.Named("a"))
+		assert.Empty(t, s.Filter(func(*Node) bool { return true }))
+		assert.Empty(t, s.Children("a"))
+		assert.Empty(t, s.Args())
+		assert.Empty(t, s.Props("a"))
+		s.Each(func(*Node) { t.Fail() })
+	}
+}
+
+func stringValues(values []Value) []string {
+	var out []string
+	for _, v := range values {
+		out = append(out, v.StringValue())
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package kdl
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Number is a number kept as the text it has been written as, e.g. "0xFF" or "1_000.5",
+// so that it is converted only when needed and without losing any precision.
+// See ParseOptions.UseNumber.
+type Number string
+
+// String returns the text of the number.
+func (n Number) String() string {
+	return string(n)
+}
+
+// parse converts the text of the number.
+func (n Number) parse() (number, error) {
+
+	r := wrapReader(newBytesReader([]byte(n)))
+	num, err := readNumber(&r)
+	if err == nil && r.offset != len(n) {
+		err = errInvalidNumValue
+	}
+	if err != nil {
+		return number{}, fmt.Errorf("kdl: invalid number %q: %w", string(n), err)
+	}
+
+	return num, nil
+}
+
+// value makes a Value holding the number, checking if it is valid first.
+func (n Number) value() (Value, error) {
+	num, err := n.parse()
+	if err != nil {
+		return newInvalidValue(), err
+	}
+	return Value{Type: num.Type, RawValue: n}, nil
+}
+
+// BigInt returns the number as a big.Int.
+// If the number has a fractional part, ErrNotInteger is returned.
+func (n Number) BigInt() (*big.Int, error) {
+	num, err := n.parse()
+	if err != nil {
+		return nil, err
+	}
+	if num.Type != TypeInteger {
+		return nil, fmt.Errorf("%w: %s", ErrNotInteger, string(n))
+	}
+	return num.Value.(*big.Int), nil
+}
+
+// Int64 returns the number as an int64.
+// If the number does not fit in an int64, ErrIntegerOverflow is returned.
+// If the number has a fractional part, ErrNotInteger is returned.
+func (n Number) Int64() (int64, error) {
+	i, err := n.BigInt()
+	if err != nil {
+		return 0, err
+	}
+	if !i.IsInt64() {
+		return 0, fmt.Errorf("%w: %s does not fit in int64", ErrIntegerOverflow, string(n))
+	}
+	return i.Int64(), nil
+}
+
+// Uint64 returns the number as a uint64.
+// If the number is negative or does not fit in a uint64, ErrIntegerOverflow is returned.
+// If the number has a fractional part, ErrNotInteger is returned.
+func (n Number) Uint64() (uint64, error) {
+	i, err := n.BigInt()
+	if err != nil {
+		return 0, err
+	}
+	if !i.IsUint64() {
+		return 0, fmt.Errorf("%w: %s does not fit in uint64", ErrIntegerOverflow, string(n))
+	}
+	return i.Uint64(), nil
+}
+
+// Float64 returns the number as the nearest float64.
+// If the number is too large, ErrFloatOverflow is returned.
+func (n Number) Float64() (float64, error) {
+	f, err := n.bigFloat()
+	if err != nil {
+		return 0, err
+	}
+	f64, _ := f.Float64()
+	if math.IsInf(f64, 0) {
+		return 0, fmt.Errorf("%w: %s", ErrFloatOverflow, string(n))
+	}
+	return f64, nil
+}
+
+// bigFloat returns the number as a big.Float, even if it is an integer.
+func (n Number) bigFloat() (*big.Float, error) {
+	num, err := n.parse()
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := num.Value.(*big.Int); ok {
+		return new(big.Float).SetInt(i), nil
+	}
+	return num.Value.(*big.Float), nil
+}
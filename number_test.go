@@ -0,0 +1,105 @@
+package kdl
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberInt64Boundaries(t *testing.T) {
+	valid := map[Number]int64{
+		"9223372036854775807":   math.MaxInt64,
+		"-9223372036854775808":  math.MinInt64,
+		"0x7FFF_FFFF_FFFF_FFFF": math.MaxInt64,
+		"-0x8000000000000000":   math.MinInt64,
+	}
+	for n, expected := range valid {
+		i, err := n.Int64()
+		assert.NoError(t, err, n)
+		assert.Equal(t, expected, i, n)
+	}
+
+	for _, n := range []Number{"9223372036854775808", "-9223372036854775809", "0x8000000000000000"} {
+		_, err := n.Int64()
+		assert.ErrorIs(t, err, ErrIntegerOverflow, n)
+		assert.ErrorContains(t, err, string(n))
+	}
+
+	for _, n := range []Number{"1.5", "1.0", "1e3"} {
+		_, err := n.Int64()
+		assert.ErrorIs(t, err, ErrNotInteger, n)
+	}
+}
+
+func TestNumberUint64Boundaries(t *testing.T) {
+	valid := map[Number]uint64{
+		"18446744073709551615":                  math.MaxUint64,
+		"0":                                     0,
+		"-0":                                    0,
+		"0b1" + Number(strings.Repeat("1", 63)): math.MaxUint64,
+	}
+	for n, expected := range valid {
+		u, err := n.Uint64()
+		assert.NoError(t, err, n)
+		assert.Equal(t, expected, u, n)
+	}
+
+	for _, n := range []Number{"18446744073709551616", "-1"} {
+		_, err := n.Uint64()
+		assert.ErrorIs(t, err, ErrIntegerOverflow, n)
+	}
+}
+
+func TestNumberFloat64(t *testing.T) {
+	f, err := Number("1.5e3").Float64()
+	assert.NoError(t, err)
+	assert.Equal(t, 1500.0, f)
+
+	f, err = Number("-0o17").Float64()
+	assert.NoError(t, err)
+	assert.Equal(t, -15.0, f)
+
+	_, err = Number("1" + strings.Repeat("0", 400)).Float64()
+	assert.ErrorIs(t, err, ErrFloatOverflow)
+
+	_, err = Number("1.2.3").Float64()
+	assert.ErrorIs(t, err, ErrInvalidSyntax)
+	_, err = Number("1 2").BigInt()
+	assert.ErrorIs(t, err, ErrInvalidSyntax)
+}
+
+func TestParseUseNumber(t *testing.T) {
+	input := "node 0xFF 3.141592653589793238462643 1_000 1e-3\n"
+	doc, err := ParseString(input, WithNumbers(true))
+	assert.NoError(t, err)
+
+	args := doc.Nodes[0].Args
+	assert.Equal(t, Number("0xFF"), args[0].RawValue)
+	assert.Equal(t, TypeInteger, args[0].Type)
+	assert.Equal(t, int64(255), args[0].IntegerValue().Int64())
+	assert.Equal(t, Number("3.141592653589793238462643"), args[1].RawValue)
+	assert.Equal(t, TypeFloat, args[1].Type)
+	assert.Equal(t, TypeInteger, args[2].Type)
+	assert.Equal(t, TypeFloat, args[3].Type)
+	assert.Equal(t, 0.001, args[3].Float64Value())
+
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, input, written)
+}
+
+func TestValueOfNumber(t *testing.T) {
+	v, err := ValueOf(Number("2.5"))
+	assert.NoError(t, err)
+	assert.Equal(t, TypeFloat, v.Type)
+
+	_, err = ValueOf(Number("abc"))
+	assert.Error(t, err)
+
+	v, err = valueToKDLValue(reflect.ValueOf(Number("7")))
+	assert.NoError(t, err)
+	assert.Equal(t, TypeInteger, v.Type)
+}
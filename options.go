@@ -0,0 +1,284 @@
+package kdl
+
+import (
+	"strconv"
+
+	"golang.org/x/text/encoding"
+)
+
+// ParseOptions configures the behavior of the parser.
+// The zero value is the default configuration.
+type ParseOptions struct {
+	// ErrorSourceContext makes the parser retain the text of the line being read,
+	// so that a ParseError can show the offending line along with a caret.
+	ErrorSourceContext bool
+
+	// AllErrors makes the parser skip over malformed nodes instead of stopping at the first error.
+	// All of the syntax errors are then reported together (see errors.Join),
+	// along with the nodes that have been parsed successfully.
+	AllErrors bool
+
+	// ContinueOnError makes ParseFS go on with the other files when one of them fails to parse,
+	// joining the errors of all of them. Each file still stops at its first error, unless AllErrors is set,
+	// which implies this option.
+	ContinueOnError bool
+
+	// Version is the version of the specification the documents are written against.
+	// Zero means Version1.
+	Version Version
+
+	// DetectVersion makes the parser try the other version of the specification,
+	// if the document fails to parse because of syntax specific to one of the versions, e.g. #true.
+	// The version that has succeeded is reported in Document.Version.
+	//
+	// The whole input is read into memory first. The Decoder and ParsePartial ignore this option.
+	DetectVersion bool
+
+	// MaxDepth limits how deeply children blocks can be nested.
+	// Zero means DefaultMaxDepth, a negative value means no limit.
+	MaxDepth int
+
+	// MaxNodes limits how many nodes a document can have,
+	// counting children and the nodes commented out with a slashdash. Zero means no limit.
+	MaxNodes int
+
+	// MaxInputBytes limits how many bytes of the input the parser can consume,
+	// including whitespace and comments. Zero means no limit.
+	MaxInputBytes int
+
+	// MaxStringLen limits how many bytes a string can have, after escapes have been replaced.
+	// Zero means no limit.
+	//
+	// A string without escapes is rejected as soon as it gets too long, others are measured once read,
+	// so MaxInputBytes is still needed to bound how much of the input is buffered.
+	MaxStringLen int
+
+	// DuplicateProps decides what happens when a node has the same property twice.
+	// The zero value is DuplicatePropsLastWins, as the specification says.
+	DuplicateProps DuplicatePropsPolicy
+
+	// AllowDisallowedChars makes the parser accept the characters that the specification
+	// does not allow to appear literally in a document, e.g. U+202E RIGHT-TO-LEFT OVERRIDE,
+	// reporting each of them to Warn instead of failing.
+	AllowDisallowedChars bool
+
+	// StrictUTF8 makes the parser fail with ErrInvalidEncoding at the first byte that is not valid UTF-8,
+	// e.g. a stray continuation byte or an overlong encoding. Otherwise such bytes are read as U+FFFD,
+	// unless they appear in an identifier.
+	StrictUTF8 bool
+
+	// Encoding is the character encoding of the documents, e.g. charmap.Windows1252.
+	// The input is transcoded to UTF-8 before being parsed, so the offsets refer to the decoded text.
+	// Nil means UTF-8, or UTF-16 if the document starts with its byte order mark.
+	//
+	// A document starting with a byte order mark that the encoding does not decode as one
+	// is rejected with ErrInvalidEncoding.
+	Encoding encoding.Encoding
+
+	// KeepComments makes the parser keep the comments, attached to the nodes around them,
+	// so that they are written back along with the document. See Node.LeadingComments.
+	// Comments cost memory, so they are dropped by default. Slashdash comments are always dropped.
+	KeepComments bool
+
+	// KeepBlankLines makes the parser record how many blank lines there are before each node,
+	// so that the groups of nodes are written back the same way. See Node.BlankLinesBefore.
+	// The blank lines after a comment kept with KeepComments are kept with the comment, see Node.LeadingComments.
+	KeepBlankLines bool
+
+	// KeepFormat makes the parser remember how the document has been written, to the byte,
+	// so that writing it back only changes what has been modified since. Everything else,
+	// including whitespace, comments, commented-out nodes and the way values are written, is copied as is.
+	// New nodes are indented like their siblings.
+	//
+	// The whole input is read into memory first. It has no effect if the input is transcoded,
+	// i.e. with an Encoding or in UTF-16, nor on the nodes read by a Decoder.
+	KeepFormat bool
+
+	// KeepPositions makes the parser record where each node, value and type hint starts,
+	// as reported by Node.Position and Value.Position, and the spans of the nodes, see Node.Spans.
+	// They cost memory, so they are only recorded with this option or KeepFormat.
+	KeepPositions bool
+
+	// UseNumber makes the parser keep numbers as a Number, i.e. the text they are written as,
+	// instead of converting them to a big.Int or a big.Float right away.
+	UseNumber bool
+
+	// Warn is called with the problems that the parser has tolerated, as a *ParseError.
+	// If nil, such problems are not reported.
+	Warn func(err error)
+}
+
+// DuplicatePropsPolicy decides what happens when a node has the same property twice, e.g. node key=1 key=2.
+type DuplicatePropsPolicy int
+
+const (
+	DuplicatePropsLastWins  DuplicatePropsPolicy = iota // The last value is kept, as the specification says.
+	DuplicatePropsFirstWins                             // The first value is kept.
+	DuplicatePropsError                                 // The document fails with ErrDuplicateProperty.
+)
+
+// Version identifies a version of the KDL specification.
+type Version int
+
+const (
+	Version1 Version = iota + 1 // KDL 1.0.0
+	Version2                    // KDL 2.0.0
+)
+
+// String returns the number of the version, e.g. "2.0.0".
+func (v Version) String() string {
+	switch v {
+	case Version1:
+		return "1.0.0"
+	case Version2:
+		return "2.0.0"
+	default:
+		return "Version(" + strconv.Itoa(int(v)) + ")"
+	}
+}
+
+// version returns the effective version of the specification.
+func (o ParseOptions) version() Version {
+	if o.Version == 0 {
+		return Version1
+	}
+	return o.Version
+}
+
+// DefaultMaxDepth is the nesting limit used if ParseOptions.MaxDepth is not set.
+const DefaultMaxDepth = 1024
+
+// maxDepth returns the effective nesting limit, or a negative value if there is none.
+func (o ParseOptions) maxDepth() int {
+	if o.MaxDepth == 0 {
+		return DefaultMaxDepth
+	}
+	return o.MaxDepth
+}
+
+// Option configures the parser, as an alternative to setting the fields of ParseOptions.
+// Options are applied in order, so a later one overrides an earlier one.
+type Option func(*parseConfig)
+
+// parseConfig is the configuration of the parser, resolved from ParseOptions and the Option values.
+type parseConfig struct {
+	ParseOptions
+
+	partial    bool   // Whether a top-level '}' ends the document, see ParsePartial.
+	terminator []byte // Text ending the document when found in place of a top-level node, if any.
+}
+
+// newParseConfig applies the options on top of the base ones.
+func newParseConfig(base ParseOptions, opts []Option) parseConfig {
+	cfg := parseConfig{ParseOptions: base}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithOptions sets all the options at once, overriding the ones applied before.
+func WithOptions(o ParseOptions) Option {
+	return func(c *parseConfig) { c.ParseOptions = o }
+}
+
+// WithTerminator makes ParsePartial stop when the text is found in place of a top-level node.
+// It has no effect on the other parsing functions.
+func WithTerminator(text string) Option {
+	return func(c *parseConfig) { c.terminator = []byte(text) }
+}
+
+// WithErrorSourceContext sets ParseOptions.ErrorSourceContext.
+func WithErrorSourceContext(enabled bool) Option {
+	return func(c *parseConfig) { c.ErrorSourceContext = enabled }
+}
+
+// WithErrorRecovery sets ParseOptions.AllErrors.
+func WithErrorRecovery(enabled bool) Option {
+	return func(c *parseConfig) { c.AllErrors = enabled }
+}
+
+// WithContinueOnError sets ParseOptions.ContinueOnError.
+func WithContinueOnError(enabled bool) Option {
+	return func(c *parseConfig) { c.ContinueOnError = enabled }
+}
+
+// WithVersion sets ParseOptions.Version.
+func WithVersion(v Version) Option {
+	return func(c *parseConfig) { c.Version = v }
+}
+
+// WithVersionDetection sets ParseOptions.DetectVersion.
+func WithVersionDetection(enabled bool) Option {
+	return func(c *parseConfig) { c.DetectVersion = enabled }
+}
+
+// WithMaxDepth sets ParseOptions.MaxDepth.
+func WithMaxDepth(depth int) Option {
+	return func(c *parseConfig) { c.MaxDepth = depth }
+}
+
+// WithMaxNodes sets ParseOptions.MaxNodes.
+func WithMaxNodes(count int) Option {
+	return func(c *parseConfig) { c.MaxNodes = count }
+}
+
+// WithMaxStringLen sets ParseOptions.MaxStringLen.
+func WithMaxStringLen(count int) Option {
+	return func(c *parseConfig) { c.MaxStringLen = count }
+}
+
+// WithDuplicateProps sets ParseOptions.DuplicateProps.
+func WithDuplicateProps(policy DuplicatePropsPolicy) Option {
+	return func(c *parseConfig) { c.DuplicateProps = policy }
+}
+
+// WithMaxInputBytes sets ParseOptions.MaxInputBytes.
+func WithMaxInputBytes(count int) Option {
+	return func(c *parseConfig) { c.MaxInputBytes = count }
+}
+
+// WithDisallowedChars sets ParseOptions.AllowDisallowedChars.
+func WithDisallowedChars(allowed bool) Option {
+	return func(c *parseConfig) { c.AllowDisallowedChars = allowed }
+}
+
+// WithNumbers sets ParseOptions.UseNumber.
+func WithNumbers(enabled bool) Option {
+	return func(c *parseConfig) { c.UseNumber = enabled }
+}
+
+// WithComments sets ParseOptions.KeepComments.
+func WithComments(enabled bool) Option {
+	return func(c *parseConfig) { c.KeepComments = enabled }
+}
+
+// WithBlankLines sets ParseOptions.KeepBlankLines.
+func WithBlankLines(enabled bool) Option {
+	return func(c *parseConfig) { c.KeepBlankLines = enabled }
+}
+
+// WithPositions sets ParseOptions.KeepPositions.
+func WithPositions(enabled bool) Option {
+	return func(c *parseConfig) { c.KeepPositions = enabled }
+}
+
+// WithFidelity sets ParseOptions.KeepFormat.
+func WithFidelity(enabled bool) Option {
+	return func(c *parseConfig) { c.KeepFormat = enabled }
+}
+
+// WithStrictUTF8 sets ParseOptions.StrictUTF8.
+func WithStrictUTF8(enabled bool) Option {
+	return func(c *parseConfig) { c.StrictUTF8 = enabled }
+}
+
+// WithEncoding sets ParseOptions.Encoding.
+func WithEncoding(e encoding.Encoding) Option {
+	return func(c *parseConfig) { c.Encoding = e }
+}
+
+// WithWarnings sets ParseOptions.Warn.
+func WithWarnings(warn func(err error)) Option {
+	return func(c *parseConfig) { c.Warn = warn }
+}
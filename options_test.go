@@ -0,0 +1,57 @@
+package kdl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoOptionsResolveToDefaults(t *testing.T) {
+	assert.Equal(t, parseConfig{}, newParseConfig(ParseOptions{}, nil))
+	assert.Equal(t, DefaultMaxDepth, newParseConfig(ParseOptions{}, nil).maxDepth())
+}
+
+func TestOptionsAreCombined(t *testing.T) {
+	cfg := newParseConfig(ParseOptions{ErrorSourceContext: true}, []Option{
+		WithErrorRecovery(true),
+		WithMaxDepth(8),
+		WithMaxNodes(100),
+		WithMaxInputBytes(1 << 20),
+		WithMaxDepth(-1),
+	})
+
+	assert.Equal(t, ParseOptions{
+		ErrorSourceContext: true,
+		AllErrors:          true,
+		MaxDepth:           -1,
+		MaxNodes:           100,
+		MaxInputBytes:      1 << 20,
+	}, cfg.ParseOptions)
+}
+
+func TestWithOptionsOverridesEarlierOptions(t *testing.T) {
+	cfg := newParseConfig(ParseOptions{}, []Option{
+		WithMaxNodes(100),
+		WithOptions(ParseOptions{AllErrors: true}),
+		WithErrorSourceContext(true),
+	})
+
+	assert.Equal(t, ParseOptions{AllErrors: true, ErrorSourceContext: true}, cfg.ParseOptions)
+}
+
+func TestParseWithOptions(t *testing.T) {
+	doc, err := ParseString("a 1\nb c\nd {\n\te { f; }\n}", WithErrorRecovery(true), WithMaxDepth(1))
+	assert.ErrorIs(t, err, errUnexpectedBareIdentifier)
+	assert.ErrorIs(t, err, ErrDepthExceeded)
+	if assert.Len(t, doc.Nodes, 1) {
+		assert.EqualValues(t, "a", doc.Nodes[0].Name)
+	}
+
+	_, err = ParseBytes([]byte("a; b; c\n"), WithMaxNodes(2))
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	d := NewDecoder(strings.NewReader("a /* comment */"), WithMaxInputBytes(8))
+	_, err = d.Decode()
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
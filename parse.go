@@ -2,49 +2,281 @@ package kdl
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"errors"
 	"io"
+	"io/fs"
 	"os"
-	"strings"
+	"sort"
 )
 
 //go:generate go run internal/tools/generate_test_cases/generate.go
 
-func parse(br innerReader) (Document, error) {
+func parse(ctx context.Context, br innerReader, cfg parseConfig) (Document, error) {
+	if cfg.DetectVersion {
+		return parseDetectingVersion(ctx, br, cfg)
+	}
+
 	doc := NewDocument()
+	doc.Version = cfg.version()
+	if _, ok := br.(*bytesReader); cfg.KeepFormat && cfg.Encoding == nil && !ok {
+		// The nodes refer to the text they have been read from
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return doc, err
+		}
+		br = newBytesReader(data)
+	}
 	r := wrapReader(br)
+	r.cfg = cfg
+	r.setContext(ctx)
+	if cfg.KeepFormat && cfg.Encoding == nil {
+		r.text = string(r.mem.data)
+	}
 
-	nodes, err := readNodes(&r)
-	if err != nil {
-		return doc, addErrPosInfo(err, &r)
+	nodes, err := readDocument(&r)
+	if err != nil && !cfg.AllErrors {
+		return doc, err
 	}
 
 	doc.Nodes = nodes
-	return doc, nil
+	doc.EndComments = r.takeComments()
+	if r.keepFormat() {
+		doc.format = newDocumentFormat(r.text, &doc)
+	}
+	if r.keepPositions() {
+		doc.parsed = &cfg
+	}
+	return doc, err
 }
 
-func ParseReader(r io.Reader) (Document, error) {
-	br := bufio.NewReader(r)
-	return parse(br)
+// parseDetectingVersion parses a document with the configured version of the specification,
+// retrying once with the other version if the syntax of a specific version has caused a failure.
+//
+// The retry is only accepted if it does not fail because of the syntax of a specific version too,
+// otherwise the original result is returned, so that real mistakes are not masked.
+func parseDetectingVersion(ctx context.Context, br innerReader, cfg parseConfig) (doc Document, err error) {
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return NewDocument(), err
+	}
+
+	defer func(cfg parseConfig) {
+		if doc.parsed != nil {
+			// Parsing the document again needs the detection too
+			doc.parsed = &cfg
+		}
+	}(cfg)
+
+	cfg.DetectVersion = false
+	doc, err = parse(ctx, newBytesReader(data), cfg)
+	if err == nil || !isVersionSyntax(err, cfg.version()) {
+		return doc, err
+	}
+
+	retry := cfg
+	retry.Version = Version2
+	if cfg.version() == Version2 {
+		retry.Version = Version1
+	}
+
+	retryDoc, retryErr := parse(ctx, newBytesReader(data), retry)
+	if retryErr != nil && isVersionSyntax(retryErr, retry.version()) {
+		return doc, err
+	}
+	return retryDoc, retryErr
 }
 
-func ParseBytes(b []byte) (Document, error) {
-	bb := bytes.NewReader(b)
-	return ParseReader(bb)
+// isVersionSyntax reports whether a document has failed to parse with the version of the specification
+// because of syntax that belongs to the other version.
+func isVersionSyntax(err error, version Version) bool {
+	if hasErrorCode(err, CodeVersionSyntax) {
+		return true
+	}
+	// Bare identifiers as values and whitespace around '=' are only errors in KDL 1.0
+	return version < Version2 && (hasErrorCode(err, CodeBareIdentifier) || hasErrorCode(err, CodeWhitespaceAroundEquals))
 }
 
-func ParseString(s string) (Document, error) {
-	sr := strings.NewReader(s)
-	br := bufio.NewReader(sr)
-	return parse(br)
+// readDocument reads all the top-level nodes, joining the errors recovered from with the final one.
+func readDocument(r *reader) ([]Node, error) {
+	nodes, err := readNodes(r)
+	if err != nil {
+		err = finishError(err, r)
+	}
+
+	if len(r.errs) > 0 {
+		err = errors.Join(append(r.errs, err)...)
+	}
+
+	return nodes, err
 }
 
-func ParseFile(path string) (Document, error) {
+func parseFile(path string, cfg parseConfig) (Document, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return NewDocument(), err
 	}
 	defer f.Close()
+	return parseNamed(f, path, cfg)
+}
+
+// parseNamed parses a document read from a file, recording its name.
+func parseNamed(f io.Reader, name string, cfg parseConfig) (Document, error) {
 	br := bufio.NewReader(f)
-	return parse(br)
+	doc, err := parse(context.Background(), br, cfg)
+	doc.SourceName = name
+	setSource(doc.Nodes, name)
+	setErrorFile(err, name)
+	return doc, err
+}
+
+func parseFS(fsys fs.FS, pattern string, cfg parseConfig) ([]Document, error) {
+	paths, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	docs := make([]Document, 0, len(paths))
+	var errs []error
+	for _, path := range paths {
+		doc, err := parseFSFile(fsys, path, cfg)
+		if err != nil {
+			if !cfg.ContinueOnError && !cfg.AllErrors {
+				return nil, err
+			}
+			errs = append(errs, err)
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, errors.Join(errs...)
+}
+
+func parseFSFile(fsys fs.FS, path string, cfg parseConfig) (Document, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		doc := NewDocument()
+		doc.SourceName = path
+		return doc, err
+	}
+	defer f.Close()
+	return parseNamed(f, path, cfg)
+}
+
+// bufferReader returns a buffered reader of a user-provided io.Reader.
+// A reader that is buffered already, e.g. a bufio.Reader, is read from directly,
+// so that it is not advanced past what the parser has consumed.
+func bufferReader(r io.Reader) innerReader {
+	if inner, ok := r.(innerReader); ok {
+		return callbackBuffer{inner}
+	}
+	return bufio.NewReader(callbackReader{r})
+}
+
+// ParseReader parses a document read from r.
+//
+// If r is a bufio.Reader, it is read from directly, without buffering the input again.
+// It is then advanced only by the bytes the parser has consumed,
+// so that whatever follows a malformed document can still be read from it,
+// unless a single token, e.g. a string, is longer than its buffer and has to be read through a larger one.
+func ParseReader(r io.Reader, opts ...Option) (Document, error) {
+	return parse(context.Background(), bufferReader(r), newParseConfig(ParseOptions{}, opts))
+}
+
+// ParseBytes parses a document held in memory.
+//
+// The slice is read in place, without being copied to a buffer first,
+// so it must not be modified until ParseBytes returns. The document does not refer to it afterwards.
+func ParseBytes(b []byte, opts ...Option) (Document, error) {
+	return parse(context.Background(), newBytesReader(b), newParseConfig(ParseOptions{}, opts))
+}
+
+func ParseString(s string, opts ...Option) (Document, error) {
+	return parse(context.Background(), newBytesReader([]byte(s)), newParseConfig(ParseOptions{}, opts))
+}
+
+// ParseFile opens and parses the file at the provided path.
+//
+// The path is recorded in Document.SourceName and in the File of every ParseError.
+// If the file cannot be opened, the error from os.Open is returned as is, e.g. matching fs.ErrNotExist.
+func ParseFile(path string, opts ...Option) (Document, error) {
+	return parseFile(path, newParseConfig(ParseOptions{}, opts))
+}
+
+// ParseFS parses all the files in fsys matching the pattern, as understood by fs.Glob.
+//
+// The documents are returned in the lexical order of their paths, each with its path in Document.SourceName.
+// Errors are annotated with the path of the file, like in ParseFile.
+// By default, the first file that fails to parse stops the whole process.
+// With WithContinueOnError, every file is parsed and the errors of all of them are joined together,
+// each file stopping at its first error. WithErrorRecovery does the same, also recovering within each file.
+// A pattern matching no files is not an error.
+func ParseFS(fsys fs.FS, pattern string, opts ...Option) ([]Document, error) {
+	return parseFS(fsys, pattern, newParseConfig(ParseOptions{}, opts))
+}
+
+// ParseContext parses a document, giving up once the context is canceled.
+//
+// The context is checked between the nodes and while skipping comments,
+// so a read from r that blocks is not interrupted.
+func ParseContext(ctx context.Context, r io.Reader, opts ...Option) (Document, error) {
+	return parse(ctx, bufferReader(r), newParseConfig(ParseOptions{}, opts))
+}
+
+// ParsePartial parses a fragment of a document embedded in a larger text.
+//
+// Unlike the other parsing functions, it stops cleanly at the first top-level '}'
+// or at the terminator set with WithTerminator, when found in place of a top-level node.
+// n is the byte offset of the first byte that has not been consumed,
+// so data[n:] starts with the delimiter, if there was one.
+func ParsePartial(data []byte, opts ...Option) (nodes []Node, n int, err error) {
+	cfg := newParseConfig(ParseOptions{}, opts)
+	cfg.partial = true
+
+	r := wrapReader(newBytesReader(data))
+	r.cfg = cfg
+
+	nodes, err = readDocument(&r)
+	if err != nil && !cfg.AllErrors {
+		return nil, r.offset, err
+	}
+
+	return nodes, r.offset, err
+}
+
+// ParseReader parses a document using these options.
+func (o ParseOptions) ParseReader(r io.Reader) (Document, error) {
+	br := bufferReader(r)
+	return parse(context.Background(), br, newParseConfig(o, nil))
+}
+
+// ParseContext parses a document using these options, giving up once the context is canceled.
+//
+// The error returned on cancellation matches ctx.Err() and tells where the parser has stopped.
+func (o ParseOptions) ParseContext(ctx context.Context, r io.Reader) (Document, error) {
+	br := bufferReader(r)
+	return parse(ctx, br, newParseConfig(o, nil))
+}
+
+// ParseBytes parses a document using these options.
+func (o ParseOptions) ParseBytes(b []byte) (Document, error) {
+	br := newBytesReader(b)
+	return parse(context.Background(), br, newParseConfig(o, nil))
+}
+
+// ParseString parses a document using these options.
+func (o ParseOptions) ParseString(s string) (Document, error) {
+	br := newBytesReader([]byte(s))
+	return parse(context.Background(), br, newParseConfig(o, nil))
+}
+
+// ParseFile parses a document using these options.
+func (o ParseOptions) ParseFile(path string) (Document, error) {
+	return parseFile(path, newParseConfig(o, nil))
+}
+
+// ParseFS parses all the files in fsys matching the pattern using these options.
+func (o ParseOptions) ParseFS(fsys fs.FS, pattern string) ([]Document, error) {
+	return parseFS(fsys, pattern, newParseConfig(o, nil))
 }
@@ -1,9 +1,27 @@
 package kdl
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
+	"unicode/utf16"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
 )
 
 const inputSimple string = `
@@ -35,3 +53,1211 @@ func BenchmarkParseSimpleDocument(b *testing.B) {
 		_, _ = ParseString(inputSimple)
 	}
 }
+
+// largeDocument is a representative document of about 50KB.
+var largeDocument = func() []byte {
+	var b bytes.Buffer
+	for i := 0; b.Len() < 50_000; i++ {
+		fmt.Fprintf(&b, "server \"srv-%d\" port=%d enabled=true {\n", i, 8000+i)
+		b.WriteString("\t// Where the traffic goes\n")
+		b.WriteString("\tupstream \"http://localhost:9000/api\" weight=1.5 retries=3\n")
+		b.WriteString("\ttags \"edge\" \"eu-west\" (u8)42 r#\"raw \"quoted\" text\"#\n")
+		b.WriteString("\t/-disabled-option null\n")
+		b.WriteString("}\n")
+	}
+	return b.Bytes()
+}()
+
+func BenchmarkParseLargeDocument(b *testing.B) {
+	b.Run("Bytes", func(b *testing.B) {
+		b.SetBytes(int64(len(largeDocument)))
+		for i := 0; i < b.N; i++ {
+			_, _ = ParseBytes(largeDocument)
+		}
+	})
+	b.Run("Reader", func(b *testing.B) {
+		b.SetBytes(int64(len(largeDocument)))
+		for i := 0; i < b.N; i++ {
+			_, _ = ParseReader(bytes.NewReader(largeDocument))
+		}
+	})
+}
+
+func TestParseBytesDoesNotAliasInput(t *testing.T) {
+	input := []byte("node \"value\" key=r#\"raw\"#\n")
+	doc, err := ParseBytes(input)
+	assert.NoError(t, err)
+
+	for i := range input {
+		input[i] = 'x'
+	}
+	assert.EqualValues(t, "node", doc.Nodes[0].Name)
+	assert.Equal(t, "value", doc.Nodes[0].Args[0].StringValue())
+	assert.Equal(t, "raw", doc.Nodes[0].Props["key"].StringValue())
+}
+
+func TestParseBytesLongString(t *testing.T) {
+	// Longer than the buffer of a bufio.Reader
+	long := strings.Repeat("ab", 10_000)
+	doc, err := ParseBytes([]byte("node \"" + long + "\" r#\"" + long + "\"#\n"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, long, doc.Nodes[0].Args[0].StringValue())
+		assert.Equal(t, long, doc.Nodes[0].Args[1].StringValue())
+	}
+}
+
+func TestParseReaderReadsBufferedReaderDirectly(t *testing.T) {
+	input := "node 1\nbroken ] and the rest\n"
+	br := bufio.NewReader(strings.NewReader(input))
+	_, err := ParseReader(br)
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		// Nothing past the bad token has been consumed
+		rest, _ := io.ReadAll(br)
+		assert.LessOrEqual(t, len(input)-len(rest), pe.Offset+1)
+		assert.Contains(t, string(rest), "and the rest")
+	}
+}
+
+func TestParseAllErrorsCollectsErrors(t *testing.T) {
+	opts := ParseOptions{AllErrors: true}
+
+	doc, err := opts.ParseString(`first 1
+broken a=
+second "two" {
+	child oops
+	grandchild (hint)"ok"
+}
+;
+third r#"{"# } 1=2
+fourth
+`)
+	assert.ErrorIs(t, err, ErrInvalidSyntax)
+
+	var pe *ParseError
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	if assert.Len(t, errs, 5) {
+		lines := make([]int, 0, len(errs))
+		for _, e := range errs {
+			if assert.ErrorAs(t, e, &pe) {
+				lines = append(lines, pe.Line)
+			}
+		}
+		assert.Equal(t, []int{2, 4, 7, 8, 8}, lines)
+	}
+
+	if assert.Len(t, doc.Nodes, 4) {
+		assert.EqualValues(t, "first", doc.Nodes[0].Name)
+		assert.EqualValues(t, "second", doc.Nodes[1].Name)
+		assert.EqualValues(t, "third", doc.Nodes[2].Name)
+		assert.EqualValues(t, "fourth", doc.Nodes[3].Name)
+		if assert.Len(t, doc.Nodes[1].Children, 1) {
+			assert.EqualValues(t, "grandchild", doc.Nodes[1].Children[0].Name)
+		}
+	}
+}
+
+func TestParseAllErrorsResumesAfterSemicolon(t *testing.T) {
+	opts := ParseOptions{AllErrors: true}
+
+	doc, err := opts.ParseString(`a 1=2; b; c { d "e"x; f }; }`)
+	assert.ErrorIs(t, err, ErrInvalidSyntax)
+	if assert.Len(t, doc.Nodes, 2) {
+		assert.EqualValues(t, "b", doc.Nodes[0].Name)
+		assert.EqualValues(t, "c", doc.Nodes[1].Name)
+		if assert.Len(t, doc.Nodes[1].Children, 1) {
+			assert.EqualValues(t, "f", doc.Nodes[1].Children[0].Name)
+		}
+	}
+}
+
+func TestParseAllErrorsAbortsOnUnterminatedString(t *testing.T) {
+	opts := ParseOptions{AllErrors: true}
+
+	doc, err := opts.ParseString("a 1\nb c\nd \"e\nf\n")
+	assert.ErrorIs(t, err, ErrInvalidSyntax)
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+	if assert.Len(t, doc.Nodes, 1) {
+		assert.EqualValues(t, "a", doc.Nodes[0].Name)
+	}
+}
+
+func TestParseStopsAtFirstErrorByDefault(t *testing.T) {
+	doc, err := ParseString("a 1\nb c\nd e\n")
+	assert.ErrorIs(t, err, ErrInvalidSyntax)
+	assert.Empty(t, doc.Nodes)
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 2, pe.Line)
+	}
+}
+
+func TestParseLimitsNestingDepth(t *testing.T) {
+	_, err := ParseString(strings.Repeat("a {", 10_000))
+	assert.ErrorIs(t, err, ErrDepthExceeded)
+	assert.ErrorContains(t, err, "the limit is 1024")
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, CodeDepthExceeded, pe.Code)
+		assert.Equal(t, 1, pe.Line)
+		assert.Equal(t, 3*DefaultMaxDepth+3, pe.Column)
+	}
+
+	_, err = ParseOptions{MaxDepth: 2}.ParseString("a { b { c { d; }; }; }")
+	assert.ErrorIs(t, err, ErrDepthExceeded)
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 11, pe.Column)
+		assert.Equal(t, []Identifier{"a", "b", "c"}, pe.Path)
+	}
+}
+
+func TestParseUnlimitedNestingDepth(t *testing.T) {
+	const depth = DefaultMaxDepth + 10
+	doc, err := ParseOptions{MaxDepth: -1}.ParseString(strings.Repeat("a {", depth) + strings.Repeat("}", depth))
+	assert.NoError(t, err)
+	assert.Len(t, doc.Nodes, 1)
+}
+
+func TestParseLimitsNodeCount(t *testing.T) {
+	input := "a {\n\tb\n\t/-c\n\td\n}\n"
+
+	_, err := ParseOptions{MaxNodes: 4}.ParseString(input)
+	assert.NoError(t, err)
+
+	_, err = ParseOptions{MaxNodes: 3}.ParseString(input)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.ErrorContains(t, err, "MaxNodes is 3")
+
+	var le *LimitExceededError
+	if assert.ErrorAs(t, err, &le) {
+		assert.Equal(t, "MaxNodes", le.Limit)
+		assert.Equal(t, 3, le.Max)
+	}
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, CodeLimitExceeded, pe.Code)
+		assert.Equal(t, 4, pe.Line)
+		assert.Equal(t, 2, pe.Column)
+		assert.Equal(t, []Identifier{"a"}, pe.Path)
+	}
+}
+
+func TestParseLimitsInputSize(t *testing.T) {
+	input := "a {\n\tb /* " + strings.Repeat("long ", 100) + "*/\n}\n"
+
+	_, err := ParseOptions{MaxInputBytes: len(input)}.ParseString(input)
+	assert.NoError(t, err)
+
+	_, err = ParseOptions{MaxInputBytes: 64}.ParseString(input)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	var le *LimitExceededError
+	if assert.ErrorAs(t, err, &le) {
+		assert.Equal(t, "MaxInputBytes", le.Limit)
+		assert.Equal(t, 64, le.Max)
+	}
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		// Reported where the parser stopped, inside of the comment
+		assert.Greater(t, pe.Offset, 9)
+		assert.LessOrEqual(t, pe.Offset, 64)
+		assert.Equal(t, []Identifier{"a", "b"}, pe.Path)
+	}
+}
+
+func TestParseLimitsStringLength(t *testing.T) {
+	valid := map[string]Version{
+		`node "12345678"`:                          Version1,
+		`node key="\u{1F600}\u{1F600}"`:            Version1, // 8 bytes decoded, 18 written
+		`node "\n\n\n\n\n\n\n\n"`:                  Version1,
+		`node r##"12"#4567"##`:                     Version1,
+		`"12345678" 1`:                             Version1,
+		"node \"\"\"\n\t\t12345678\n\t\t\"\"\"":    Version2,
+		"node #\"\"\"\n\t\t1234\\678\n\t\t\"\"\"#": Version2,
+		`node "1234\s678"`:                         Version2,
+	}
+	for input, version := range valid {
+		_, err := ParseString(input, WithVersion(version), WithMaxStringLen(8))
+		assert.NoError(t, err, input)
+	}
+
+	invalid := map[string]Version{
+		`node "123456789"`:                          Version1,
+		`node key="\u{1F600}\u{1F600}\u{1}"`:        Version1,
+		`node "\n\n\n\n\n\n\n\n\n"`:                 Version1,
+		`node r##"12"#45678"##`:                     Version1,
+		`"123456789" 1`:                             Version1,
+		`node "123456789`:                           Version1, // Too long before it turns out to be unterminated
+		"node \"\"\"\n\t\t123456789\n\t\t\"\"\"":    Version2,
+		"node #\"\"\"\n\t\t1234\\6789\n\t\t\"\"\"#": Version2,
+		`node #"123456789"#`:                        Version2,
+	}
+	for input, version := range invalid {
+		_, err := ParseString(input, WithVersion(version), WithMaxStringLen(8))
+		assert.ErrorIs(t, err, ErrLimitExceeded, input)
+
+		var le *LimitExceededError
+		if assert.ErrorAs(t, err, &le, input) {
+			assert.Equal(t, "MaxStringLen", le.Limit, input)
+			assert.Equal(t, 8, le.Max, input)
+		}
+
+		// Reported at the start of the string
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe, input) {
+			assert.Equal(t, CodeLimitExceeded, pe.Code, input)
+			assert.Contains(t, []byte{'"', 'r', '#'}, input[pe.Offset], input)
+		}
+	}
+
+	// No limit by default
+	long := strings.Repeat("x", 100_000)
+	doc, err := ParseString(`node "` + long + `"`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, long, doc.Nodes[0].Args[0].StringValue())
+	}
+}
+
+func TestParseDuplicateProps(t *testing.T) {
+	input := "node key=1 other=2 key=(u8)3 key=(i32)4\n"
+
+	doc, err := ParseString(input)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(4), doc.Nodes[0].Props["key"].IntegerValue().Int64())
+	}
+
+	doc, err = ParseString(input, WithDuplicateProps(DuplicatePropsFirstWins))
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1), doc.Nodes[0].Props["key"].IntegerValue().Int64())
+		assert.True(t, doc.Nodes[0].Props["key"].TypeHint.IsAbsent())
+		assert.Len(t, doc.Nodes[0].Props, 2)
+	}
+
+	_, err = ParseString(input, WithDuplicateProps(DuplicatePropsError))
+	assert.ErrorIs(t, err, ErrDuplicateProperty)
+	assert.ErrorIs(t, err, ErrInvalidSyntax)
+	assert.ErrorContains(t, err, `duplicate property "key", first set at line 1, column 6`)
+
+	var de *DuplicatePropertyError
+	if assert.ErrorAs(t, err, &de) {
+		assert.EqualValues(t, "key", de.Key)
+		assert.Equal(t, 5, de.FirstOffset)
+		assert.Equal(t, 1, de.FirstLine)
+		assert.Equal(t, 6, de.FirstColumn)
+	}
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		// At the key of the second one
+		assert.Equal(t, CodeDuplicateProperty, pe.Code)
+		assert.Equal(t, strings.Index(input, "key=(u8)3"), pe.Offset)
+	}
+}
+
+func TestParseDuplicatePropsErrorPerNode(t *testing.T) {
+	opts := ParseOptions{DuplicateProps: DuplicatePropsError}
+
+	// Properties of different nodes, quoted keys and the ones commented out
+	doc, err := opts.ParseString("a key=1 {\n\tb key=2\n}\nc key=1 /-key=2\nd \"key\"=1 \"other\"=2\n")
+	if assert.NoError(t, err) {
+		assert.Len(t, doc.Nodes, 3)
+	}
+
+	_, err = opts.ParseString("a x=1\nb {\n\tc x=1 \\\n\t\ty=2 \"x\"=3\n}\n")
+	var de *DuplicatePropertyError
+	if assert.ErrorAs(t, err, &de) {
+		assert.Equal(t, 3, de.FirstLine)
+		assert.Equal(t, 4, de.FirstColumn)
+	}
+
+	// Recovered from like other syntax errors
+	opts.AllErrors = true
+	doc, err = opts.ParseString("a x=1 x=2\nb x=1\n")
+	assert.ErrorIs(t, err, ErrDuplicateProperty)
+	if assert.Len(t, doc.Nodes, 1) {
+		assert.EqualValues(t, "b", doc.Nodes[0].Name)
+	}
+}
+
+// blockingReader returns its chunks one by one, waiting for the context to be canceled before the last one.
+type blockingReader struct {
+	ctx    context.Context
+	chunks []string
+}
+
+func (b *blockingReader) Read(p []byte) (int, error) {
+	if len(b.chunks) == 0 {
+		return 0, io.EOF
+	}
+	if len(b.chunks) == 1 {
+		<-b.ctx.Done()
+	}
+	n := copy(p, b.chunks[0])
+	b.chunks = b.chunks[1:]
+	return n, nil
+}
+
+func TestParseContextStopsBetweenNodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	r := &blockingReader{ctx: ctx, chunks: []string{"a 1\nb 2\n", "c 3\nd 4\n"}}
+	_, err := ParseContext(ctx, r)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 3, pe.Line)
+		assert.Equal(t, 1, pe.Column)
+	}
+}
+
+func TestParseContextStopsInsideComment(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	r := &blockingReader{ctx: ctx, chunks: []string{"a /* comment", strings.Repeat(" more", 1000) + " */"}}
+	_, err := ParseContext(ctx, r)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 1, pe.Line)
+		assert.Greater(t, pe.Column, 3)
+	}
+}
+
+func TestParseContextAlreadyCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := ParseContext(ctx, strings.NewReader("a 1"))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestParsePartialStopsAtTopLevelBrace(t *testing.T) {
+	data := []byte("a 1\nb {\n\tc\n}\n} rest of the outer format")
+
+	nodes, n, err := ParsePartial(data)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 2)
+	assert.Equal(t, "} rest of the outer format", string(data[n:]))
+
+	doc, err := ParseBytes(data[:n])
+	assert.NoError(t, err)
+	assert.Equal(t, nodes, doc.Nodes)
+
+	// The normal parser still rejects it
+	_, err = ParseBytes(data)
+	assert.ErrorIs(t, err, errUnexpectedRightBracket)
+}
+
+func TestParsePartialStopsAtTerminator(t *testing.T) {
+	data := []byte("a 1 \"---\"\n/* --- */\n---\nb 2\n")
+
+	nodes, n, err := ParsePartial(data, WithTerminator("---"))
+	assert.NoError(t, err)
+	if assert.Len(t, nodes, 1) {
+		assert.EqualValues(t, "a", nodes[0].Name)
+	}
+	assert.Equal(t, "---\nb 2\n", string(data[n:]))
+
+	doc, err := ParseBytes(data[:n])
+	assert.NoError(t, err)
+	assert.Equal(t, nodes, doc.Nodes)
+}
+
+func TestParsePartialConsumesWholeDocument(t *testing.T) {
+	data := []byte("a 1\nb 2\n")
+
+	nodes, n, err := ParsePartial(data)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 2)
+	assert.Equal(t, len(data), n)
+}
+
+func TestParsePartialReportsErrors(t *testing.T) {
+	nodes, _, err := ParsePartial([]byte("a 1\nb c\n}"))
+	assert.ErrorIs(t, err, errUnexpectedBareIdentifier)
+	assert.Nil(t, nodes)
+}
+
+func TestParseVeryDeepDocument(t *testing.T) {
+	const depth = 100_000
+	input := strings.Repeat("a {\n", depth) + strings.Repeat("}\n", depth)
+
+	doc, err := ParseString(input, WithMaxDepth(-1))
+	assert.NoError(t, err)
+
+	levels := 0
+	nodes := doc.Nodes
+	for len(nodes) == 1 {
+		levels++
+		nodes = nodes[0].Children
+	}
+	assert.Equal(t, depth, levels)
+
+	_, err = ParseString(input[:len(input)-2], WithMaxDepth(-1))
+	assert.ErrorIs(t, err, ErrUnclosedChildren)
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 1, pe.Line)
+		assert.Equal(t, []Identifier{"a"}, pe.Path)
+	}
+}
+
+func TestParseDetectsVersion(t *testing.T) {
+	doc, err := ParseString("node true", WithVersionDetection(true))
+	assert.NoError(t, err)
+	assert.Equal(t, Version1, doc.Version)
+
+	doc, err = ParseString("node #true", WithVersionDetection(true))
+	assert.NoError(t, err)
+	assert.Equal(t, Version2, doc.Version)
+	assert.Equal(t, true, doc.Nodes[0].Args[0].BoolValue())
+
+	doc, err = ParseReader(strings.NewReader("node null"), WithVersion(Version2), WithVersionDetection(true))
+	assert.NoError(t, err)
+	assert.Equal(t, Version1, doc.Version)
+
+	doc, err = ParseString("node #null")
+	assert.ErrorIs(t, err, errHashKeywordInV1)
+	assert.Equal(t, Version1, doc.Version)
+
+	_, err = ParseString("n \"\"\"\n  hi\n  \"\"\"")
+	assert.ErrorIs(t, err, errMultiLineStringInV1)
+	assert.True(t, hasErrorCode(err, CodeVersionSyntax))
+
+	// Strings written as bare identifiers, whitespace around '=' and multi-line strings only work in KDL 2.0
+	for _, input := range []string{"node foo", "node key=foo --flag", "node key = 1", "n \"\"\"\n  hi\n  \"\"\"", "\"\"\"\n  name\n  \"\"\" 1"} {
+		doc, err = ParseString(input, WithVersionDetection(true))
+		assert.NoError(t, err, input)
+		assert.Equal(t, Version2, doc.Version, input)
+	}
+}
+
+func TestParseDetectsVersionOnlyForVersionErrors(t *testing.T) {
+	// Generic syntax errors are reported as they are
+	_, err := ParseString("node #true; bad 1abc", WithVersionDetection(true), WithVersion(Version2))
+	assert.ErrorIs(t, err, errInvalidNumValue)
+
+	// If neither version works, the error of the configured one is reported
+	_, err = ParseString("node #true false", WithVersionDetection(true))
+	assert.ErrorIs(t, err, errHashKeywordInV1)
+
+	// Other errors are reported with the version that does not fail because of its syntax
+	doc, err := ParseString("a #true\nb 1 2 3oops", WithVersionDetection(true), WithErrorRecovery(true))
+	assert.ErrorIs(t, err, errInvalidNumValue)
+	assert.NotErrorIs(t, err, errHashKeywordInV1)
+	assert.Equal(t, Version2, doc.Version)
+	assert.Len(t, doc.Nodes, 1)
+}
+
+func TestVersionNames(t *testing.T) {
+	assert.Equal(t, "1.0.0", Version1.String())
+	assert.Equal(t, "2.0.0", Version2.String())
+	assert.Equal(t, "Version(0)", Version(0).String())
+}
+
+func TestParseRawStrings(t *testing.T) {
+	doc, err := ParseString("node r#\"say \"hi\"\"# r\"two\nlines\" r\"key\"=r##\"a\"#b\"##\n")
+	assert.NoError(t, err)
+	node := doc.Nodes[0]
+	assert.Equal(t, `say "hi"`, node.Args[0].StringValue())
+	assert.Equal(t, "two\nlines", node.Args[1].StringValue())
+	assert.Equal(t, `a"#b`, node.GetProp("key").StringValue())
+
+	doc, err = ParseString("node #\"say \"hi\"\"# #\"key\"#=##\"a\"#b\"##\n", WithVersion(Version2))
+	assert.NoError(t, err)
+	node = doc.Nodes[0]
+	assert.Equal(t, `say "hi"`, node.Args[0].StringValue())
+	assert.Equal(t, `a"#b`, node.GetProp("key").StringValue())
+
+	out, err := doc.WriteString()
+	assert.NoError(t, err)
+	again, err := ParseString(out, WithVersion(Version2))
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Nodes, again.Nodes)
+}
+
+func TestParseUnterminatedRawString(t *testing.T) {
+	_, err := ParseString("node r#\"never ends\"")
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+	assert.True(t, hasErrorCode(err, CodeUnterminatedString))
+
+	var perr *ParseError
+	if assert.ErrorAs(t, err, &perr) {
+		assert.Equal(t, 1, perr.Line)
+		assert.Equal(t, 6, perr.Column)
+	}
+}
+
+func TestParseRejectsDisallowedChars(t *testing.T) {
+	_, err := ParseString("node \"abc\u202edef\"\n")
+	assert.ErrorIs(t, err, errDisallowedChar)
+	assert.ErrorContains(t, err, "U+202E")
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, CodeDisallowedChar, pe.Code)
+		assert.Equal(t, 1, pe.Line)
+		assert.Equal(t, 10, pe.Column)
+		assert.Equal(t, 9, pe.Offset)
+		assert.Equal(t, []Identifier{"node"}, pe.Path)
+	}
+
+	// The path leads to the node the character is in, even between children
+	_, err = ParseString("a {\n  b \"\u202e\"\n}\n")
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, []Identifier{"a", "b"}, pe.Path)
+		assert.Equal(t, Identifier("b"), pe.Node)
+	}
+	_, err = ParseString("a {\n  b\n  // \u202e\n  c\n}\n")
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, []Identifier{"a"}, pe.Path)
+	}
+
+	// Escaped, the character is fine
+	doc, err := ParseString("node \"abc\\u{202e}def\"\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc\u202edef", doc.Nodes[0].Args[0].StringValue())
+
+	_, err = ParseString("// \x01\nnode\n")
+	assert.ErrorIs(t, err, errDisallowedChar)
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Empty(t, pe.Path)
+	}
+}
+
+func TestParseAllowsDisallowedCharsWithWarning(t *testing.T) {
+	var warnings []error
+	doc, err := ParseString("node \"abc\u202edef\"\nnode \"\x7f\"\n",
+		WithDisallowedChars(true),
+		WithWarnings(func(err error) { warnings = append(warnings, err) }))
+	assert.NoError(t, err)
+	assert.Len(t, doc.Nodes, 2)
+	assert.Equal(t, "abc\u202edef", doc.Nodes[0].Args[0].StringValue())
+
+	if assert.Len(t, warnings, 2) {
+		var pe *ParseError
+		if assert.ErrorAs(t, warnings[1], &pe) {
+			assert.Equal(t, CodeDisallowedChar, pe.Code)
+			assert.Equal(t, 2, pe.Line)
+			assert.Equal(t, 7, pe.Column)
+			assert.Equal(t, []Identifier{"node"}, pe.Path)
+		}
+	}
+
+	// Other nodes are kept when recovering from errors
+	doc, err = ParseString("a\nb \"\u202e\"\nc\n", WithErrorRecovery(true))
+	assert.ErrorIs(t, err, errDisallowedChar)
+	assert.Len(t, doc.Nodes, 2)
+}
+
+func TestParseUnicodeWhitespace(t *testing.T) {
+	doc, err := ParseString("node\u00a0arg=1\u00a0\"two\"\n")
+	assert.NoError(t, err)
+	if assert.Len(t, doc.Nodes, 1) {
+		assert.EqualValues(t, "node", doc.Nodes[0].Name)
+		assert.Equal(t, "two", doc.Nodes[0].Args[0].StringValue())
+		assert.True(t, doc.Nodes[0].HasProp("arg"))
+	}
+
+	doc, err = ParseString("node 1\u3000key=2\u2009 3\u00a0\n")
+	assert.NoError(t, err)
+	if assert.Len(t, doc.Nodes, 1) {
+		assert.Len(t, doc.Nodes[0].Args, 2)
+		assert.True(t, doc.Nodes[0].HasProp("key"))
+	}
+}
+
+func TestParseNewlines(t *testing.T) {
+	for _, sep := range []string{"\n", "\r\n", "\r", "\u0085", "\f", "\u2028", "\u2029"} {
+		input := "a 1" + sep + "b \"x\" // comment" + sep + "c /* é */ \\" + sep + "  3" + sep + sep + "d"
+		doc, err := ParseString(input)
+		assert.NoError(t, err, "%q", input)
+		if assert.Len(t, doc.Nodes, 4, "%q", input) {
+			assert.EqualValues(t, "c", doc.Nodes[2].Name)
+			assert.Len(t, doc.Nodes[2].Args, 1)
+			assert.EqualValues(t, "d", doc.Nodes[3].Name)
+		}
+	}
+}
+
+func TestParseCarriageReturnOnly(t *testing.T) {
+	doc, err := ParseString("a\r\r\rb {\r  c 1\r  d\r}\r\re \"\r\"\r")
+	assert.NoError(t, err)
+	if assert.Len(t, doc.Nodes, 3) {
+		assert.Len(t, doc.Nodes[1].Children, 2)
+		assert.Equal(t, "\r", doc.Nodes[2].Args[0].StringValue())
+	}
+
+	// Line numbers count a lone CR as a line break
+	_, err = ParseString("a\rb\r;")
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 3, pe.Line)
+	}
+}
+
+func TestSlashdashAllowsSpaceBeforeTarget(t *testing.T) {
+	// Each document has a single node "node" with a single argument 2 and no children
+	common := []string{
+		"node /- \\\n  1 2",
+		"node /- /* why */ 1 2",
+		"node /- \\ // why\n  1 2",
+		"node /-\tkey=1 2",
+		"node 2 /- \\\n  { child; }",
+		"node 2 /- /* why */ { child; }",
+		"/- \\\n  other\nnode 2",
+		"/- /* why */ other\nnode 2",
+	}
+	onlyV2 := []string{
+		"node /-\n  1 2",
+		"node /- // why\n  1 2",
+		"node /-\n\n  key=1 2",
+		"node 2 /-\n  { child; }",
+		"/-\nother\nnode 2",
+		"/- // why\n\n  other\nnode 2",
+	}
+
+	check := func(input string, version Version) {
+		doc, err := ParseString(input, WithVersion(version))
+		if !assert.NoError(t, err, "%q in %v", input, version) || !assert.Len(t, doc.Nodes, 1, input) {
+			return
+		}
+		node := doc.Nodes[0]
+		assert.EqualValues(t, "node", node.Name, input)
+		assert.Empty(t, node.Props, input)
+		assert.Empty(t, node.Children, input)
+		if assert.Len(t, node.Args, 1, input) {
+			assert.EqualValues(t, 2, node.Args[0].IntegerValue().Int64(), input)
+		}
+	}
+
+	for _, input := range common {
+		check(input, Version1)
+		check(input, Version2)
+	}
+	for _, input := range onlyV2 {
+		check(input, Version2)
+
+		_, err := ParseString(input, WithVersion(Version1))
+		assert.ErrorIs(t, err, errUnexpectedSlashdash, input)
+	}
+}
+
+func TestQuotedPropertyKeys(t *testing.T) {
+	cases := map[string]struct {
+		key     Identifier
+		written string
+	}{
+		`node "a=b"=1`:                        {"a=b", `node "a=b"=1`},
+		`node "has space"=2`:                  {"has space", `node "has space"=2`},
+		`node "1key"=3`:                       {"1key", `node "1key"=3`},
+		`node "tab\t\"quote\" \u{1F600}\\"=4`: {"tab\t\"quote\" \U0001F600\\", "node \"tab\\t\\\"quote\\\" \U0001F600\\\\\"=4"},
+		`node ""=5`:                           {"", `node ""=5`},
+		`node "plain"=6`:                      {"plain", `node "plain"=6`},
+		`node r#"raw "key""#=7`:               {`raw "key"`, `node r#"raw "key""#=7`},
+		`node "a"=1 "a=b"`:                    {"a", `node "a=b" "a"=1`},
+	}
+	for input, expected := range cases {
+		doc, err := ParseString(input + "\n")
+		if !assert.NoError(t, err, input) {
+			continue
+		}
+		props := doc.Nodes[0].Props
+		if assert.Len(t, props, 1, input) {
+			_, ok := props[expected.key]
+			assert.True(t, ok, "%s: missing key %q", input, expected.key)
+		}
+
+		written, err := doc.WriteString()
+		assert.NoError(t, err)
+		assert.Equal(t, expected.written+"\n", written, input)
+
+		again, err := ParseString(written)
+		if assert.NoError(t, err, written) {
+			_, ok := again.Nodes[0].Props[expected.key]
+			assert.True(t, ok, "%s: missing key %q after writing", input, expected.key)
+		}
+	}
+}
+
+func TestTypeHintsOnPropertyValues(t *testing.T) {
+	input := "node key=(u8)42 date=(date)\"2024-01-01\" big=(i128)1 (u8)7\n"
+	doc, err := ParseString(input)
+	if !assert.NoError(t, err) {
+		return
+	}
+	props := doc.Nodes[0].Props
+	assert.EqualValues(t, "u8", props["key"].TypeHint.MustGet())
+	assert.EqualValues(t, 42, props["key"].IntegerValue().Int64())
+	assert.EqualValues(t, "date", props["date"].TypeHint.MustGet())
+	assert.EqualValues(t, "u8", doc.Nodes[0].Args[0].TypeHint.MustGet())
+
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, "node (u8)7 key=(u8)42 date=(date)\"2024-01-01\" big=(i128)1\n", written)
+}
+
+func TestRejectsTypeHintsOnPropertyKeys(t *testing.T) {
+	for _, input := range []string{"node (hint)key=1\n", "node (hint)\"key\"=1\n", "node 1 (u8)x=(u8)2\n"} {
+		_, err := ParseString(input)
+		assert.ErrorIs(t, err, errHintOnPropertyKey, input)
+		assert.ErrorContains(t, err, "key=(hint)value", input)
+
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe) {
+			assert.Equal(t, strings.Index(input, "("), pe.Offset, input)
+		}
+	}
+
+	// A string argument may still look like a property
+	doc, err := ParseString("node (hint)\"key=1\"\n")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "key=1", doc.Nodes[0].Args[0].StringValue())
+	}
+}
+
+func TestParseSkipsByteOrderMark(t *testing.T) {
+	doc, err := ParseString("\uFEFFnode 1\n")
+	if assert.NoError(t, err) && assert.Len(t, doc.Nodes, 1) {
+		assert.EqualValues(t, "node", doc.Nodes[0].Name)
+	}
+
+	doc, err = ParseString("\uFEFF")
+	assert.NoError(t, err)
+	assert.Empty(t, doc.Nodes)
+
+	doc, err = ParseString("\uFEFF// comment\n(t)node")
+	if assert.NoError(t, err) && assert.Len(t, doc.Nodes, 1) {
+		assert.EqualValues(t, "t", doc.Nodes[0].TypeHint.MustGet())
+	}
+
+	// The mark does not count towards columns, but it does count towards offsets
+	_, err = ParseString("\uFEFFnode \"x")
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 6, pe.Column)
+		assert.Equal(t, 8, pe.Offset)
+	}
+}
+
+func TestParseRejectsLaterByteOrderMarks(t *testing.T) {
+	for _, input := range []string{"\uFEFF\uFEFFnode", "node 1\n\uFEFFnext", "node \"\uFEFF\""} {
+		_, err := ParseString(input)
+		assert.ErrorIs(t, err, errDisallowedChar, input)
+	}
+}
+
+func TestParseStrictUTF8(t *testing.T) {
+	invalid := map[string]int{
+		"node \"a\xc3\"\n":      7, // A lone lead byte
+		"node 1 \xc0\xaf/ c\n":  7, // An overlong encoding of '/'
+		"node \"\xc0\xaf\"\n":   6,
+		"node \"\x80\"\n":       6,  // A stray continuation byte
+		"node \"\xed\xa0\x80\"": 6,  // An encoded surrogate
+		"// \xff\nnode\n":       3,  // In a comment
+		"na\xc3me 1\n":          2,  // In a node name
+		"node\nn\xc3":           6,  // At the end of the document
+		"node key=\"\xc3\xc3\"": 10, // Two lead bytes in a row
+	}
+	for input, offset := range invalid {
+		_, err := ParseString(input, WithStrictUTF8(true))
+		assert.ErrorIs(t, err, ErrInvalidEncoding, "%q", input)
+
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe, "%q", input) {
+			assert.Equal(t, CodeInvalidEncoding, pe.Code, "%q", input)
+			assert.Equal(t, offset, pe.Offset, "%q", input)
+		}
+	}
+
+	_, err := ParseString("a {\n  b \"\x80\"\n}\n", WithStrictUTF8(true))
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, []Identifier{"a", "b"}, pe.Path)
+	}
+
+	// A U+FFFD written in the document is valid
+	doc, err := ParseString("n� \"�\"\n", WithStrictUTF8(true))
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, "n�", doc.Nodes[0].Name)
+	}
+
+	// Without the option, invalid bytes in strings are replaced
+	doc, err = ParseString("node \"a\xc3\"\n")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a\xc3", doc.Nodes[0].Args[0].StringValue())
+	}
+}
+
+func TestParseFileAnnotatesErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.kdl")
+	assert.NoError(t, os.WriteFile(path, []byte("node 1\nnode 2 ; 3\n"), 0o644))
+
+	_, err := ParseFile(path)
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, path, pe.File)
+		assert.Equal(t, 2, pe.Line)
+		assert.True(t, strings.HasPrefix(err.Error(), path+":2:"), err.Error())
+		assert.NotContains(t, err.Error(), "invalid syntax")
+	}
+
+	// With error recovery, every error knows its file
+	assert.NoError(t, os.WriteFile(path, []byte("a ;1\nb ;2\n"), 0o644))
+	_, err = ParseFile(path, WithErrorRecovery(true))
+	joined, ok := err.(interface{ Unwrap() []error })
+	if assert.True(t, ok) && assert.Len(t, joined.Unwrap(), 2) {
+		for _, err := range joined.Unwrap() {
+			assert.ErrorAs(t, err, &pe)
+			assert.Equal(t, path, pe.File)
+		}
+	}
+
+	// Other errors keep their messages
+	assert.NoError(t, os.WriteFile(path, []byte("node \"x"), 0o644))
+	_, err = ParseFile(path)
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+	assert.Contains(t, err.Error(), path+":1:")
+	assert.Contains(t, err.Error(), "unterminated string")
+}
+
+func TestParseFileRecordsSourceName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.kdl")
+	assert.NoError(t, os.WriteFile(path, []byte("node 1\n"), 0o644))
+
+	doc, err := ParseFile(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, path, doc.SourceName)
+	}
+
+	doc, err = ParseString("node 1\n")
+	if assert.NoError(t, err) {
+		assert.Empty(t, doc.SourceName)
+	}
+}
+
+func TestParseFileMissing(t *testing.T) {
+	dir := t.TempDir()
+	_, err := ParseFile(filepath.Join(dir, "missing.kdl"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	var pe *ParseError
+	assert.False(t, errors.As(err, &pe))
+
+	// A dangling symlink is missing too
+	link := filepath.Join(dir, "link.kdl")
+	if os.Symlink(filepath.Join(dir, "nowhere.kdl"), link) == nil {
+		_, err = ParseFile(link)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	}
+}
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf/b.kdl":   {Data: []byte("b 2\n")},
+		"conf/a.kdl":   {Data: []byte("a 1\n")},
+		"conf/c.txt":   {Data: []byte("not kdl {")},
+		"conf/d/e.kdl": {Data: []byte("e 5\n")},
+	}
+
+	docs, err := ParseFS(fsys, "conf/*.kdl")
+	if assert.NoError(t, err) && assert.Len(t, docs, 2) {
+		assert.Equal(t, "conf/a.kdl", docs[0].SourceName)
+		assert.EqualValues(t, "a", docs[0].Nodes[0].Name)
+		assert.Equal(t, "conf/b.kdl", docs[1].SourceName)
+		assert.EqualValues(t, "b", docs[1].Nodes[0].Name)
+	}
+
+	// A pattern matching nothing gives nothing
+	docs, err = ParseFS(fsys, "conf/*.json")
+	assert.NoError(t, err)
+	assert.Empty(t, docs)
+
+	_, err = ParseFS(fsys, "conf/[")
+	assert.ErrorIs(t, err, path.ErrBadPattern)
+
+	// Also works with a real directory
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "x.kdl"), []byte("x\n"), 0o644))
+	docs, err = ParseFS(os.DirFS(dir), "*.kdl")
+	if assert.NoError(t, err) && assert.Len(t, docs, 1) {
+		assert.Equal(t, "x.kdl", docs[0].SourceName)
+	}
+}
+
+func TestParseFSBadFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.kdl": {Data: []byte("a 1\n")},
+		"b.kdl": {Data: []byte("b ;1\n")},
+		"c.kdl": {Data: []byte("c \"x")},
+		"d.kdl": {Data: []byte("d 4\n")},
+	}
+
+	// By default, the first bad file stops everything
+	docs, err := ParseFS(fsys, "*.kdl")
+	assert.Nil(t, docs)
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, "b.kdl", pe.File)
+	}
+
+	// With error recovery, the other files are still parsed
+	docs, err = ParseFS(fsys, "*.kdl", WithErrorRecovery(true))
+	if assert.Len(t, docs, 4) {
+		assert.EqualValues(t, "a", docs[0].Nodes[0].Name)
+		assert.EqualValues(t, "d", docs[3].Nodes[0].Name)
+	}
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+	assert.Contains(t, err.Error(), "b.kdl:1:")
+	assert.Contains(t, err.Error(), "c.kdl:1:")
+	assert.NotContains(t, err.Error(), "a.kdl")
+
+	// Or only the files, each stopping at its first error
+	fsys["b.kdl"] = &fstest.MapFile{Data: []byte("b ;1\nb2 2\n")}
+	docs, err = ParseFS(fsys, "*.kdl", WithContinueOnError(true))
+	if assert.Len(t, docs, 4) {
+		assert.EqualValues(t, "a", docs[0].Nodes[0].Name)
+		assert.Empty(t, docs[1].Nodes)
+		assert.EqualValues(t, "d", docs[3].Nodes[0].Name)
+	}
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+	assert.Contains(t, err.Error(), "b.kdl:1:")
+	assert.Contains(t, err.Error(), "c.kdl:1:")
+
+	docs, err = ParseFS(fsys, "*.kdl", WithErrorRecovery(true))
+	if assert.Len(t, docs, 4) && assert.Len(t, docs[1].Nodes, 2) {
+		assert.EqualValues(t, "b2", docs[1].Nodes[1].Name)
+	}
+	assert.Error(t, err)
+}
+
+// encodeUTF16 encodes the text as UTF-16 with a byte order mark.
+func encodeUTF16(text string, order binary.ByteOrder) []byte {
+	units := utf16.Encode([]rune("\uFEFF" + text))
+	b := make([]byte, 2*len(units))
+	for i, unit := range units {
+		order.PutUint16(b[2*i:], unit)
+	}
+	return b
+}
+
+func TestParseUTF16(t *testing.T) {
+	text := "ノード \"żółw 🐢\" key=1\nnext\n"
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		doc, err := ParseBytes(encodeUTF16(text, order))
+		if assert.NoError(t, err, order) && assert.Len(t, doc.Nodes, 2) {
+			assert.EqualValues(t, "ノード", doc.Nodes[0].Name)
+			assert.Equal(t, "żółw 🐢", doc.Nodes[0].Args[0].StringValue())
+			assert.EqualValues(t, "next", doc.Nodes[1].Name)
+		}
+	}
+
+	// Positions refer to the decoded text, with the mark encoded as UTF-8
+	_, err := ParseBytes(encodeUTF16("żółw 1\nnode \"x", binary.LittleEndian))
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 2, pe.Line)
+		assert.Equal(t, 6, pe.Column)
+		assert.Equal(t, len("\uFEFFżółw 1\nnode "), pe.Offset)
+	}
+
+	// An unpaired surrogate is replaced
+	b := encodeUTF16("node \"x\"", binary.LittleEndian)
+	b[len(b)-4], b[len(b)-3] = 0x00, 0xd8
+	doc, err := ParseBytes(b)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "\uFFFD", doc.Nodes[0].Args[0].StringValue())
+	}
+
+	// The decoder reads UTF-16 too
+	dec := NewDecoder(bytes.NewReader(encodeUTF16("a\nb\n", binary.BigEndian)))
+	node, err := dec.Decode()
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, "a", node.Name)
+	}
+}
+
+func TestParseTruncatedUTF16(t *testing.T) {
+	for _, text := range []string{"node 1\n", "node \"ż", "node"} {
+		b := encodeUTF16(text, binary.LittleEndian)
+		_, err := ParseBytes(b[:len(b)-1])
+		assert.ErrorIs(t, err, ErrInvalidEncoding, text)
+
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe, text) {
+			assert.Equal(t, CodeInvalidEncoding, pe.Code, text)
+		}
+	}
+}
+
+func TestParseWithEncoding(t *testing.T) {
+	input := []byte("caf\xe9 \"\x80 5\"\n")
+	doc, err := ParseBytes(input, WithEncoding(charmap.Windows1252), WithStrictUTF8(true))
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, "café", doc.Nodes[0].Name)
+		assert.Equal(t, "€ 5", doc.Nodes[0].Args[0].StringValue())
+	}
+
+	// Offsets refer to the decoded text
+	_, err = ParseBytes([]byte("caf\xe9 \"x"), WithEncoding(charmap.Windows1252))
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 6, pe.Column)
+		assert.Equal(t, len("café "), pe.Offset)
+	}
+
+	// So does the decoder
+	dec := NewDecoder(bytes.NewReader(input), WithEncoding(charmap.ISO8859_1))
+	node, err := dec.Decode()
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, "café", node.Name)
+	}
+}
+
+func TestParseWithEncodingByteOrderMark(t *testing.T) {
+	utf16le := encodeUTF16("żółw 1\n", binary.LittleEndian)
+
+	// A mark that the encoding would take for text
+	for _, input := range [][]byte{utf16le, []byte("\uFEFFnode\n")} {
+		_, err := ParseBytes(input, WithEncoding(charmap.Windows1252))
+		assert.ErrorIs(t, err, ErrInvalidEncoding)
+
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe) {
+			assert.Equal(t, CodeInvalidEncoding, pe.Code)
+			assert.Equal(t, 1, pe.Line)
+		}
+	}
+
+	_, err := ParseBytes(utf16le, WithEncoding(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)))
+	assert.ErrorIs(t, err, ErrInvalidEncoding)
+
+	// A mark of the same encoding
+	for _, e := range []encoding.Encoding{
+		unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+		unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM),
+		unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	} {
+		doc, err := ParseBytes(utf16le, WithEncoding(e))
+		if assert.NoError(t, err, e) {
+			assert.EqualValues(t, "żółw", doc.Nodes[0].Name)
+		}
+	}
+
+	doc, err := ParseBytes([]byte("\uFEFFnode\n"), WithEncoding(unicode.UTF8))
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, "node", doc.Nodes[0].Name)
+	}
+}
+
+const commentedDocument = `// Server configuration
+/* applies to
+   all servers */
+server "main" port=80 /* http */ { // opening
+	// Where to listen
+	listen "0.0.0.0" // all interfaces
+	/-disabled 1
+	/* end of server */
+} // server done
+
+// Before last
+/-ignored {
+	// commented out with the node
+}
+last; // after semicolon
+// end of document
+`
+
+func TestParseKeepsComments(t *testing.T) {
+	doc, err := ParseString(commentedDocument, WithComments(true))
+	assert.NoError(t, err)
+	if !assert.Len(t, doc.Nodes, 2) || !assert.Len(t, doc.Nodes[0].Children, 1) {
+		return
+	}
+
+	server := doc.Nodes[0]
+	assert.Equal(t, []string{"// Server configuration", "/* applies to\n   all servers */"}, server.LeadingComments)
+	assert.Equal(t, "/* http */ // server done", server.TrailingComment)
+	assert.Equal(t, []string{"/* end of server */"}, server.EndComments)
+
+	listen := server.Children[0]
+	assert.Equal(t, []string{"// opening", "// Where to listen"}, listen.LeadingComments)
+	assert.Equal(t, "// all interfaces", listen.TrailingComment)
+	assert.Empty(t, listen.EndComments)
+
+	// The comments before a node commented out with a slashdash move to the next one
+	last := doc.Nodes[1]
+	assert.Equal(t, []string{"// Before last"}, last.LeadingComments)
+	assert.Equal(t, "// after semicolon", last.TrailingComment)
+
+	assert.Equal(t, []string{"// end of document"}, doc.EndComments)
+}
+
+func TestParseDropsCommentsByDefault(t *testing.T) {
+	doc, err := ParseString(commentedDocument)
+	assert.NoError(t, err)
+	assert.Empty(t, doc.EndComments)
+	doc.Walk(func(_ []*Node, n *Node) WalkAction {
+		assert.Empty(t, n.LeadingComments)
+		assert.Empty(t, n.TrailingComment)
+		assert.Empty(t, n.EndComments)
+		return WalkContinue
+	})
+}
+
+func TestParseKeepsBlankLines(t *testing.T) {
+	doc, err := ParseString(`
+
+first
+second
+
+third {
+
+	child // comment
+	/* a comment line is not blank */
+
+	/-dropped
+
+	other
+
+
+
+}
+fourth; fifth
+
+
+
+sixth
+`, WithBlankLines(true))
+	assert.NoError(t, err)
+
+	blankLines := func(nodes []Node) []int {
+		var counts []int
+		for _, n := range nodes {
+			counts = append(counts, n.BlankLinesBefore)
+		}
+		return counts
+	}
+	assert.Equal(t, []int{2, 0, 1, 0, 0, 2}, blankLines(doc.Nodes))
+	// The blank lines before a node commented out with a slashdash are added to the next one, up to the limit
+	assert.Equal(t, []int{1, 2}, blankLines(doc.Nodes[2].Children))
+
+	doc, err = ParseString("a\n\nb\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 0}, blankLines(doc.Nodes))
+}
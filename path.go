@@ -0,0 +1,81 @@
+package kdl
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Nodes are stored by value in the slices of their parents, so they do not keep references to them:
+// a reference would be left stale every time a slice of children is reallocated or a Node is copied.
+// Instead, the place of a node is found by walking the Document.
+
+// ParentOf finds where a node is in this Document: its parent and its index among the children of the parent.
+// The parent of a top-level node is nil, with the index into Nodes.
+//
+// The node is looked up by its address, e.g. as returned by Child or Walk,
+// so ok is false for a copy of a node, or after the slice holding it has been reallocated.
+func (d *Document) ParentOf(n *Node) (parent *Node, index int, ok bool) {
+	_ = d.Walk(func(path []*Node, visited *Node) WalkAction {
+		if visited != n {
+			return WalkContinue
+		}
+		siblings := d.Nodes
+		if len(path) > 0 {
+			parent = path[len(path)-1]
+			siblings = parent.Children
+		}
+		index = indexOfAddress(siblings, n)
+		ok = true
+		return WalkStop
+	})
+	return parent, index, ok
+}
+
+// PathOf returns a printable path of a node in this Document, e.g. `server > listen[1] > port`,
+// looking the node up like ParentOf does.
+//
+// Each of the nodes on the path is written by its name, quoted if needed.
+// If the parent of a node has more children with the same name,
+// the name is followed by the index of the node among them, starting at 0.
+func (d *Document) PathOf(n *Node) (string, bool) {
+	var found []*Node
+	_ = d.Walk(func(path []*Node, visited *Node) WalkAction {
+		if visited != n {
+			return WalkContinue
+		}
+		found = append(append(found, path...), n)
+		return WalkStop
+	})
+	if found == nil {
+		return "", false
+	}
+
+	var b strings.Builder
+	w := writer{writer: bufio.NewWriter(&b)}
+	siblings := d.Nodes
+	for i, node := range found {
+		if i > 0 {
+			_, _ = w.writer.WriteString(" > ")
+		}
+		_ = writeIdentifier(&w, node.Name)
+		if named := findChildren(siblings, node.Name); len(named) > 1 {
+			_, _ = w.writer.WriteString("[" + strconv.Itoa(slices.Index(named, node)) + "]")
+		}
+		siblings = node.Children
+	}
+	_ = w.writer.Flush()
+	return b.String(), true
+}
+
+// indexOfAddress returns the index of the node in the slice, comparing addresses, or -1.
+func indexOfAddress(nodes []Node, n *Node) int {
+	for i := range nodes {
+		if &nodes[i] == n {
+			return i
+		}
+	}
+	return -1
+}
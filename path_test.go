@@ -0,0 +1,72 @@
+package kdl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const pathDocument = `server {
+	listen 80
+	listen 443 {
+		port
+	}
+	"root dir" "/srv"
+}
+server
+client
+`
+
+func TestDocumentParentOf(t *testing.T) {
+	doc, err := ParseString(pathDocument)
+	assert.NoError(t, err)
+
+	parent, index, ok := doc.ParentOf(&doc.Nodes[2])
+	assert.True(t, ok)
+	assert.Nil(t, parent)
+	assert.Equal(t, 2, index)
+
+	port := &doc.Nodes[0].Children[1].Children[0]
+	parent, index, ok = doc.ParentOf(port)
+	assert.True(t, ok)
+	assert.Same(t, &doc.Nodes[0].Children[1], parent)
+	assert.Equal(t, 0, index)
+
+	parent, index, ok = doc.ParentOf(parent)
+	assert.True(t, ok)
+	assert.Same(t, &doc.Nodes[0], parent)
+	assert.Equal(t, 1, index)
+
+	// Copies and detached nodes are not in the document
+	copied := doc.Nodes[2]
+	_, _, ok = doc.ParentOf(&copied)
+	assert.False(t, ok)
+	client := &doc.Nodes[2]
+	assert.Equal(t, 1, doc.RemoveChildren("client"))
+	_, _, ok = doc.ParentOf(client)
+	assert.False(t, ok)
+}
+
+func TestDocumentPathOf(t *testing.T) {
+	doc, err := ParseString(pathDocument)
+	assert.NoError(t, err)
+
+	cases := map[*Node]string{
+		&doc.Nodes[0]:                         "server[0]",
+		&doc.Nodes[1]:                         "server[1]",
+		&doc.Nodes[2]:                         "client",
+		&doc.Nodes[0].Children[0]:             "server[0] > listen[0]",
+		&doc.Nodes[0].Children[1].Children[0]: "server[0] > listen[1] > port",
+		&doc.Nodes[0].Children[2]:             `server[0] > "root dir"`,
+	}
+	for n, want := range cases {
+		path, ok := doc.PathOf(n)
+		assert.True(t, ok, want)
+		assert.Equal(t, want, path)
+	}
+
+	leaf := NewNode("leaf")
+	path, ok := doc.PathOf(&leaf)
+	assert.False(t, ok)
+	assert.Empty(t, path)
+}
@@ -0,0 +1,101 @@
+package kdl
+
+import "strconv"
+
+// Position is a place in a document that has been parsed, see ParseOptions.KeepPositions.
+// Like the ones of a ParseError, positions refer to the text after it has been decoded,
+// e.g. with a UTF-16 document, and a CRLF is a single line break.
+type Position struct {
+	Offset int // Byte offset, 0-indexed.
+	Line   int // Line, 1-indexed.
+	Column int // Column in runes, 1-indexed.
+}
+
+// IsValid reports whether the position has been recorded.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position as "line:column", or "-" if it is not valid.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
+
+func (p position) exported() Position {
+	return Position{Offset: p.offset, Line: p.line, Column: p.column}
+}
+
+// nodePosition is where the parts of a node are.
+type nodePosition struct {
+	hint, name Position
+	nameEnd    int
+	entries    []EntrySpan
+	children   Span
+	end        int // End of the last part of the node read so far.
+}
+
+// valuePosition is where the parts of a value start.
+type valuePosition struct {
+	hint, value Position
+}
+
+func newNodePosition(hint TypeHint, hintStart, nameStart position) *nodePosition {
+	p := &nodePosition{name: nameStart.exported(), nameEnd: nameStart.offset, end: nameStart.offset}
+	if hint.IsPresent() {
+		p.hint = hintStart.exported()
+	}
+	return p
+}
+
+// positioned records where a value read with the provided type hint starts, if ParseOptions.KeepPositions is enabled.
+func (r *reader) positioned(v Value, hintStart, start position) Value {
+	if r.keepPositions() {
+		v.position = &valuePosition{value: start.exported()}
+		if v.TypeHint.IsPresent() {
+			v.position.hint = hintStart.exported()
+		}
+	}
+	return v
+}
+
+// keepPositions reports whether the positions of the nodes and values should be recorded.
+func (r *reader) keepPositions() bool {
+	return r.cfg.KeepPositions || r.cfg.KeepFormat
+}
+
+// Position returns where the name of the node has been read from, if ParseOptions.KeepPositions was enabled.
+// Nodes that have not been parsed have no position. The position is kept by Clone.
+func (n *Node) Position() (Position, bool) {
+	if n.position == nil {
+		return Position{}, false
+	}
+	return n.position.name, true
+}
+
+// HintPosition returns where the type hint of the node has been read from, like Position does.
+func (n *Node) HintPosition() (Position, bool) {
+	if n.position == nil || !n.position.hint.IsValid() {
+		return Position{}, false
+	}
+	return n.position.hint, true
+}
+
+// Position returns where the value has been read from, not including its type hint,
+// if ParseOptions.KeepPositions was enabled. Values that have not been parsed have no position.
+func (v Value) Position() (Position, bool) {
+	if v.position == nil {
+		return Position{}, false
+	}
+	return v.position.value, true
+}
+
+// HintPosition returns where the type hint of the value has been read from, like Position does.
+func (v Value) HintPosition() (Position, bool) {
+	if v.position == nil || !v.position.hint.IsValid() {
+		return Position{}, false
+	}
+	return v.position.hint, true
+}
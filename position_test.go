@@ -0,0 +1,77 @@
+package kdl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPositions(t *testing.T) {
+	// The line breaks are CRLFs, and the characters before the targets take more than a byte each
+	src := "// żółw\r\nnœud \"é\" (u8)1\r\n\r\n(t)über k=(f32)2.5 {\r\n    ß #true\r\n}\r\n"
+	doc, err := ParseString(src, WithPositions(true), WithVersion(Version2))
+	assert.NoError(t, err)
+
+	assertPosition := func(want Position, got Position, ok bool) {
+		t.Helper()
+		assert.True(t, ok)
+		assert.Equal(t, want, got)
+	}
+
+	nœud := &doc.Nodes[0]
+	pos, ok := nœud.Position()
+	assertPosition(Position{Offset: 12, Line: 2, Column: 1}, pos, ok)
+	_, ok = nœud.HintPosition()
+	assert.False(t, ok)
+	pos, ok = nœud.Args[0].Position()
+	assertPosition(Position{Offset: 18, Line: 2, Column: 6}, pos, ok)
+	pos, ok = nœud.Args[1].HintPosition()
+	assertPosition(Position{Offset: 23, Line: 2, Column: 10}, pos, ok)
+	pos, ok = nœud.Args[1].Position()
+	assertPosition(Position{Offset: 27, Line: 2, Column: 14}, pos, ok)
+
+	über := &doc.Nodes[1]
+	pos, ok = über.HintPosition()
+	assertPosition(Position{Offset: 32, Line: 4, Column: 1}, pos, ok)
+	pos, ok = über.Position()
+	assertPosition(Position{Offset: 35, Line: 4, Column: 4}, pos, ok)
+	k, _ := über.PropValue("k")
+	pos, ok = k.HintPosition()
+	assertPosition(Position{Offset: 43, Line: 4, Column: 11}, pos, ok)
+	pos, ok = k.Position()
+	assertPosition(Position{Offset: 48, Line: 4, Column: 16}, pos, ok)
+
+	ß := &über.Children[0]
+	pos, ok = ß.Position()
+	assertPosition(Position{Offset: 59, Line: 5, Column: 5}, pos, ok)
+	assert.Equal(t, "5:5", pos.String())
+	pos, ok = ß.Args[0].Position()
+	assertPosition(Position{Offset: 62, Line: 5, Column: 7}, pos, ok)
+
+	// The positions are kept by Clone
+	clone := doc.Clone()
+	pos, _ = clone.Nodes[1].Children[0].Args[0].Position()
+	assert.Equal(t, 62, pos.Offset)
+	k, _ = clone.Nodes[1].PropValue("k")
+	pos, _ = k.Position()
+	assert.Equal(t, 48, pos.Offset)
+}
+
+func TestPositionsAreOptional(t *testing.T) {
+	doc, err := ParseString("a 1\n")
+	assert.NoError(t, err)
+	_, ok := doc.Nodes[0].Position()
+	assert.False(t, ok)
+	pos, ok := doc.Nodes[0].Args[0].Position()
+	assert.False(t, ok)
+	assert.False(t, pos.IsValid())
+	assert.Equal(t, "-", pos.String())
+
+	// New values have no position either
+	doc, err = ParseString("a 1\n", WithPositions(true))
+	assert.NoError(t, err)
+	doc.Nodes[0].SetProp("b", 2)
+	b, _ := doc.Nodes[0].PropValue("b")
+	_, ok = b.Position()
+	assert.False(t, ok)
+}
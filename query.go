@@ -0,0 +1,714 @@
+package kdl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QueryError describes a malformed KDL Query Language query.
+// It matches ErrInvalidQuery.
+type QueryError struct {
+	Query  string // The query being parsed.
+	Offset int    // Byte offset in the query where the error occurred, 0-indexed.
+	Msg    string // What went wrong.
+}
+
+func (e *QueryError) Error() string {
+	return ErrInvalidQuery.Error() + " at offset " + strconv.Itoa(e.Offset) + ": " + e.Msg
+}
+
+func (e *QueryError) Unwrap() error {
+	return ErrInvalidQuery
+}
+
+// Query finds the nodes of this Document, at any depth, selected by a query
+// in the KDL Query Language, in the order of the document. For example:
+//
+//	top() > package dependencies[platform = "windows"] > []
+//
+// The query is made of selectors separated by "||", any of which can select a node. A selector is
+// a chain of matchers joined with combinators: "a > b" selects b directly under a,
+// "a b" or "a >> b" selects b anywhere under a, "a + b" selects b just after its sibling a,
+// and "a ~ b" selects b after its sibling a. "top()" at the start of a selector makes it
+// only match top-level nodes, and selects all of them on its own.
+//
+// A matcher consists of, in order and all optional, but at least one of:
+//   - a type annotation, e.g. "(u8)", or "()" for any,
+//   - the name of the node, quoted if needed, or "*" for any,
+//   - filters in brackets, e.g. "[val(1) > 2]", or "[]" for any node.
+//
+// A filter either checks that a value exists or compares it with a literal using one of
+// =, !=, >, >=, <, <= (numbers only), ^=, $= and *= (strings only: prefix, suffix and substring).
+// The values are "val()" for the first argument, "val(i)" or just "i" for the argument at index i,
+// "prop(key)" or just "key" for a property, "name()" for the name of the node
+// and "tag()" for its type annotation.
+//
+// A malformed query makes a QueryError returned.
+func (d *Document) Query(q string) (NodeSet, error) {
+	parsed, err := parseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.find(d), nil
+}
+
+// Select finds the nodes of this Document, at any depth, matching a selector, in the order of the document.
+// The selectors are a simpler subset of the KDL Query Language of Query, e.g.:
+//
+//	server > listen[port = 8080]
+//	dependency[name ^= "lib"]
+//	* > route[0 = "/"]
+//
+// A selector is a chain of names of nodes, or "*" for any name, joined with ">": "a > b" selects b directly under a.
+// Each of the names may be followed by predicates in brackets, either a key of a property
+// or an index of an argument, optionally compared with a literal string, number or boolean
+// using one of the operators of Query. A predicate without an operator checks that the value exists.
+//
+// A malformed selector makes a QueryError returned, pointing at the offending character.
+func (d *Document) Select(selector string) (NodeSet, error) {
+	p := &queryParser{src: selector, simple: true}
+	parsed, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return parsed.find(d), nil
+}
+
+// SetAll sets or replaces a property of all the nodes of this Document matching a selector, like Select does.
+// It returns how many nodes have been changed.
+func (d *Document) SetAll(selector string, key Identifier, value Value) (int, error) {
+	found, err := d.Select(selector)
+	if err != nil {
+		return 0, err
+	}
+	for _, n := range found {
+		n.SetPropValue(key, value)
+	}
+	return len(found), nil
+}
+
+// RemoveAll removes all the nodes of this Document matching a selector, like Select does,
+// keeping the order of the rest. It returns how many nodes have been removed,
+// not counting the matching nodes inside other removed nodes.
+func (d *Document) RemoveAll(selector string) (int, error) {
+	found, err := d.Select(selector)
+	if err != nil {
+		return 0, err
+	}
+	// All the nodes are matched before any is removed, so that the removals do not affect the matching
+	matched := make(map[*Node]bool, len(found))
+	for _, n := range found {
+		matched[n] = true
+	}
+	return removeMatched(&d.Nodes, matched), nil
+}
+
+// removeMatched removes the nodes at the addresses from the slice and the children of the nodes left, at any depth.
+func removeMatched(nodes *[]Node, matched map[*Node]bool) int {
+	var removed int
+	*nodes, removed = removeNodesFunc(*nodes, func(n *Node) bool { return matched[n] })
+	for i := range *nodes {
+		removed += removeMatched(&(*nodes)[i].Children, matched)
+	}
+	return removed
+}
+
+// query is a parsed query: a list of alternative selectors.
+type query []selector
+
+type selector struct {
+	top         bool
+	compounds   []compound
+	combinators []combinator // Between each pair of compounds.
+}
+
+type combinator int
+
+const (
+	combinatorChild      combinator = iota // a > b
+	combinatorDescendant                   // a b, a >> b
+	combinatorNext                         // a + b
+	combinatorFollowing                    // a ~ b
+)
+
+// compound is a matcher of a single node.
+type compound struct {
+	hasTag  bool       // A type annotation is required.
+	tag     Identifier // The required type annotation. Any if empty.
+	anyTag  bool       // "()"
+	hasName bool
+	name    Identifier
+	filters []filter
+}
+
+type filter struct {
+	accessor accessor
+	op       operator
+	value    Value // The literal to compare with, if op is not operatorNone.
+}
+
+type accessorKind int
+
+const (
+	accessorVal accessorKind = iota
+	accessorProp
+	accessorName
+	accessorTag
+)
+
+type accessor struct {
+	kind  accessorKind
+	index int        // For accessorVal.
+	key   Identifier // For accessorProp.
+}
+
+type operator int
+
+const (
+	operatorNone operator = iota
+	operatorEqual
+	operatorNotEqual
+	operatorGreater
+	operatorGreaterEqual
+	operatorLess
+	operatorLessEqual
+	operatorPrefix
+	operatorSuffix
+	operatorContains
+)
+
+// operators are ordered, so that the longer ones are tried first.
+var operators = []struct {
+	text string
+	op   operator
+}{
+	{">=", operatorGreaterEqual},
+	{"<=", operatorLessEqual},
+	{"!=", operatorNotEqual},
+	{"^=", operatorPrefix},
+	{"$=", operatorSuffix},
+	{"*=", operatorContains},
+	{"=", operatorEqual},
+	{">", operatorGreater},
+	{"<", operatorLess},
+}
+
+// find returns the nodes of the document selected by the query, in the order of the document.
+func (q query) find(d *Document) NodeSet {
+	var found NodeSet
+	_ = d.Walk(func(path []*Node, n *Node) WalkAction {
+		if q.matches(d, path, n) {
+			found = append(found, n)
+		}
+		return WalkContinue
+	})
+	return found
+}
+
+// matches checks if the node, with the ancestors in the path, is selected by the query.
+func (q query) matches(d *Document, path []*Node, n *Node) bool {
+	for _, s := range q {
+		if s.matches(d, path, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s selector) matches(d *Document, path []*Node, n *Node) bool {
+	if len(s.compounds) == 0 {
+		return s.top && len(path) == 0
+	}
+	return s.matchesFrom(d, len(s.compounds)-1, path, n)
+}
+
+// matchesFrom checks the compounds up to i from right to left, with the compound i matched against the node.
+func (s selector) matchesFrom(d *Document, i int, path []*Node, n *Node) bool {
+	if !s.compounds[i].matches(n) {
+		return false
+	}
+	if i == 0 {
+		return !s.top || len(path) == 0
+	}
+
+	switch s.combinators[i-1] {
+	case combinatorChild:
+		if len(path) == 0 {
+			return false
+		}
+		return s.matchesFrom(d, i-1, path[:len(path)-1], path[len(path)-1])
+	case combinatorDescendant:
+		for k := len(path) - 1; k >= 0; k-- {
+			if s.matchesFrom(d, i-1, path[:k], path[k]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	siblings := d.Nodes
+	if len(path) > 0 {
+		siblings = path[len(path)-1].Children
+	}
+	index := indexOfAddress(siblings, n)
+	if s.combinators[i-1] == combinatorNext {
+		return index > 0 && s.matchesFrom(d, i-1, path, &siblings[index-1])
+	}
+	for j := index - 1; j >= 0; j-- {
+		if s.matchesFrom(d, i-1, path, &siblings[j]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compound) matches(n *Node) bool {
+	if c.hasTag {
+		hint, ok := n.TypeHint.Get()
+		if !ok || (!c.anyTag && hint != c.tag) {
+			return false
+		}
+	}
+	if c.hasName && n.Name != c.name {
+		return false
+	}
+	for i := range c.filters {
+		if !c.filters[i].matches(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *filter) matches(n *Node) bool {
+	v, ok := f.accessor.get(n)
+	if !ok {
+		return false
+	}
+
+	switch f.op {
+	case operatorNone:
+		return true
+	case operatorEqual, operatorNotEqual:
+		equal := EqualOptions{IgnoreTypeHints: true, IgnoreNumberKinds: true}.Values(v, f.value)
+		return equal == (f.op == operatorEqual)
+	case operatorPrefix, operatorSuffix, operatorContains:
+		s, ok := v.asString()
+		literal, _ := f.value.asString()
+		switch {
+		case !ok:
+			return false
+		case f.op == operatorPrefix:
+			return strings.HasPrefix(s, literal)
+		case f.op == operatorSuffix:
+			return strings.HasSuffix(s, literal)
+		}
+		return strings.Contains(s, literal)
+	}
+
+	if v.Type != TypeInteger && v.Type != TypeFloat {
+		return false
+	}
+	a, b := bigFloat(v), bigFloat(f.value)
+	if a == nil || b == nil {
+		// NaN is not ordered
+		return false
+	}
+	cmp := a.Cmp(b)
+	switch f.op {
+	case operatorGreater:
+		return cmp > 0
+	case operatorGreaterEqual:
+		return cmp >= 0
+	case operatorLess:
+		return cmp < 0
+	}
+	return cmp <= 0
+}
+
+func (a *accessor) get(n *Node) (Value, bool) {
+	switch a.kind {
+	case accessorVal:
+		return n.Arg(a.index)
+	case accessorProp:
+		return n.PropValue(a.key)
+	case accessorName:
+		return NewStringValue(string(n.Name), NoHint()), true
+	}
+	hint, ok := n.TypeHint.Get()
+	if !ok {
+		return newInvalidValue(), false
+	}
+	return NewStringValue(string(hint), NoHint()), true
+}
+
+// queryParser reads a query.
+type queryParser struct {
+	src    string
+	pos    int
+	simple bool // Only the syntax of Select is allowed.
+}
+
+func parseQuery(src string) (query, error) {
+	p := &queryParser{src: src}
+	return p.parse()
+}
+
+func (p *queryParser) parse() (query, error) {
+	var q query
+	for {
+		p.skipSpace()
+		s, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		q = append(q, s)
+		p.skipSpace()
+		if p.pos == len(p.src) {
+			return q, nil
+		}
+		if p.simple || !p.consume("||") {
+			return nil, p.errorf("expected %s or the end of the query", p.expectedCombinator())
+		}
+	}
+}
+
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	return &QueryError{Query: p.src, Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// skipSpace skips the whitespace, reporting if there was any.
+func (p *queryParser) skipSpace() bool {
+	start := p.pos
+	for p.pos < len(p.src) {
+		ch, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !unicode.IsSpace(ch) {
+			break
+		}
+		p.pos += size
+	}
+	return p.pos > start
+}
+
+func (p *queryParser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(s string) error {
+	if !p.consume(s) {
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+// atSelectorEnd checks if the selector ends here, i.e. at "||" or at the end of the query.
+func (p *queryParser) atSelectorEnd() bool {
+	return p.pos == len(p.src) || !p.simple && strings.HasPrefix(p.src[p.pos:], "||")
+}
+
+func (p *queryParser) expectedCombinator() string {
+	if p.simple {
+		return "\">\""
+	}
+	return "a combinator, \"||\""
+}
+
+func (p *queryParser) parseSelector() (selector, error) {
+	var s selector
+	if !p.simple && p.consume("top()") {
+		s.top = true
+		p.skipSpace()
+		if p.atSelectorEnd() {
+			return s, nil
+		}
+		if strings.HasPrefix(p.src[p.pos:], ">>") || !p.consume(">") {
+			return s, p.errorf("expected \">\" after top()")
+		}
+		p.skipSpace()
+	}
+
+	for {
+		c, err := p.parseCompound()
+		if err != nil {
+			return s, err
+		}
+		s.compounds = append(s.compounds, c)
+
+		spaced := p.skipSpace()
+		if p.atSelectorEnd() {
+			return s, nil
+		}
+		switch {
+		case p.simple:
+			if strings.HasPrefix(p.src[p.pos:], ">>") || !p.consume(">") {
+				return s, p.errorf("expected \">\" or the end of the query")
+			}
+			s.combinators = append(s.combinators, combinatorChild)
+		case p.consume(">>"):
+			s.combinators = append(s.combinators, combinatorDescendant)
+		case p.consume(">"):
+			s.combinators = append(s.combinators, combinatorChild)
+		case p.consume("+"):
+			s.combinators = append(s.combinators, combinatorNext)
+		case p.consume("~"):
+			s.combinators = append(s.combinators, combinatorFollowing)
+		case spaced:
+			s.combinators = append(s.combinators, combinatorDescendant)
+		default:
+			return s, p.errorf("expected %s or the end of the query", p.expectedCombinator())
+		}
+		p.skipSpace()
+	}
+}
+
+func (p *queryParser) parseCompound() (compound, error) {
+	var c compound
+	start := p.pos
+
+	if !p.simple && p.consume("(") {
+		c.hasTag = true
+		p.skipSpace()
+		if p.consume(")") {
+			c.anyTag = true
+		} else {
+			tag, err := p.parseIdentifier()
+			if err != nil {
+				return c, err
+			}
+			c.tag = tag
+			p.skipSpace()
+			if err := p.expect(")"); err != nil {
+				return c, err
+			}
+		}
+	}
+
+	switch {
+	case p.consume("*"):
+		// Any name
+	case p.pos < len(p.src) && !isQueryDelimiter(p.peekRune()) || strings.HasPrefix(p.src[p.pos:], "\""):
+		name, err := p.parseIdentifier()
+		if err != nil {
+			return c, err
+		}
+		c.hasName = true
+		c.name = name
+	}
+
+	for p.consume("[") {
+		p.skipSpace()
+		if p.consume("]") {
+			// Any node
+			continue
+		}
+		f, err := p.parseFilter()
+		if err != nil {
+			return c, err
+		}
+		c.filters = append(c.filters, f)
+	}
+
+	if p.pos == start {
+		if p.pos == len(p.src) {
+			return c, p.errorf("expected a matcher, but the query has ended")
+		}
+		return c, p.errorf("expected a matcher, found %q", p.peekRune())
+	}
+	return c, nil
+}
+
+// parseFilter reads the inside of the brackets of a filter, after the "[".
+func (p *queryParser) parseFilter() (filter, error) {
+	var f filter
+	var err error
+	if f.accessor, err = p.parseAccessor(); err != nil {
+		return f, err
+	}
+	p.skipSpace()
+	if p.consume("]") {
+		return f, nil
+	}
+
+	for _, o := range operators {
+		if p.consume(o.text) {
+			f.op = o.op
+			break
+		}
+	}
+	if f.op == operatorNone {
+		return f, p.errorf("expected an operator or \"]\"")
+	}
+
+	p.skipSpace()
+	start := p.pos
+	if f.value, err = p.parseValue(); err != nil {
+		return f, err
+	}
+	switch f.op {
+	case operatorGreater, operatorGreaterEqual, operatorLess, operatorLessEqual:
+		if f.value.Type != TypeInteger && f.value.Type != TypeFloat {
+			p.pos = start
+			return f, p.errorf("only numbers can be compared with %s", operatorText(f.op))
+		}
+	case operatorPrefix, operatorSuffix, operatorContains:
+		if f.value.Type != TypeString {
+			p.pos = start
+			return f, p.errorf("only strings can be compared with %s", operatorText(f.op))
+		}
+	}
+
+	p.skipSpace()
+	return f, p.expect("]")
+}
+
+func operatorText(op operator) string {
+	for _, o := range operators {
+		if o.op == op {
+			return o.text
+		}
+	}
+	return ""
+}
+
+func (p *queryParser) parseAccessor() (accessor, error) {
+	if index, ok, err := p.parseIndex(); ok || err != nil {
+		return accessor{kind: accessorVal, index: index}, err
+	}
+
+	switch {
+	case p.simple:
+		// Only keys and indexes
+	case p.consume("val("):
+		p.skipSpace()
+		index, _, err := p.parseIndex()
+		if err != nil {
+			return accessor{}, err
+		}
+		p.skipSpace()
+		return accessor{kind: accessorVal, index: index}, p.expect(")")
+	case p.consume("prop("):
+		p.skipSpace()
+		key, err := p.parseIdentifier()
+		if err != nil {
+			return accessor{}, err
+		}
+		p.skipSpace()
+		return accessor{kind: accessorProp, key: key}, p.expect(")")
+	case p.consume("name()"):
+		return accessor{kind: accessorName}, nil
+	case p.consume("tag()"):
+		return accessor{kind: accessorTag}, nil
+	}
+
+	key, err := p.parseIdentifier()
+	return accessor{kind: accessorProp, key: key}, err
+}
+
+// parseIndex reads a decimal index of an argument, if there is one.
+func (p *queryParser) parseIndex() (int, bool, error) {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return 0, false, nil
+	}
+	index, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, false, p.errorf("invalid argument index")
+	}
+	return index, true, nil
+}
+
+func (p *queryParser) peekRune() rune {
+	ch, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return ch
+}
+
+// isQueryDelimiter checks if a character cannot be a part of a bare identifier in a query.
+func isQueryDelimiter(ch rune) bool {
+	return unicode.IsSpace(ch) || strings.ContainsRune("[]()>+~|=!<^$*\",", ch)
+}
+
+// parseIdentifier reads a bare or a quoted identifier.
+func (p *queryParser) parseIdentifier() (Identifier, error) {
+	if strings.HasPrefix(p.src[p.pos:], "\"") {
+		v, err := p.parseValue()
+		if err != nil {
+			return "", err
+		}
+		return Identifier(v.StringValue()), nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && !isQueryDelimiter(p.peekRune()) {
+		_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.pos += size
+	}
+	if p.pos == start {
+		if p.pos == len(p.src) {
+			return "", p.errorf("expected an identifier, but the query has ended")
+		}
+		return "", p.errorf("expected an identifier, found %q", p.peekRune())
+	}
+	return Identifier(p.src[start:p.pos]), nil
+}
+
+// parseValue reads a literal value, written like in a KDL document of either version.
+func (p *queryParser) parseValue() (Value, error) {
+	start := p.pos
+	rest := p.src[p.pos:]
+
+	switch {
+	case strings.HasPrefix(rest, "\""):
+		// A quoted string, ending at the first unescaped quote
+		end := 1
+		for end < len(rest) && rest[end] != '"' {
+			if rest[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(rest) {
+			return newInvalidValue(), p.errorf("unterminated string")
+		}
+		p.pos += end + 1
+	case strings.HasPrefix(rest, "r#"), strings.HasPrefix(rest, "r\""), strings.HasPrefix(rest, "#\""), strings.HasPrefix(rest, "##"):
+		// A raw string, ending at a quote followed by as many hashes as it has started with
+		text := strings.TrimPrefix(rest, "r")
+		hashes := len(text) - len(strings.TrimLeft(text, "#"))
+		if !strings.HasPrefix(text[hashes:], "\"") {
+			return newInvalidValue(), p.errorf("invalid raw string")
+		}
+		closing := "\"" + strings.Repeat("#", hashes)
+		end := strings.Index(text[hashes+1:], closing)
+		if end < 0 {
+			return newInvalidValue(), p.errorf("unterminated string")
+		}
+		p.pos += len(rest) - len(text) + hashes + 1 + end + len(closing)
+	default:
+		for p.pos < len(p.src) && p.src[p.pos] != ']' && !unicode.IsSpace(p.peekRune()) {
+			_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			p.pos += size
+		}
+	}
+
+	literal := p.src[start:p.pos]
+	if literal == "" {
+		return newInvalidValue(), p.errorf("expected a value")
+	}
+	doc, err := ParseString("_ "+literal+"\n", WithVersionDetection(true))
+	if err != nil || len(doc.Nodes) != 1 || len(doc.Nodes[0].Args) != 1 || len(doc.Nodes[0].Props) != 0 {
+		p.pos = start
+		return newInvalidValue(), p.errorf("invalid value %s", literal)
+	}
+	return doc.Nodes[0].Args[0], nil
+}
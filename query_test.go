@@ -0,0 +1,286 @@
+package kdl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// queryDocument is the example document of the KDL Query Language specification.
+const queryDocument = `package {
+	name "foo"
+	version "1.0.0"
+	dependencies platform="windows" {
+		winapi "1.0.0" path="./crates/my-winapi-fork"
+	}
+	dependencies {
+		miette "2.0.0" dev=#true
+	}
+}
+`
+
+func queryNames(t *testing.T, doc *Document, q string) []Identifier {
+	t.Helper()
+	found, err := doc.Query(q)
+	assert.NoError(t, err, q)
+	var names []Identifier
+	for _, n := range found {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+func TestQuerySpecExamples(t *testing.T) {
+	doc, err := ParseString(queryDocument, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	cases := map[string][]Identifier{
+		"package name":                 {"name"},
+		"top() > package name":         {"name"},
+		"dependencies":                 {"dependencies", "dependencies"},
+		"dependencies[platform]":       {"dependencies"},
+		"dependencies[prop(platform)]": {"dependencies"},
+		"dependencies > []":            {"winapi", "miette"},
+	}
+	for q, want := range cases {
+		assert.Equal(t, want, queryNames(t, &doc, q), q)
+	}
+
+	found, err := doc.Query("dependencies[platform]")
+	assert.NoError(t, err)
+	assert.Equal(t, NodeSet{&doc.Nodes[0].Children[2]}, found)
+}
+
+func TestQuery(t *testing.T) {
+	doc, err := ParseString(`a 1 "xyz" {
+	b 2 k="v" {
+		c
+	}
+	(t)c 3.5
+	"d e"
+	c
+}
+(u8)c 10
+`, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	cases := map[string][]Identifier{
+		"top()":                    {"a", "c"},
+		"top() > c":                {"c"},
+		"a c":                      {"c", "c", "c"},
+		"a >> c":                   {"c", "c", "c"},
+		"a > c":                    {"c", "c"},
+		"b > c":                    {"c"},
+		"a b c":                    {"c"},
+		"b + c":                    {"c"},
+		"b ~ c":                    {"c", "c"},
+		`"d e" + c`:                {"c"},
+		"b || top() > c":           {"b", "c"},
+		"()":                       {"c", "c"},
+		"(t)":                      {"c"},
+		"(u8)c":                    {"c"},
+		"[]":                       {"a", "b", "c", "c", "d e", "c", "c"},
+		"[val()]":                  {"a", "b", "c", "c"},
+		"[val(1)]":                 {"a"},
+		"[val() = 2]":              {"b"},
+		"[val() = 2.0]":            {"b"},
+		"[val() != 2]":             {"a", "c", "c"},
+		"[val() > 2]":              {"c", "c"},
+		"[val() >= 2]":             {"b", "c", "c"},
+		"[val() < 3.5]":            {"a", "b"},
+		"[val() <= 3.5]":           {"a", "b", "c"},
+		`[val(1) ^= "xy"]`:         {"a"},
+		`[val(1) $= "yz"]`:         {"a"},
+		`[val(1) *= "y"]`:          {"a"},
+		`[val(1) *= "q"]`:          nil,
+		`[k = "v"]`:                {"b"},
+		`[prop(k) = "v"]`:          {"b"},
+		`[k = #"v"#]`:              {"b"},
+		`[name() ^= "d"]`:          {"d e"},
+		`[tag() = "u8"]`:           {"c"},
+		`a[val() = 1][val(1)]`:     {"a"},
+		`a[val() = 1][val(1) = 1]`: nil,
+		"a > *":                    {"b", "c", "d e", "c"},
+		"*[1]":                     {"a"},
+	}
+	for q, want := range cases {
+		assert.Equal(t, want, queryNames(t, &doc, q), q)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	doc := NewDocument()
+	cases := map[string]int{
+		"":                 0,
+		"a >":              3,
+		"a > > b":          4,
+		"a ||":             4,
+		"top() b":          6,
+		"(u8":              3,
+		"a[":               2,
+		"a[val(]":          6,
+		"a[b ? 1]":         4,
+		"a[b = ]":          6,
+		`a[b = "x`:         6,
+		"a[b > \"x\"]":     6,
+		"a[b ^= 1]":        7,
+		"a[b = 1":          7,
+		"a[b = 1 2]":       8,
+		"a[b = 1-invalid]": 6,
+	}
+	for q, offset := range cases {
+		_, err := doc.Query(q)
+		assert.ErrorIs(t, err, ErrInvalidQuery, q)
+		var queryErr *QueryError
+		if assert.True(t, errors.As(err, &queryErr), q) {
+			assert.Equal(t, offset, queryErr.Offset, q)
+			assert.Equal(t, q, queryErr.Query)
+		}
+	}
+
+	_, err := doc.Query("a > > b")
+	assert.EqualError(t, err, `invalid query at offset 4: expected a matcher, found '>'`)
+}
+
+const selectDocument = `server "public" {
+	listen port=8080 tls=#false
+	listen port=8443 tls=#true
+}
+server "internal" {
+	listen port=8080
+}
+dependency name="libfoo" "1.0"
+dependency name="bar" "2.0"
+`
+
+func TestSelect(t *testing.T) {
+	doc, err := ParseString(selectDocument, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	found, err := doc.Select("server > listen[port=8080]")
+	assert.NoError(t, err)
+	assert.Equal(t, NodeSet{&doc.Nodes[0].Children[0], &doc.Nodes[1].Children[0]}, found)
+
+	found, err = doc.Select(`dependency[name^="lib"]`)
+	assert.NoError(t, err)
+	assert.Equal(t, NodeSet{&doc.Nodes[2]}, found)
+
+	cases := map[string]int{
+		"listen":                        3,
+		"*":                             7,
+		"* > *":                         3,
+		"*[tls]":                        2,
+		"listen[tls = true]":            1,
+		"listen[tls = #false]":          1,
+		"listen[port > 8080]":           1,
+		`server[0 = "internal"] > *`:    1,
+		`server[0="public"]>listen`:     2,
+		`dependency[0 = "2.0"]`:         1,
+		`dependency[name][0]`:           2,
+		`dependency[name $= "foo"][0]`:  1,
+		`dependency[name *= "x"]`:       0,
+		`"dependency"[name != "bar"]`:   1,
+		`server > listen > *`:           0,
+		`listen[port >= 8080][tls]`:     2,
+		`server[0 = "public"] > listen`: 2,
+	}
+	for selector, want := range cases {
+		found, err := doc.Select(selector)
+		assert.NoError(t, err, selector)
+		assert.Equal(t, want, found.Len(), selector)
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	doc := NewDocument()
+	cases := map[string]int{
+		"":                  0,
+		"server >":          8,
+		"server listen":     7,
+		"server >> listen":  7,
+		"a || b":            2,
+		"a + b":             2,
+		"top() > a":         3,
+		"(u8)a":             0,
+		"a[val(0)]":         5,
+		"a[name() = \"b\"]": 6,
+		"a[port = ]":        9,
+		"a[port == 1]":      8,
+		"a[port = 1":        10,
+	}
+	for selector, offset := range cases {
+		_, err := doc.Select(selector)
+		assert.ErrorIs(t, err, ErrInvalidQuery, selector)
+		var queryErr *QueryError
+		if assert.True(t, errors.As(err, &queryErr), selector) {
+			assert.Equal(t, offset, queryErr.Offset, selector)
+		}
+	}
+}
+
+func TestSetAll(t *testing.T) {
+	doc, err := ParseString(selectDocument, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	changed, err := doc.SetAll("listen[port = 8080]", "tls", NewBoolValue(true, NoHint()))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, changed)
+	assert.Len(t, mustSelect(t, &doc, "listen[tls = true]"), 3)
+
+	changed, err = doc.SetAll("listen[port = 1]", "tls", NewBoolValue(true, NoHint()))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, changed)
+
+	changed, err = doc.SetAll("listen[", "tls", NewBoolValue(true, NoHint()))
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+	assert.Equal(t, 0, changed)
+}
+
+func TestRemoveAll(t *testing.T) {
+	doc, err := ParseString(`a {
+	x 1
+	b {
+		x 2 {
+			x 3
+		}
+		y
+	}
+	x 4
+}
+x 5
+y
+`, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	// Nodes at many depths, including some inside others
+	removed, err := doc.RemoveAll("x")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, removed)
+	assert.Empty(t, mustSelect(t, &doc, "x"))
+	assert.Equal(t, []Identifier{"a", "y"}, childNames(doc.Nodes))
+	assert.Equal(t, []Identifier{"b"}, childNames(doc.Nodes[0].Children))
+	assert.Equal(t, []Identifier{"y"}, childNames(doc.Nodes[0].Children[0].Children))
+
+	removed, err = doc.RemoveAll("a > * > y")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, []Identifier{"a", "y"}, childNames(doc.Nodes))
+	assert.Empty(t, doc.Nodes[0].Children[0].Children)
+
+	removed, err = doc.RemoveAll("missing")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	removed, err = doc.RemoveAll(">")
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+	assert.Equal(t, 0, removed)
+	assert.Len(t, doc.Nodes, 2)
+}
+
+func mustSelect(t *testing.T, doc *Document, selector string) NodeSet {
+	t.Helper()
+	found, err := doc.Select(selector)
+	assert.NoError(t, err, selector)
+	return found
+}
@@ -0,0 +1,99 @@
+package kdl
+
+import "strings"
+
+// quoting is how a string or an identifier has been written in a document,
+// if not the way the writer would write it anyway. See WriteOptions.NormalizeQuotes.
+type quoting struct {
+	kind   quoteKind
+	hashes int // Count of the '#' around a raw string.
+}
+
+type quoteKind uint8
+
+const (
+	quoteDefault quoteKind = iota // Bare if possible, quoted otherwise.
+	quoteQuoted                   // Quoted, e.g. "my-node", even though it could be bare.
+	quoteRaw                      // A raw string, e.g. r#"text"# in KDL 1.0 or #"text"# in KDL 2.0.
+	quoteBare                     // A string written as a bare identifier, e.g. node value in KDL 2.0.
+)
+
+// forIdentifier returns the quoting of an identifier that has been read with it,
+// or zero if the identifier could not be bare anyway.
+func (q quoting) forIdentifier(i Identifier) quoting {
+	if q.kind == quoteQuoted && !isAllowedBareIdentifier(string(i)) {
+		return quoting{}
+	}
+	return q
+}
+
+// forString returns the quoting of a string value that has been read with it,
+// or zero if it has been quoted, as string values are written so anyway.
+func (q quoting) forString() quoting {
+	if q.kind == quoteQuoted {
+		return quoting{}
+	}
+	return q
+}
+
+// quotedArg makes an argument of a string that has been read like an identifier.
+func quotedArg(i Identifier, q quoting) Value {
+	v := NewStringValue(string(i), NoHint())
+	v.quotes = q.forString()
+	return v
+}
+
+// setKeyQuotes records how the key of a property has been written.
+func (n *Node) setKeyQuotes(key Identifier, q quoting) {
+	if q.kind == quoteDefault && n.keyQuotes == nil {
+		return
+	}
+	if n.keyQuotes == nil {
+		n.keyQuotes = make(map[Identifier]quoting)
+	}
+	n.keyQuotes[key] = q
+}
+
+// writeIdentifierAs writes an identifier the way it has been written in a document, if it can still be written so.
+func writeIdentifierAs(w *writer, i Identifier, q quoting) error {
+	if w.normalizeQuotes || q.kind == quoteDefault {
+		return writeIdentifier(w, i)
+	}
+	return writeStringAs(w, string(i), q)
+}
+
+// writeStringAs writes a string the way it has been written in a document, if it can still be written so,
+// and as a quoted string otherwise. A raw string is written in the syntax of the version of the writer,
+// with more '#' around it if it needs them, and a bare one only by a writer of KDL 2.0.
+func writeStringAs(w *writer, s string, q quoting) error {
+	v2 := w.version >= Version2
+	if !w.normalizeQuotes && q.kind == quoteBare && v2 && isValidBareIdentifier(s, Version2) {
+		_, err := w.writer.WriteString(s)
+		return err
+	}
+	if w.normalizeQuotes || q.kind != quoteRaw {
+		return writeString(w, s)
+	}
+	for _, ch := range s {
+		// Raw strings of KDL 2.0 are on a single line, unless they are multi-line ones
+		if isDisallowedChar(ch) || isNewLine(ch) && v2 {
+			return writeString(w, s)
+		}
+	}
+
+	hashes := q.hashes
+	if v2 && hashes == 0 {
+		hashes = 1
+	}
+	for strings.Contains(s, `"`+strings.Repeat("#", hashes)) {
+		hashes++
+	}
+	if !v2 {
+		if err := w.writer.WriteByte('r'); err != nil {
+			return err
+		}
+	}
+	delimiter := strings.Repeat("#", hashes)
+	_, err := w.writer.WriteString(delimiter + `"` + s + `"` + delimiter)
+	return err
+}
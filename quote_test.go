@@ -0,0 +1,80 @@
+package kdl
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritesQuotesAsRead(t *testing.T) {
+	cases := []string{
+		`bare "arg" key="value"` + "\n",
+		`"quoted" "arg" "key"="value"` + "\n",
+		`("hint")node ("hint")"arg" k=("hint")1` + "\n",
+		`r"raw" r#"with "quotes""# r##"key"##=r"value"` + "\n",
+		`node r"two` + "\n" + `lines"` + "\n",
+		`"needs quotes" "true" "1st"=2` + "\n",
+	}
+	for _, input := range cases {
+		doc, err := ParseString(input)
+		if !assert.NoError(t, err, input) {
+			continue
+		}
+		assert.Equal(t, input, doc.String(), input)
+
+		again, err := ParseString(doc.String())
+		if assert.NoError(t, err, input) {
+			assert.True(t, again.Equal(&doc), input)
+		}
+	}
+
+	// KDL 2.0 documents get the raw strings of KDL 2.0, and KDL 1.0 ones the raw strings of KDL 1.0
+	doc, err := ParseString(`#"raw"# ##"key"##=#"value"#`+"\n", WithVersion(Version2))
+	assert.NoError(t, err)
+	assert.Equal(t, `#"raw"# ##"key"##=#"value"#`+"\n", doc.String())
+	doc.Version = Version1
+	assert.Equal(t, `r#"raw"# r##"key"##=r#"value"#`+"\n", doc.String())
+}
+
+func TestWritesChangedQuotesAnew(t *testing.T) {
+	doc, err := ParseString(`"quoted" r#"raw"# a=("hint")r"x"`+"\n", WithVersion(Version1))
+	assert.NoError(t, err)
+	node := &doc.Nodes[0]
+
+	// More '#' for the quotes
+	node.Args[0].RawValue = `say "#hi"#`
+	// Still raw
+	v := node.Props["a"]
+	v.RawValue = `C:\path`
+	node.Props["a"] = v
+	// Still in quotes, even though they are not needed
+	assert.NoError(t, node.Rename("renamed"))
+	assert.Equal(t, `"renamed" r##"say "#hi"#"## a=("hint")r"C:\path"`+"\n", doc.String())
+
+	// Written anew, with KDL 2.0 syntax
+	doc, err = ParseString("node #\"raw\"#\n", WithVersion(Version2), WithFidelity(true))
+	assert.NoError(t, err)
+	doc.Nodes[0].Args[0].RawValue = `"#`
+	assert.Equal(t, "node ##\"\"#\"##\n", doc.String())
+	doc.Nodes[0].Args[0].RawValue = "two\nlines"
+	assert.Equal(t, "node \"two\\nlines\"\n", doc.String())
+
+	doc, err = ParseString("node r\"raw\"\n", WithVersion(Version1), WithFidelity(true))
+	assert.NoError(t, err)
+	doc.Nodes[0].Args[0].RawValue = "two\nlines"
+	assert.Equal(t, "node r\"two\nlines\"\n", doc.String())
+}
+
+func TestNormalizeQuotes(t *testing.T) {
+	const input = `("hint")"node" r#"raw"# "key"=("hint")"value"` + "\n"
+	for _, opt := range []Option{WithVersion(Version1), WithFidelity(true)} {
+		doc, err := ParseString(input, opt)
+		assert.NoError(t, err)
+		assert.Equal(t, input, doc.String())
+
+		var buf bytes.Buffer
+		assert.NoError(t, doc.WriteWithOptions(&buf, WriteOptions{NormalizeQuotes: true}))
+		assert.Equal(t, `(hint)node "raw" key=(hint)"value"`+"\n", buf.String())
+	}
+}
@@ -1,244 +1,622 @@
 package kdl
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
 )
 
 var (
-	errUnexpectedSemicolon    = fmt.Errorf("%w: unexpected ';' not terminating a node", ErrInvalidSyntax)
-	errUnexpectedRightBracket = fmt.Errorf("%w: unexpected top-level '}'", ErrInvalidSyntax)
-	errUnexpectedLineCont     = fmt.Errorf("%w: unexpected top-level '\\'", ErrInvalidSyntax)
-	errUnexpectedSlashdash    = fmt.Errorf("%w: unexpected slashdash", ErrInvalidSyntax)
+	errUnexpectedSemicolon    = withCode(CodeUnexpectedSemicolon, fmt.Errorf("%w: unexpected ';' not terminating a node", ErrInvalidSyntax))
+	errUnexpectedRightBracket = withCode(CodeUnexpectedRightBrace, fmt.Errorf("%w: unexpected top-level '}'", ErrInvalidSyntax))
+	errUnexpectedLineCont     = withCode(CodeUnexpectedLineContinuation, fmt.Errorf("%w: unexpected top-level '\\'", ErrInvalidSyntax))
+	errUnexpectedSlashdash    = withCode(CodeUnexpectedSlashdash, fmt.Errorf("%w: unexpected slashdash", ErrInvalidSyntax))
 )
 
+// readNodes reads all the nodes until the end of the current block.
 func readNodes(r *reader) (nodes []Node, err error) {
 
 	nodes = make([]Node, 0, 3)
 
 	for {
-		for {
-			err = readUntilSignificant(r, false)
+		node, done, err := readNextNode(r)
+		if err != nil || done {
+			return nodes, err
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+// readNextNode reads the next node in the current block, skipping over the commented-out ones.
+//
+// If there are no more nodes, done is true. The closing '}' of a children block is consumed.
+func readNextNode(r *reader) (node Node, done bool, err error) {
+
+	defer func() { err = errorAt(err, r.pos()) }()
+	defer r.recoverPanic(&err)
+
+	for {
+		var slashdash bool
+		slashdash, done, err = readNodeStart(r)
+		if done && err == nil {
+			err = r.takeCharError()
+		}
+		if err != nil || done {
+			return
+		}
+
+		node, err = readNode(r)
+		if err == nil {
+			err = r.takeCharError()
+			if err != nil && r.recoverFrom(err) {
+				// The node has been read to its end already
+				continue
+			}
+		} else if charErr := r.takeCharError(); errors.Is(charErr, ErrInvalidEncoding) {
+			// The invalid bytes are the likely cause of whatever has failed after them
+			err = charErr
+		}
+		if err != nil {
+			if !r.recoverFrom(err) {
+				return
+			}
+			// The broken node is dropped entirely
+			err = skipToNextNode(r)
 			if err != nil {
-				if err == io.EOF && r.depth == 0 {
-					err = nil
-				}
 				return
 			}
+			continue
+		}
 
-			var ch rune
-			ch, err = r.peekRune()
-			if err != nil {
-				if err == io.EOF && r.depth == 0 {
-					err = nil
-				}
+		if !slashdash {
+			return
+		}
+		r.restoreComments(&node)
+	}
+}
+
+// restoreComments makes the comments and the blank lines before a node commented out with a slashdash
+// lead the next node instead.
+func (r *reader) restoreComments(node *Node) {
+	if len(node.LeadingComments) > 0 {
+		r.comments = append(node.LeadingComments, r.comments...)
+	}
+	r.blankLines += node.BlankLinesBefore
+}
+
+// readNodeStart skips to the start of the next node in the current block.
+//
+// If there are no more nodes, done is true. The closing '}' of a children block is consumed.
+// If the node is commented out, slashdash is true.
+func readNodeStart(r *reader) (slashdash bool, done bool, err error) {
+
+	defer func() { err = errorAt(err, r.pos()) }()
+
+	err = r.checkCanceled()
+	if err != nil {
+		return
+	}
+	err = r.decodeInput()
+	if err != nil {
+		return
+	}
+	r.skipByteOrderMark()
+
+	for {
+		// A line is blank if it has nothing but whitespace from its very start
+		lineStart := r.column == 0
+		r.sawComment = false
+		err = readUntilSignificant(r, false)
+		if err != nil {
+			if err == io.EOF {
+				done = true
+				err = r.endOfNodes()
+			}
+			return
+		}
+
+		var ch rune
+		ch, err = r.peekRune()
+		if err != nil {
+			if err == io.EOF {
+				done = true
+				err = r.endOfNodes()
+			}
+			return
+		}
+
+		if r.depth == 0 && r.cfg.partial && len(r.cfg.terminator) > 0 {
+			if end, _ := r.isNext(r.cfg.terminator); end {
+				done = true
 				return
 			}
+		}
 
-			if !isNewLine(ch) {
-				if ch == ';' {
-					err = errUnexpectedSemicolon
+		if !isNewLine(ch) {
+			if ch == ';' {
+				err = errorAt(errUnexpectedSemicolon, r.pos())
+				if !r.recoverFrom(err) {
 					return
-				} else if ch == '}' {
-					if r.depth == 0 {
-						err = errUnexpectedRightBracket
+				}
+				r.discardByte()
+				continue
+			} else if ch == '}' {
+				if r.depth == 0 && r.cfg.partial {
+					// The end of the fragment, to be handled by the caller
+					done = true
+					return
+				}
+				if r.depth == 0 {
+					err = errorAt(errUnexpectedRightBracket, r.pos())
+					if r.recoverFrom(err) {
+						r.discardByte()
+						continue
 					}
-					r.discardByte()
+				}
+				r.discardByte()
+				r.blankLines = 0
+				done = true
+				return
+			} else if ch == '\\' {
+				err = errorAt(errUnexpectedLineCont, r.pos())
+				if !r.recoverFrom(err) {
 					return
-				} else if ch == '\\' {
-					err = errUnexpectedLineCont
+				}
+				r.discardByte()
+				err = skipUntilNewLine(r, true)
+				if err != nil {
 					return
 				}
-				break
+				continue
 			}
+			break
+		}
 
-			err = skipUntilNewLine(r, true)
-			if err != nil {
-				return
+		if lineStart && !r.sawComment && r.cfg.KeepBlankLines {
+			if len(r.comments) == 0 {
+				r.blankLines++
+			} else if r.blankComments() < MaxBlankLines {
+				// A blank line after a comment stays after it
+				r.comments = append(r.comments, "")
 			}
 		}
-
-		// A "slashdash" comment silences the whole node
-		var slashdash bool
-		slashdash, err = r.isNext(charsSlashDash[:])
+		err = skipUntilNewLine(r, true)
 		if err != nil {
 			return
 		}
-		if slashdash {
-			r.discardBytes(2)
-		}
+	}
 
+	// A "slashdash" comment silences the whole node
+	slashdashPos := r.pos()
+	slashdash, err = r.isNext(charsSlashDash[:])
+	if err != nil {
+		return
+	}
+	if slashdash {
+		r.discardBytes(2)
+		err = skipAfterSlashdash(r)
+	} else {
 		err = readUntilSignificant(r, true)
+	}
+	if err != nil {
+		if err == io.EOF {
+			err = errorAt(errUnexpectedSlashdash, slashdashPos)
+		}
+		return
+	}
+
+	// In KDL 1.0, the silenced node must start on the same line
+	if slashdash {
+		if ch, _ := r.peekRune(); isNewLine(ch) {
+			err = errorAt(errUnexpectedSlashdash, slashdashPos)
+			return
+		}
+	}
+
+	err = r.startNode()
+	return
+}
+
+// openNode is a node whose children block is being read.
+type openNode struct {
+	node      Node
+	slashdash bool   // Whether the node itself is commented out.
+	discard   bool   // Whether the children block is commented out.
+	children  []Node // Children read so far.
+}
+
+// readNode reads a node, along with all of its children.
+//
+// Instead of recursing into the children blocks, the nodes being read are kept on an explicit stack,
+// so that the depth of the document is not limited by the size of the Go stack.
+func readNode(r *reader) (Node, error) {
+
+	var stack []openNode
+	slashdash := false
+	afterChildren := false // Whether the children block of the node in hand has been read.
+
+	defer func() { r.path = r.path[:0] }()
+	node, err := readNodeHead(r)
+	for {
+
+		// Read the rest of the node in hand, up to its end or its children block
+		open, discard := false, false
+		if err == nil {
+			if !afterChildren {
+				r.path = append(r.path, node.Name)
+			}
+			open, discard, err = readNodeBody(r, &node, afterChildren)
+		}
+		if !open {
+			r.path = r.path[:len(stack)]
+		}
+
 		if err != nil {
-			if err == io.EOF {
-				err = errUnexpectedSlashdash
+			if len(stack) == 0 || !r.recoverFrom(err) {
+				return node, unwindOpenNodes(r, err, stack)
 			}
-			return
+			// The broken node is dropped entirely
+			err = skipToNextNode(r)
+			if err != nil {
+				return node, unwindOpenNodes(r, err, stack)
+			}
+		} else if open {
+			stack = append(stack, openNode{node: node, slashdash: slashdash, discard: discard})
+		} else if len(stack) == 0 {
+			return node, nil
+		} else if !slashdash {
+			top := &stack[len(stack)-1]
+			top.children = append(top.children, node)
+		} else {
+			r.restoreComments(&node)
 		}
 
-		var node Node
-		node, err = readNode(r)
+		// Find the next node in hand: either a new child or a node whose children block has ended
+		var done bool
+		slashdash, done, err = readNodeStart(r)
 		if err != nil {
-			return
+			return node, unwindOpenNodes(r, err, stack)
 		}
 
-		if !slashdash {
-			nodes = append(nodes, node)
+		if done {
+			r.depth--
+			r.braces = r.braces[:len(r.braces)-1]
+
+			closed := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			node, slashdash = closed.node, closed.slashdash
+			comments := r.takeComments()
+			if !closed.discard {
+				for i := range closed.children {
+					node.AddChild(closed.children[i])
+				}
+				node.EndComments = comments
+				if node.format != nil {
+					node.format.closeBlock(&node)
+				}
+				if node.position != nil {
+					// The '}' has just been consumed
+					node.position.children.End, node.position.end = r.offset, r.offset
+				}
+			}
+			afterChildren = true
+			continue
 		}
+
+		node, err = readNodeHead(r)
+		afterChildren = false
 	}
 }
 
-func readNode(r *reader) (Node, error) {
+// unwindOpenNodes records the names of the nodes enclosing an error, innermost first.
+func unwindOpenNodes(r *reader, err error, stack []openNode) error {
+	err = errorAt(err, r.pos())
+	for i := len(stack) - 1; i >= 0; i-- {
+		err = errorInNode(err, stack[i].node.Name)
+	}
+	return err
+}
+
+// readNodeHead reads the type hint and the name of a node.
+func readNodeHead(r *reader) (node Node, err error) {
 
-	node := NewNode("")
+	node = NewNode("")
+	node.LeadingComments = r.takeComments()
+	node.BlankLinesBefore = r.blankLines
+	if node.BlankLinesBefore > MaxBlankLines {
+		node.BlankLinesBefore = MaxBlankLines
+	}
+	r.blankLines = 0
 
+	start, hintPos := r.offset, r.pos()
 	hint, err := readMaybeTypeHint(r)
 	if err != nil {
 		return node, err
 	}
-	node.TypeHint = hint
+	node.TypeHint, node.hintQuotes = hint, r.hintQuotes
+	if r.keepPositions() {
+		node.position = newNodePosition(hint, hintPos, r.pos())
+	}
 
-	name, err, _ := readIdentifier(r, stopModeSemicolon)
+	name, err, _ := readIdentifier(r, stopModeNodeName)
 	if err != nil {
 		return node, err
 	}
 
-	node.Name = name
+	node.Name, node.nameQuotes = name, r.quotes.forIdentifier(name)
+	if node.position != nil {
+		node.position.nameEnd, node.position.end = r.offset, r.offset
+	}
+	if r.keepFormat() {
+		node.format = newNodeFormat(r, &node, start)
+	}
+	return node, nil
+}
+
+// readNodeBody reads the arguments and properties of a node, until its end or the start of its children block.
+//
+// If a children block has been opened, open is true and the reader is positioned just after the '{'.
+// If the block is commented out, discard is true.
+// Once the children block has been read, KDL 2.0 does not allow any more arguments or properties.
+func readNodeBody(r *reader, node *Node, afterChildren bool) (open bool, discard bool, err error) {
+
+	end := -1 // Where the node ends, if not where the reader stops.
+	defer func() {
+		if err != nil {
+			err = errorInNode(errorAt(err, r.pos()), node.Name)
+			return
+		}
+		r.attachTrailingComments(node)
+		if node.format != nil && !open {
+			if end < 0 {
+				end = r.offset
+			}
+			node.format.setEnd(node, end)
+		}
+	}()
 
 	for {
 
 		err := readUntilSignificant(r, true)
 		if err != nil {
 			if err == io.EOF {
-				return node, nil
+				return false, false, nil
 			}
-			return node, err
+			return false, false, err
 		}
 
+		slashdashPos := r.pos()
 		slashdash, err := r.isNext(charsSlashDash[:])
 		if slashdash && err == nil {
 			r.discardBytes(2)
+			err = skipAfterSlashdash(r)
+		} else {
+			err = readUntilSignificant(r, true)
 		}
-
-		err = readUntilSignificant(r, true)
 		if err != nil {
 			if err == io.EOF {
-				return node, errUnexpectedSlashdash
+				return false, false, errorAt(errUnexpectedSlashdash, slashdashPos)
 			}
-			return node, err
+			return false, false, err
 		}
 
 		ch, err := r.peekRune()
 		if err != nil {
-			return node, err
+			return false, false, err
 		}
 
 		if isNewLine(ch) {
-			r.discardRunes(1)
 			if slashdash {
-				return node, errUnexpectedSlashdash
+				return false, false, errorAt(errUnexpectedSlashdash, slashdashPos)
 			}
-			return node, nil
+			// The line break belongs to the text before the next node
+			end = r.offset
+			r.discardRunes(1)
+			return false, false, nil
 		} else if ch == ';' {
-			r.discardByte()
 			if slashdash {
-				return node, errUnexpectedSlashdash
+				return false, false, errorAt(errUnexpectedSlashdash, slashdashPos)
 			}
-			return node, nil
+			r.discardByte()
+			if r.cfg.KeepComments {
+				// The comments after the semicolon are still on the line of the node
+				if err := readUntilSignificant(r, false); err != nil && err != io.EOF {
+					return false, false, err
+				}
+			}
+			return false, false, nil
 		} else if ch == '}' {
 			if slashdash {
-				return node, errUnexpectedSlashdash
+				return false, false, errorAt(errUnexpectedSlashdash, slashdashPos)
 			}
-			return node, nil
+			return false, false, nil
 		} else if ch == '{' {
-			r.discardByte()
-			r.depth++
-			children, err := readNodes(r)
-			if err != nil {
-				return node, err
+			if limit := r.cfg.maxDepth(); limit >= 0 && r.depth >= limit {
+				return false, false, errorAt(&depthExceededError{limit: limit}, r.pos())
 			}
-			r.depth--
-			if !slashdash {
-				for i := range children {
-					node.AddChild(children[i])
-				}
+			r.braces = append(r.braces, r.pos())
+			if node.format != nil && !slashdash && node.format.brace < 0 {
+				node.format.brace = r.offset
+			}
+			if node.position != nil && !slashdash && node.position.children.End == 0 {
+				node.position.children.Start = r.offset
 			}
 			r.discardByte()
+			r.depth++
+			return true, slashdash, nil
 		} else {
-			err = readArgOrProp(r, &node, slashdash)
+			if afterChildren && r.cfg.version() >= Version2 {
+				return false, false, errorAt(errEntryAfterChildren, r.pos())
+			}
+			start, args := r.offset, len(node.Args)
+			err = readArgOrProp(r, node, slashdash)
 			if err != nil {
-				return node, err
+				return false, false, err
+			}
+			if len(node.Args) > args {
+				if node.format != nil {
+					node.format.addEntry(start, r.offset, -1, args, node.Args[args])
+				}
+				if node.position != nil {
+					node.position.addEntry(EntrySpan{Span: Span{start, r.offset}, Value: Span{start, r.offset}, Arg: args})
+				}
 			}
 		}
 	}
 }
 
 var (
-	errUnexpectedBareIdentifier       = fmt.Errorf("%w: unexpected bare identifier", ErrInvalidSyntax)
-	errUnexpectedTokenAfterValue      = fmt.Errorf("%w: unexpected token after value", ErrInvalidSyntax)
-	errUnexpectedTokenAfterIdentifier = fmt.Errorf("%w: unexpected token after identifier", ErrInvalidSyntax)
+	errUnexpectedBareIdentifier       = withCode(CodeBareIdentifier, fmt.Errorf("%w: unexpected bare identifier", ErrInvalidSyntax))
+	errUnexpectedTokenAfterValue      = withCode(CodeUnexpectedTokenAfterValue, fmt.Errorf("%w: unexpected token after value", ErrInvalidSyntax))
+	errUnexpectedTokenAfterIdentifier = withCode(CodeUnexpectedTokenAfterIdentifier, fmt.Errorf("%w: unexpected token after identifier", ErrInvalidSyntax))
+	errWhitespaceAroundEquals         = withCode(CodeWhitespaceAroundEquals, fmt.Errorf("%w: properties must not have whitespace around '='; write key=value", ErrInvalidSyntax))
+	errEntryAfterChildren             = withCode(CodeEntryAfterChildren, fmt.Errorf("%w: arguments and properties must come before the children block", ErrInvalidSyntax))
+	errHintOnPropertyKey              = withCode(CodeHintOnPropertyKey, fmt.Errorf("%w: a property key cannot have a type annotation; annotate the value instead, e.g. key=(hint)value", ErrInvalidSyntax))
 )
 
+// bareIdentifierError explains why a bare identifier cannot be used as a value.
+func bareIdentifierError(i Identifier) error {
+	if slices.Contains(hashKeywords[:], string(i)) {
+		return errHashKeywordInV1
+	}
+	if startsLikeNumber(string(i), Version2) {
+		// Something like .5, which is not a number
+		return errLeadingDecimalPoint
+	}
+	return errUnexpectedBareIdentifier
+}
+
 // readArgOrProp reads an argument or a property
 // and adds them to the provided Node definition.
 func readArgOrProp(r *reader, dest *Node, discard bool) error {
 
+	hintStart := r.pos()
 	hint, err := readMaybeTypeHint(r)
 	if err != nil {
 		return err
 	}
+	hintQuotes := r.hintQuotes
+
+	// In KDL 2.0, a keyword like #true cannot be an identifier
+	keyword := r.cfg.version() >= Version2 && nextHashKeyword(r) != ""
 
 	// This can only be a property if there is no type hint at this time
-	if hint.IsAbsent() {
+	if hint.IsAbsent() && !keyword {
+		start := r.pos()
 		i, err, quoted := readIdentifier(r, stopModeEquals)
 		if err == nil {
 			// Identifier read successfully.
+			quotes := r.quotes
+			keyEnd := r.offset
 			ch, err := r.peekRune()
+			if err == nil && isWhitespace(ch) && r.cfg.version() >= Version2 {
+				// KDL 2.0 allows whitespace around the '=' of a property, e.g. key = value
+				if next, _ := r.peekAfterWhitespace(); next == '=' {
+					ch, err = skipWhitespace(r)
+				}
+			}
+			argQuotes := quotes
+			if !quoted && r.cfg.version() >= Version2 {
+				// In KDL 2.0, a string can be written as a bare identifier too
+				argQuotes = quoting{kind: quoteBare}
+			}
 			if err == io.EOF {
-				if quoted {
+				if quoted || argQuotes.kind == quoteBare {
 					if !discard {
-						dest.AddArg(NewStringValue(string(i), NoHint()))
+						dest.AddArg(r.positioned(quotedArg(i, argQuotes), start, start))
 					}
 					return nil
 				}
-				return errUnexpectedBareIdentifier
+				return errorAt(bareIdentifierError(i), start)
 			} else if err == nil {
 				if isValidValueTerminator(ch) {
-					if quoted {
+					if quoted || argQuotes.kind == quoteBare {
 						if !discard {
-							dest.AddArgValue(NewStringValue(string(i), NoHint()))
+							dest.AddArgValue(r.positioned(quotedArg(i, argQuotes), start, start))
 						}
 						return nil
 					}
-					return errUnexpectedBareIdentifier
+					// A common mistake is writing properties like `key = value`
+					if isWhitespace(ch) {
+						if next, _ := r.peekAfterWhitespace(); next == '=' {
+							return errorAt(errWhitespaceAroundEquals, r.pos())
+						}
+					}
+					return errorAt(bareIdentifierError(i), start)
 				} else if ch == '=' {
 					r.discardByte()
+					if next, _ := r.peekRune(); isWhitespace(next) {
+						if r.cfg.version() >= Version2 {
+							if _, err := skipWhitespace(r); err != nil && err != io.EOF {
+								return errorAt(err, r.pos())
+							}
+						} else if !quoted {
+							return errorAt(errWhitespaceAroundEquals, r.pos())
+						}
+					}
+					r.propKeyEnd, r.propValue = keyEnd, r.offset
 					v, err := readValue(r)
 					if err != nil {
 						return err
 					}
 					if !discard {
-						dest.SetPropValue(i, v)
+						return setProp(r, dest, i, quotes.forIdentifier(i), v, start)
 					}
 					return nil
 				}
-				return errUnexpectedTokenAfterIdentifier
+				return errorAt(errUnexpectedTokenAfterIdentifier, r.pos())
 			}
+			return errorAt(err, r.pos())
+		}
+
+		// A broken quoted string would not be any better as a Value
+		if quoted {
 			return err
 		}
 
+		// Something like 1st=1 is a property with an invalid key, not a malformed number
+		if errors.Is(err, errNumberLikeBareIdent) {
+			if word, _ := peekWord(r); bytes.IndexByte(word, '=') > 0 {
+				return err
+			}
+		}
+
 		// Else: Bad identifier. This should be a Value instead. Fallthrough.
 	}
 
+	start := r.pos()
 	v, err := readValue(r)
 	if err != nil {
+		// Something like (hint)key=1, where the key has been read as a value
+		if hint.IsPresent() {
+			if word, _ := peekWord(r); bytes.IndexByte(word, '=') > 0 {
+				return errorAt(errHintOnPropertyKey, hintStart)
+			}
+		}
 		// Not a valid Value
 		return err
 	}
 	v.TypeHint = hint
+	if hint.IsPresent() {
+		v.hintQuotes = hintQuotes
+		if v.position != nil {
+			v.position.hint = hintStart.exported()
+		}
+	}
+	if err := checkIntegerHint(v); err != nil {
+		return errorAt(err, start)
+	}
 
 	ch, err := r.peekRune()
+	if err == nil && ch == '=' && hint.IsPresent() {
+		// The same, but with a quoted key
+		return errorAt(errHintOnPropertyKey, hintStart)
+	}
 
 	if err == io.EOF || (err == nil && isValidValueTerminator(ch)) {
 		if !discard {
@@ -246,10 +624,59 @@ func readArgOrProp(r *reader, dest *Node, discard bool) error {
 		}
 		return nil
 	} else if err != nil {
-		return err
+		return errorAt(err, r.pos())
 	}
 
-	return errUnexpectedTokenAfterValue
+	return errorAt(errUnexpectedTokenAfterValue, r.pos())
+}
+
+// setProp sets a property read from the document, following ParseOptions.DuplicateProps.
+// The key is at the provided position.
+func setProp(r *reader, dest *Node, key Identifier, keyQuotes quoting, v Value, at position) error {
+
+	_, exists := dest.Props[key]
+	switch r.cfg.DuplicateProps {
+	case DuplicatePropsFirstWins:
+		if exists {
+			return nil
+		}
+	case DuplicatePropsError:
+		if exists {
+			first := r.propKeys[key]
+			return errorAt(&DuplicatePropertyError{Key: key, FirstOffset: first.offset, FirstLine: first.line, FirstColumn: first.column}, at)
+		}
+		if r.propKeys == nil {
+			r.propKeys = make(map[Identifier]position)
+		}
+		r.propKeys[key] = at
+	}
+
+	dest.SetPropValue(key, v)
+	dest.setKeyQuotes(key, keyQuotes)
+	if dest.format != nil {
+		dest.format.addProp(key, at.offset, r.propValue, r.offset, v)
+	}
+	if dest.position != nil {
+		dest.position.addEntry(EntrySpan{
+			Span:  Span{at.offset, r.offset},
+			Key:   Span{at.offset, r.propKeyEnd},
+			Value: Span{r.propValue, r.offset},
+			Arg:   -1,
+			Prop:  key,
+		})
+	}
+	return nil
+}
+
+// skipWhitespace discards a run of whitespace, returning the first rune after it.
+func skipWhitespace(r *reader) (rune, error) {
+	for {
+		ch, err := r.peekRune()
+		if err != nil || !isWhitespace(ch) {
+			return ch, err
+		}
+		r.discardRunes(1)
+	}
 }
 
 // skipUntilNewLine discards the reader to the next new line character OR EOF.
@@ -260,6 +687,12 @@ func skipUntilNewLine(r *reader, afterBreak bool) error {
 
 	for {
 
+		// Most of the text is ASCII other than a line break, skip it in bulk
+		if n := r.asciiRun(classNewLine); n > 0 {
+			r.discardBytes(n)
+			continue
+		}
+
 		// CRLF is a special case as it spans two runes, so we check it first
 		if isCrlf, err := r.isNext(charsCRLF[:]); isCrlf && err == nil {
 			if afterBreak {
@@ -281,18 +714,90 @@ func skipUntilNewLine(r *reader, afterBreak bool) error {
 
 		if isNewLine(ch) {
 			if afterBreak {
-				r.discardByte()
+				r.discardRunes(1)
 			}
 			break
 		}
 
-		r.discardByte()
+		r.discardRunes(1)
 	}
 
 	return nil
 }
 
-var errSignificantInCont = fmt.Errorf("%w: unexpected significant token in escline", ErrInvalidSyntax)
+// skipToNextNode discards the rest of a broken node, so that parsing can resume after it.
+//
+// The reader is positioned after the newline or semicolon terminating the node,
+// or just before the '}' closing the enclosing children block.
+func skipToNextNode(r *reader) error {
+
+	depth := 0
+	for {
+
+		ch, err := r.peekRune()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		switch {
+		case ch == '"':
+			if _, err := readQuotedString(r); err != nil {
+				return err
+			}
+		case ch == 'r' || ch == '#':
+			_, err := readRawStringOf(r, 0)
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				r.discardByte()
+			}
+		case ch == '{':
+			depth++
+			r.discardByte()
+		case ch == '}':
+			if depth == 0 {
+				return nil
+			}
+			depth--
+			r.discardByte()
+		case ch == ';' && depth == 0:
+			r.discardByte()
+			return nil
+		case ch == '\\':
+			// Line continuation - the next line belongs to this node too
+			r.discardByte()
+			if err := skipUntilNewLine(r, true); err != nil {
+				return err
+			}
+		case isNewLine(ch):
+			if err := skipUntilNewLine(r, true); err != nil || depth == 0 {
+				return err
+			}
+		case ch == '/':
+			if comment, _ := r.isNext(charsStartCommentBlock[:]); comment {
+				if err := skipBlockComment(r, nil); err != nil {
+					return err
+				}
+			} else if comment, _ := r.isNext(charsStartComment[:]); comment {
+				if err := skipUntilNewLine(r, false); err != nil {
+					return err
+				}
+			} else {
+				r.discardByte()
+			}
+		default:
+			r.discardRunes(1)
+		}
+	}
+}
+
+var (
+	errSignificantInCont = withCode(CodeSignificantAfterLineContinuation, fmt.Errorf("%w: unexpected significant token in escline", ErrInvalidSyntax))
+	errLineContAtEOF     = withCode(CodeUnexpectedEOF, unexpectedEOFError("line continuation at end of file: a '\\' must be followed by a new line or a comment in KDL 1.0"))
+)
 
 // readUntilSignificant allows the provided reader to skip whitespace and comments.
 //
@@ -300,12 +805,17 @@ var errSignificantInCont = fmt.Errorf("%w: unexpected significant token in escli
 func readUntilSignificant(r *reader, insideNode bool) error {
 
 	escapedLine := false
+	var escapePos position
+	commented := false
 
-outer:
 	for {
 
 		ch, err := r.peekRune()
 		if err != nil {
+			// KDL 2.0 allows a document to end right after a '\', KDL 1.0 requires a new line
+			if err == io.EOF && escapedLine && !commented && r.cfg.version() < Version2 {
+				return errorAt(errLineContAtEOF, escapePos)
+			}
 			return err
 		}
 
@@ -316,52 +826,45 @@ outer:
 
 		// Check for line continuation
 		if ch == '\\' && insideNode {
+			escapePos = r.pos()
 			r.discardByte()
 			escapedLine = true
 			continue
 		}
 
-		// Check for single-line comments
-		if comment, err := r.isNext(charsStartComment[:]); comment && err == nil {
-			r.discardBytes(2)
-			return skipUntilNewLine(r, true)
-		}
-
-		// Check for multiline comments
-		if comment, err := r.isNext(charsStartCommentBlock[:]); comment && err == nil {
-			r.discardBytes(2)
-			// Per spec, multiline comments can be nested, so we can't do naive ReadString("*/")
-			depth := 1
-		inner:
-			for {
-
-				start, err := r.isNext(charsStartCommentBlock[:])
-				if err != nil {
-					return err
-				}
-
-				if start {
-					depth += 1
+		if ch == '/' {
+			// Check for single-line comments
+			if comment, err := r.isNext(charsStartComment[:]); comment && err == nil {
+				// Leave the new line to be handled below or by the caller
+				if r.cfg.KeepComments {
+					if err := readLineComment(r); err != nil {
+						return err
+					}
+				} else {
 					r.discardBytes(2)
-					continue inner
+					if err := skipUntilNewLine(r, false); err != nil {
+						return err
+					}
 				}
+				commented = escapedLine
+				r.sawComment = true
+				continue
+			}
 
-				end, err := r.isNext(charsEndCommentBlock[:])
-				if err != nil {
+			// Check for multiline comments
+			if comment, err := r.isNext(charsStartCommentBlock[:]); comment && err == nil {
+				var text *[]byte
+				if r.cfg.KeepComments {
+					text = new([]byte)
+				}
+				if err := skipBlockComment(r, text); err != nil {
 					return err
 				}
-
-				if end {
-					r.discardBytes(2)
-					depth -= 1
-					if depth <= 0 {
-						continue outer
-					} else {
-						continue inner
-					}
+				if text != nil {
+					r.comments = append(r.comments, string(*text))
 				}
-
-				r.discardByte()
+				r.sawComment = true
+				continue
 			}
 		}
 
@@ -371,11 +874,127 @@ outer:
 					return err
 				}
 				escapedLine = false
+				commented = false
 				continue
 			}
-			return errSignificantInCont
+			return errorAt(errSignificantInCont, r.pos())
 		}
 
 		return nil
 	}
 }
+
+// skipAfterSlashdash discards the space between a slashdash and the node, value or children block it silences.
+// KDL 2.0 allows new lines and single-line comments there too.
+func skipAfterSlashdash(r *reader) error {
+
+	for {
+		if err := readUntilSignificant(r, true); err != nil {
+			return err
+		}
+		if r.cfg.version() < Version2 {
+			return nil
+		}
+
+		ch, err := r.peekRune()
+		if err != nil {
+			return err
+		}
+		if !isNewLine(ch) {
+			return nil
+		}
+		if err := skipUntilNewLine(r, true); err != nil {
+			return err
+		}
+	}
+}
+
+// readLineComment reads a single-line comment into the comments of the reader,
+// assuming the reader is positioned at its start. The new line is left in the input.
+func readLineComment(r *reader) error {
+
+	var text []byte
+	for {
+		ch, err := r.peekRune()
+		if err == io.EOF || (err == nil && isNewLine(ch)) {
+			r.comments = append(r.comments, string(text))
+			return nil
+		} else if err != nil {
+			return err
+		}
+		text = utf8.AppendRune(text, ch)
+		r.discardRunes(1)
+	}
+}
+
+// skipBlockComment discards a multiline comment, assuming the reader is positioned at its start.
+// If text is not nil, the comment is appended to it.
+func skipBlockComment(r *reader, text *[]byte) error {
+
+	start := r.pos()
+	r.discardBytesInto(text, 2)
+
+	// Per spec, multiline comments can be nested, so we can't do naive ReadString("*/")
+	depth := 1
+	for {
+
+		if err := r.checkCanceled(); err != nil {
+			return err
+		}
+
+		opening, err := r.isNext(charsStartCommentBlock[:])
+		if err != nil {
+			return errorAt(unterminatedComment(err, depth), start)
+		}
+
+		if opening {
+			depth += 1
+			r.discardBytesInto(text, 2)
+			continue
+		}
+
+		end, err := r.isNext(charsEndCommentBlock[:])
+		if err != nil {
+			return errorAt(unterminatedComment(err, depth), start)
+		}
+
+		if end {
+			r.discardBytesInto(text, 2)
+			depth -= 1
+			if depth <= 0 {
+				return nil
+			}
+			continue
+		}
+
+		if text != nil {
+			ch, _ := r.peekRune()
+			*text = utf8.AppendRune(*text, ch)
+		}
+		r.discardRunes(1)
+	}
+}
+
+// unterminatedCommentError describes a multiline comment left open at the end of the document.
+type unterminatedCommentError struct {
+	depth int // How many nested comments have been left open.
+}
+
+func (e *unterminatedCommentError) Error() string {
+	if e.depth > 1 {
+		return ErrUnterminatedComment.Error() + ": " + strconv.Itoa(e.depth) + " nested comments left open"
+	}
+	return ErrUnterminatedComment.Error() + ": did you forget to close it?"
+}
+
+func (e *unterminatedCommentError) Unwrap() error {
+	return ErrUnterminatedComment
+}
+
+// unterminatedComment converts a reader error, if it was caused by the end of the document.
+func unterminatedComment(err error, depth int) error {
+	if err == io.EOF {
+		return &unterminatedCommentError{depth: depth}
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package kdl
 import (
 	"io"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,7 +19,7 @@ func TestReadsSimpleNode(t *testing.T) {
 		Name: "foo",
 		Args: []Value{
 			NewStringValue("bar", NoHint()),
-			NewIntegerValue(big.NewInt(2), Hint("abc")),
+			withLiteral(NewIntegerValue(big.NewInt(2), Hint("abc")), "2"),
 		},
 	}, n)
 
@@ -82,3 +83,280 @@ func TestReadsRTL(t *testing.T) {
 	assert.Equal(t, 1, len(n.Props))
 	assert.EqualValues(t, 2, n.Props["الطاب"].IntegerValue().Int64())
 }
+
+func TestReadsNodeAfterChildren(t *testing.T) {
+	reader := readerFromString("foo { bar }\nbaz")
+	nodes, err := readNodes(&reader)
+	assert.NoError(t, err)
+	if assert.Len(t, nodes, 2) {
+		assert.Len(t, nodes[0].Children, 1)
+		assert.EqualValues(t, "baz", nodes[1].Name)
+	}
+}
+
+func TestReadsNodesWithTrailingComments(t *testing.T) {
+	reader := readerFromString("foo 1 // first\nbar {\n  // second\n  baz // third\n}\r\nquox \\ // fourth\n  2")
+	nodes, err := readNodes(&reader)
+	assert.NoError(t, err)
+	if assert.Len(t, nodes, 3) {
+		assert.Len(t, nodes[0].Args, 1)
+		assert.Len(t, nodes[1].Children, 1)
+		assert.Len(t, nodes[2].Args, 1)
+	}
+}
+
+func TestReadsNodesAfterLongComments(t *testing.T) {
+	long := strings.Repeat("ascii text ", 100)
+	input := "// " + long + "żółw " + long + "\r\nfoo 1 // " + long + "\u2028bar\n// " + long
+	for _, reader := range []reader{readerFromString(input), readerWithRefills(input)} {
+		nodes, err := readNodes(&reader)
+		assert.NoError(t, err)
+		if assert.Len(t, nodes, 2) {
+			assert.EqualValues(t, "foo", nodes[0].Name)
+			assert.EqualValues(t, "bar", nodes[1].Name)
+		}
+		assert.Equal(t, 4, reader.line)
+	}
+}
+
+func TestReadsPropertyWithWhitespaceAroundEquals(t *testing.T) {
+	for _, input := range []string{`node key ="v"`, `node key= "v"`, `node key = "v"`, "node key\t　= 1"} {
+		reader := readerFromString(input)
+		_, err := readNode(&reader)
+		assert.ErrorIs(t, err, errWhitespaceAroundEquals, input)
+		assert.ErrorContains(t, err, "write key=value", input)
+	}
+
+	for _, input := range []string{`node key "v"`, `node key`, `node "key" = "v"`} {
+		reader := readerFromString(input)
+		_, err := readNode(&reader)
+		assert.NotErrorIs(t, err, errWhitespaceAroundEquals, input)
+	}
+
+	reader := readerFromString(`node key "v"`)
+	_, err := readNode(&reader)
+	assert.ErrorIs(t, err, errUnexpectedBareIdentifier)
+}
+
+func TestReadsPropertyWithWhitespaceAroundEqualsInV2(t *testing.T) {
+	for _, input := range []string{`node key ="v"`, `node key= "v"`, `node key = "v"`, "node key\t　= \"v\"", `node "key" = "v"`} {
+		doc, err := ParseString(input, WithVersion(Version2), WithPositions(true))
+		if !assert.NoError(t, err, input) || !assert.Len(t, doc.Nodes, 1, input) {
+			continue
+		}
+		assert.Equal(t, "v", doc.Nodes[0].Props["key"].RawValue, input)
+		assert.Empty(t, doc.Nodes[0].Args, input)
+
+		spans, ok := doc.Nodes[0].Spans()
+		if assert.True(t, ok, input) && assert.Len(t, spans.Entries, 1, input) {
+			e := spans.Entries[0]
+			assert.Equal(t, strings.TrimSpace(input[e.Key.Start:e.Key.End]), input[e.Key.Start:e.Key.End], input)
+			assert.Equal(t, `"v"`, input[e.Value.Start:e.Value.End], input)
+		}
+	}
+
+	doc, err := ParseString("node key = 1 other=2\n", WithVersion(Version2), WithFidelity(true))
+	assert.NoError(t, err)
+	assert.NoError(t, doc.Nodes[0].SetProp("key", 3))
+	assert.Equal(t, "node key = 3 other=2\n", doc.String())
+}
+
+func TestReadsBareStringsInV2(t *testing.T) {
+	const input = "node foo key=bar --flag (hint)baz k=(h)-v {\n    child x; other y\n}\n"
+	doc, err := ParseString(input, WithVersion(Version2))
+	if !assert.NoError(t, err) || !assert.Len(t, doc.Nodes, 1) {
+		return
+	}
+	node := doc.Nodes[0]
+	if assert.Len(t, node.Args, 3) {
+		assert.Equal(t, "foo", node.Args[0].RawValue)
+		assert.Equal(t, "--flag", node.Args[1].RawValue)
+		assert.Equal(t, "baz", node.Args[2].RawValue)
+		assert.EqualValues(t, "hint", node.Args[2].TypeHint.MustGet())
+	}
+	assert.Equal(t, "bar", node.Props["key"].RawValue)
+	assert.Equal(t, "-v", node.Props["k"].RawValue)
+	if assert.Len(t, node.Children, 2) {
+		assert.Equal(t, "x", node.Children[0].Args[0].RawValue)
+		assert.Equal(t, "y", node.Children[1].Args[0].RawValue)
+	}
+
+	assert.Equal(t, "node foo --flag (hint)baz key=bar k=(h)-v {\n    child x\n    other y\n}\n", doc.String())
+	doc.Version = Version1
+	assert.Equal(t, "node \"foo\" \"--flag\" (hint)\"baz\" key=\"bar\" k=(h)\"-v\" {\n    child \"x\"\n    other \"y\"\n}\n", doc.String())
+
+	// Changed ones are still written bare, if they can be
+	doc, err = ParseString("node foo key=bar\n", WithVersion(Version2), WithFidelity(true))
+	assert.NoError(t, err)
+	doc.Nodes[0].Args[0].RawValue = "changed"
+	v := doc.Nodes[0].Props["key"]
+	v.RawValue = "not bare"
+	doc.Nodes[0].Props["key"] = v
+	assert.Equal(t, "node changed key=\"not bare\"\n", doc.String())
+
+	for input, want := range map[string]error{
+		"node true":         ErrInvalidSyntax,
+		"node key=null":     ErrInvalidSyntax,
+		"node inf":          errReservedBareIdent,
+		"node 1foo":         errInvalidNumValue,
+		"node foo {} bar":   errEntryAfterChildren,
+		"node {} key=value": errEntryAfterChildren,
+	} {
+		_, err := ParseString(input, WithVersion(Version2))
+		assert.ErrorIs(t, err, want, input)
+	}
+	_, err = ParseString("node true", WithVersion(Version2))
+	assert.ErrorContains(t, err, "write #true instead")
+
+	// Still not values in KDL 1.0
+	for _, input := range []string{"node foo", "node key=bar", "node (hint)baz", "node key=rfoo"} {
+		_, err := ParseString(input, WithVersion(Version1))
+		assert.ErrorIs(t, err, errUnexpectedBareIdentifier, input)
+	}
+}
+
+func TestReadsSlashdashedChildren(t *testing.T) {
+	reader := readerFromString("node /-{ a; b } real=1\nnext")
+	nodes, err := readNodes(&reader)
+	assert.NoError(t, err)
+	if assert.Len(t, nodes, 2) {
+		assert.Empty(t, nodes[0].Children)
+		assert.EqualValues(t, 1, nodes[0].Props["real"].IntegerValue().Int64())
+		assert.EqualValues(t, "next", nodes[1].Name)
+	}
+
+	reader = readerFromString("node /-{\n  a /-{ x } { y }\n  b\n} {\n  c /-{ z }\n}\nnext")
+	nodes, err = readNodes(&reader)
+	assert.NoError(t, err)
+	if assert.Len(t, nodes, 2) && assert.Len(t, nodes[0].Children, 1) {
+		c := nodes[0].Children[0]
+		assert.EqualValues(t, "c", c.Name)
+		assert.Empty(t, c.Children)
+		assert.EqualValues(t, "next", nodes[1].Name)
+	}
+}
+
+func TestValidatesSlashdashedChildren(t *testing.T) {
+	reader := readerFromString("node /-{\n  a key = 1\n}\nnext")
+	_, err := readNodes(&reader)
+	assert.ErrorIs(t, err, errWhitespaceAroundEquals)
+
+	reader = readerFromString("node /-{\n  a /-{\n}\nnext")
+	_, err = readNodes(&reader)
+	assert.ErrorIs(t, err, ErrUnclosedChildren)
+}
+
+func TestSlashdashSilencesTypeHint(t *testing.T) {
+	inputs := []string{
+		"/-(hint)node 1\nreal",
+		"/- (hint)node 1\nreal",
+		"/- \\\n  (hint)node 1\nreal",
+		"/- /* why */ (hint)node \\\n  1 { child; }\nreal",
+		"/-(hint)\"quoted node\" (u8)1 key=(i32)2\nreal",
+	}
+	for _, input := range inputs {
+		reader := readerFromString(input)
+		nodes, err := readNodes(&reader)
+		if assert.NoError(t, err, input) && assert.Len(t, nodes, 1, input) {
+			assert.EqualValues(t, "real", nodes[0].Name, input)
+			assert.True(t, nodes[0].TypeHint.IsAbsent(), input)
+		}
+	}
+}
+
+func TestRejectsSlashdashAfterTypeHint(t *testing.T) {
+	for _, input := range []string{"(hint)/-node\nreal", "node (u8)/-1\nreal"} {
+		reader := readerFromString(input)
+		_, err := readNodes(&reader)
+		assert.ErrorIs(t, err, errSlashdashAfterHint, input)
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe) {
+			assert.Equal(t, CodeUnexpectedSlashdash, pe.Code)
+		}
+	}
+}
+
+func TestValidatesSlashdashedNodeWithTypeHint(t *testing.T) {
+	for _, input := range []string{"/-(hint node\nreal", "/-(hint)node key = 1\nreal", "/-(hint)1node\nreal"} {
+		reader := readerFromString(input)
+		_, err := readNodes(&reader)
+		assert.ErrorIs(t, err, ErrInvalidSyntax, input)
+	}
+}
+
+func TestReadsInlineChildren(t *testing.T) {
+	// Each input is written back as its canonical form
+	cases := map[string]string{
+		"node {a;b;c}":         "node {\n    a\n    b\n    c\n}\n",
+		"node { a; }":          "node {\n    a\n}\n",
+		"node {a}":             "node {\n    a\n}\n",
+		"node{a}":              "node {\n    a\n}\n",
+		"node {a {b;c}; d}":    "node {\n    a {\n        b\n        c\n    }\n    d\n}\n",
+		"node {a 1; b k=2}":    "node {\n    a 1\n    b k=2\n}\n",
+		"node 1{a}":            "node 1 {\n    a\n}\n",
+		"node \"s\"{(t)a}":     "node \"s\" {\n    (t)a\n}\n",
+		"node k=1{a};next":     "node k=1 {\n    a\n}\nnext\n",
+		"node {\"a\";\"b c\"}": "node {\n    \"a\"\n    \"b c\"\n}\n",
+		"node {}":              "node\n",
+	}
+	for input, expected := range cases {
+		doc, err := ParseString(input)
+		if !assert.NoError(t, err, input) {
+			continue
+		}
+		written, err := doc.WriteString()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, written, input)
+	}
+}
+
+func TestRejectsEmptyStatementsInChildren(t *testing.T) {
+	for _, input := range []string{"node {;}", "node { ;; a }", "node { a;; }"} {
+		_, err := ParseString(input)
+		assert.ErrorIs(t, err, errUnexpectedSemicolon, input)
+	}
+}
+
+func TestReadsLineContinuationWithComment(t *testing.T) {
+	inputs := []string{
+		"node 1 \\ // explanation\n  2",
+		"node 1 \\ // a \\ in the comment\n  2",
+		"node 1 \\   \n  2",
+		"node 1 \\\t// explanation\r\n  2",
+		"node 1 \\ /* c */ // c\n  2",
+	}
+	for _, input := range inputs {
+		reader := readerFromString(input)
+		n, err := readNode(&reader)
+		if assert.NoError(t, err, input) && assert.Len(t, n.Args, 2, input) {
+			assert.EqualValues(t, 2, n.Args[1].IntegerValue().Int64(), input)
+		}
+	}
+}
+
+func TestReadsLineContinuationAtEOF(t *testing.T) {
+	for _, input := range []string{"node 1 \\", "node 1 \\   "} {
+		_, err := ParseString(input)
+		assert.ErrorIs(t, err, errLineContAtEOF, input)
+		assert.ErrorIs(t, err, ErrUnexpectedEOF, input)
+		assert.ErrorContains(t, err, "line continuation at end of file", input)
+
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe) {
+			assert.Equal(t, CodeUnexpectedEOF, pe.Code)
+			assert.Equal(t, 8, pe.Column)
+		}
+
+		// KDL 2.0 allows the document to end there
+		doc, err := ParseString(input, WithVersion(Version2))
+		if assert.NoError(t, err, input) {
+			assert.Len(t, doc.Nodes[0].Args, 1)
+		}
+	}
+
+	// A comment runs up to the end of the file
+	doc, err := ParseString("node 1 \\ // c")
+	if assert.NoError(t, err) {
+		assert.Len(t, doc.Nodes[0].Args, 1)
+	}
+}
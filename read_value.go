@@ -2,8 +2,10 @@ package kdl
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"math/big"
 	"regexp"
 	"strconv"
@@ -15,44 +17,149 @@ import (
 	"golang.org/x/exp/slices"
 )
 
-var unicodeEscapePattern = regexp.MustCompile(`\\u\{([0-9a-fA-F]{1,6})\}`)
+var errBadEscape = withCode(CodeBadEscape, fmt.Errorf("%w: invalid escape sequence", ErrInvalidSyntax))
 
-func unicodeUnescapeFunc(matched string) string {
-	i, _ := strconv.ParseInt(matched[3:len(matched)-1], 16, 32)
-	return string(rune(i))
+// badEscapeError describes an invalid escape sequence found in a string.
+type badEscapeError struct {
+	text   string // The offending escape sequence, as written.
+	offset int    // Byte offset of the escape sequence from the start of the contents of the string.
 }
 
-var escapeReplacer = strings.NewReplacer(
-	`\/`, `/`,
-	`\\`, `\`,
-	`\"`, `"`,
-	`\n`, "\n",
-	`\r`, "\r",
-	`\t`, "\t",
-	`\b`, "\b",
-	`\f`, "\f",
-)
+func (e *badEscapeError) Error() string {
+	return errBadEscape.Error() + " " + strconv.Quote(e.text)
+}
+
+func (e *badEscapeError) Unwrap() error {
+	return errBadEscape
+}
+
+// maxUnicodeEscapeLength is the number of bytes of \u{10FFFF}.
+const maxUnicodeEscapeLength = 10
+
+// unescapeString replaces escape sequences in the contents of a quoted string.
+//
+// A Unicode escape must name a Unicode scalar value, i.e. have 1 to 6 hex digits
+// and not be a surrogate half nor be above U+10FFFF.
+// Note that \u{0} is a valid escape in both versions of the specification,
+// even though KDL 2.0 does not allow a literal NUL in the document.
+//
+// KDL 2.0 adds \s for a space, and a backslash followed by whitespace,
+// which is removed together with the backslash, so that long strings can be wrapped.
+func unescapeString(s string, version Version) (string, error) {
+
+	var b strings.Builder
+	b.Grow(len(s))
+	contents := s
+
+	for {
+
+		i := strings.IndexByte(s, '\\')
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+
+		b.WriteString(s[:i])
+		start := len(contents) - len(s) + i
+		s = s[i+1:]
+		if len(s) == 0 {
+			return "", &badEscapeError{text: `\`, offset: start}
+		}
+
+		escaped := s[0]
+		s = s[1:]
+
+		switch escaped {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '\\', '/', '"':
+			b.WriteByte(escaped)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 's':
+			if version < Version2 {
+				return "", &badEscapeError{text: `\s`, offset: start}
+			}
+			b.WriteByte(' ')
+		case 'u':
+			end := strings.IndexByte(s, '}')
+			if len(s) < 3 || s[0] != '{' || end < 2 || end > 7 {
+				return "", badUnicodeEscape(contents, start)
+			}
+			i, err := strconv.ParseUint(s[1:end], 16, 32)
+			if err != nil || i > unicode.MaxRune || (i >= 0xd800 && i <= 0xdfff) {
+				return "", badUnicodeEscape(contents, start)
+			}
+			b.WriteRune(rune(i))
+			s = s[end+1:]
+		default:
+			ch, size := utf8.DecodeRuneInString(contents[start+1:])
+			if version >= Version2 && (isWhitespace(ch) || isNewLine(ch)) {
+				s = strings.TrimLeftFunc(contents[start+1:], isWhitespaceOrNewLine)
+				continue
+			}
+			return "", &badEscapeError{text: contents[start : start+1+size], offset: start}
+		}
+	}
+}
+
+// badUnicodeEscape describes an invalid \u escape starting at the offset of the contents of a string.
+func badUnicodeEscape(contents string, offset int) error {
+	text := contents[offset:]
+	if end := strings.IndexByte(text, '}'); end >= 0 && end < maxUnicodeEscapeLength+4 {
+		text = text[:end+1]
+	} else if len(text) > maxUnicodeEscapeLength {
+		text = strings.ToValidUTF8(text[:maxUnicodeEscapeLength], "")
+	}
+	return &badEscapeError{text: text, offset: offset}
+}
 
 func readQuotedString(r *reader) (string, error) {
 
+	if multiLine, _ := r.isNext(charsMultiLineQuotes[:]); multiLine {
+		if r.cfg.version() < Version2 {
+			// Never valid in KDL 1.0 either, as an empty string cannot be followed by another one
+			return "", errMultiLineStringInV1
+		}
+		return readMultiLineString(r)
+	}
+	r.quotes = quoting{kind: quoteQuoted}
+
+	start := r.pos()
 	str, escapes, err := readQuotedStringInner(r)
 	if err != nil {
 		return str, err
 	}
 
 	if escapes {
-		str = unicodeEscapePattern.ReplaceAllStringFunc(str, unicodeUnescapeFunc)
-		str = escapeReplacer.Replace(str)
+		s, err := unescapeString(str, r.cfg.version())
+		var bad *badEscapeError
+		if errors.As(err, &bad) {
+			// Point at the escape sequence itself rather than at the whole string
+			return "", errorAt(err, start.after(`"`+str[:bad.offset]))
+		}
+		if err != nil {
+			return "", err
+		}
+		return s, r.checkStringLen(len(s), start)
 	}
 
 	return str, nil
 }
 
-var errUnexpectedEOFInsideString = fmt.Errorf("%w: did you forget to close a string?", ErrUnexpectedEOF)
-var errExpectedQuotedString = fmt.Errorf("%w: expected quoted string", ErrInvalidSyntax)
+// errUnexpectedEOFInsideString is expected to be reported at the position of the opening quote.
+var errUnexpectedEOFInsideString = withCode(CodeUnterminatedString, unexpectedEOFError("unterminated string started: did you forget to close it?"))
+var errExpectedQuotedString = withCode(CodeExpectedValue, fmt.Errorf("%w: expected quoted string", ErrInvalidSyntax))
 
 func readQuotedStringInner(r *reader) (string, bool, error) {
 
+	startPos := r.pos()
 	start, err := r.readByte()
 	if err != nil {
 		// EOF expected to be handled by the caller
@@ -102,33 +209,177 @@ func readQuotedStringInner(r *reader) (string, bool, error) {
 			return toRet, hasEscapes, nil
 		}
 
+		// Escapes can only make the value shorter, so it can be measured after unescaping
+		if !hasEscapes {
+			if err := r.checkStringLen(count, startPos); err != nil {
+				return "", false, err
+			}
+		}
+
+		count++
+	}
+}
+
+var (
+	errMultiLineStringStart = withCode(CodeBadIndentation, fmt.Errorf("%w: the opening quotes of a multi-line string must be followed by a newline", ErrInvalidSyntax))
+	errMultiLineStringEnd   = withCode(CodeBadIndentation, fmt.Errorf("%w: the closing quotes of a multi-line string must be on their own line", ErrInvalidSyntax))
+)
+
+// readMultiLineString reads a KDL 2.0 string enclosed in triple quotes.
+func readMultiLineString(r *reader) (string, error) {
+
+	start := r.pos()
+	line := r.line
+	r.discardBytes(len(charsMultiLineQuotes))
+
+	count := 0
+	var content string
+
+	for {
+
 		count++
+		bytes, err := r.peekBytes(count)
+		if err != nil {
+			if err == io.EOF {
+				err = errUnexpectedEOFInsideString
+			}
+			return "", err
+		}
+
+		ch := bytes[len(bytes)-1]
+		if ch == '\\' {
+			// Whatever is escaped cannot close the string
+			count++
+			continue
+		}
+
+		if ch == '"' {
+			if end, _ := r.peekBytes(count + 2); len(end) == count+2 && end[count] == '"' && end[count+1] == '"' {
+				content = string(bytes[:count-1])
+				r.discardBytes(count + 2)
+				break
+			}
+		}
+	}
+
+	s, err := dedentMultiLineString(content, line)
+	if err != nil {
+		return "", err
+	}
+
+	s, err = unescapeString(s, Version2)
+	if err != nil {
+		return "", err
 	}
+	return s, r.checkStringLen(len(s), start)
 }
 
-var errExpectedRawString = fmt.Errorf("%w: expected raw string", ErrInvalidSyntax)
+// dedentMultiLineString turns the text between the quotes of a multi-line string into its value.
+// The line breaks around the text are removed, together with the indentation of the closing quotes,
+// which must prefix every line that is not blank. Line breaks are normalized to '\n'.
+//
+// The line is where the opening quotes are, so that a badly indented line can be reported.
+func dedentMultiLineString(s string, line int) (string, error) {
+
+	lines := splitLines(s)
+	if len(lines) < 2 || lines[0] != "" {
+		return "", errMultiLineStringStart
+	}
+
+	indent := lines[len(lines)-1]
+	if !isBlank(indent) {
+		return "", errMultiLineStringEnd
+	}
+
+	lines = lines[1 : len(lines)-1]
+	for i, l := range lines {
+		if isBlank(l) {
+			lines[i] = ""
+		} else if strings.HasPrefix(l, indent) {
+			lines[i] = l[len(indent):]
+		} else {
+			return "", withCode(CodeBadIndentation, fmt.Errorf("%w: line %d does not start with the indentation of the closing quotes of its multi-line string", ErrInvalidSyntax, line+1+i))
+		}
+	}
 
+	return strings.Join(lines, "\n"), nil
+}
+
+// splitLines splits the text at line breaks, treating CRLF as a single one.
+func splitLines(s string) []string {
+
+	var lines []string
+	start := 0
+
+	for i := 0; i < len(s); {
+		ch, size := utf8.DecodeRuneInString(s[i:])
+		if isNewLine(ch) {
+			lines = append(lines, s[start:i])
+			if ch == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				size++
+			}
+			start = i + size
+		}
+		i += size
+	}
+
+	return append(lines, s[start:])
+}
+
+// isBlank checks if the text consists only of whitespace.
+func isBlank(s string) bool {
+	for _, ch := range s {
+		if !isWhitespace(ch) {
+			return false
+		}
+	}
+	return true
+}
+
+var errExpectedRawString = withCode(CodeExpectedValue, fmt.Errorf("%w: expected raw string", ErrInvalidSyntax))
+
+var (
+	errRawStringV1InV2     = withCode(CodeVersionSyntax, fmt.Errorf("%w: raw strings are written like #\"foo\"# in KDL 2.0, without the r", ErrInvalidSyntax))
+	errMultiLineStringInV1 = withCode(CodeVersionSyntax, fmt.Errorf("%w: multi-line strings in \"\"\" are only allowed in KDL 2.0", ErrInvalidSyntax))
+	errRawStringV2InV1     = withCode(CodeVersionSyntax, fmt.Errorf("%w: raw strings are written like r#\"foo\"# in KDL 1.0", ErrInvalidSyntax))
+)
+
+// readRawString reads a raw string, e.g. r#"foo"# in KDL 1.0 or #"foo"# in KDL 2.0.
 func readRawString(r *reader) (string, error) {
+	return readRawStringOf(r, r.cfg.version())
+}
+
+// readRawStringOf reads a raw string in the syntax of the version of the specification.
+// If the version is zero, the syntax of any version is accepted.
+func readRawStringOf(r *reader, version Version) (string, error) {
 
+	start := r.pos()
 	ch, err := r.peekByte()
 	if err != nil {
 		// EOF expected to be handled by the caller
 		return "", err
 	}
 
-	// A raw string must start with an 'r'
-	if ch != 'r' {
+	// A raw string must start with an 'r' in KDL 1.0, or a '#' in KDL 2.0
+	length := 1
+	if ch == '#' {
+		length = 0
+	} else if ch != 'r' {
 		return "", errExpectedRawString
 	}
 
 	// followed by 0 or more '#' characters
 	leadingPoundCount := 0
-	length := 2
+	length++
 
 	for {
 
 		bytes, err := r.peekBytes(length)
 		if err != nil {
+			if err == io.EOF {
+				// Not a raw string at all, e.g. an identifier at the end of the document
+				err = errExpectedRawString
+			}
 			return "", err
 		}
 
@@ -144,23 +395,58 @@ func readRawString(r *reader) (string, error) {
 		}
 	}
 
+	prefixed := ch == 'r'
+	if prefixed && version == Version2 {
+		return "", errRawStringV1InV2
+	} else if !prefixed && version == Version1 {
+		return "", errRawStringV2InV1
+	}
+
+	// In KDL 2.0, three doublequotes open a multi-line raw string, which needs three to close
+	line := r.line
+	quotes := 1
+	if !prefixed {
+		if next, _ := r.peekBytes(length + 2); len(next) == length+2 && next[length] == '"' && next[length+1] == '"' {
+			quotes = 3
+			length += 2
+		}
+	}
+
 	// The string proper starts now
 	contentStart := length
 	closingPoundCount := 0
-	isJustAfterDoublequotes := false
+	closingQuoteCount := 0
 	var bytes []byte
 
 	for {
 
-		if isJustAfterDoublequotes && leadingPoundCount == closingPoundCount {
-			s := string(bytes[contentStart : len(bytes)-leadingPoundCount-1])
+		if closingQuoteCount >= quotes && leadingPoundCount == closingPoundCount {
+			s := string(bytes[contentStart : len(bytes)-leadingPoundCount-quotes])
 			r.discardBytes(length)
-			return s, nil
+			if quotes > 1 {
+				s, err = dedentMultiLineString(s, line)
+				if err != nil {
+					return "", err
+				}
+			} else {
+				r.quotes = quoting{kind: quoteRaw, hashes: leadingPoundCount}
+			}
+			return s, r.checkStringLen(len(s), start)
+		}
+
+		// Dedenting can only make the value shorter, so it can be measured afterwards
+		if quotes == 1 {
+			if err := r.checkStringLen(length-contentStart-closingQuoteCount-closingPoundCount, start); err != nil {
+				return "", err
+			}
 		}
 
 		length++
 		bytes, err = r.peekBytes(length)
 		if err != nil {
+			if err == io.EOF {
+				err = errUnexpectedEOFInsideString
+			}
 			return "", err
 		}
 
@@ -169,21 +455,24 @@ func readRawString(r *reader) (string, error) {
 			// The contents of the string may have possibly ended.
 			// To return, we must now read the exact number of '#' characters
 			// that we started the raw string with
-			isJustAfterDoublequotes = true
+			if closingPoundCount > 0 {
+				closingQuoteCount = 0
+			}
+			closingQuoteCount++
 			closingPoundCount = 0
 			continue
 		}
 
-		if isJustAfterDoublequotes && ch == '#' {
+		if closingQuoteCount > 0 && ch == '#' {
 			closingPoundCount++
 		} else {
 			closingPoundCount = 0
-			isJustAfterDoublequotes = false
+			closingQuoteCount = 0
 		}
 	}
 }
 
-var errExpectedString = fmt.Errorf("%w: expected string", ErrInvalidSyntax)
+var errExpectedString = withCode(CodeExpectedValue, fmt.Errorf("%w: expected string", ErrInvalidSyntax))
 
 func readString(r *reader) (string, error) {
 
@@ -204,7 +493,7 @@ func readString(r *reader) (string, error) {
 
 var bytesTrue = [...]byte{'t', 'r', 'u', 'e'}
 var bytesFalse = [...]byte{'f', 'a', 'l', 's', 'e'}
-var errExpectedBool = fmt.Errorf("%w: expected boolean", ErrInvalidSyntax)
+var errExpectedBool = withCode(CodeExpectedValue, fmt.Errorf("%w: expected boolean", ErrInvalidSyntax))
 
 func readBool(r *reader) (bool, error) {
 
@@ -234,11 +523,80 @@ func readBool(r *reader) (bool, error) {
 		return start == 't', nil
 	}
 
-	return false, errExpectedBool
+	return false, notKeywordError(r, string(expected), errExpectedBool)
+}
+
+// notKeywordError explains why the next token is not the keyword it starts like:
+// it is either the keyword cut short, or a string that has not been quoted, e.g. foo"bar".
+func notKeywordError(r *reader, keyword string, err error) error {
+	if word, _ := peekWord(r); len(word) > 0 && strings.HasPrefix(keyword, string(word)) {
+		return err
+	}
+	return errUnquotedString
+}
+
+// hashKeywords are the values introduced in KDL 2.0.
+var hashKeywords = [...]string{"#true", "#false", "#null", "#inf", "#-inf", "#nan"}
+
+var errHashKeywordInV1 = withCode(CodeVersionSyntax, fmt.Errorf("%w: keywords like #true are only allowed in KDL 2.0", ErrInvalidSyntax))
+
+// nextHashKeyword returns the KDL 2.0 keyword the reader is positioned at, if any.
+func nextHashKeyword(r *reader) string {
+	for _, keyword := range hashKeywords {
+		if next, _ := r.isNext([]byte(keyword)); next {
+			return keyword
+		}
+	}
+	return ""
+}
+
+// readHashKeyword reads a KDL 2.0 keyword value, e.g. #true.
+func readHashKeyword(r *reader, hint TypeHint) (Value, error) {
+
+	keyword := nextHashKeyword(r)
+	if keyword == "" {
+		return newInvalidValue(), errExpectedValue
+	}
+
+	if r.cfg.version() < Version2 {
+		return newInvalidValue(), errHashKeywordInV1
+	}
+
+	r.discardBytes(len(keyword))
+	switch keyword {
+	case "#true":
+		return NewBoolValue(true, hint), nil
+	case "#false":
+		return NewBoolValue(false, hint), nil
+	case "#null":
+		return NewNullValue(hint), nil
+	case "#inf":
+		return NewFloat64Value(math.Inf(1), hint), nil
+	case "#-inf":
+		return NewFloat64Value(math.Inf(-1), hint), nil
+	default:
+		return NewFloat64Value(math.NaN(), hint), nil
+	}
+}
+
+// checkNotBareKeyword rejects true, false and null written without a '#' in KDL 2.0.
+func checkNotBareKeyword(r *reader) error {
+
+	if r.cfg.version() < Version2 {
+		return nil
+	}
+
+	for _, keyword := range keywords {
+		if next, _ := r.isNext([]byte(keyword)); next {
+			return withCode(CodeVersionSyntax, fmt.Errorf("%w: bare %s is not a value in KDL 2.0, write #%s instead", ErrInvalidSyntax, keyword, keyword))
+		}
+	}
+
+	return nil
 }
 
 var bytesNull = [...]byte{'n', 'u', 'l', 'l'}
-var errExpectedNull = fmt.Errorf("%w: expected null", ErrInvalidSyntax)
+var errExpectedNull = withCode(CodeExpectedValue, fmt.Errorf("%w: expected null", ErrInvalidSyntax))
 
 func readNull(r *reader) error {
 
@@ -252,7 +610,7 @@ func readNull(r *reader) error {
 		return nil
 	}
 
-	return errExpectedNull
+	return notKeywordError(r, string(bytesNull[:]), errExpectedNull)
 }
 
 var (
@@ -260,58 +618,214 @@ var (
 	// Note: Patterns below do not support signs before the number: we're stripping them first
 
 	patternDecimal = regexp.MustCompile(`^[0-9][_0-9]*(\.[0-9][_0-9]*)?([eE][-+]?[0-9][_0-9]*)?$`)
-	errBadDecimal  = fmt.Errorf("%w (decimal does not match pattern)", errInvalidNumValue)
+	errBadDecimal  = withCode(CodeBadNumber, fmt.Errorf("%w (decimal does not match pattern)", errInvalidNumValue))
+
+	errLeadingDecimalPoint  = withCode(CodeBadNumber, fmt.Errorf("%w: a decimal point must be preceded by a digit, e.g. 0.5", errBadDecimal))
+	errTrailingDecimalPoint = withCode(CodeBadNumber, fmt.Errorf("%w: a decimal point must be followed by a digit", errBadDecimal))
+	errSecondDecimalPoint   = withCode(CodeBadNumber, fmt.Errorf("%w: a number can have only one decimal point", errBadDecimal))
+	errFractionalExponent   = withCode(CodeBadNumber, fmt.Errorf("%w: exponent must be an integer", errBadDecimal))
+	errEmptyExponent        = withCode(CodeBadNumber, fmt.Errorf("%w: exponent requires at least one digit", errBadDecimal))
 
 	patternHex = regexp.MustCompile(`^0x[0-9a-fA-F][_0-9a-fA-F]*$`)
-	errBadHex  = fmt.Errorf("%w (hex does not match pattern)", errInvalidNumValue)
+	errBadHex  = withCode(CodeBadNumber, fmt.Errorf("%w (hex does not match pattern)", errInvalidNumValue))
 	prefixHex  = []byte{'0', 'x'}
 
 	patternOctal = regexp.MustCompile(`^0o[0-7][_0-7]*$`)
-	errBadOctal  = fmt.Errorf("%w (octal does not match pattern)", errInvalidNumValue)
+	errBadOctal  = withCode(CodeBadNumber, fmt.Errorf("%w (octal does not match pattern)", errInvalidNumValue))
 	prefixOctal  = []byte{'0', 'o'}
 
 	patternBinary = regexp.MustCompile(`^0b[01][_01]*$`)
-	errBadBinary  = fmt.Errorf("%w (binary does not match pattern)", errInvalidNumValue)
+	errBadBinary  = withCode(CodeBadNumber, fmt.Errorf("%w (binary does not match pattern)", errInvalidNumValue))
 	prefixBinary  = []byte{'0', 'b'}
 
-	errInvalidNumValue    = fmt.Errorf("%w: bad numeric value", ErrInvalidSyntax)
-	errEmptyNumber        = fmt.Errorf("%w (number is empty)", errInvalidNumValue)
-	errSepsOnlyInDecimals = fmt.Errorf("%w (separators available only in numbers base 10)", errInvalidNumValue)
+	errInvalidNumValue     = withCode(CodeBadNumber, fmt.Errorf("%w: bad numeric value", ErrInvalidSyntax))
+	errEmptyNumber         = withCode(CodeBadNumber, fmt.Errorf("%w (number is empty)", errInvalidNumValue))
+	errSepsOnlyInDecimals  = withCode(CodeBadNumber, fmt.Errorf("%w (separators available only in numbers base 10)", errInvalidNumValue))
+	errMisplacedUnderscore = withCode(CodeBadNumber, fmt.Errorf("%w (an underscore must follow a digit)", errInvalidNumValue))
 
-	errFailedToParseInt   = fmt.Errorf("%w (could not parse integer)", errInvalidNumValue)
-	errFailedToParseFloat = fmt.Errorf("%w (could not parse float)", errInvalidNumValue)
+	errFailedToParseInt   = withCode(CodeBadNumber, fmt.Errorf("%w (could not parse integer)", errInvalidNumValue))
+	errFailedToParseFloat = withCode(CodeBadNumber, fmt.Errorf("%w (could not parse float)", errInvalidNumValue))
 )
 
-type number struct {
-	Value interface{}
-	Type  TypeTag
+// badDigitError points the error at the first character of a prefixed number that is not a digit of its base,
+// assuming the reader is positioned at the start of the number.
+func badDigitError(r *reader, err error, literal []byte, base int) error {
+
+	prefixLength := len(prefixHex)
+	if literal[0] == '-' || literal[0] == '+' {
+		prefixLength++
+	}
+
+	for i, ch := range string(literal[prefixLength:]) {
+		if ch == '_' || strings.ContainsRune(hexDigits[:base], unicode.ToLower(ch)) {
+			continue
+		}
+		pos := r.pos().after(string(literal[:prefixLength+i]))
+		return errorAt(fmt.Errorf("%w: %q is not a base %d digit", err, ch, base), pos)
+	}
+
+	if len(literal) == prefixLength {
+		return fmt.Errorf("%w: expected a digit after %q", err, literal)
+	}
+	return err
 }
 
-func readNumber(r *reader) (number, error) {
+const hexDigits = "0123456789abcdef"
 
-	length := 0
-	var data []byte
-	var err error
+// badDecimalError tells what is wrong with a decimal number, pointing at the offending character.
+// It assumes the reader is positioned at the start of the number.
+func badDecimalError(r *reader, literal []byte) error {
 
-	for {
+	signLength := 0
+	if literal[0] == '-' || literal[0] == '+' {
+		signLength = 1
+	}
 
-		length++
+	const (
+		inInteger = iota
+		afterPoint
+		inFraction
+		afterExponent
+		afterExponentSign
+		inExponent
+	)
+
+	text := string(literal[signLength:])
+	state := inInteger
+	point := -1
+	exponent := -1
+
+	errAt := func(err error, i int) error {
+		return errorAt(err, r.pos().after(string(literal[:signLength+i])))
+	}
 
-		data, err = r.peekBytes(length)
-		if err != nil {
-			if err == io.EOF {
-				break
+	for i, ch := range text {
+		switch {
+		case ch >= '0' && ch <= '9':
+			switch state {
+			case afterPoint:
+				state = inFraction
+			case afterExponent, afterExponentSign:
+				state = inExponent
+			}
+			continue
+		case ch == '_' && (state == inInteger || state == inFraction || state == inExponent):
+			continue
+		case ch == '.':
+			switch state {
+			case inInteger:
+				if i == 0 {
+					return errAt(errLeadingDecimalPoint, i)
+				}
+				state = afterPoint
+				point = i
+				continue
+			case afterPoint, inFraction:
+				return errAt(errSecondDecimalPoint, i)
+			default:
+				return errAt(errFractionalExponent, i)
+			}
+		case ch == 'e' || ch == 'E':
+			switch state {
+			case inInteger, inFraction:
+				state = afterExponent
+				exponent = i
+				continue
+			case afterPoint:
+				return errAt(errTrailingDecimalPoint, point)
+			}
+		case ch == '+' || ch == '-':
+			if state == afterExponent {
+				state = afterExponentSign
+				continue
 			}
-			return number{}, err
 		}
+		return errAt(fmt.Errorf("%w: unexpected %q", errBadDecimal, ch), i)
+	}
+
+	switch state {
+	case afterPoint:
+		return errAt(errTrailingDecimalPoint, point)
+	case afterExponent, afterExponentSign:
+		return errAt(errEmptyExponent, exponent)
+	}
+	return errBadDecimal
+}
+
+// peekWord returns, without consuming it, the text up to the next whitespace, newline, ';', '/' or brace.
+func peekWord(r *reader) ([]byte, error) {
 
-		ch := rune(data[len(data)-1])
-		if ch == ';' || ch == '/' || unicode.IsSpace(ch) {
-			data = data[0 : len(data)-1]
+	length := 0
+	for {
+
+		next, err := r.peekBytes(length + utf8.UTFMax)
+		if len(next) <= length {
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+
+		// Whitespace can span several bytes, e.g. a NO-BREAK SPACE
+		ch, size := rune(next[length]), 1
+		if ch >= utf8.RuneSelf {
+			ch, size = utf8.DecodeRune(next[length:])
+		}
+		if ch == ';' || ch == '/' || ch == '{' || ch == '}' || isWhitespace(ch) || isNewLine(ch) {
 			break
 		}
+		length += size
+	}
+
+	data, _ := r.peekBytes(length)
+	return data, nil
+}
+
+// hasMisplacedUnderscore checks if an unsigned number has an underscore
+// at its start, or right after its radix prefix, the decimal point or the exponent marker.
+// Underscores are allowed anywhere after a digit, including at the end of the number.
+func hasMisplacedUnderscore(data []byte) bool {
+
+	decimal := true
+	if len(data) > 2 && data[0] == '0' && (data[1] == 'x' || data[1] == 'o' || data[1] == 'b') {
+		decimal = false
+		data = data[2:]
+	}
+
+	for i, b := range data {
+		if b != '_' {
+			continue
+		}
+		if i == 0 {
+			return true
+		}
+		switch data[i-1] {
+		case '.', '+', '-':
+			return true
+		case 'e', 'E':
+			if decimal {
+				return true
+			}
+		}
 	}
 
+	return false
+}
+
+type number struct {
+	Value interface{}
+	Type  TypeTag
+	Text  string // The literal the number has been read from.
+}
+
+func readNumber(r *reader) (number, error) {
+
+	data, err := peekWord(r)
+	if err != nil {
+		return number{}, err
+	}
+	literal := data
+
 	if len(data) == 0 {
 		return number{}, errEmptyNumber
 	}
@@ -327,30 +841,34 @@ func readNumber(r *reader) (number, error) {
 		data = data[1:]
 	}
 
+	if hasMisplacedUnderscore(data) {
+		return number{}, errMisplacedUnderscore
+	}
+
 	base := 10
-	if len(data) > 2 {
+	if len(data) >= 2 {
 		maybeBasePrefix := data[0:2]
 		if bytes.Equal(maybeBasePrefix, prefixBinary) {
 			base = 2
 			if !patternBinary.Match(data) {
-				return number{}, errBadBinary
+				return number{}, badDigitError(r, errBadBinary, literal, base)
 			}
 		} else if bytes.Equal(maybeBasePrefix, prefixOctal) {
 			base = 8
 			if !patternOctal.Match(data) {
-				return number{}, errBadOctal
+				return number{}, badDigitError(r, errBadOctal, literal, base)
 			}
 		} else if bytes.Equal(maybeBasePrefix, prefixHex) {
 			base = 16
 			if !patternHex.Match(data) {
-				return number{}, errBadHex
+				return number{}, badDigitError(r, errBadHex, literal, base)
 			}
 		}
 	}
 
 	if base == 10 {
 		if !patternDecimal.Match(data) {
-			return number{}, errBadDecimal
+			return number{}, badDecimalError(r, literal)
 		}
 	} else {
 		data = data[2:]
@@ -359,57 +877,54 @@ func readNumber(r *reader) (number, error) {
 		}
 	}
 
+	text := string(literal)
 	str := string(data)
-	r.discardBytes(length - 1)
+	r.discardBytes(len(literal))
+
+	// A decimal point or an exponent makes a number a float, even if its value is whole
+	isFloat := base == 10 && strings.ContainsAny(str, ".eE")
+
+	if r.cfg.UseNumber {
+		// The conversion is left for later, see Number
+		typ := TypeInteger
+		if isFloat {
+			typ = TypeFloat
+		}
+		return number{Type: typ, Value: Number(text), Text: text}, nil
+	}
 
 	str = strings.ReplaceAll(str, "_", "")
-	if base == 10 {
-		if strings.ContainsRune(str, '.') {
-			f, _, err := big.ParseFloat(str, 10, 53, big.AwayFromZero)
-			if err != nil {
-				return number{}, errFailedToParseFloat
-			}
-			if sign < 0 {
-				f = f.Neg(f)
-			}
-			return number{Type: TypeFloat, Value: f}, nil
+	if isFloat {
+		f, _, err := big.ParseFloat(str, 10, 53, big.AwayFromZero)
+		if err != nil {
+			return number{}, errFailedToParseFloat
 		}
-		if strings.ContainsAny(str, "eE") {
-			str = strings.ToUpper(str)
-			man, exp, _ := strings.Cut(str, "E")
-			if strings.HasPrefix(exp, "-") {
-				f, _, err := big.ParseFloat(str, 10, 53, big.AwayFromZero)
-				if err != nil {
-					return number{}, errFailedToParseFloat
-				}
-				if sign < 0 {
-					f = f.Neg(f)
-				}
-				return number{Type: TypeFloat, Value: f}, nil
-			} else {
-				e, _ := strconv.Atoi(exp)
-				str = man + strings.Repeat("0", e)
-			}
+		if sign < 0 {
+			f = f.Neg(f)
 		}
+		return number{Type: TypeFloat, Value: f, Text: text}, nil
 	}
 
-	// Numbers in other bases are guaranteed to be integers
+	// Everything else is an integer
 	i := new(big.Int)
 	_, ok := i.SetString(str, base)
 	if ok {
 		if sign < 0 {
 			i = i.Neg(i)
 		}
-		return number{Type: TypeInteger, Value: i}, nil
+		return number{Type: TypeInteger, Value: i, Text: text}, nil
 	}
 
 	return number{}, errFailedToParseInt
 }
 
 var (
-	errInvalidBareIdent              = fmt.Errorf("%w: invalid bare identifier", ErrInvalidSyntax)
-	errInvalidCharInBareIdent        = fmt.Errorf("%w (illegal character)", errInvalidBareIdent)
-	errInvalidInitialCharInBareIdent = fmt.Errorf("%w (does not start with a valid character)", errInvalidBareIdent)
+	errInvalidBareIdent              = withCode(CodeInvalidIdentifier, fmt.Errorf("%w: invalid bare identifier", ErrInvalidSyntax))
+	errInvalidCharInBareIdent        = withCode(CodeInvalidIdentifier, fmt.Errorf("%w (illegal character)", errInvalidBareIdent))
+	errInvalidInitialCharInBareIdent = withCode(CodeInvalidIdentifier, fmt.Errorf("%w (does not start with a valid character)", errInvalidBareIdent))
+	errNumberLikeBareIdent           = withCode(CodeInvalidIdentifier, fmt.Errorf("%w: it starts like a number, quote it if it is meant to be a name", errInvalidInitialCharInBareIdent))
+	errReservedBareIdent             = withCode(CodeInvalidIdentifier, fmt.Errorf("%w (reserved keyword, quote it if it is meant to be a name)", errInvalidBareIdent))
+	errUnquotedString                = withCode(CodeInvalidIdentifier, fmt.Errorf("%w (strings have to be quoted, e.g. \"foo\")", errInvalidBareIdent))
 )
 
 type identStopMode int
@@ -418,21 +933,28 @@ const (
 	stopModeFreestanding identStopMode = iota
 	stopModeCloseParen
 	stopModeEquals
-	stopModeSemicolon
+	stopModeNodeName // Stops at a ';' or a brace, e.g. in {a;b}.
 )
 
 func readBareIdentifier(r *reader, stopMode identStopMode) (Identifier, error) {
 
+	start := r.pos()
 	ch, err := r.peekRune()
 	if err != nil {
 		return "", err
 	}
 
-	if !isAllowedInitialCharacter(ch) {
+	version := r.cfg.version()
+	if unicode.IsDigit(ch) {
+		return "", errNumberLikeBareIdent
+	}
+	if !isAllowedInitialCharacter(ch, version) {
 		return "", errInvalidInitialCharInBareIdent
 	}
 
+	identClass := bareIdentClass(version)
 	lengthBytes := 0
+	lengthRunes := 0
 	for {
 
 		b, err := r.peekBytes(lengthBytes + 1)
@@ -444,8 +966,14 @@ func readBareIdentifier(r *reader, stopMode identStopMode) (Identifier, error) {
 		}
 
 		lastByte := b[len(b)-1]
+		if lastByte < utf8.RuneSelf && asciiClasses[lastByte]&identClass != 0 {
+			lengthBytes++
+			lengthRunes++
+			continue
+		}
+
 		if !utf8.RuneStart(lastByte) {
-			return "", ErrInvalidEncoding
+			return "", errorAt(errInvalidUTF8, start.advanced(lengthBytes, lengthRunes))
 		}
 
 		runeRemLen := remainingUTF8Bytes(lastByte)
@@ -453,33 +981,36 @@ func readBareIdentifier(r *reader, stopMode identStopMode) (Identifier, error) {
 			b, err = r.peekBytes(lengthBytes + runeRemLen + 1)
 			if err != nil {
 				if err == io.EOF {
-					return "", ErrUnexpectedEOF
+					// The document ends in the middle of the rune
+					return "", errorAt(errInvalidUTF8, start.advanced(lengthBytes, lengthRunes))
 				}
 				return "", err
 			}
 		}
 
-		ch, _ := utf8.DecodeLastRune(b)
-		if ch == utf8.RuneError {
-			return "", ErrInvalidEncoding
+		// A U+FFFD written in the document is fine, an invalid sequence decoded as one is not
+		ch, size := utf8.DecodeRune(b[lengthBytes:])
+		if ch == utf8.RuneError && size == 1 {
+			return "", errorAt(errInvalidUTF8, start.advanced(lengthBytes, lengthRunes))
 		}
 
 		if isWhitespace(ch) || isNewLine(ch) {
 			break
 		}
 
-		if !isRuneAllowedInBareIdentifier(ch) {
+		if !isRuneAllowedInBareIdentifier(ch, version) {
 			if stopMode == stopModeCloseParen && ch == ')' {
 				break
 			} else if stopMode == stopModeEquals && ch == '=' {
 				break
-			} else if stopMode == stopModeSemicolon && ch == ';' {
+			} else if stopMode == stopModeNodeName && (ch == ';' || ch == '{' || ch == '}') {
 				break
 			}
-			return "", errInvalidCharInBareIdent
+			return "", errorAt(errInvalidCharInBareIdent, start.advanced(lengthBytes, lengthRunes))
 		}
 
 		lengthBytes += (runeRemLen + 1)
+		lengthRunes++
 	}
 
 	b, err := r.peekBytes(lengthBytes)
@@ -489,11 +1020,11 @@ func readBareIdentifier(r *reader, stopMode identStopMode) (Identifier, error) {
 
 	// Unsafe string to avoid allocations if this was not a valid identifier
 	ident := unsafe.String(unsafe.SliceData(b), len(b))
-	if isKeyword(ident) {
-		return "", errInvalidBareIdent
+	if isReservedIdentifier(ident, version) {
+		return "", errReservedBareIdent
 	}
-	if startsWithDigit(ident) {
-		return "", errInvalidBareIdent
+	if startsLikeNumber(ident, version) {
+		return "", errNumberLikeBareIdent
 	}
 
 	// Actually make a copy now
@@ -506,6 +1037,9 @@ func readBareIdentifier(r *reader, stopMode identStopMode) (Identifier, error) {
 func readIdentifier(r *reader, stopMode identStopMode) (i Identifier, err error, quoted bool) {
 
 	i = ""
+	start := r.pos()
+	defer func() { err = errorAt(err, start) }()
+	r.quotes = quoting{}
 
 	var ch rune
 	ch, err = r.peekRune()
@@ -521,12 +1055,15 @@ func readIdentifier(r *reader, stopMode identStopMode) (i Identifier, err error,
 		return
 	}
 
-	// r could mean a raw string or a bare ident
-	if ch == 'r' {
+	// r (or # in KDL 2.0) could mean a raw string or a bare ident
+	if ch == 'r' || ch == '#' {
 		s, err = readRawString(r)
-		if err != nil {
+		if err == errExpectedRawString {
 			i, err = readBareIdentifier(r, stopMode)
 			return
+		} else if err != nil {
+			quoted = true
+			return
 		}
 
 		quoted = true
@@ -534,7 +1071,7 @@ func readIdentifier(r *reader, stopMode identStopMode) (i Identifier, err error,
 		return
 	}
 
-	if isAllowedInitialCharacter(ch) {
+	if isAllowedInitialCharacter(ch, r.cfg.version()) || unicode.IsDigit(ch) {
 		i, err = readBareIdentifier(r, stopMode)
 	} else {
 		err = errInvalidInitialCharInBareIdent
@@ -543,11 +1080,16 @@ func readIdentifier(r *reader, stopMode identStopMode) (i Identifier, err error,
 	return
 }
 
-var errExpectedCloseHint = fmt.Errorf("%w: expected ) after type hint", ErrInvalidSyntax)
+var (
+	errExpectedCloseHint   = withCode(CodeUnclosedTypeHint, fmt.Errorf("%w: expected ) after type hint", ErrInvalidSyntax))
+	errWhitespaceInHint    = withCode(CodeWhitespaceInTypeHint, fmt.Errorf("%w: type hints must not have whitespace inside of the parentheses; write (hint)", ErrInvalidSyntax))
+	errWhitespaceAfterHint = withCode(CodeWhitespaceInTypeHint, fmt.Errorf("%w: type hints must not be followed by whitespace; write (hint)value", ErrInvalidSyntax))
+)
 
 // readMaybeTypeHint reads an optional type hint, if one exists in the input.
 func readMaybeTypeHint(r *reader) (TypeHint, error) {
 
+	r.hintQuotes = quoting{}
 	ch, err := r.peekByte()
 	if err != nil {
 		// EOF expected to be handled by the caller
@@ -561,37 +1103,169 @@ func readMaybeTypeHint(r *reader) (TypeHint, error) {
 
 	r.discardByte()
 
-	// An identifier should follow right after - no whitespace nor comments
+	// In KDL 1.0, an identifier should follow right after - no whitespace nor comments
+	if err := skipSpaceInHint(r, errWhitespaceInHint); err != nil {
+		return NoHint(), err
+	}
 	ident, err, _ := readIdentifier(r, stopModeCloseParen)
 	if err != nil {
 		return NoHint(), err
 	}
+	r.hintQuotes = r.quotes.forIdentifier(ident)
 
-	// The parenthesis also should close just after - no whitespace nor comments
+	// The parenthesis also should close just after
+	if err := skipSpaceInHint(r, errWhitespaceInHint); err != nil {
+		return NoHint(), err
+	}
 	ch, err = r.peekByte()
 	if err != nil {
 		if err == io.EOF {
-			return NoHint(), ErrUnexpectedEOF
+			err = ErrUnexpectedEOF
 		}
-		return NoHint(), err
+		return NoHint(), errorAt(err, r.pos())
 	}
 
 	if ch == ')' {
 		r.discardByte()
+		// And the hinted node or value should follow right after the parenthesis
+		if err := skipSpaceInHint(r, errWhitespaceAfterHint); err != nil {
+			return NoHint(), err
+		}
+		// Something like (hint)/-node, where the slashdash would silence only a part of the node
+		if slashdash, _ := r.isNext(charsSlashDash[:]); slashdash {
+			return NoHint(), errorAt(errSlashdashAfterHint, r.pos())
+		}
 		return Hint(string(ident)), nil
 	}
 
-	return NoHint(), errExpectedCloseHint
+	return NoHint(), errorAt(errExpectedCloseHint, r.pos())
+}
+
+var errSlashdashAfterHint = withCode(CodeUnexpectedSlashdash, fmt.Errorf("%w: a slashdash must come before the type annotation, e.g. /-(hint)node", ErrInvalidSyntax))
+
+// skipSpaceInHint discards the whitespace, line continuations and block comments
+// KDL 2.0 allows in and after a type hint. In KDL 1.0, the provided error is returned for any of them instead.
+func skipSpaceInHint(r *reader, errSpace error) error {
+
+	ch, err := r.peekRune()
+	if err != nil {
+		// EOF expected to be handled by the caller
+		return nil
+	}
+	space := isWhitespaceOrNewLine(ch) || ch == '\\'
+	if !space {
+		space, _ = r.isNext(charsStartCommentBlock[:])
+	}
+	if !space {
+		return nil
+	}
+
+	if r.cfg.version() < Version2 {
+		return errorAt(errSpace, r.pos())
+	}
+	err = readUntilSignificant(r, true)
+	if err == io.EOF {
+		return nil
+	}
+	return err
 }
 
-var errExpectedValue = fmt.Errorf("%w: expected value", ErrInvalidSyntax)
+var errExpectedValue = withCode(CodeExpectedValue, fmt.Errorf("%w: expected value", ErrInvalidSyntax))
 
 func readValue(r *reader) (Value, error) {
 
+	hintStart := r.pos()
 	hint, err := readMaybeTypeHint(r)
 	if err != nil {
 		return newInvalidValue(), err
 	}
+	hintQuotes := r.hintQuotes
+
+	start := r.pos()
+	r.quotes = quoting{}
+	v, err := readValueAfterHint(r, hint)
+	if err == nil {
+		err = checkIntegerHint(v)
+	}
+	if err != nil {
+		return v, errorAt(err, start)
+	}
+	v.quotes, v.hintQuotes = r.quotes.forString(), hintQuotes
+
+	return r.positioned(v, hintStart, start), nil
+}
+
+// readNumberValue reads a number as a Value, remembering the text it has been written as.
+func readNumberValue(r *reader, hint TypeHint) (Value, error) {
+
+	n, err := readNumber(r)
+	if err != nil {
+		return newInvalidValue(), err
+	}
+
+	if num, ok := n.Value.(Number); ok {
+		return Value{Type: n.Type, RawValue: num, TypeHint: hint}, nil
+	}
+
+	var v Value
+	switch n.Type {
+	case TypeFloat:
+		f := n.Value.(*big.Float)
+		v = NewFloatValue(f, hint)
+		v.source = &numberLiteral{text: n.Text, value: new(big.Float).Copy(f)}
+	case TypeInteger:
+		i := n.Value.(*big.Int)
+		v = NewIntegerValue(i, hint)
+		v.source = &numberLiteral{text: n.Text, value: new(big.Int).Set(i)}
+	default:
+		return newInvalidValue(), errInvalidNumValue
+	}
+
+	return v, nil
+}
+
+// integerHintRange describes the range of integers a type hint allows.
+type integerHintRange struct {
+	min, max *big.Int
+}
+
+// integerHintRanges lists the reserved integer type hints wider than any Go integer.
+var integerHintRanges = map[Identifier]integerHintRange{
+	"i128": {
+		min: new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127)),
+		max: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1)),
+	},
+	"u128": {
+		min: new(big.Int),
+		max: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1)),
+	},
+}
+
+// checkIntegerHint returns an error if a number Value with an (i128) or (u128) type hint
+// is not an integer, or does not fit in the range of the hint.
+func checkIntegerHint(v Value) error {
+	hint, ok := v.TypeHint.Get()
+	if !ok {
+		return nil
+	}
+	bounds, ok := integerHintRanges[hint]
+	if !ok {
+		return nil
+	}
+	if v.Type == TypeFloat {
+		return withCode(CodeBadNumber, fmt.Errorf("%w: %w: (%s) needs an integer", ErrInvalidSyntax, ErrNotInteger, hint))
+	} else if v.Type != TypeInteger {
+		return nil
+	}
+	i := v.IntegerValue()
+	if i.Cmp(bounds.min) < 0 || i.Cmp(bounds.max) > 0 {
+		return withCode(CodeBadNumber, fmt.Errorf("%w: %w: %s is out of range for (%s)", ErrInvalidSyntax, ErrIntegerOverflow, i, hint))
+	}
+	return nil
+}
+
+// readValueAfterHint reads a Value, assuming its optional type hint has been already consumed.
+func readValueAfterHint(r *reader, hint TypeHint) (Value, error) {
 
 	ch, err := r.peekRune()
 	if err != nil {
@@ -599,18 +1273,16 @@ func readValue(r *reader) (Value, error) {
 	}
 
 	if unicode.IsDigit(ch) {
-		n, err := readNumber(r)
-		if err != nil {
-			return newInvalidValue(), err
-		}
+		return readNumberValue(r, hint)
+	}
 
-		switch n.Type {
-		case TypeFloat:
-			return NewFloatValue(n.Value.(*big.Float), hint), nil
-		case TypeInteger:
-			return NewIntegerValue(n.Value.(*big.Int), hint), nil
-		default:
-			return newInvalidValue(), errInvalidNumValue
+	// In KDL 2.0, a string can be written as a bare identifier too, e.g. node value
+	if ch != '"' && ch != '#' && ch != 'r' {
+		word, _ := peekWord(r)
+		if r.cfg.version() >= Version2 && !startsLikeNumber(string(word), Version2) {
+			return readBareString(r, hint)
+		} else if r.cfg.version() < Version2 && isValidBareIdentifier(string(word), Version1) {
+			return newInvalidValue(), errUnexpectedBareIdentifier
 		}
 	}
 
@@ -621,36 +1293,59 @@ func readValue(r *reader) (Value, error) {
 			return newInvalidValue(), err
 		}
 		return NewStringValue(v, hint), nil
+	case '#':
+		v, err := readRawString(r)
+		if err == errExpectedRawString {
+			return readHashKeyword(r, hint)
+		} else if err != nil {
+			return newInvalidValue(), err
+		}
+		return NewStringValue(v, hint), nil
 	case 't', 'f':
+		if err := checkNotBareKeyword(r); err != nil {
+			return newInvalidValue(), err
+		}
 		v, err := readBool(r)
 		if err != nil {
 			return newInvalidValue(), err
 		}
 		return NewBoolValue(v, hint), nil
 	case '-', '+':
-		n, err := readNumber(r)
-		if err != nil {
-			return newInvalidValue(), err
-		}
-
-		switch n.Type {
-		case TypeFloat:
-			return NewFloatValue(n.Value.(*big.Float), hint), nil
-		case TypeInteger:
-			return NewIntegerValue(n.Value.(*big.Int), hint), nil
-		default:
-			return newInvalidValue(), errInvalidNumValue
-		}
+		return readNumberValue(r, hint)
 	case 'r':
 		v, err := readRawString(r)
-		if err != nil {
+		if err == errExpectedRawString {
+			if r.cfg.version() >= Version2 {
+				return readBareString(r, hint)
+			}
+			return newInvalidValue(), errUnexpectedBareIdentifier
+		} else if err != nil {
 			return newInvalidValue(), err
 		}
 		return NewStringValue(v, hint), nil
 	case 'n':
+		if err := checkNotBareKeyword(r); err != nil {
+			return newInvalidValue(), err
+		}
 		err := readNull(r)
 		return NewNullValue(hint), err
 	default:
 		return newInvalidValue(), errExpectedValue
 	}
 }
+
+// readBareString reads a string written as a bare identifier, which only KDL 2.0 allows.
+func readBareString(r *reader, hint TypeHint) (Value, error) {
+	i, err := readBareIdentifier(r, stopModeNodeName)
+	if err == errReservedBareIdent {
+		// Something like true, which is #true in KDL 2.0
+		if kwErr := checkNotBareKeyword(r); kwErr != nil {
+			err = kwErr
+		}
+	}
+	if err != nil {
+		return newInvalidValue(), err
+	}
+	r.quotes = quoting{kind: quoteBare}
+	return NewStringValue(string(i), hint), nil
+}
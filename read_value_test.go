@@ -2,7 +2,7 @@ package kdl
 
 import (
 	"bufio"
-	"io"
+	"math"
 	"math/big"
 	"strings"
 	"testing"
@@ -54,7 +54,8 @@ func TestReadsRawString(t *testing.T) {
 	assert.Equal(t, "oh\n\tHi\"##there##!\n", s)
 
 	_, err = readRawString(&reader)
-	assert.ErrorIs(t, err, io.EOF)
+	assert.ErrorIs(t, err, errUnexpectedEOFInsideString)
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
 
 	reader = readerFromString(`r#"one pound"#`)
 	s, err = readRawString(&reader)
@@ -68,6 +69,78 @@ func TestReadsRawString(t *testing.T) {
 
 }
 
+func TestReadsRawStringOfVersion2(t *testing.T) {
+
+	reader := readerFromString(`#"C:\path "quoted""###"a"#b"##`)
+	reader.cfg.Version = Version2
+
+	s, err := readRawString(&reader)
+	assert.NoError(t, err)
+	assert.Equal(t, `C:\path "quoted"`, s)
+
+	s, err = readRawString(&reader)
+	assert.NoError(t, err)
+	assert.Equal(t, `a"#b`, s)
+
+	reader = readerFromString(`r"old style"`)
+	reader.cfg.Version = Version2
+	_, err = readRawString(&reader)
+	assert.ErrorIs(t, err, errRawStringV1InV2)
+	assert.True(t, hasErrorCode(err, CodeVersionSyntax))
+
+	reader = readerFromString(`#"new style"#`)
+	_, err = readRawString(&reader)
+	assert.ErrorIs(t, err, errRawStringV2InV1)
+	assert.True(t, hasErrorCode(err, CodeVersionSyntax))
+}
+
+func TestReadsMultiLineString(t *testing.T) {
+
+	reader := readerFromString("\"\"\"\n    Hello\n\n      \\\"world\\\"\n    \"\"\"")
+	reader.cfg.Version = Version2
+	s, err := readQuotedString(&reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello\n\n  \"world\"", s)
+
+	reader = readerFromString("\"\"\"\n\"\"\"")
+	reader.cfg.Version = Version2
+	s, err = readQuotedString(&reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "", s)
+
+	reader = readerFromString("\"\"\"\r\n  one\r\n  two\r\n  \"\"\"")
+	reader.cfg.Version = Version2
+	s, err = readQuotedString(&reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "one\ntwo", s)
+
+	reader = readerFromString("#\"\"\"\n  C:\\\"\"\"\n  \"\"\"#")
+	reader.cfg.Version = Version2
+	s, err = readRawString(&reader)
+	assert.NoError(t, err)
+	assert.Equal(t, `C:\"""`, s)
+}
+
+func TestReadsMultiLineStringWithBadIndentation(t *testing.T) {
+
+	// Tabs do not match spaces
+	reader := readerFromString("\"\"\"\n\tfoo\n  \"\"\"")
+	reader.cfg.Version = Version2
+	_, err := readQuotedString(&reader)
+	assert.True(t, hasErrorCode(err, CodeBadIndentation))
+	assert.ErrorContains(t, err, "line 2 ")
+
+	reader = readerFromString("\"\"\"foo\n\"\"\"")
+	reader.cfg.Version = Version2
+	_, err = readQuotedString(&reader)
+	assert.ErrorIs(t, err, errMultiLineStringStart)
+
+	reader = readerFromString("\"\"\"\n  foo\"\"\"")
+	reader.cfg.Version = Version2
+	_, err = readQuotedString(&reader)
+	assert.ErrorIs(t, err, errMultiLineStringEnd)
+}
+
 func BenchmarkReadRawString(b *testing.B) {
 	input := `r##"Hello world! This is a raw string!"##`
 	for i := 0; i < b.N; i++ {
@@ -103,6 +176,19 @@ func TestReadsBool(t *testing.T) {
 
 	_, err = readBool(&reader)
 	assert.ErrorIs(t, err, ErrInvalidSyntax)
+	assert.ErrorIs(t, err, errUnquotedString)
+
+	// Only something that starts like a boolean is said to be a broken one
+	reader = readerFromString("fals")
+	_, err = readBool(&reader)
+	assert.ErrorIs(t, err, errExpectedBool)
+
+	for _, input := range []string{`n foo"bar"`, `n t"x"`, `n key=nope"x"`} {
+		_, err := ParseString(input)
+		assert.ErrorIs(t, err, errUnquotedString, input)
+		assert.NotErrorIs(t, err, errExpectedBool, input)
+		assert.NotErrorIs(t, err, errExpectedNull, input)
+	}
 }
 
 func BenchmarkReadBool(b *testing.B) {
@@ -124,6 +210,10 @@ func TestReadsNull(t *testing.T) {
 
 	err = readNull(&reader)
 	assert.ErrorIs(t, err, ErrInvalidSyntax)
+
+	reader = readerFromString("nul")
+	err = readNull(&reader)
+	assert.ErrorIs(t, err, errExpectedNull)
 }
 
 func expectFloat(t *testing.T, r *reader, v float64) {
@@ -160,12 +250,114 @@ func TestReadsNumberDecimal(t *testing.T) {
 	expectInt(t, &reader, 2)
 	expectInt(t, &reader, -6)
 	expectInt(t, &reader, 1337)
-	expectInt(t, &reader, 4000)
-	expectInt(t, &reader, 2000)
+	expectFloat(t, &reader, 4000)
+	expectFloat(t, &reader, 2000)
 	expectFloat(t, &reader, 0.07)
 	expectFloat(t, &reader, -0.011)
 }
 
+func TestReadsNumberWithUnderscores(t *testing.T) {
+	valid := map[string]any{
+		"1_000_000":    int64(1000000),
+		"1__2":         int64(12),
+		"1_":           int64(1),
+		"-1_0":         int64(-10),
+		"+1_0":         int64(10),
+		"1_e5":         int64(100000),
+		"1e5_":         int64(100000),
+		"1_0E+1_0":     int64(100000000000),
+		"0xDEAD_BEEF":  int64(0xDEADBEEF),
+		"0xdead_beef_": int64(0xDEADBEEF),
+		"0xE_1":        int64(0xE1),
+		"0o1_7_":       int64(0o17),
+		"0b1_0_":       int64(2),
+		"1_0.5_0e1_0":  1.05e11,
+		"1_.5":         1.5,
+		"1.0_":         1.0,
+		"1e-5_0":       1e-50,
+	}
+	for input, expected := range valid {
+		r := readerFromString(input)
+		n, err := readNumber(&r)
+		if !assert.NoError(t, err, input) {
+			continue
+		}
+		switch v := n.Value.(type) {
+		case *big.Int:
+			assert.Equal(t, expected, v.Int64(), input)
+		case *big.Float:
+			f, _ := v.Float64()
+			assert.InEpsilon(t, expected, f, 1e-9, input)
+		default:
+			t.Errorf("%s: unexpected value %v", input, v)
+		}
+	}
+
+	for _, input := range []string{"_1", "-_1", "0x_1", "0o_7", "0b_1", "1._5", "1e_5", "1e+_5", "1.5E-_2"} {
+		r := readerFromString(input)
+		_, err := readNumber(&r)
+		assert.ErrorIs(t, err, errMisplacedUnderscore, input)
+	}
+}
+
+// Decimal cases adapted from the number tests of the kdl-org test suite.
+func TestReadsDecimalStrictly(t *testing.T) {
+	valid := map[string]float64{
+		"1E+10":    1e10,
+		"1e-7":     1e-7,
+		"-0.0":     0,
+		"1.0":      1,
+		"1_000.0":  1000,
+		"1.5e3":    1500,
+		"-1.5E-3":  -0.0015,
+		"0.000_1":  0.0001,
+		"12e0":     12,
+		"+1.25e+2": 125,
+	}
+	for input, expected := range valid {
+		doc, err := ParseString("node " + input + "\n")
+		if assert.NoError(t, err, input) {
+			var actual float64
+			switch v := doc.Nodes[0].Args[0].RawValue.(type) {
+			case *big.Int:
+				actual, _ = new(big.Float).SetInt(v).Float64()
+			case *big.Float:
+				actual, _ = v.Float64()
+			}
+			assert.InDelta(t, expected, actual, 1e-12, input)
+		}
+	}
+
+	invalid := []struct {
+		input  string
+		err    error
+		column int
+	}{
+		{"1.", errTrailingDecimalPoint, 2},
+		{"1.e5", errTrailingDecimalPoint, 2},
+		{".5", errLeadingDecimalPoint, 1},
+		{"-.5", errLeadingDecimalPoint, 1},
+		{"1e", errEmptyExponent, 2},
+		{"1e+", errEmptyExponent, 2},
+		{"-1.5E-", errEmptyExponent, 5},
+		{"1.2.3", errSecondDecimalPoint, 4},
+		{"1e5.5", errFractionalExponent, 4},
+		{"1ee5", errBadDecimal, 3},
+		{"1x", errBadDecimal, 2},
+		{"1e5e5", errBadDecimal, 4},
+		{"1+2", errBadDecimal, 2},
+	}
+	for _, c := range invalid {
+		_, err := ParseString("node " + c.input + "\n")
+		assert.ErrorIs(t, err, c.err, c.input)
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe, c.input) {
+			assert.Equal(t, CodeBadNumber, pe.Code, c.input)
+			assert.Equal(t, 5+c.column, pe.Column, c.input)
+		}
+	}
+}
+
 func TestReadsNumberHex(t *testing.T) {
 	reader := readerFromString("0xc 0xa_0_f -0xD2")
 	expectInt(t, &reader, 12)
@@ -185,6 +377,35 @@ func TestReadsNumberBinary(t *testing.T) {
 	expectInt(t, &reader, -129)
 }
 
+func TestReadsPrefixedNumberWithBadDigit(t *testing.T) {
+	cases := map[string]int{
+		"0b102":      5,
+		"-0o7_8":     6,
+		"+0xDEAD_G0": 9,
+		"0b1é":       4,
+	}
+	for input, column := range cases {
+		_, err := ParseString("node " + input + "\n")
+		assert.ErrorIs(t, err, errInvalidNumValue, input)
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe, input) {
+			assert.Equal(t, CodeBadNumber, pe.Code, input)
+			assert.Equal(t, 5+column, pe.Column, input)
+		}
+	}
+}
+
+func TestReadsPrefixedNumberBeyondInt64(t *testing.T) {
+	doc, err := ParseString("node 0x1_0000_0000_0000_0000 -0o2_000_000_000_000_000_000_001 0b1" + strings.Repeat("0", 64) + "\n")
+	assert.NoError(t, err)
+
+	expected := []string{"18446744073709551616", "-18446744073709551617", "18446744073709551616"}
+	for i, arg := range doc.Nodes[0].Args {
+		assert.Equal(t, TypeInteger, arg.Type)
+		assert.Equal(t, expected[i], arg.RawValue.(*big.Int).String())
+	}
+}
+
 func TestReadsBareIdentifier(t *testing.T) {
 
 	reader := readerFromString("abc")
@@ -291,7 +512,9 @@ func TestReadsValue(t *testing.T) {
 	_ = readUntilSignificant(&reader, true)
 	value, err = readValue(&reader)
 	assert.NoError(t, err)
-	assert.EqualValues(t, NewNullValue(Hint("hey")), value)
+	hinted := NewNullValue(Hint("hey"))
+	hinted.hintQuotes = quoting{kind: quoteQuoted}
+	assert.EqualValues(t, hinted, value)
 
 	_ = readUntilSignificant(&reader, true)
 	value, err = readValue(&reader)
@@ -302,3 +525,332 @@ func TestReadsValue(t *testing.T) {
 	_, err = readValue(&reader)
 	assert.Error(t, err)
 }
+
+func TestUnescapesString(t *testing.T) {
+	cases := map[string]string{
+		`plain`:           "plain",
+		`a\nb\rc\td`:      "a\nb\rc\td",
+		`\\\/\"\b\f`:      "\\/\"\b\f",
+		`\u{41}\u{1F600}`: "A\U0001F600",
+		`\\u{41}`:         `\u{41}`,
+	}
+	for input, expected := range cases {
+		s, err := unescapeString(input, Version1)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, s)
+	}
+
+	for _, input := range []string{`\q`, `\`, `\u`, `\u{}`, `\u{41`, `\u41`, `\u{1234567}`, `\u{xyz}`} {
+		_, err := unescapeString(input, Version1)
+		assert.ErrorIs(t, err, errBadEscape, input)
+	}
+}
+
+func TestUnescapesWhitespaceInV2(t *testing.T) {
+	s, err := unescapeString(`a \s\s b\s`, Version2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a    b "), []byte(s))
+
+	s, err = unescapeString("Lorem ipsum \\\n    dolor sit amet, \\\r\n\t\u3000consectetur\\   \n\n  adipiscing", Version2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Lorem ipsum dolor sit amet, consecteturadipiscing"), []byte(s))
+
+	_, err = unescapeString(`\s`, Version1)
+	assert.ErrorIs(t, err, errBadEscape)
+	_, err = unescapeString("\\\n", Version1)
+	assert.ErrorIs(t, err, errBadEscape)
+}
+
+func TestParseWrappedString(t *testing.T) {
+	doc, err := ParseString("node \"one \\\n     two\\s\\\n     three\"\n", WithVersion(Version2))
+	assert.NoError(t, err)
+	if assert.Len(t, doc.Nodes, 1) {
+		assert.Equal(t, []byte("one two three"), []byte(doc.Nodes[0].Args[0].StringValue()))
+	}
+
+	_, err = ParseString("node \"one \\\n     two\"\n")
+	assert.ErrorIs(t, err, errBadEscape)
+}
+
+func TestValidatesUnicodeEscapes(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+		bad      string
+	}{
+		{input: `\u{0}`, expected: "\x00"},
+		{input: `\u{7f}`, expected: "\x7f"},
+		{input: `\u{D7FF}`, expected: "\ud7ff"},
+		{input: `\u{E000}`, expected: "\ue000"},
+		{input: `\u{10FFFF}`, expected: "\U0010FFFF"},
+		{input: `\u{00041}`, expected: "A"},
+		{input: `a\u{D800}`, bad: `\u{D800}`},
+		{input: `\u{DFFF}b`, bad: `\u{DFFF}`},
+		{input: `\u{110000}`, bad: `\u{110000}`},
+		{input: `\u{FFFFFF}`, bad: `\u{FFFFFF}`},
+		{input: `\u{}`, bad: `\u{}`},
+		{input: `\u{0000041}`, bad: `\u{0000041}`},
+		{input: `\u{-41}`, bad: `\u{-41}`},
+		{input: `ok \ä`, bad: `\ä`},
+	}
+	for _, c := range cases {
+		s, err := unescapeString(c.input, Version1)
+		if c.bad == "" {
+			assert.NoError(t, err, c.input)
+			assert.Equal(t, c.expected, s, c.input)
+			continue
+		}
+
+		var bad *badEscapeError
+		if assert.ErrorAs(t, err, &bad, c.input) {
+			assert.Equal(t, c.bad, bad.text, c.input)
+			assert.Equal(t, strings.Index(c.input, c.bad), bad.offset, c.input)
+		}
+		assert.ErrorIs(t, err, errBadEscape, c.input)
+	}
+}
+
+func TestBadEscapePosition(t *testing.T) {
+	_, err := ParseString("node \"line\none \\u{D800}\"\n")
+	assert.ErrorContains(t, err, `"\\u{D800}"`)
+
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, CodeBadEscape, pe.Code)
+		assert.Equal(t, 2, pe.Line)
+		assert.Equal(t, 5, pe.Column)
+		assert.Equal(t, 15, pe.Offset)
+	}
+}
+
+func TestReadsHashKeywordsInV2(t *testing.T) {
+
+	reader := readerFromString("#true #false #null #inf #-inf #nan #maybe")
+	reader.cfg.Version = Version2
+
+	values := make([]Value, 0)
+	for i := 0; i < 6; i++ {
+		_ = readUntilSignificant(&reader, true)
+		v, err := readValue(&reader)
+		assert.NoError(t, err)
+		values = append(values, v)
+	}
+
+	assert.Equal(t, true, values[0].BoolValue())
+	assert.Equal(t, false, values[1].BoolValue())
+	assert.Equal(t, TypeNull, values[2].Type)
+	assert.True(t, math.IsInf(values[3].Float64Value(), 1))
+	assert.True(t, math.IsInf(values[4].Float64Value(), -1))
+	assert.True(t, values[5].IsNaN())
+	assert.True(t, math.IsNaN(values[5].Float64Value()))
+
+	_ = readUntilSignificant(&reader, true)
+	_, err := readValue(&reader)
+	assert.ErrorIs(t, err, errExpectedValue)
+}
+
+func TestRejectsKeywordsOfOtherVersion(t *testing.T) {
+
+	_, err := ParseString("node #true")
+	assert.ErrorIs(t, err, errHashKeywordInV1)
+
+	for _, keyword := range keywords {
+		_, err = ParseString("node "+keyword, WithVersion(Version2))
+		assert.ErrorIs(t, err, ErrInvalidSyntax)
+		assert.ErrorContains(t, err, "write #"+keyword+" instead")
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe) {
+			assert.Equal(t, CodeVersionSyntax, pe.Code)
+			assert.Equal(t, 6, pe.Column)
+		}
+	}
+
+	doc, err := ParseString("node (flag)#false key=#null")
+	assert.ErrorIs(t, err, errHashKeywordInV1)
+	doc, err = ParseString("node (flag)#false key=#null", WithVersion(Version2))
+	assert.NoError(t, err)
+	assert.Equal(t, NewBoolValue(false, Hint("flag")), doc.Nodes[0].Args[0])
+	assert.Equal(t, NewNullValue(NoHint()), doc.Nodes[0].Props["key"])
+}
+
+func TestReads128BitHints(t *testing.T) {
+	valid := map[string]string{
+		"(i128)170141183460469231731687303715884105727":   "170141183460469231731687303715884105727",
+		"(i128)-170141183460469231731687303715884105728":  "-170141183460469231731687303715884105728",
+		"(u128)340282366920938463463374607431768211455":   "340282366920938463463374607431768211455",
+		"(u128)0xFFFF_FFFF_FFFF_FFFF_FFFF_FFFF_FFFF_FFFF": "340282366920938463463374607431768211455",
+		"(u128)0": "0",
+	}
+	for text, expected := range valid {
+		for _, input := range []string{"node " + text + "\n", "node key=" + text + "\n"} {
+			doc, err := ParseString(input)
+			if !assert.NoError(t, err, input) {
+				continue
+			}
+			n := doc.Nodes[0]
+			v, ok := n.Props["key"]
+			if !ok {
+				v = n.Args[0]
+			}
+			assert.Equal(t, expected, v.IntegerValue().String(), input)
+
+			written, err := doc.WriteString()
+			assert.NoError(t, err)
+			assert.Equal(t, input, written)
+		}
+	}
+}
+
+func TestRejects128BitHintsOutOfRange(t *testing.T) {
+	invalid := []string{
+		"(i128)170141183460469231731687303715884105728",
+		"(i128)-170141183460469231731687303715884105729",
+		"(u128)340282366920938463463374607431768211456",
+		"(u128)-1",
+	}
+	for _, text := range invalid {
+		for _, input := range []string{"node " + text + "\n", "node key=" + text + "\n"} {
+			_, err := ParseString(input)
+			assert.ErrorIs(t, err, ErrInvalidSyntax, input)
+			assert.ErrorIs(t, err, ErrIntegerOverflow, input)
+			assert.ErrorContains(t, err, text[1:5], input)
+
+			var pe *ParseError
+			if assert.ErrorAs(t, err, &pe) {
+				assert.Equal(t, CodeBadNumber, pe.Code)
+				assert.Equal(t, strings.Index(input, "(")+6, pe.Offset, input)
+			}
+		}
+	}
+
+	_, err := ParseString("node (u128)-1\n", WithNumbers(true))
+	assert.ErrorIs(t, err, ErrIntegerOverflow)
+}
+
+func TestRejects128BitHintsOnNonIntegers(t *testing.T) {
+	for _, text := range []string{"(i128)1.5", "(u128)1e300", "(i128)1.0"} {
+		for _, input := range []string{"node " + text + "\n", "node key=" + text + "\n"} {
+			for _, opt := range []Option{WithNumbers(false), WithNumbers(true)} {
+				_, err := ParseString(input, opt)
+				assert.ErrorIs(t, err, ErrInvalidSyntax, input)
+				assert.ErrorIs(t, err, ErrNotInteger, input)
+
+				var pe *ParseError
+				if assert.ErrorAs(t, err, &pe) {
+					assert.Equal(t, CodeBadNumber, pe.Code)
+					assert.Equal(t, strings.Index(input, "(")+6, pe.Offset, input)
+				}
+			}
+		}
+	}
+
+	for _, input := range []string{"node (i128)#inf\n", "node key=(u128)#nan\n"} {
+		_, err := ParseString(input, WithVersion(Version2))
+		assert.ErrorIs(t, err, ErrNotInteger, input)
+	}
+
+	// Other hints are not checked
+	_, err := ParseString("node (f64)1.5 (i128)\"text\"\n")
+	assert.NoError(t, err)
+}
+
+func TestReadsSignedNumbersInEveryBase(t *testing.T) {
+	valid := map[string]int64{
+		"+3":     3,
+		"-3":     -3,
+		"+0x10":  16,
+		"-0x10":  -16,
+		"+0o17":  15,
+		"-0o17":  -15,
+		"+0b1":   1,
+		"-0b1_0": -2,
+	}
+	for input, expected := range valid {
+		r := readerFromString(input)
+		n, err := readNumber(&r)
+		if !assert.NoError(t, err, input) {
+			continue
+		}
+		assert.Equal(t, expected, n.Value.(*big.Int).Int64(), input)
+		assert.Equal(t, input, n.Text)
+	}
+}
+
+func TestRejectsMalformedSignedPrefixes(t *testing.T) {
+	invalid := map[string]error{
+		"+0x_":  errMisplacedUnderscore,
+		"-0b_1": errMisplacedUnderscore,
+		"+0x":   errBadHex,
+		"-0o":   errBadOctal,
+		"+0b":   errBadBinary,
+		"0x":    errBadHex,
+		"+0xG":  errBadHex,
+		"-0b2":  errBadBinary,
+		"+-1":   errBadDecimal,
+		"++0x1": errBadDecimal,
+	}
+	for input, expected := range invalid {
+		r := readerFromString(input)
+		_, err := readNumber(&r)
+		assert.ErrorIs(t, err, expected, input)
+		assert.ErrorIs(t, err, ErrInvalidSyntax, input)
+	}
+
+	_, err := ParseString("node +0x\n")
+	assert.ErrorContains(t, err, `expected a digit after "+0x"`)
+}
+
+func TestRejectsWhitespaceInTypeHintsInV1(t *testing.T) {
+	invalid := map[string]struct {
+		err    error
+		column int
+	}{
+		"( u8)node":        {errWhitespaceInHint, 2},
+		"(u8 )node":        {errWhitespaceInHint, 4},
+		"(u 8)node":        {errWhitespaceInHint, 3},
+		"(\nu8)node":       {errWhitespaceInHint, 2},
+		"(u8\n)node":       {errWhitespaceInHint, 4},
+		"(u8)\tnode":       {errWhitespaceAfterHint, 5},
+		"(u8) node":        {errWhitespaceAfterHint, 5},
+		"node (u8) 1":      {errWhitespaceAfterHint, 10},
+		"node key=(u8) 1":  {errWhitespaceAfterHint, 14},
+		"node (/**/u8)1":   {errWhitespaceInHint, 7},
+		"node (u8)\\\n  1": {errWhitespaceAfterHint, 10},
+	}
+	for input, expected := range invalid {
+		_, err := ParseString(input + "\n")
+		assert.ErrorIs(t, err, expected.err, input)
+
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe) {
+			assert.Equal(t, CodeWhitespaceInTypeHint, pe.Code, input)
+			assert.Equal(t, expected.column, pe.Column, input)
+		}
+	}
+
+	for _, input := range []string{"(u8)node", "node (u8)1 key=(u8)2", "(\"with space\")node"} {
+		_, err := ParseString(input + "\n")
+		assert.NoError(t, err, input)
+	}
+}
+
+func TestAllowsSpaceInTypeHintsInV2(t *testing.T) {
+	for _, input := range []string{"( u8 )node", "(u8)\tnode", "node (u8) 1", "node key=( u8 ) 1", "node ( \\\n  u8 /* c */ )1"} {
+		doc, err := ParseString(input+"\n", WithVersion(Version2))
+		if assert.NoError(t, err, input) {
+			hint := doc.Nodes[0].TypeHint
+			if n := doc.Nodes[0]; len(n.Args) > 0 {
+				hint = n.Args[0].TypeHint
+			} else if v, ok := n.Props["key"]; ok {
+				hint = v.TypeHint
+			}
+			assert.EqualValues(t, "u8", hint.MustGet(), input)
+		}
+	}
+
+	// New lines and spaces within the name are still not allowed
+	for _, input := range []string{"(\nu8)node", "(u 8)node", "(u8)\nnode"} {
+		_, err := ParseString(input+"\n", WithVersion(Version2))
+		assert.ErrorIs(t, err, ErrInvalidSyntax, input)
+	}
+}
@@ -1,11 +1,18 @@
 package kdl
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"unicode/utf8"
+
+	"golang.org/x/text/transform"
 )
 
 type innerReader interface {
+	io.Reader
 	io.ByteScanner
 	io.RuneScanner
 	Discard(n int) (discarded int, err error)
@@ -13,44 +20,461 @@ type innerReader interface {
 }
 
 type reader struct {
-	reader innerReader
-	line   int
-	pos    int
-	depth  int
+	reader   innerReader
+	mem      *bytesReader // The same as reader, if the whole input is in memory.
+	cfg      parseConfig
+	line     int        // Current line, 1-indexed.
+	column   int        // Count of runes consumed on the current line.
+	offset   int        // Count of bytes consumed from the start of the document.
+	afterCR  bool       // Whether the last consumed rune was a CR.
+	lineText []byte     // Consumed part of the current line, if ErrorSourceContext is enabled.
+	prevLine []byte     // Text of the previous line, if ErrorSourceContext is enabled.
+	errs     []error    // Errors recovered from, if AllErrors is enabled.
+	braces   []position // Positions of the '{' of children blocks being read, innermost last.
+	depth    int
+	nodes    int          // Count of nodes started so far, if MaxNodes is set.
+	charErr  error        // The first disallowed character consumed, if not reported yet.
+	path     []Identifier // Names of the nodes being read, outermost first, for charErr.
+	rawInput bool         // Whether UTF-16 input should be read as is, not transcoded.
+	decoded  bool         // Whether the input is already transcoded from ParseOptions.Encoding.
+
+	// Comments read since they have been attached to a node last, if KeepComments is enabled.
+	comments []string
+	// Whether readUntilSignificant has skipped a comment since this was last reset.
+	sawComment bool
+	// Blank lines read since they have been attached to a node last, if KeepBlankLines is enabled.
+	// The ones after a comment are kept with the comments instead.
+	blankLines int
+
+	// The whole input, if KeepFormat is enabled. See nodeFormat.
+	text string
+	// Offsets of the end of the key of the property being read and of the start of its value,
+	// if KeepFormat or KeepPositions is enabled.
+	propKeyEnd, propValue int
+	// How the last string or identifier, and the last type hint, have been written. See quoting.
+	quotes, hintQuotes quoting
+
+	// Positions of the keys of the properties set so far, if DuplicateProps is DuplicatePropsError.
+	// Entries for keys that the node being read does not have are stale.
+	propKeys map[Identifier]position
+
+	ctx  context.Context // Context of the current parsing operation, if any.
+	done <-chan struct{} // Closed when ctx is canceled, nil if it never is.
+}
+
+// bytesReader is an innerReader backed directly by a byte slice holding the whole document.
+// Unlike a bufio.Reader, it does not copy the input, nor does it limit how far ahead it can peek.
+type bytesReader struct {
+	data []byte
+	pos  int
+	last int // Size of the last rune read, if it can be unread.
+}
+
+func newBytesReader(data []byte) *bytesReader {
+	return &bytesReader{data: data}
+}
+
+func (b *bytesReader) Read(p []byte) (int, error) {
+	if b.pos >= len(b.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, b.data[b.pos:])
+	b.pos += n
+	b.last = 0
+	return n, nil
 }
 
+func (b *bytesReader) ReadByte() (byte, error) {
+	if b.pos >= len(b.data) {
+		return 0, io.EOF
+	}
+	c := b.data[b.pos]
+	b.pos++
+	b.last = 0
+	return c, nil
+}
+
+func (b *bytesReader) UnreadByte() error {
+	if b.pos == 0 {
+		return bufio.ErrInvalidUnreadByte
+	}
+	b.pos--
+	b.last = 0
+	return nil
+}
+
+func (b *bytesReader) ReadRune() (ch rune, size int, err error) {
+	ch, size = b.next()
+	if size == 0 {
+		return ch, 0, io.EOF
+	}
+	b.pos += size
+	b.last = size
+	return ch, size, nil
+}
+
+// next decodes the next rune without consuming it. The size is 0 at the end of the input.
+func (b *bytesReader) next() (rune, int) {
+	if b.pos >= len(b.data) {
+		return 0, 0
+	}
+	if c := b.data[b.pos]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	return utf8.DecodeRune(b.data[b.pos:])
+}
+
+func (b *bytesReader) UnreadRune() error {
+	if b.last <= 0 {
+		return bufio.ErrInvalidUnreadRune
+	}
+	b.pos -= b.last
+	b.last = 0
+	return nil
+}
+
+func (b *bytesReader) Discard(n int) (int, error) {
+	b.last = 0
+	if rest := len(b.data) - b.pos; n > rest {
+		b.pos = len(b.data)
+		return rest, io.EOF
+	}
+	b.pos += n
+	return n, nil
+}
+
+func (b *bytesReader) Peek(n int) ([]byte, error) {
+	b.last = 0
+	if rest := len(b.data) - b.pos; n > rest {
+		return b.data[b.pos:], io.EOF
+	}
+	return b.data[b.pos : b.pos+n], nil
+}
+
+// Buffered returns the count of bytes not read yet.
+func (b *bytesReader) Buffered() int {
+	return len(b.data) - b.pos
+}
+
+// maxRetainedLineLength limits how much of a single line is kept for error messages.
+const maxRetainedLineLength = 256
+
 func wrapReader(r innerReader) reader {
-	return reader{reader: r, line: 1, pos: 0}
+	mem, _ := r.(*bytesReader)
+	return reader{reader: r, mem: mem, line: 1}
 }
 
-func (r *reader) readRune() (ch rune, err error) {
+// setInner makes the reader read the rest of the input from another source.
+func (r *reader) setInner(inner innerReader) {
+	r.reader = inner
+	r.mem, _ = inner.(*bytesReader)
+}
 
-	ch, _, err = r.reader.ReadRune()
-	if err != nil {
+// reset makes the reader start over with another input,
+// keeping the configuration and reusing the allocated buffers.
+func (r *reader) reset(inner innerReader) {
+	*r = reader{
+		cfg:      r.cfg,
+		line:     1,
+		lineText: r.lineText[:0],
+		prevLine: r.prevLine[:0],
+		braces:   r.braces[:0],
+	}
+	r.setInner(inner)
+}
+
+// position describes a place in the document.
+type position struct {
+	offset int // Byte offset from the start of the document, 0-indexed.
+	line   int // Line, 1-indexed.
+	column int // Column in runes, 1-indexed.
+}
+
+// pos returns the position of the next rune to be consumed.
+func (r *reader) pos() position {
+	return position{offset: r.offset, line: r.line, column: r.column + 1}
+}
+
+// after returns the position following the text, if it starts at this position.
+func (p position) after(text string) position {
+	afterCR := false
+	for i := 0; i < len(text); {
+		ch, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		p.offset += size
+		if ch == '\n' && afterCR {
+			afterCR = false
+			continue
+		}
+		afterCR = ch == '\r'
+		if isNewLine(ch) {
+			p.line++
+			p.column = 1
+		} else {
+			p.column++
+		}
+	}
+	return p
+}
+
+// endOfNodes returns an error if the document ended inside of a children block.
+func (r *reader) endOfNodes() error {
+	if r.depth == 0 {
+		return nil
+	}
+	return errorAt(ErrUnclosedChildren, r.braces[len(r.braces)-1])
+}
+
+// recoverFrom records the error and returns true, if the parser should continue after it.
+func (r *reader) recoverFrom(err error) bool {
+	if !r.cfg.AllErrors || !isRecoverable(err) {
+		return false
+	}
+	r.errs = append(r.errs, finishError(err, r))
+	return true
+}
+
+// takeComments returns the comments read since the last call, if any.
+func (r *reader) takeComments() []string {
+	comments := r.comments
+	r.comments = nil
+	return comments
+}
+
+// blankComments returns how many blank lines are at the end of the comments read since the last call
+// to takeComments. See Node.LeadingComments.
+func (r *reader) blankComments() int {
+	n := 0
+	for n < len(r.comments) && r.comments[len(r.comments)-1-n] == "" {
+		n++
+	}
+	return n
+}
+
+// attachTrailingComments adds the comments read since the last call to the comment on the line of the node.
+func (r *reader) attachTrailingComments(node *Node) {
+	for _, comment := range r.takeComments() {
+		if node.TrailingComment != "" {
+			node.TrailingComment += " "
+		}
+		node.TrailingComment += comment
+	}
+}
+
+// keepFormat reports whether the offsets of the nodes read should be recorded, see ParseOptions.KeepFormat.
+// They are only meaningful if the input has not been transcoded.
+func (r *reader) keepFormat() bool {
+	return r.text != "" && r.mem != nil
+}
+
+// setContext makes the reader check for the cancellation of the context.
+func (r *reader) setContext(ctx context.Context) {
+	r.ctx = ctx
+	r.done = ctx.Done()
+}
+
+// checkCanceled returns the error of the context, if it has been canceled.
+func (r *reader) checkCanceled() error {
+	if r.done == nil {
+		return nil
+	}
+	select {
+	case <-r.done:
+		return errorAt(r.ctx.Err(), r.pos())
+	default:
+		return nil
+	}
+}
+
+// startNode counts a node about to be read, checking it against ParseOptions.MaxNodes.
+func (r *reader) startNode() error {
+	if r.cfg.MaxNodes <= 0 {
+		return nil
+	}
+	r.nodes++
+	if r.nodes > r.cfg.MaxNodes {
+		return errorAt(&LimitExceededError{Limit: "MaxNodes", Max: r.cfg.MaxNodes}, r.pos())
+	}
+	return nil
+}
+
+// exceedsInputLimit checks if consuming n more bytes would go over ParseOptions.MaxInputBytes.
+func (r *reader) exceedsInputLimit(n int) bool {
+	return r.cfg.MaxInputBytes > 0 && r.offset+n > r.cfg.MaxInputBytes
+}
+
+// checkStringLen checks the length of a string value against ParseOptions.MaxStringLen.
+// The error is reported at the start of the string.
+func (r *reader) checkStringLen(n int, start position) error {
+	if r.cfg.MaxStringLen > 0 && n > r.cfg.MaxStringLen {
+		return errorAt(&LimitExceededError{Limit: "MaxStringLen", Max: r.cfg.MaxStringLen}, start)
+	}
+	return nil
+}
+
+func (r *reader) inputLimitError() error {
+	return errorAt(&LimitExceededError{Limit: "MaxInputBytes", Max: r.cfg.MaxInputBytes}, r.pos())
+}
+
+// advanced returns a position moved forward on the same line.
+func (p position) advanced(bytes, runes int) position {
+	return position{offset: p.offset + bytes, line: p.line, column: p.column + runes}
+}
+
+// advance updates the position counters after a rune has been consumed.
+//
+// A CRLF sequence is counted as a single line break.
+func (r *reader) advance(ch rune, size int) {
+	if (ch < 0x20 || ch >= 0x7f) && isDisallowedChar(ch) {
+		r.disallowedChar(ch)
+	} else if ch == utf8.RuneError && size == 1 && r.cfg.StrictUTF8 && r.charErr == nil {
+		// Not a U+FFFD written in the document, which takes 3 bytes
+		r.charErr = r.inNodes(errorAt(errInvalidUTF8, r.pos()))
+	}
+
+	r.offset += size
+
+	if ch == '\n' && r.afterCR {
+		r.afterCR = false
 		return
 	}
 
+	r.afterCR = ch == '\r'
 	if isNewLine(ch) {
+		r.line++
+		r.column = 0
+		if r.cfg.ErrorSourceContext {
+			r.prevLine, r.lineText = r.lineText, r.prevLine[:0]
+		}
+		return
+	}
 
-		if ch == '\r' {
+	r.column++
+	if r.cfg.ErrorSourceContext && len(r.lineText) < maxRetainedLineLength {
+		r.lineText = utf8.AppendRune(r.lineText, ch)
+	}
+}
 
-			next, _, errNext := r.reader.ReadRune()
-			if errNext != nil {
-				return
-			}
+var errInvalidUTF8 = withCode(CodeInvalidEncoding, fmt.Errorf("%w: invalid UTF-8 byte sequence", ErrInvalidEncoding))
 
-			if next == '\n' {
-				_ = r.reader.UnreadRune()
-				return
-			}
+var errDisallowedChar = withCode(CodeDisallowedChar, fmt.Errorf("%w: disallowed character", ErrInvalidSyntax))
+
+// disallowedChar reports a disallowed character about to be consumed.
+// Unless it is allowed, the error is kept until takeCharError is called.
+func (r *reader) disallowedChar(ch rune) {
+	if ch == 0xfeff && r.offset == 0 {
+		// A byte order mark
+		return
+	}
+
+	err := r.inNodes(errorAt(fmt.Errorf("%w U+%04X", errDisallowedChar, ch), r.pos()))
+	if !r.cfg.AllowDisallowedChars {
+		if r.charErr == nil {
+			r.charErr = err
 		}
+		return
+	}
 
-		r.line++
-		r.pos = 0
+	if r.cfg.Warn != nil {
+		r.cfg.Warn(finishError(err, r))
+	}
+}
+
+// skipByteOrderMark discards a byte order mark at the very start of the document, if there is one.
+// The mark does not count towards the column of anything on the first line.
+//
+// A UTF-16 byte order mark makes the rest of the input transcoded to UTF-8,
+// so that the positions refer to the decoded text.
+func (r *reader) skipByteOrderMark() {
+	if r.offset != 0 {
 		return
 	}
+	if !r.rawInput {
+		if b, _ := r.reader.Peek(2); len(b) == 2 {
+			if order := utf16ByteOrder(b); order != nil {
+				r.setInner(bufio.NewReader(&utf16Reader{src: r.reader, order: order}))
+			}
+		}
+	}
+	if bom, _ := r.isNext(charsByteOrderMark[:]); bom {
+		r.discardBytes(len(charsByteOrderMark))
+		r.column = 0
+		r.lineText = r.lineText[:0]
+	}
+}
+
+// decodeInput makes the rest of the input transcoded from ParseOptions.Encoding, if set.
+func (r *reader) decodeInput() error {
+	if r.offset != 0 || r.decoded || r.rawInput || r.cfg.Encoding == nil {
+		return nil
+	}
+	r.decoded = true
 
-	r.pos++
+	b, _ := r.reader.Peek(len(charsByteOrderMark))
+	if utf16ByteOrder(b) != nil || bytes.HasPrefix(b, charsByteOrderMark[:]) {
+		if !decodesByteOrderMark(r.cfg.Encoding, b) {
+			return errEncodingMismatch
+		}
+	}
+
+	r.setInner(bufio.NewReader(transform.NewReader(r.reader, r.cfg.Encoding.NewDecoder())))
+	return nil
+}
+
+// inNodes records the names of the nodes being read in an error that is not propagated up through them.
+func (r *reader) inNodes(err error) error {
+	for i := len(r.path) - 1; i >= 0; i-- {
+		err = errorInNode(err, r.path[i])
+	}
+	return err
+}
+
+// takeCharError returns the first disallowed character consumed since the last call, if any.
+func (r *reader) takeCharError() error {
+	err := r.charErr
+	r.charErr = nil
+	return err
+}
+
+// currentLineText returns the text of the line the reader is on, without consuming anything.
+func (r *reader) currentLineText() []byte {
+	text := r.lineText
+	if utf8.RuneCount(text) < r.column {
+		// The line has been truncated already
+		return text
+	}
+
+	rest, _ := r.peekBytes(maxRetainedLineLength - len(text))
+	if end := bytes.IndexFunc(rest, isNewLine); end >= 0 {
+		rest = rest[:end]
+	}
+	return append(text[:len(text):len(text)], rest...)
+}
+
+func (r *reader) readRune() (ch rune, err error) {
+	if r.mem != nil {
+		ch, size := r.mem.next()
+		if size == 0 {
+			return ch, io.EOF
+		}
+		if r.exceedsInputLimit(size) {
+			return utf8.RuneError, r.inputLimitError()
+		}
+		r.mem.pos += size
+		r.mem.last = size
+		r.advance(ch, size)
+		return ch, nil
+	}
+
+	ch, size, err := r.reader.ReadRune()
+	if err != nil {
+		return
+	}
+	if r.exceedsInputLimit(size) {
+		_ = r.reader.UnreadRune()
+		return utf8.RuneError, r.inputLimitError()
+	}
+	r.advance(ch, size)
 	return
 }
 
@@ -62,11 +486,25 @@ func (r *reader) discardRunes(count int) {
 
 func (r *reader) readByte() (b byte, err error) {
 	b, err = r.reader.ReadByte()
-	if b == '\n' || b == '\r' {
-		r.line++
-		r.pos = 0
-	} else {
-		r.pos++
+	if err != nil {
+		return
+	}
+	if r.exceedsInputLimit(1) {
+		_ = r.reader.UnreadByte()
+		return 0, r.inputLimitError()
+	}
+	if b < utf8.RuneSelf {
+		r.advance(rune(b), 1)
+		return
+	}
+
+	// Part of a multi-byte rune
+	r.offset++
+	if utf8.RuneStart(b) {
+		r.column++
+	}
+	if r.cfg.ErrorSourceContext && len(r.lineText) < maxRetainedLineLength {
+		r.lineText = append(r.lineText, b)
 	}
 	return
 }
@@ -77,6 +515,9 @@ func (r *reader) peekByte() (b byte, err error) {
 		return
 	}
 	err = r.reader.UnreadByte()
+	if err == nil && r.exceedsInputLimit(1) {
+		err = r.inputLimitError()
+	}
 	return
 }
 
@@ -86,50 +527,131 @@ func (r *reader) discardByte() {
 
 func (r *reader) discardBytes(count int) {
 
-	wasCR := false
-	bytes, _ := r.peekBytes(count)
-	for _, b := range bytes {
+	b, _ := r.peekBytes(count)
+	if r.mem != nil {
+		r.mem.Discard(len(b))
+	} else {
+		r.reader.Discard(len(b))
+	}
+
+	for len(b) > 0 {
+		ch, size := utf8.DecodeRune(b)
+		r.advance(ch, size)
+		b = b[size:]
+	}
+}
 
-		if b == '\n' && !wasCR {
-			r.line++
-			r.pos = 0
-			wasCR = false
-			continue
-		}
+// discardBytesInto discards the next count bytes, appending them to text, unless it is nil.
+func (r *reader) discardBytesInto(text *[]byte, count int) {
+	if text != nil {
+		b, _ := r.peekBytes(count)
+		*text = append(*text, b...)
+	}
+	r.discardBytes(count)
+}
 
-		if b == '\r' {
-			wasCR = true
-			r.line++
-			r.pos = 0
-			continue
+// peekBytes tries to return next N bytes without advancing the reader.
+func (r *reader) peekBytes(count int) (b []byte, err error) {
+	if r.mem != nil {
+		b, err = r.mem.Peek(count)
+	} else {
+		b, err = r.reader.Peek(count)
+		if err == bufio.ErrBufferFull {
+			// A token longer than the buffer, e.g. a long string, so the rest is read through a larger one
+			r.setInner(bufio.NewReaderSize(r.reader, 2*count))
+			b, err = r.reader.Peek(count)
 		}
+	}
+	if r.exceedsInputLimit(len(b)) {
+		return b[:r.cfg.MaxInputBytes-r.offset], r.inputLimitError()
+	}
+	return b, err
+}
 
-		wasCR = false
-		r.pos++
+func (r *reader) peekRune() (ch rune, err error) {
+	var size int
+	if r.mem != nil {
+		ch, size = r.mem.next()
+		if size == 0 {
+			return ch, io.EOF
+		}
+	} else {
+		ch, size, err = r.reader.ReadRune()
+		if err != nil {
+			return ch, err
+		}
+		err = r.reader.UnreadRune()
 	}
 
-	r.reader.Discard(count)
+	if err == nil && r.exceedsInputLimit(size) {
+		err = r.inputLimitError()
+	}
+	if err == nil && ch == utf8.RuneError && size == 1 && r.cfg.StrictUTF8 {
+		err = errorAt(errInvalidUTF8, r.pos())
+	}
+	return ch, err
 }
 
-// peekBytes tries to return next N bytes without advancing the reader.
-func (r *reader) peekBytes(count int) ([]byte, error) {
-	return r.reader.Peek(count)
+// asciiRun returns the length of the run of ASCII characters not of the stop class
+// that comes next in the input, looking only at what can be peeked without waiting for more.
+func (r *reader) asciiRun(stop charClass) int {
+	n := r.buffered()
+	if n > maxASCIIRun {
+		n = maxASCIIRun
+	}
+	b, _ := r.peekBytes(n)
+	for i, c := range b {
+		if c >= utf8.RuneSelf || asciiClasses[c]&stop != 0 {
+			return i
+		}
+	}
+	return len(b)
 }
 
-func (r *reader) peekRune() (rune, error) {
-	ch, _, err := r.reader.ReadRune()
-	if err != nil {
-		return ch, err
+// maxASCIIRun limits how far ahead asciiRun looks at once.
+const maxASCIIRun = 256
+
+// buffered returns the count of bytes that can be peeked without reading from the source.
+func (r *reader) buffered() int {
+	if r.mem != nil {
+		return r.mem.Buffered()
+	}
+	if b, ok := r.reader.(interface{ Buffered() int }); ok {
+		return b.Buffered()
 	}
+	return 0
+}
 
-	err = r.reader.UnreadRune()
-	return ch, err
+// peekAfterWhitespace returns the first rune after a run of whitespace, without advancing the reader.
+func (r *reader) peekAfterWhitespace() (rune, error) {
+	n := 0
+	for {
+		b, err := r.peekBytes(n + utf8.UTFMax)
+		if len(b) <= n {
+			if err == nil {
+				err = io.EOF
+			}
+			return utf8.RuneError, err
+		}
+
+		ch, size := utf8.DecodeRune(b[n:])
+		if !isWhitespace(ch) {
+			return ch, nil
+		}
+		n += size
+	}
 }
 
+// isNext checks if the bytes are next in the input, without advancing the reader.
+//
+// If the input ends before all of them, they are not next, but io.EOF is returned if nothing is left at all.
 func (r *reader) isNext(expected []byte) (bool, error) {
 
 	next, err := r.peekBytes(len(expected))
 	if err != nil {
+		if err == io.EOF && len(next) > 0 {
+			return false, nil
+		}
 		return false, err
 	}
 
@@ -0,0 +1,161 @@
+package kdl
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readerWithRefills creates a reader with the smallest buffer possible,
+// filled one byte at a time, so that most reads span a refill.
+func readerWithRefills(s string) reader {
+	return wrapReader(bufio.NewReaderSize(iotest.OneByteReader(strings.NewReader(s)), 16))
+}
+
+func TestReaderIsNextAcrossRefills(t *testing.T) {
+	r := readerWithRefills("0123456789abcd/*comment*/")
+	r.discardBytes(14)
+
+	ok, err := r.isNext(charsStartCommentBlock[:])
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 14, r.offset)
+
+	r.discardBytes(2)
+	ok, err = r.isNext([]byte("comment*/"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// A probe longer than the rest of the input
+	ok, err = r.isNext([]byte("comment*/ and"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestReaderRunesSplitAcrossRefills(t *testing.T) {
+	// Each of the multi-byte runes straddles a refill at some point
+	input := strings.Repeat("ż", 7) + "a🐢" + strings.Repeat("ł", 5) + "b"
+	for _, newReader := range []func(string) reader{readerWithRefills, readerFromString} {
+		r := newReader(input)
+		var got []rune
+		for {
+			ch, err := r.peekRune()
+			if err != nil {
+				break
+			}
+			next, err := r.readRune()
+			assert.NoError(t, err)
+			assert.Equal(t, ch, next)
+			got = append(got, ch)
+		}
+		assert.Equal(t, input, string(got))
+		assert.Equal(t, len(input), r.offset)
+		assert.Equal(t, 15, r.column)
+	}
+
+	r := readerWithRefills("ab🐢c")
+	r.discardBytes(2 + len("🐢"))
+	ch, err := r.peekRune()
+	assert.NoError(t, err)
+	assert.Equal(t, 'c', ch)
+	assert.Equal(t, 4, r.pos().column)
+}
+
+func TestReaderPeekDoesNotAllocate(t *testing.T) {
+	input := strings.Repeat("node \"żółw\" /* c */\n", 64)
+	for name, newReader := range map[string]func(string) reader{
+		"bufio": readerWithRefills,
+		"bytes": func(s string) reader { return wrapReader(newBytesReader([]byte(s))) },
+	} {
+		r := newReader(input)
+		allocs := testing.AllocsPerRun(100, func() {
+			_, _ = r.peekRune()
+			_, _ = r.isNext(charsStartCommentBlock[:])
+			_, _ = r.peekBytes(4)
+			r.discardBytes(3)
+		})
+		assert.Zero(t, allocs, name)
+	}
+}
+
+func BenchmarkReaderPeek(b *testing.B) {
+	input := []byte(strings.Repeat("node \"żółw\" /* c */\n", 64))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := wrapReader(bufio.NewReader(strings.NewReader(string(input))))
+		for {
+			if _, err := r.peekRune(); err != nil {
+				break
+			}
+			_, _ = r.isNext(charsStartCommentBlock[:])
+			_, _ = r.readRune()
+		}
+	}
+}
+
+func TestReaderTokensLongerThanBuffer(t *testing.T) {
+	long := strings.Repeat("a", 10000)
+	digits := "1" + strings.Repeat("0", 10000)
+	cases := map[string]string{
+		"quoted string":     `node "` + long + `"`,
+		"raw string":        `node #"` + long + `"#`,
+		"multi-line string": "node \"\"\"\n" + long + "\n\"\"\"",
+		"bare identifier":   long + " 1",
+		"number":            "node " + digits,
+	}
+	paths := map[string]func(src string) (Document, error){
+		"ParseReader": func(src string) (Document, error) {
+			return ParseReader(strings.NewReader(src), WithVersion(Version2))
+		},
+		"bufio.Reader": func(src string) (Document, error) {
+			return ParseReader(bufio.NewReader(iotest.HalfReader(strings.NewReader(src))), WithVersion(Version2))
+		},
+		"ParseFile": func(src string) (Document, error) {
+			path := filepath.Join(t.TempDir(), "long.kdl")
+			if err := os.WriteFile(path, []byte(src), 0o600); err != nil {
+				return Document{}, err
+			}
+			return ParseFile(path, WithVersion(Version2))
+		},
+		"ParseFS": func(src string) (Document, error) {
+			docs, err := ParseFS(fstest.MapFS{"long.kdl": {Data: []byte(src)}}, "*.kdl", WithVersion(Version2))
+			if err != nil || len(docs) != 1 {
+				return Document{}, err
+			}
+			return docs[0], nil
+		},
+		"Decoder": func(src string) (Document, error) {
+			dec := NewDecoder(strings.NewReader(src), WithVersion(Version2))
+			doc := NewDocument()
+			for {
+				node, err := dec.Decode()
+				if err == io.EOF {
+					return doc, nil
+				} else if err != nil {
+					return doc, err
+				}
+				doc.AddChild(node)
+			}
+		},
+	}
+	for name, src := range cases {
+		src += "\nnext\n"
+		want, err := ParseString(src, WithVersion(Version2))
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		for path, parse := range paths {
+			doc, err := parse(src)
+			if assert.NoError(t, err, "%s: %s", path, name) {
+				assert.True(t, want.Equal(&doc), "%s: %s", path, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package kdl
+
+import (
+	"context"
+	"strings"
+)
+
+// TextEdit is a change to the text of a document: the Deleted bytes at Offset are replaced with Inserted.
+type TextEdit struct {
+	Offset   int
+	Deleted  int
+	Inserted string
+}
+
+// Reparse updates the Document after an edit of the text it has been parsed from, given the whole new text.
+//
+// Only the top-level nodes around the edit are parsed again, along with the nodes just before and after them,
+// so that edits joining or splitting nodes are handled. The other nodes are kept as they are,
+// with their positions and spans moved to match the new text. If the nodes around the edit cannot be parsed
+// on their own, e.g. because the edit has opened a multiline comment, the whole text is parsed again.
+// Either way, the result is the same as parsing the new text from scratch with the same options.
+//
+// The Document must have been parsed with ParseOptions.KeepPositions, and not modified since.
+// With ParseOptions.KeepFormat, DetectVersion, MaxNodes or MaxInputBytes, the whole text is always parsed again.
+// If the new text cannot be parsed, the Document is left unchanged, unless ParseOptions.AllErrors is enabled.
+func (d *Document) Reparse(text string, edit TextEdit) error {
+	if d.parsed == nil {
+		return ErrNoPositions
+	}
+	cfg := *d.parsed
+	if cfg.KeepFormat || cfg.DetectVersion || cfg.MaxNodes > 0 || cfg.MaxInputBytes > 0 || isTranscoded(text, cfg) {
+		return d.reparseAll(text)
+	}
+	for i := range d.Nodes {
+		if d.Nodes[i].position == nil {
+			return d.reparseAll(text)
+		}
+	}
+
+	// The nodes touching the edit are parsed again, and so are the ones right before and after them
+	nodes := d.Nodes
+	editEnd := edit.Offset + edit.Deleted
+	first := len(nodes)
+	for i := range nodes {
+		if nodes[i].position.end >= edit.Offset {
+			first = i
+			break
+		}
+	}
+	last := -1
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if nodes[i].position.start().Offset <= editEnd {
+			last = i
+			break
+		}
+	}
+	first, last = first-1, last+2
+	if first < 0 {
+		first = 0
+	}
+	if last > len(nodes) {
+		last = len(nodes)
+	}
+
+	// The text before the first of them is the same as before the edit, so is the state of the parser there
+	start := Position{Line: 1, Column: 1}
+	if first > 0 {
+		start = nodes[first].position.start()
+	}
+	delta := len(edit.Inserted) - edit.Deleted
+	end := len(text)
+	if last < len(nodes) {
+		end = nodes[last].position.start().Offset + delta
+	}
+	if start.Offset > end || end > len(text) {
+		return d.reparseAll(text)
+	}
+
+	region, err := parse(context.Background(), newBytesReader([]byte(text[start.Offset:end])), cfg)
+	if err != nil || len(region.Nodes) == 0 && (first > 0 || last < len(nodes)) {
+		return d.reparseAll(text)
+	}
+	setSource(region.Nodes, d.SourceName)
+	shiftNodes(region.Nodes, positionShift{
+		offset: start.Offset,
+		line:   start.Line - 1,
+		onLine: 1,
+		column: start.Column - 1,
+	})
+	if last < len(nodes) && region.Nodes[len(region.Nodes)-1].position.end != nodes[last-1].position.end+delta {
+		// The edit has changed the meaning of the text after it, e.g. by starting a comment,
+		// so the rest of the text might not be read as it was either
+		return d.reparseAll(text)
+	}
+	if first > 0 {
+		// The comments before the first node are not in the text parsed again
+		region.Nodes[0].LeadingComments = nodes[first].LeadingComments
+		region.Nodes[0].BlankLinesBefore = nodes[first].BlankLinesBefore
+	}
+
+	// The nodes after the edit are moved along with their text
+	after := nodes[last:]
+	if len(after) > 0 {
+		old := after[0].position.start()
+		moved := position{offset: start.Offset, line: start.Line, column: start.Column}.after(text[start.Offset:end])
+		shiftNodes(after, positionShift{
+			offset: delta,
+			line:   moved.line - old.Line,
+			onLine: old.Line,
+			column: moved.column - old.Column,
+		})
+	} else {
+		d.EndComments = region.EndComments
+	}
+
+	spliced := make([]Node, 0, first+len(region.Nodes)+len(after))
+	spliced = append(spliced, nodes[:first]...)
+	spliced = append(spliced, region.Nodes...)
+	d.Nodes = append(spliced, after...)
+	return nil
+}
+
+// reparseAll replaces the Document with the result of parsing the text from scratch, see Reparse.
+func (d *Document) reparseAll(text string) error {
+	doc, err := parseNamed(newBytesReader([]byte(text)), d.SourceName, *d.parsed)
+	if err != nil && !d.parsed.AllErrors {
+		return err
+	}
+	*d = doc
+	return err
+}
+
+// isTranscoded reports whether the text is decoded before being parsed, so that its offsets are not those of the nodes.
+func isTranscoded(text string, cfg parseConfig) bool {
+	if cfg.Encoding != nil {
+		return true
+	}
+	return strings.HasPrefix(text, "\xff\xfe") || strings.HasPrefix(text, "\xfe\xff")
+}
+
+// start returns where the node starts, at its type hint or its name.
+func (p *nodePosition) start() Position {
+	if p.hint.IsValid() {
+		return p.hint
+	}
+	return p.name
+}
+
+// positionShift moves the positions of nodes, e.g. after their text has been moved by an edit.
+// The columns are only moved on one of the lines, before the lines are moved.
+type positionShift struct {
+	offset, line   int
+	onLine, column int
+}
+
+func (s positionShift) position(p Position) Position {
+	if !p.IsValid() {
+		return p
+	}
+	if p.Line == s.onLine {
+		p.Column += s.column
+	}
+	p.Offset += s.offset
+	p.Line += s.line
+	return p
+}
+
+func (s positionShift) span(sp Span) Span {
+	if sp.Len() == 0 {
+		return sp
+	}
+	return Span{sp.Start + s.offset, sp.End + s.offset}
+}
+
+func (s positionShift) value(v *Value) {
+	if v.position == nil {
+		return
+	}
+	// The positions can be shared with clones, so they are replaced instead
+	v.position = &valuePosition{hint: s.position(v.position.hint), value: s.position(v.position.value)}
+}
+
+// shiftNodes moves the positions and the spans of the nodes and all of their descendants.
+func shiftNodes(nodes []Node, s positionShift) {
+	for i := range nodes {
+		n := &nodes[i]
+		if p := n.position; p != nil {
+			shifted := &nodePosition{
+				hint:     s.position(p.hint),
+				name:     s.position(p.name),
+				nameEnd:  p.nameEnd + s.offset,
+				children: s.span(p.children),
+				end:      p.end + s.offset,
+			}
+			if p.entries != nil {
+				shifted.entries = make([]EntrySpan, len(p.entries))
+				for j, e := range p.entries {
+					e.Span, e.Key, e.Value = s.span(e.Span), s.span(e.Key), s.span(e.Value)
+					shifted.entries[j] = e
+				}
+			}
+			n.position = shifted
+		}
+		for j := range n.Args {
+			s.value(&n.Args[j])
+		}
+		for key, v := range n.Props {
+			s.value(&v)
+			n.Props[key] = v
+		}
+		shiftNodes(n.Children, s)
+	}
+}
@@ -0,0 +1,128 @@
+package kdl
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const reparseDocument = `// Settings
+title "Example" lang="en"
+
+server host="localhost" port=8080 {
+    route "/" handler="index"
+    route "/api" {
+        timeout 30; retries 3
+    }
+}
+/- disabled 1
+(t)typed 1.5 (u8)2 #true
+multiline """
+    text
+    """ after=#null
+last r=#"raw"# // the end
+`
+
+// layout describes everything about the nodes of a document that Equal does not compare.
+func layout(doc *Document) []string {
+	var out []string
+	_ = doc.Walk(func(path []*Node, n *Node) WalkAction {
+		spans, _ := n.Spans()
+		pos, _ := n.Position()
+		hint, _ := n.HintPosition()
+		out = append(out, fmt.Sprintf("%s %v %v %v %q %q %d", n.Name, spans, pos, hint, n.LeadingComments, n.TrailingComment, n.BlankLinesBefore))
+		for _, v := range n.Args {
+			pos, _ := v.Position()
+			hint, _ := v.HintPosition()
+			out = append(out, fmt.Sprintf("  arg %v %v", pos, hint))
+		}
+		for _, key := range n.PropKeys() {
+			v, _ := n.PropValue(key)
+			pos, _ := v.Position()
+			hint, _ := v.HintPosition()
+			out = append(out, fmt.Sprintf("  prop %s %v %v", key, pos, hint))
+		}
+		return WalkContinue
+	})
+	return append(out, fmt.Sprintf("%q", doc.EndComments))
+}
+
+func TestReparse(t *testing.T) {
+	opts := []Option{WithPositions(true), WithVersion(Version2)}
+	doc, err := ParseString(reparseDocument, opts...)
+	assert.NoError(t, err)
+	first := doc.Nodes[0].position
+
+	// A new property on the last node
+	offset := len(reparseDocument) - len(" // the end\n")
+	text := reparseDocument[:offset] + " s=1" + reparseDocument[offset:]
+	assert.NoError(t, doc.Reparse(text, TextEdit{Offset: offset, Inserted: " s=1"}))
+	want, err := ParseString(text, opts...)
+	assert.NoError(t, err)
+	assert.True(t, want.Equal(&doc))
+	assert.Equal(t, layout(&want), layout(&doc))
+	// The nodes far from the edit have not been parsed again
+	assert.Same(t, first, doc.Nodes[0].position)
+
+	// An edit that cannot be parsed leaves the document as it is
+	broken := text + "{"
+	err = doc.Reparse(broken, TextEdit{Offset: len(text), Inserted: "{"})
+	_, wantErr := ParseString(broken, opts...)
+	assert.EqualError(t, err, wantErr.Error())
+	assert.True(t, want.Equal(&doc))
+
+	// A document without positions cannot be parsed again
+	doc, err = ParseString(reparseDocument, WithVersion(Version2))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, doc.Reparse(reparseDocument, TextEdit{}), ErrNoPositions)
+}
+
+// TestReparseAgreesWithParse applies random edits to a document,
+// checking that parsing it again incrementally gives the same result as parsing it from scratch.
+func TestReparseAgreesWithParse(t *testing.T) {
+	snippets := []string{
+		"\n", " ", "a", "1", `"`, "{", "}", ";", "/-", "/*", "*/", "//", "\\", "k=2", "node 2\n",
+		"(t)", "#true", `"s"`, "\r\n", `"""`, "é",
+	}
+	optionSets := [][]Option{
+		{WithPositions(true), WithVersion(Version2)},
+		{WithPositions(true), WithVersion(Version2), WithComments(true), WithBlankLines(true)},
+	}
+	for i, opts := range optionSets {
+		rng := rand.New(rand.NewSource(int64(i + 1)))
+		text := reparseDocument
+		doc, err := ParseString(text, opts...)
+		assert.NoError(t, err)
+
+		for step := 0; step < 2000; step++ {
+			edit := TextEdit{Offset: rng.Intn(len(text) + 1)}
+			if rng.Intn(3) > 0 {
+				edit.Inserted = snippets[rng.Intn(len(snippets))]
+			}
+			if rng.Intn(2) == 0 {
+				edit.Deleted = rng.Intn(6)
+			}
+			if edit.Offset+edit.Deleted > len(text) {
+				edit.Deleted = len(text) - edit.Offset
+			}
+			edited := text[:edit.Offset] + edit.Inserted + text[edit.Offset+edit.Deleted:]
+
+			want, wantErr := ParseString(edited, opts...)
+			err := doc.Reparse(edited, edit)
+			if wantErr != nil {
+				if !assert.EqualError(t, err, wantErr.Error(), "%q", edited) {
+					return
+				}
+				continue
+			}
+			if !assert.NoError(t, err, "%q", edited) ||
+				!assert.True(t, want.Equal(&doc), "%q\n%q\n%s\n%s", text, edited, want.String(), doc.String()) ||
+				!assert.Equal(t, layout(&want), layout(&doc), "%q", edited) {
+				return
+			}
+			text = edited
+		}
+	}
+}
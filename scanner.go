@@ -0,0 +1,307 @@
+package kdl
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+)
+
+// TokenKind tells what kind of lexical element a Token is.
+type TokenKind int
+
+const (
+	// TokenInvalid is a piece of text that is not valid KDL, e.g. an unterminated string.
+	TokenInvalid TokenKind = iota
+	TokenIdent
+	TokenString
+	TokenRawString
+	TokenNumber
+	TokenKeyword
+	TokenEquals
+	TokenBraceOpen
+	TokenBraceClose
+	TokenSemicolon
+	TokenNewline
+	TokenComment
+	TokenSlashdash
+	TokenTypeHintOpen
+	TokenTypeHintClose
+	TokenLineContinuation
+)
+
+var tokenKindNames = [...]string{
+	TokenInvalid:          "Invalid",
+	TokenIdent:            "Ident",
+	TokenString:           "String",
+	TokenRawString:        "RawString",
+	TokenNumber:           "Number",
+	TokenKeyword:          "Keyword",
+	TokenEquals:           "Equals",
+	TokenBraceOpen:        "BraceOpen",
+	TokenBraceClose:       "BraceClose",
+	TokenSemicolon:        "Semicolon",
+	TokenNewline:          "Newline",
+	TokenComment:          "Comment",
+	TokenSlashdash:        "Slashdash",
+	TokenTypeHintOpen:     "TypeHintOpen",
+	TokenTypeHintClose:    "TypeHintClose",
+	TokenLineContinuation: "LineContinuation",
+}
+
+// String returns the name of the kind, e.g. "Ident".
+func (k TokenKind) String() string {
+	if k >= 0 && int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return "TokenKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Token is a single lexical element of a document.
+type Token struct {
+	Kind   TokenKind
+	Text   string // The exact source text of the token.
+	Offset int    // Byte offset of the token from the start of the document, 0-indexed.
+	Line   int    // Line of the token, 1-indexed.
+	Column int    // Column of the token in runes, 1-indexed.
+}
+
+// Scanner splits a document into tokens, e.g. for syntax highlighting.
+// Whitespace between the tokens is skipped.
+//
+// The scanner does not stop at syntax errors:
+// the offending text is returned as a TokenInvalid and scanning continues after it.
+//
+// A Scanner is not safe for concurrent use.
+type Scanner struct {
+	r   reader
+	src *sourceRecorder
+	tok Token
+	err error
+}
+
+// NewScanner creates a new Scanner reading from r.
+func NewScanner(r io.Reader) *Scanner {
+	src := &sourceRecorder{inner: r}
+	s := &Scanner{r: wrapReader(bufio.NewReader(src)), src: src}
+	// The text of the tokens is taken from the source as is
+	s.r.rawInput = true
+	return s
+}
+
+// Scan advances the scanner to the next token, which will then be available through Token.
+// It returns false at the end of the document or if reading the document has failed.
+func (s *Scanner) Scan() bool {
+
+	if s.err != nil {
+		return false
+	}
+
+	s.r.skipByteOrderMark()
+	for {
+		ch, err := s.r.peekRune()
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			s.tok = Token{}
+			return false
+		}
+
+		if !isWhitespace(ch) {
+			break
+		}
+		s.r.discardBytes(utf8.RuneLen(ch))
+	}
+
+	start := s.r.pos()
+	kind := s.scanToken()
+	if s.r.offset == start.offset {
+		// Make sure the scanner always moves forward
+		s.r.discardRunes(1)
+		kind = TokenInvalid
+	}
+
+	text := s.src.take(start.offset, s.r.offset)
+	if kind == TokenIdent {
+		kind = classifyWord(text)
+	}
+
+	s.tok = Token{Kind: kind, Text: text, Offset: start.offset, Line: start.line, Column: start.column}
+	return true
+}
+
+// Token returns the token found by the most recent call to Scan.
+func (s *Scanner) Token() Token {
+	return s.tok
+}
+
+// Err returns the error that stopped the scanner, if it was not the end of the document.
+// Syntax errors are not reported here.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// scanToken consumes a single token, assuming the reader is positioned at its start.
+//
+// Bare words are reported as TokenIdent, to be classified by the caller.
+func (s *Scanner) scanToken() TokenKind {
+
+	r := &s.r
+	ch, err := r.peekRune()
+	if err != nil {
+		return TokenInvalid
+	}
+
+	switch ch {
+	case '{':
+		r.discardByte()
+		return TokenBraceOpen
+	case '}':
+		r.discardByte()
+		return TokenBraceClose
+	case ';':
+		r.discardByte()
+		return TokenSemicolon
+	case '=':
+		r.discardByte()
+		return TokenEquals
+	case '(':
+		r.discardByte()
+		return TokenTypeHintOpen
+	case ')':
+		r.discardByte()
+		return TokenTypeHintClose
+	case '\\':
+		r.discardByte()
+		return TokenLineContinuation
+	case '/':
+		return s.scanSlash()
+	case '"':
+		_, err := readQuotedString(r)
+		if err == errUnexpectedEOFInsideString {
+			s.skipToEnd()
+		}
+		if err != nil {
+			return TokenInvalid
+		}
+		return TokenString
+	case 'r', '#':
+		if next, _ := r.peekBytes(2); len(next) == 2 && (next[1] == '"' || next[1] == '#') {
+			_, err := readRawStringOf(r, 0)
+			if err == nil {
+				return TokenRawString
+			} else if err == errUnexpectedEOFInsideString {
+				s.skipToEnd()
+				return TokenInvalid
+			}
+		}
+	}
+
+	if isNewLine(ch) {
+		if err := skipUntilNewLine(r, true); err != nil {
+			return TokenInvalid
+		}
+		return TokenNewline
+	}
+
+	s.scanWord()
+	return TokenIdent
+}
+
+// scanSlash consumes a comment, a slashdash or a stray '/'.
+func (s *Scanner) scanSlash() TokenKind {
+
+	r := &s.r
+	if slashdash, _ := r.isNext(charsSlashDash[:]); slashdash {
+		r.discardBytes(2)
+		return TokenSlashdash
+	}
+
+	if comment, _ := r.isNext(charsStartCommentBlock[:]); comment {
+		if err := skipBlockComment(r, nil); err != nil {
+			s.skipToEnd()
+			return TokenInvalid
+		}
+		return TokenComment
+	}
+
+	if comment, _ := r.isNext(charsStartComment[:]); comment {
+		// Unlike skipUntilNewLine, stop before a CRLF, so that it becomes a single Newline token
+		for {
+			ch, err := r.peekRune()
+			if err != nil || isNewLine(ch) {
+				return TokenComment
+			}
+			r.discardRunes(1)
+		}
+	}
+
+	r.discardByte()
+	return TokenInvalid
+}
+
+// scanWord consumes a run of runes that could form a bare identifier, a number or a keyword.
+func (s *Scanner) scanWord() {
+	for {
+		ch, err := s.r.peekRune()
+		if err != nil || isWhitespace(ch) || isNewLine(ch) || !isRuneAllowedInBareIdentifier(ch, s.r.cfg.version()) {
+			return
+		}
+		s.r.discardRunes(1)
+	}
+}
+
+// skipToEnd consumes the rest of the document.
+func (s *Scanner) skipToEnd() {
+	for {
+		if _, err := s.r.readRune(); err != nil {
+			return
+		}
+	}
+}
+
+// classifyWord tells what a bare word is, using the same rules as the parser.
+func classifyWord(word string) TokenKind {
+
+	if isKeyword(word) || slices.Contains(hashKeywords[:], word) {
+		return TokenKeyword
+	}
+
+	if startsWithDigit(word) {
+		r := wrapReader(newBytesReader([]byte(word)))
+		if _, err := readNumber(&r); err != nil {
+			return TokenInvalid
+		}
+		return TokenNumber
+	}
+
+	if isValidBareIdentifier(word, Version1) || isValidBareIdentifier(word, Version2) {
+		return TokenIdent
+	}
+
+	return TokenInvalid
+}
+
+// sourceRecorder keeps the text read from a reader, until it is taken by the scanner.
+type sourceRecorder struct {
+	inner io.Reader
+	data  []byte
+	base  int // Offset of the first byte of data from the start of the document.
+}
+
+func (s *sourceRecorder) Read(p []byte) (int, error) {
+	n, err := s.inner.Read(p)
+	s.data = append(s.data, p[:n]...)
+	return n, err
+}
+
+// take returns the text between two offsets and forgets everything before the end one.
+func (s *sourceRecorder) take(start, end int) string {
+	text := string(s.data[start-s.base : end-s.base])
+	s.data = append(s.data[:0], s.data[end-s.base:]...)
+	s.base = end
+	return text
+}
@@ -0,0 +1,77 @@
+package kdl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scanAll(t *testing.T, doc string) []Token {
+	s := NewScanner(strings.NewReader(doc))
+	tokens := make([]Token, 0)
+	for s.Scan() {
+		tokens = append(tokens, s.Token())
+	}
+	assert.NoError(t, s.Err())
+	return tokens
+}
+
+func kindsOf(tokens []Token) []TokenKind {
+	kinds := make([]TokenKind, len(tokens))
+	for i, tok := range tokens {
+		kinds[i] = tok.Kind
+	}
+	return kinds
+}
+
+func TestScannerEmitsTokens(t *testing.T) {
+	doc := "(u8)node 1 \"two\" r#\"three\"# key=true /* block */ {\r\n\t/-child; // comment\r\n}\n"
+	tokens := scanAll(t, doc)
+
+	assert.Equal(t, []TokenKind{
+		TokenTypeHintOpen, TokenIdent, TokenTypeHintClose, TokenIdent,
+		TokenNumber, TokenString, TokenRawString,
+		TokenIdent, TokenEquals, TokenKeyword,
+		TokenComment, TokenBraceOpen, TokenNewline,
+		TokenSlashdash, TokenIdent, TokenSemicolon, TokenComment, TokenNewline,
+		TokenBraceClose, TokenNewline,
+	}, kindsOf(tokens))
+
+	// The tokens cover the whole document, except for whitespace
+	var b strings.Builder
+	for _, tok := range tokens {
+		assert.Equal(t, tok.Text, doc[tok.Offset:tok.Offset+len(tok.Text)])
+		b.WriteString(tok.Text)
+	}
+	assert.Equal(t, "(u8)node1\"two\"r#\"three\"#key=true/* block */{\r\n/-child;// comment\r\n}\n", b.String())
+
+	assert.Equal(t, Token{Kind: TokenSlashdash, Text: "/-", Offset: 53, Line: 2, Column: 2}, tokens[13])
+}
+
+func TestScannerContinuesAfterInvalidTokens(t *testing.T) {
+	tokens := scanAll(t, "node 1x [ -\\\n\"bad\\q\" ok")
+
+	assert.Equal(t, []TokenKind{
+		TokenIdent, TokenInvalid, TokenInvalid, TokenIdent, TokenLineContinuation, TokenNewline,
+		TokenInvalid, TokenIdent,
+	}, kindsOf(tokens))
+	assert.Equal(t, "ok", tokens[7].Text)
+}
+
+func TestScannerReportsUnterminatedTokens(t *testing.T) {
+	tokens := scanAll(t, "node \"open\n}")
+	assert.Equal(t, []TokenKind{TokenIdent, TokenInvalid}, kindsOf(tokens))
+	assert.Equal(t, "\"open\n}", tokens[1].Text)
+
+	tokens = scanAll(t, "node /* open\n}")
+	assert.Equal(t, []TokenKind{TokenIdent, TokenInvalid}, kindsOf(tokens))
+	assert.Equal(t, "/* open\n}", tokens[1].Text)
+}
+
+func TestScannerSkipsByteOrderMark(t *testing.T) {
+	tokens := scanAll(t, "\uFEFFnode")
+	if assert.Len(t, tokens, 1) {
+		assert.Equal(t, Token{Kind: TokenIdent, Text: "node", Offset: 3, Line: 1, Column: 1}, tokens[0])
+	}
+}
@@ -0,0 +1,72 @@
+package kdl
+
+// Span is a half-open range of bytes of a document, from Start up to but not including End.
+// Like a Position, it refers to the text after it has been decoded.
+type Span struct {
+	Start, End int
+}
+
+// Len returns the count of bytes in the span.
+func (s Span) Len() int {
+	return s.End - s.Start
+}
+
+// Contains reports whether the other span is within this one.
+func (s Span) Contains(other Span) bool {
+	return s.Start <= other.Start && other.End <= s.End
+}
+
+// EntrySpan is the span of an argument or a property of a node.
+type EntrySpan struct {
+	Span             // The whole argument or property.
+	Key   Span       // The key of a property. Empty for an argument.
+	Value Span       // The value, along with its type hint.
+	Arg   int        // Index of an argument, -1 for a property.
+	Prop  Identifier // Key of a property.
+}
+
+// NodeSpans are the spans of the parts of a node, see Node.Spans.
+type NodeSpans struct {
+	// Node spans from the type hint or the name of the node to the end of its children block,
+	// or else to the end of its last argument or property.
+	// Neither the terminator of the node, i.e. its ';' or line break, nor the whitespace
+	// and the comments before it are included.
+	Node Span
+
+	// Name is the name of the node, without its type hint, but with the quotes if it has any.
+	Name Span
+
+	// Children spans from the '{' to the '}' of the children block. Empty if the node has none.
+	Children Span
+
+	// Entries are the arguments and the properties of the node, in the order they have been written.
+	// A property set more than once has an entry each time.
+	Entries []EntrySpan
+}
+
+// Spans returns the spans of the parts of the node, if ParseOptions.KeepPositions or KeepFormat was enabled.
+// The spans of the entries and the children block are within the span of the node.
+//
+// Like the positions, the spans are kept by Clone. They refer to the document as it has been read,
+// so they do not follow the changes made to the node since.
+func (n *Node) Spans() (NodeSpans, bool) {
+	p := n.position
+	if p == nil {
+		return NodeSpans{}, false
+	}
+	start := p.name.Offset
+	if p.hint.IsValid() {
+		start = p.hint.Offset
+	}
+	return NodeSpans{
+		Node:     Span{start, p.end},
+		Name:     Span{p.name.Offset, p.nameEnd},
+		Children: p.children,
+		Entries:  append([]EntrySpan(nil), p.entries...),
+	}, true
+}
+
+func (p *nodePosition) addEntry(e EntrySpan) {
+	p.entries = append(p.entries, e)
+	p.end = e.End
+}
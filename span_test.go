@@ -0,0 +1,94 @@
+package kdl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const spanDocument = `// Spans
+(pkg)package "kdl" version="2.0" {
+    /- skipped 1
+    dep "a" (semver)"1.0"; dep "b" key=1 key=2
+    "quoted name" #"raw"# /* comment */ // trailing
+    nested { inner { deepest #true } } /-{ commented }
+}
+last 1 /-2
+`
+
+func TestSpans(t *testing.T) {
+	for _, opt := range []Option{WithPositions(true), WithFidelity(true)} {
+		doc, err := ParseString(spanDocument, opt, WithVersion(Version2))
+		assert.NoError(t, err)
+
+		err = doc.Walk(func(path []*Node, n *Node) WalkAction {
+			spans, ok := n.Spans()
+			if !assert.True(t, ok, n.Name) {
+				return WalkStop
+			}
+
+			// Each node can be read back from its span
+			text := spanDocument[spans.Node.Start:spans.Node.End]
+			reparsed, err := ParseString(text, WithVersion(Version2))
+			if assert.NoError(t, err, text) && assert.Len(t, reparsed.Nodes, 1, text) {
+				assert.True(t, reparsed.Nodes[0].Equal(n), text)
+			}
+
+			// The spans nest
+			assert.True(t, spans.Node.Contains(spans.Name), n.Name)
+			assert.True(t, spans.Children.Len() == 0 || spans.Node.Contains(spans.Children), n.Name)
+			for _, e := range spans.Entries {
+				assert.True(t, spans.Node.Contains(e.Span), n.Name)
+				assert.True(t, e.Key.Len() == 0 || e.Contains(e.Key), n.Name)
+				assert.True(t, e.Contains(e.Value), n.Name)
+			}
+			if len(path) > 0 {
+				parent, _ := path[len(path)-1].Spans()
+				assert.True(t, parent.Children.Contains(spans.Node), n.Name)
+			}
+			return WalkContinue
+		})
+		assert.NoError(t, err)
+	}
+}
+
+func TestSpanParts(t *testing.T) {
+	doc, err := ParseString(spanDocument, WithPositions(true), WithVersion(Version2))
+	assert.NoError(t, err)
+	slice := func(s Span) string { return spanDocument[s.Start:s.End] }
+
+	pkg, _ := doc.Nodes[0].Spans()
+	assert.Equal(t, "package", slice(pkg.Name))
+	assert.Equal(t, `(pkg)package "kdl" version="2.0" {`, slice(pkg.Node)[:34])
+	assert.Equal(t, '{', rune(slice(pkg.Children)[0]))
+	assert.Equal(t, '}', rune(spanDocument[pkg.Children.End-1]))
+	assert.Equal(t, []EntrySpan{
+		{Span: pkg.Entries[0].Span, Value: pkg.Entries[0].Value, Arg: 0},
+		{Span: pkg.Entries[1].Span, Key: pkg.Entries[1].Key, Value: pkg.Entries[1].Value, Arg: -1, Prop: "version"},
+	}, pkg.Entries)
+	assert.Equal(t, `"kdl"`, slice(pkg.Entries[0].Span))
+	assert.Equal(t, `version="2.0"`, slice(pkg.Entries[1].Span))
+	assert.Equal(t, `version`, slice(pkg.Entries[1].Key))
+	assert.Equal(t, `"2.0"`, slice(pkg.Entries[1].Value))
+
+	// The terminators are not included
+	dep, _ := doc.Nodes[0].Children[0].Spans()
+	assert.Equal(t, `dep "a" (semver)"1.0"`, slice(dep.Node))
+	assert.Equal(t, `(semver)"1.0"`, slice(dep.Entries[1].Value))
+	assert.Equal(t, 0, dep.Children.Len())
+	dep, _ = doc.Nodes[0].Children[1].Spans()
+	assert.Equal(t, `dep "b" key=1 key=2`, slice(dep.Node))
+	assert.Len(t, dep.Entries, 3)
+	quoted, _ := doc.Nodes[0].Children[2].Spans()
+	assert.Equal(t, `"quoted name" #"raw"#`, slice(quoted.Node))
+	assert.Equal(t, `"quoted name"`, slice(quoted.Name))
+	nested, _ := doc.Nodes[0].Children[3].Spans()
+	assert.Equal(t, `nested { inner { deepest #true } }`, slice(nested.Node))
+	last, _ := doc.Nodes[1].Spans()
+	assert.Equal(t, `last 1`, slice(last.Node))
+
+	// New nodes have no spans
+	n := NewNode("a")
+	_, ok := n.Spans()
+	assert.False(t, ok)
+}
@@ -0,0 +1,194 @@
+package kdl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+// ValueSplice is a value to replace in the text of a Document, see Document.SpliceValues.
+type ValueSplice struct {
+	Node  *Node      // A node of the document, e.g. one found with Select.
+	Arg   int        // Index of the argument to replace, -1 for a property.
+	Prop  Identifier // Key of the property to replace.
+	Value Value      // The new value, written along with its type hint in place of the old one and its hint.
+}
+
+// SpliceValue replaces an argument of a node in the text the Document has been parsed from, see SpliceValues.
+// If arg is negative, ErrArgIndex is returned.
+func (d *Document) SpliceValue(source []byte, node *Node, arg int, v Value) ([]byte, error) {
+	if arg < 0 && node != nil {
+		return nil, argIndexError(node, arg)
+	}
+	return d.SpliceValues(source, ValueSplice{Node: node, Arg: arg, Value: v})
+}
+
+// SpliceProp replaces a property of a node in the text the Document has been parsed from, see SpliceValues.
+func (d *Document) SpliceProp(source []byte, node *Node, key Identifier, v Value) ([]byte, error) {
+	return d.SpliceValues(source, ValueSplice{Node: node, Arg: -1, Prop: key, Value: v})
+}
+
+// SpliceValues returns the text the Document has been parsed from, with only the provided values replaced,
+// so that everything else in it is kept as it is, without ParseOptions.KeepFormat.
+// The source must be the text the Document has been parsed from, after decoding it, if it has been transcoded.
+// The Document itself is left as it is; the text can be parsed to get the new one.
+//
+// The Document must have been parsed with ParseOptions.KeepPositions, or else ErrNoPositions is returned.
+// ErrDocumentModified is returned if its nodes, or their arguments and properties, have been added,
+// removed or moved since, as their positions would not match the source any more,
+// or if the source is not the text they have been read from.
+// Values replaced with new ones, e.g. made with NewStringValue, count as modifications too,
+// while changes made to the values that have been read, e.g. to their RawValue, are not seen, nor written.
+// ErrMissingValue is returned if a node has no such argument or property, or is not in the Document.
+//
+// If the same value is replaced more than once, the last of the replacements is used.
+func (d *Document) SpliceValues(source []byte, splices ...ValueSplice) ([]byte, error) {
+	if d.parsed == nil {
+		return nil, ErrNoPositions
+	}
+	check := positionCheck{source: source, cfg: parseConfig{ParseOptions: ParseOptions{Version: d.Version}}}
+	if err := check.nodes(d.Nodes, 0, len(source)); err != nil {
+		return nil, err
+	}
+
+	// The values are replaced back to front, so that the spans of the ones before stay valid
+	spans := make([]Span, len(splices))
+	for i, s := range splices {
+		span, err := d.spliceSpan(s)
+		if err != nil {
+			return nil, err
+		}
+		spans[i] = span
+	}
+	order := make([]int, len(splices))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return spans[b].Start - spans[a].Start })
+
+	out := slices.Clone(source)
+	var buf bytes.Buffer
+	w := writer{writer: bufio.NewWriter(&buf), version: d.Version}
+	for i, j := range order {
+		if i+1 < len(order) && spans[order[i+1]] == spans[j] {
+			// Replaced again by a later splice
+			continue
+		}
+		buf.Reset()
+		v := splices[j].Value
+		if err := writeValue(&w, &v); err != nil {
+			return nil, err
+		}
+		if err := w.writer.Flush(); err != nil {
+			return nil, err
+		}
+		out = slices.Replace(out, spans[j].Start, spans[j].End, buf.Bytes()...)
+	}
+	return out, nil
+}
+
+// spliceSpan returns the span of the value to replace, along with its type hint.
+func (d *Document) spliceSpan(s ValueSplice) (Span, error) {
+	if s.Node == nil || !containsNode(d.Nodes, s.Node) {
+		return Span{}, fmt.Errorf("kdl: node is not in the document: %w", ErrMissingValue)
+	}
+	entries := s.Node.position.entries
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if s.Arg >= 0 && e.Arg == s.Arg || s.Arg < 0 && e.Arg < 0 && e.Prop == s.Prop {
+			return e.Value, nil
+		}
+	}
+	if s.Arg >= 0 {
+		return Span{}, fmt.Errorf("kdl: node %q has no argument %d: %w", s.Node.Name, s.Arg, ErrMissingValue)
+	}
+	return Span{}, fmt.Errorf("kdl: node %q has no property %q: %w", s.Node.Name, s.Prop, ErrMissingValue)
+}
+
+// containsNode reports whether the node is one of the nodes or their descendants.
+func containsNode(nodes []Node, node *Node) bool {
+	for i := range nodes {
+		if &nodes[i] == node || containsNode(nodes[i].Children, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// positionCheck checks that the nodes of a document are where they have been read from in the source,
+// and that there is nothing else between them, e.g. the text of nodes removed since.
+type positionCheck struct {
+	source []byte
+	cfg    parseConfig
+}
+
+// nodes checks the nodes within the provided range of the source,
+// and their arguments, properties and children.
+func (c *positionCheck) nodes(nodes []Node, start, end int) error {
+	for i := range nodes {
+		n := &nodes[i]
+		p := n.position
+		if p == nil || p.start().Offset < start || p.end > end || !c.blank(start, p.start().Offset) {
+			return ErrDocumentModified
+		}
+
+		args := 0
+		props := make(map[Identifier]int, len(n.Props))
+		for _, e := range p.entries {
+			if e.Arg < 0 {
+				// The value of a property set more than once is the last one
+				props[e.Prop] = e.Value.Start
+				continue
+			}
+			args++
+			if e.Arg >= len(n.Args) || !readFrom(n.Args[e.Arg], e.Value.Start) {
+				return ErrDocumentModified
+			}
+		}
+		if args != len(n.Args) || len(props) != len(n.Props) {
+			return ErrDocumentModified
+		}
+		for key, offset := range props {
+			if v, ok := n.Props[key]; !ok || !readFrom(v, offset) {
+				return ErrDocumentModified
+			}
+		}
+
+		if p.children.Len() > 0 {
+			// Within the braces
+			if err := c.nodes(n.Children, p.children.Start+1, p.children.End-1); err != nil {
+				return err
+			}
+		} else if len(n.Children) > 0 {
+			return ErrDocumentModified
+		}
+		start = p.end
+	}
+	if !c.blank(start, end) {
+		return ErrDocumentModified
+	}
+	return nil
+}
+
+// blank reports whether the text between two nodes, or the end of a node and the end of its block,
+// has no nodes in it, only the terminator of the node before it, whitespace and comments.
+func (c *positionCheck) blank(start, end int) bool {
+	// The text is read as the rest of a node, so that it can start with a terminator
+	text := append([]byte("_ "), c.source[start:end]...)
+	doc, err := parse(context.Background(), newBytesReader(text), c.cfg)
+	return err == nil && len(doc.Nodes) == 1
+}
+
+// readFrom reports whether the value, along with its type hint, has been read from the offset.
+func readFrom(v Value, offset int) bool {
+	if v.position == nil {
+		return false
+	}
+	if v.position.hint.IsValid() {
+		return v.position.hint.Offset == offset
+	}
+	return v.position.value.Offset == offset
+}
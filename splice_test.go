@@ -0,0 +1,106 @@
+package kdl
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpliceValues(t *testing.T) {
+	const src = `// Config
+server  "old"   port=(u16)8080 { // main
+    route "/" timeout=30
+}
+`
+	source := []byte(src)
+	doc, err := ParseBytes(source, WithPositions(true), WithVersion(Version2))
+	assert.NoError(t, err)
+	server := &doc.Nodes[0]
+	route := &server.Children[0]
+
+	// A string with a longer one
+	out, err := doc.SpliceValue(source, server, 0, NewStringValue("a longer name", NoHint()))
+	assert.NoError(t, err)
+	assert.Equal(t, `// Config
+server  "a longer name"   port=(u16)8080 { // main
+    route "/" timeout=30
+}
+`, string(out))
+
+	// A number with a null, along with its type hint
+	out, err = doc.SpliceProp(source, server, "port", NewNullValue(NoHint()))
+	assert.NoError(t, err)
+	assert.Equal(t, `// Config
+server  "old"   port=#null { // main
+    route "/" timeout=30
+}
+`, string(out))
+
+	// Two of them at once, in any order
+	out, err = doc.SpliceValues(source,
+		ValueSplice{Node: server, Arg: -1, Prop: "port", Value: NewIntegerValue(big.NewInt(443), NoHint())},
+		ValueSplice{Node: route, Arg: -1, Prop: "timeout", Value: NewIntegerValue(big.NewInt(5), NoHint())},
+		ValueSplice{Node: route, Arg: 0, Value: NewStringValue("/api", NoHint())},
+		ValueSplice{Node: server, Arg: -1, Prop: "port", Value: NewIntegerValue(big.NewInt(8443), Hint("u16"))},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, `// Config
+server  "old"   port=(u16)8443 { // main
+    route "/api" timeout=5
+}
+`, string(out))
+
+	// The document is left as it is
+	assert.Equal(t, src, string(source))
+	assert.Equal(t, "old", server.Args[0].StringValue())
+}
+
+func TestSpliceValuesRefuses(t *testing.T) {
+	const src = "a 1 k=2 {\n    b 3\n}\nc\n"
+	source := []byte(src)
+	parse := func() Document {
+		doc, err := ParseString(src, WithPositions(true))
+		assert.NoError(t, err)
+		return doc
+	}
+	one := NewIntegerValue(big.NewInt(1), NoHint())
+
+	doc, err := ParseString(src)
+	assert.NoError(t, err)
+	_, err = doc.SpliceValue(source, &doc.Nodes[0], 0, one)
+	assert.ErrorIs(t, err, ErrNoPositions)
+
+	doc = parse()
+	_, err = doc.SpliceValue(source, &doc.Nodes[0], 1, one)
+	assert.ErrorIs(t, err, ErrMissingValue)
+	_, err = doc.SpliceProp(source, &doc.Nodes[1], "k", one)
+	assert.ErrorIs(t, err, ErrMissingValue)
+	other := parse()
+	_, err = doc.SpliceValue(source, &other.Nodes[0], 0, one)
+	assert.ErrorIs(t, err, ErrMissingValue)
+	_, err = doc.SpliceValue(source, &doc.Nodes[0], -1, one)
+	assert.ErrorIs(t, err, ErrArgIndex)
+
+	modifications := map[string]func(d *Document){
+		"added arg":     func(d *Document) { d.Nodes[0].AddArgValue(one) },
+		"replaced arg":  func(d *Document) { d.Nodes[0].Args[0] = one },
+		"replaced prop": func(d *Document) { d.Nodes[0].SetPropValue("k", one) },
+		"removed prop":  func(d *Document) { d.Nodes[0].RemoveProp("k") },
+		"added child":   func(d *Document) { d.Nodes[1].AddChild(NewNode("d")) },
+		"removed child": func(d *Document) { d.Nodes[0].RemoveChild(0) },
+		"moved nodes":   func(d *Document) { d.Nodes[0], d.Nodes[1] = d.Nodes[1], d.Nodes[0] },
+		"new node":      func(d *Document) { d.InsertChild(1, NewNode("new")) },
+	}
+	for name, modify := range modifications {
+		doc := parse()
+		modify(&doc)
+		_, err := doc.SpliceValue(source, &doc.Nodes[len(doc.Nodes)-1], 0, one)
+		assert.ErrorIs(t, err, ErrDocumentModified, name)
+	}
+
+	// Nor can the source be another text
+	doc = parse()
+	_, err = doc.SpliceValue(source[:10], &doc.Nodes[0], 0, one)
+	assert.ErrorIs(t, err, ErrDocumentModified)
+}
@@ -0,0 +1,41 @@
+package kdl
+
+// Stats summarizes the size and the shape of a Document.
+type Stats struct {
+	Nodes         int             // The number of nodes, at any depth.
+	MaxDepth      int             // The number of levels of nodes: 1 for top-level nodes only, 0 for an empty document.
+	Args          int             // The number of arguments of all the nodes.
+	Props         int             // The number of properties of all the nodes.
+	Values        map[TypeTag]int // The number of arguments and properties of each type.
+	LongestString int             // The length in bytes of the longest string argument or property.
+}
+
+// Stats counts the nodes and the values of this Document, in a single pass over it.
+func (d *Document) Stats() Stats {
+	s := Stats{Values: make(map[TypeTag]int)}
+	_ = d.Walk(func(path []*Node, n *Node) WalkAction {
+		s.Nodes++
+		if len(path) >= s.MaxDepth {
+			s.MaxDepth = len(path) + 1
+		}
+		for i := range n.Args {
+			s.countValue(n.Args[i])
+		}
+		for _, v := range n.Props {
+			if v.Type != TypeInvalid {
+				s.Props++
+				s.countValue(v)
+			}
+		}
+		s.Args += len(n.Args)
+		return WalkContinue
+	})
+	return s
+}
+
+func (s *Stats) countValue(v Value) {
+	s.Values[v.Type]++
+	if v.Type == TypeString && len(v.StringValue()) > s.LongestString {
+		s.LongestString = len(v.StringValue())
+	}
+}
@@ -0,0 +1,92 @@
+package kdl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleDocument_Stats() {
+	doc, _ := ParseString(`server "public" port=443 {
+	route "/" handler="index"
+	route "/api" {
+		limit 100 burst=#null
+	}
+}
+debug #false
+`, WithVersion(Version2))
+
+	stats := doc.Stats()
+	fmt.Println("nodes:", stats.Nodes, "depth:", stats.MaxDepth)
+	fmt.Println("arguments:", stats.Args, "properties:", stats.Props)
+	fmt.Println("strings:", stats.Values[TypeString], "longest:", stats.LongestString)
+	// Output:
+	// nodes: 5 depth: 3
+	// arguments: 5 properties: 3
+	// strings: 4 longest: 6
+}
+
+func TestDocumentStats(t *testing.T) {
+	doc, err := ParseString(`a 1 2.5 "hello" #true #null {
+	b x="héllo!" {
+		c {
+			d
+		}
+	}
+	b
+}
+e y=1 z=2
+`, WithVersion(Version2))
+	assert.NoError(t, err)
+
+	assert.Equal(t, Stats{
+		Nodes:    6,
+		MaxDepth: 4,
+		Args:     5,
+		Props:    3,
+		Values: map[TypeTag]int{
+			TypeInteger: 3,
+			TypeFloat:   1,
+			TypeString:  2,
+			TypeBool:    1,
+			TypeNull:    1,
+		},
+		LongestString: 7,
+	}, doc.Stats())
+
+	empty := NewDocument()
+	assert.Equal(t, Stats{Values: map[TypeTag]int{}}, empty.Stats())
+
+	// Removed properties are not counted
+	doc.Nodes[1].Props["y"] = Value{}
+	assert.Equal(t, 2, doc.Stats().Props)
+}
+
+// statsDocument builds a document of many nodes with a child each.
+func statsDocument(count int) *Document {
+	doc := NewDocument()
+	for i := 0; i < count; i++ {
+		n := NewNode("node")
+		n.AddArgValue(NewStringValue("value", NoHint()))
+		n.AddChild(NewNode("child"))
+		doc.AddChild(n)
+	}
+	return &doc
+}
+
+func TestDocumentStatsAllocations(t *testing.T) {
+	small, large := statsDocument(10), statsDocument(1000)
+	assert.Equal(t,
+		testing.AllocsPerRun(10, func() { _ = small.Stats() }),
+		testing.AllocsPerRun(10, func() { _ = large.Stats() }))
+}
+
+func BenchmarkDocumentStats(b *testing.B) {
+	doc := statsDocument(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = doc.Stats()
+	}
+}
@@ -1,7 +1,7 @@
 package kdl
 
 import (
-	"regexp"
+	"fmt"
 	"unicode"
 	"unicode/utf8"
 
@@ -20,11 +20,21 @@ func isKeyword(s string) bool {
 // node, argument or property.
 var charsSlashDash = [...]byte{'/', '-'}
 
+// charsByteOrderMark is U+FEFF encoded as UTF-8, which some editors put at the start of a file.
+var charsByteOrderMark = [...]byte{0xef, 0xbb, 0xbf}
+
+// charsByteOrderMarkUTF16LE and charsByteOrderMarkUTF16BE are U+FEFF encoded as UTF-16.
+var charsByteOrderMarkUTF16LE = [...]byte{0xff, 0xfe}
+var charsByteOrderMarkUTF16BE = [...]byte{0xfe, 0xff}
+
 var charsStartComment = [...]byte{'/', '/'}
 var charsStartCommentBlock = [...]byte{'/', '*'}
 var charsEndCommentBlock = [...]byte{'*', '/'}
 var charsCRLF = [...]byte{'\r', '\n'}
 
+// charsMultiLineQuotes open and close a multi-line string in KDL 2.0.
+var charsMultiLineQuotes = [...]byte{'"', '"', '"'}
+
 // isNewLine checks if the rune is a line break character.
 //
 // Note: according to spec, CRLF is treated as a *singular* new line.
@@ -42,6 +52,33 @@ var charsWhitespaceBig = [...]rune{
 	0x3000,
 }
 
+// isDisallowedChar checks if the rune may not appear literally in a document,
+// as it is a control character or could be used to make the text look different than it is parsed.
+func isDisallowedChar(ch rune) bool {
+	if ch < 0x80 {
+		return ch < 0x09 || (ch > 0x0d && ch < 0x20) || ch == 0x7f
+	}
+	if ch < 0x200e {
+		return false
+	}
+	return ch <= 0x200f || (ch >= 0x202a && ch <= 0x202e) || (ch >= 0x2066 && ch <= 0x2069) ||
+		(ch >= 0xd800 && ch <= 0xdfff) || ch == 0xfeff
+}
+
+// validateName checks if a name of a node can be written to a document.
+// The writer escapes some of the disallowed characters, the rest must not be in the name.
+func validateName(name Identifier) error {
+	if !utf8.ValidString(string(name)) {
+		return fmt.Errorf("%w: %q is not valid UTF-8", ErrInvalidName, name)
+	}
+	for _, ch := range name {
+		if isDisallowedChar(ch) && ch != '\b' && ch != '\f' {
+			return fmt.Errorf("%w: %q has the disallowed character %U", ErrInvalidName, name, ch)
+		}
+	}
+	return nil
+}
+
 // isWhitespace checks if the rune is a whitespace character.
 func isWhitespace(ch rune) bool {
 	if ch < 0x80 {
@@ -57,6 +94,11 @@ func isWhitespace(ch rune) bool {
 	return found
 }
 
+// isWhitespaceOrNewLine checks if the rune is a whitespace character or a line break.
+func isWhitespaceOrNewLine(ch rune) bool {
+	return isWhitespace(ch) || isNewLine(ch)
+}
+
 // Identifier is a fancy name for a string
 // in place of a node's name, type hint or a property key.
 type Identifier string
@@ -91,36 +133,130 @@ func startsWithDigit(s string) bool {
 	return false
 }
 
-var patternBareIdentifier = regexp.MustCompile(`^([^\/(){}<>;[\]=,"0-9\-+\s\\]|[\-+][^\/(){}<>;[\]=,"0-9\s\\])[^\/(){}<>;[\]=,"\s\\]*$`)
+// keywordsV2 are the bare identifiers that KDL 2.0 reserves in addition to keywords.
+var keywordsV2 = [...]string{"inf", "-inf", "nan"}
 
+// isReservedIdentifier checks if the text cannot be a bare identifier in the version of the specification.
+func isReservedIdentifier(s string, version Version) bool {
+	return isKeyword(s) || (version >= Version2 && slices.Contains(keywordsV2[:], s))
+}
+
+// isAllowedBareIdentifier checks if the text can be written as a bare identifier,
+// so that it is read back the same in both versions of the specification.
 func isAllowedBareIdentifier(s string) bool {
-	return !isKeyword(s) && patternBareIdentifier.MatchString(s)
+	return isValidBareIdentifier(s, Version1) && isValidBareIdentifier(s, Version2)
+}
+
+// isValidBareIdentifier checks if the text is a bare identifier in the version of the specification.
+func isValidBareIdentifier(s string, version Version) bool {
+	if s == "" || isReservedIdentifier(s, version) || startsLikeNumber(s, version) {
+		return false
+	}
+	for _, ch := range s {
+		if !isRuneAllowedInBareIdentifier(ch, version) {
+			return false
+		}
+	}
+	return true
 }
 
+// startsLikeNumber checks if a bare identifier would be confused with a number,
+// i.e. if it starts with a digit, optionally preceded by a sign.
+// In KDL 2.0, a '.' before the digit is not allowed either.
+func startsLikeNumber(s string, version Version) bool {
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	if version >= Version2 && s != "" && s[0] == '.' {
+		s = s[1:]
+	}
+	return startsWithDigit(s)
+}
+
+// asciiAllowedInBareIdent lists the ASCII characters allowed in bare identifiers in KDL 1.0.
+// Besides whitespace, \/(){}<>;[]=," are not.
 var asciiAllowedInBareIdent = [128]byte{
 	// 1  2  3  4  5  6  7  8  9  A  B  C  D  E  F
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 0x00 - 0x0F
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 0x10 - 0x1F
-	1, 1, 0, 1, 1, 1, 1, 1, 0, 0, 1, 1, 0, 1, 1, 0, // 0x20 - 0x2F
+	0, 1, 0, 1, 1, 1, 1, 1, 0, 0, 1, 1, 0, 1, 1, 0, // 0x20 - 0x2F
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 1, // 0x30 - 0x3F
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 0x40 - 0x4F
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 1, 1, // 0x50 - 0x5F
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 0x60 - 0x6F
-	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 0, 1, 1, // 0x70 - 0x7F
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 0, 1, 0, // 0x70 - 0x7F
 }
 
-func isRuneAllowedInBareIdentifier(ch rune) bool {
+// asciiAllowedInBareIdentV2 lists the ASCII characters allowed in bare identifiers in KDL 2.0.
+// Besides whitespace, \/(){};[]="# are not.
+var asciiAllowedInBareIdentV2 = [128]byte{
+	// 1  2  3  4  5  6  7  8  9  A  B  C  D  E  F
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 0x00 - 0x0F
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 0x10 - 0x1F
+	0, 1, 0, 0, 1, 1, 1, 1, 0, 0, 1, 1, 1, 1, 1, 0, // 0x20 - 0x2F
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 0, 1, 1, // 0x30 - 0x3F
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 0x40 - 0x4F
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 1, 1, // 0x50 - 0x5F
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 0x60 - 0x6F
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 0, 1, 0, // 0x70 - 0x7F
+}
+
+// charClass is a set of flags classifying an ASCII character, see asciiClasses.
+type charClass uint8
+
+const (
+	classWhitespace  charClass = 1 << iota // See isWhitespace.
+	classNewLine                           // See isNewLine.
+	classBareIdent                         // Allowed in a bare identifier in KDL 1.0.
+	classBareIdentV2                       // Allowed in a bare identifier in KDL 2.0.
+)
+
+// asciiClasses classifies the ASCII characters, so that the hot loops of the parser
+// can skip decoding a rune and calling a chain of predicates for most of the document.
+var asciiClasses = func() (classes [utf8.RuneSelf]charClass) {
+	for ch := range classes {
+		if isWhitespace(rune(ch)) {
+			classes[ch] |= classWhitespace
+		}
+		if isNewLine(rune(ch)) {
+			classes[ch] |= classNewLine
+		}
+		if asciiAllowedInBareIdent[ch] > 0 {
+			classes[ch] |= classBareIdent
+		}
+		if asciiAllowedInBareIdentV2[ch] > 0 {
+			classes[ch] |= classBareIdentV2
+		}
+	}
+	return
+}()
+
+// bareIdentClass returns the class of the ASCII characters allowed in a bare identifier in the version.
+func bareIdentClass(version Version) charClass {
+	if version >= Version2 {
+		return classBareIdentV2
+	}
+	return classBareIdent
+}
+
+// isRuneAllowedInBareIdentifier checks if the rune can be a part of a bare identifier
+// in the version of the specification.
+func isRuneAllowedInBareIdentifier(ch rune, version Version) bool {
 	if ch < 0x80 {
+		if version >= Version2 {
+			return asciiAllowedInBareIdentV2[byte(ch)] > 0
+		}
 		return asciiAllowedInBareIdent[byte(ch)] > 0
 	}
-	return ch <= 0x10ffff
+	return ch <= unicode.MaxRune && !isWhitespace(ch) && !isNewLine(ch)
 }
 
 // isAllowedInitialCharacter checks if a bare identifier is allowed to start with this rune.
-func isAllowedInitialCharacter(ch rune) bool {
-	return isRuneAllowedInBareIdentifier(ch) && !unicode.IsDigit(ch)
+func isAllowedInitialCharacter(ch rune, version Version) bool {
+	return isRuneAllowedInBareIdentifier(ch, version) && !unicode.IsDigit(ch)
 }
 
+// isValidValueTerminator checks if a rune can end a value, e.g. the '}' in {a 1}.
 func isValidValueTerminator(ch rune) bool {
-	return ch == ';' || ch == '}' || isWhitespace(ch) || isNewLine(ch)
+	return ch == ';' || ch == '{' || ch == '}' || isWhitespace(ch) || isNewLine(ch)
 }
@@ -2,6 +2,7 @@ package kdl
 
 import (
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -21,3 +22,147 @@ func TestDisallowsInvalidBareIdentifiers(t *testing.T) {
 	assert.False(t, isAllowedBareIdentifier("true"))
 	assert.False(t, isAllowedBareIdentifier(""))
 }
+
+func TestAllowsBareIdentifiersPerVersion(t *testing.T) {
+	assert.True(t, isValidBareIdentifier("a,b", Version2))
+	assert.False(t, isValidBareIdentifier("a,b", Version1))
+	assert.True(t, isValidBareIdentifier("a#b", Version1))
+	assert.False(t, isValidBareIdentifier("a#b", Version2))
+	assert.True(t, isValidBareIdentifier(".5", Version1))
+	assert.False(t, isValidBareIdentifier(".5", Version2))
+	assert.True(t, isValidBareIdentifier("nan", Version1))
+	assert.False(t, isValidBareIdentifier("nan", Version2))
+
+	// Written as bare only if read back the same in both versions
+	assert.False(t, isAllowedBareIdentifier("a,b"))
+	assert.False(t, isAllowedBareIdentifier("a#b"))
+	assert.True(t, isAllowedBareIdentifier("-"))
+}
+
+// Identifier cases adapted from the kdl-org test suite, checked against both versions of the specification.
+func TestBareIdentifierSuiteCases(t *testing.T) {
+	cases := []struct {
+		input   string
+		validV1 bool
+		validV2 bool
+	}{
+		{`foo123~!@$%^&*.:'|?+ "weeee"`, true, true},
+		{`foo123~!@#$%^&*.:'|?+ "weeee"`, true, false},
+		{`foo123<bar>foo "weeee"`, false, true},
+		{`foo123,bar "weeee"`, false, true},
+		{`foo123{bar}foo "weeee"`, false, false},
+		{`foo123[bar]foo "weeee"`, false, false},
+		{`foo123(bar)foo "weeee"`, false, false},
+		{`foo123/bar "weeee"`, false, false},
+		{`foo123\bar "weeee"`, false, false},
+		{`foo123"bar "weeee"`, false, false},
+		{`foo123=bar "weeee"`, false, false},
+		{`node_name "arg"`, true, true},
+		{`😁 "happy!"`, true, true},
+		{`r "arg"`, true, true},
+		{`- "arg"`, true, true},
+		{`-- "arg"`, true, true},
+		{`+ "arg"`, true, true},
+		{`. "arg"`, true, true},
+		{`+. "arg"`, true, true},
+		{`0node "arg"`, false, false},
+		{`+0node "arg"`, false, false},
+		{`-0node "arg"`, false, false},
+		{`.0node "arg"`, true, false},
+		{`-.0node "arg"`, true, false},
+		{`true "arg"`, false, false},
+		{`null "arg"`, false, false},
+		{`inf "arg"`, true, false},
+		{`"true" "arg"`, true, true},
+	}
+	for _, c := range cases {
+		_, err := ParseString(c.input+"\n", WithVersion(Version1))
+		assert.Equal(t, c.validV1, err == nil, "%s in KDL 1.0: %v", c.input, err)
+		_, err = ParseString(c.input+"\n", WithVersion(Version2))
+		assert.Equal(t, c.validV2, err == nil, "%s in KDL 2.0: %v", c.input, err)
+	}
+}
+
+func TestReportsNumberLikeIdentifier(t *testing.T) {
+	_, err := ParseString("10abc\n")
+	assert.ErrorIs(t, err, errNumberLikeBareIdent)
+
+	_, err = ParseString("foo\"bar\" 1\n")
+	assert.ErrorIs(t, err, errInvalidCharInBareIdent)
+	var pe *ParseError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, 4, pe.Column)
+	}
+}
+
+func TestRecognizesWhitespace(t *testing.T) {
+	for _, ch := range "\t \u00a0\u1680\u2000\u2005\u200a\u202f\u205f\u3000" {
+		assert.True(t, isWhitespace(ch), "%U", ch)
+	}
+	for _, ch := range "\n\r\u200b\u2028\u3001a" {
+		assert.False(t, isWhitespace(ch), "%U", ch)
+	}
+}
+
+func TestASCIIClassesMatchPredicates(t *testing.T) {
+	for ch := rune(0); ch < utf8.RuneSelf; ch++ {
+		c := asciiClasses[ch]
+		assert.Equal(t, isWhitespace(ch), c&classWhitespace != 0, "%U", ch)
+		assert.Equal(t, isNewLine(ch), c&classNewLine != 0, "%U", ch)
+		assert.Equal(t, isRuneAllowedInBareIdentifier(ch, Version1), c&bareIdentClass(Version1) != 0, "%U", ch)
+		assert.Equal(t, isRuneAllowedInBareIdentifier(ch, Version2), c&bareIdentClass(Version2) != 0, "%U", ch)
+	}
+}
+
+func TestSignsAloneAreIdentifiers(t *testing.T) {
+	for _, version := range []Version{Version1, Version2} {
+		for _, name := range []string{"-", "+", "--flag", "+abc", "-_", "+-"} {
+			assert.True(t, isValidBareIdentifier(name, version), "%s in %v", name, version)
+
+			doc, err := ParseString(name+" arg=1\n", WithVersion(version))
+			if assert.NoError(t, err, name) {
+				assert.Equal(t, Identifier(name), doc.Nodes[0].Name)
+			}
+		}
+		for _, name := range []string{"+1", "-1", "+0x10", "-0b1", "+1e5", "+1abc"} {
+			assert.False(t, isValidBareIdentifier(name, version), "%s in %v", name, version)
+
+			_, err := ParseString(name+"\n", WithVersion(version))
+			assert.ErrorIs(t, err, errNumberLikeBareIdent, name)
+		}
+	}
+}
+
+func TestRejectsNumberLikeNames(t *testing.T) {
+	invalid := map[string][]Version{
+		"1stnode":          {Version1, Version2},
+		"+5":               {Version1, Version2},
+		"-3x":              {Version1, Version2},
+		".5name":           {Version2},
+		"node 1st=1":       {Version1, Version2},
+		"node -3x=1":       {Version1, Version2},
+		"node .5name=1":    {Version2},
+		"node a=1 2b=\"\"": {Version1, Version2},
+	}
+	for input, versions := range invalid {
+		for _, version := range versions {
+			_, err := ParseString(input+"\n", WithVersion(version))
+			assert.ErrorIs(t, err, errNumberLikeBareIdent, "%s in %v", input, version)
+			assert.ErrorContains(t, err, "quote it", input)
+		}
+	}
+
+	valid := []string{"+y", "\"1stnode\" arg=1", "node \"1st\"=1 \"-3x\"=2", "node +y=1", "node -x=1"}
+	for _, input := range valid {
+		for _, version := range []Version{Version1, Version2} {
+			_, err := ParseString(input+"\n", WithVersion(version))
+			assert.NoError(t, err, "%s in %v", input, version)
+		}
+	}
+
+	// KDL 1.0 only forbids a sign followed by a digit
+	doc, err := ParseString(".5name\n", WithVersion(Version1))
+	if assert.NoError(t, err) {
+		assert.Equal(t, Identifier(".5name"), doc.Nodes[0].Name)
+	}
+}
@@ -3,7 +3,18 @@ package kdl
 import (
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
+	"golang.org/x/text/width"
 )
 
 // var caserTitle cases.Caser = cases.Title(language.English)
 var caserLower cases.Caser = cases.Lower(language.English)
+
+// isWideRune checks if the rune takes up two columns in a monospace terminal.
+func isWideRune(ch rune) bool {
+	switch width.LookupRune(ch).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,5 +1,15 @@
 package kdl
 
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+)
+
 // remainingUTF8Bytes returns the count of the remaining continuation bytes,
 // given a start of a multi-byte sequence.
 // If b is US-ASCII, returns 0. If b is not valid, returns -1.
@@ -17,3 +27,104 @@ func remainingUTF8Bytes(b byte) int {
 	}
 	return -1
 }
+
+var errTruncatedUTF16 = withCode(CodeInvalidEncoding, fmt.Errorf("%w: UTF-16 input ends in the middle of a code unit", ErrInvalidEncoding))
+
+var errEncodingMismatch = withCode(CodeInvalidEncoding, fmt.Errorf("%w: byte order mark does not match the configured encoding", ErrInvalidEncoding))
+
+// decodesByteOrderMark checks if the encoding takes b, the start of a document, for a byte order mark.
+func decodesByteOrderMark(e encoding.Encoding, b []byte) bool {
+	for n := 2; n <= len(b); n++ {
+		decoded, err := e.NewDecoder().Bytes(b[:n])
+		if err == nil && (len(decoded) == 0 || string(decoded) == "\uFEFF") {
+			return true
+		}
+	}
+	return false
+}
+
+// utf16ByteOrder returns the byte order of UTF-16 text, if b starts with its byte order mark.
+func utf16ByteOrder(b []byte) binary.ByteOrder {
+	if bytes.HasPrefix(b, charsByteOrderMarkUTF16LE[:]) {
+		return binary.LittleEndian
+	} else if bytes.HasPrefix(b, charsByteOrderMarkUTF16BE[:]) {
+		return binary.BigEndian
+	}
+	return nil
+}
+
+// utf16Reader transcodes UTF-16 text to UTF-8.
+//
+// The byte order mark is transcoded too, to be skipped like the one of a UTF-8 document.
+// An unpaired surrogate becomes U+FFFD, like in utf16.Decode.
+type utf16Reader struct {
+	src     innerReader
+	order   binary.ByteOrder
+	pending []byte // Transcoded bytes that did not fit in the last read.
+	buf     [utf8.UTFMax]byte
+	err     error
+}
+
+func (u *utf16Reader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if len(u.pending) > 0 {
+			c := copy(p[n:], u.pending)
+			u.pending = u.pending[c:]
+			n += c
+			continue
+		}
+
+		// Do not wait for more input if something can be returned already
+		if u.err != nil || n > 0 && !u.buffered() {
+			break
+		}
+
+		ch, err := u.decodeRune()
+		if err != nil {
+			u.err = err
+			break
+		}
+
+		if n+utf8.RuneLen(ch) <= len(p) {
+			n += utf8.EncodeRune(p[n:], ch)
+		} else {
+			u.pending = utf8.AppendRune(u.buf[:0], ch)
+		}
+	}
+
+	if n > 0 {
+		return n, nil
+	}
+	return 0, u.err
+}
+
+// buffered checks if a whole code unit can be read without blocking.
+func (u *utf16Reader) buffered() bool {
+	b, ok := u.src.(interface{ Buffered() int })
+	return !ok || b.Buffered() >= 2
+}
+
+func (u *utf16Reader) decodeRune() (rune, error) {
+	b, err := u.src.Peek(2)
+	if len(b) < 2 {
+		if len(b) == 1 {
+			u.src.Discard(1)
+			return utf8.RuneError, errTruncatedUTF16
+		}
+		return utf8.RuneError, err
+	}
+
+	unit := rune(u.order.Uint16(b))
+	if utf16.IsSurrogate(unit) {
+		if b, _ := u.src.Peek(4); len(b) == 4 {
+			if ch := utf16.DecodeRune(unit, rune(u.order.Uint16(b[2:]))); ch != utf8.RuneError {
+				u.src.Discard(4)
+				return ch, nil
+			}
+		}
+		unit = utf8.RuneError
+	}
+
+	u.src.Discard(2)
+	return unit, nil
+}
@@ -1,9 +1,13 @@
 package kdl
 
 import (
+	"encoding/base64"
 	"errors"
+	"math"
 	"math/big"
 	"reflect"
+	"strconv"
+	"time"
 )
 
 // TypeTag discriminates between Value types.
@@ -21,11 +25,69 @@ const (
 
 var errInvalidTypeTag = errors.New("value has invalid type tag")
 
+// String returns the name of the type, e.g. "integer".
+func (t TypeTag) String() string {
+	switch t {
+	case TypeInvalid:
+		return "invalid"
+	case TypeNull:
+		return "null"
+	case TypeBool:
+		return "bool"
+	case TypeString:
+		return "string"
+	case TypeInteger:
+		return "integer"
+	case TypeFloat:
+		return "float"
+	}
+	return "TypeTag(" + strconv.Itoa(int(t)) + ")"
+}
+
 // Value can be used either as an argument or a property to a Node.
 type Value struct {
 	RawValue interface{}
 	TypeHint TypeHint
 	Type     TypeTag
+
+	source   *numberLiteral // The text a number has been read from, if any.
+	position *valuePosition // Where the value has been read from, if recorded. See Position.
+
+	// How a string and the type hint have been written, if not the usual way.
+	quotes, hintQuotes quoting
+}
+
+// numberLiteral is the text a number has been written as in a document.
+// It is written back as is, so that no digits or formatting are lost.
+type numberLiteral struct {
+	text  string
+	value interface{} // A copy of RawValue when it was read.
+}
+
+// literalText returns the text the number has been read from, if its value has not been changed since.
+func (v Value) literalText() (string, bool) {
+
+	if n, ok := v.RawValue.(Number); ok {
+		return string(n), true
+	}
+
+	if v.source == nil {
+		return "", false
+	}
+
+	switch raw := v.RawValue.(type) {
+	case *big.Int:
+		if read, ok := v.source.value.(*big.Int); ok && v.Type == TypeInteger && raw.Cmp(read) == 0 {
+			return v.source.text, true
+		}
+	case *big.Float:
+		if read, ok := v.source.value.(*big.Float); ok && v.Type == TypeFloat && raw != nil &&
+			raw.Cmp(read) == 0 && raw.Signbit() == read.Signbit() {
+			return v.source.text, true
+		}
+	}
+
+	return "", false
 }
 
 // NewNullValue constructs a Value that holds a null.
@@ -64,34 +126,170 @@ func NewIntegerValue(v *big.Int, hint TypeHint) Value {
 	return Value{Type: TypeInteger, RawValue: v, TypeHint: hint}
 }
 
+// IsInteger returns true if the Value holds an integer, e.g. 1.
+func (v Value) IsInteger() bool {
+	return v.Type == TypeInteger
+}
+
+// IsFloat returns true if the Value holds a floating point number.
+// Numbers written with a decimal point or an exponent are floats even if they are whole, e.g. 1.0 or 1e2.
+func (v Value) IsFloat() bool {
+	return v.Type == TypeFloat
+}
+
 // IntegerValue returns the inner int value or panics, if the Value is not an integer.
+// A Number is converted on each call.
+//
+// Integers are kept with all of their digits, regardless of their size.
 func (v Value) IntegerValue() *big.Int {
 	if v.Type != TypeInteger {
 		panic("value is not an integer")
 	}
+	if n, ok := v.RawValue.(Number); ok {
+		i, err := n.BigInt()
+		if err != nil {
+			panic(err)
+		}
+		return i
+	}
 	return v.RawValue.(*big.Int)
 }
 
+// Int64Value returns the inner int value as an int64 or panics, if the Value is not an integer.
+// If the integer does not fit in an int64, ErrIntegerOverflow is returned.
+func (v Value) Int64Value() (int64, error) {
+	i := v.IntegerValue()
+	if !i.IsInt64() {
+		return 0, ErrIntegerOverflow
+	}
+	return i.Int64(), nil
+}
+
+// Uint64Value returns the inner int value as a uint64 or panics, if the Value is not an integer.
+// If the integer is negative or does not fit in a uint64, ErrIntegerOverflow is returned.
+func (v Value) Uint64Value() (uint64, error) {
+	i := v.IntegerValue()
+	if !i.IsUint64() {
+		return 0, ErrIntegerOverflow
+	}
+	return i.Uint64(), nil
+}
+
 // NewFloatValue constructs a Value that holds a float.
 func NewFloatValue(v *big.Float, hint TypeHint) Value {
 	return Value{Type: TypeFloat, RawValue: v, TypeHint: hint}
 }
 
+// NewFloat64Value constructs a Value that holds a float, including an infinity or NaN.
+func NewFloat64Value(v float64, hint TypeHint) Value {
+	if math.IsNaN(v) {
+		// big.Float cannot represent a NaN
+		return Value{Type: TypeFloat, RawValue: (*big.Float)(nil), TypeHint: hint}
+	}
+	return NewFloatValue(big.NewFloat(v), hint)
+}
+
 // FloatValue returns the inner float value or panics, if the Value is not a floating point number.
+//
+// A NaN cannot be represented by a big.Float, so nil is returned for it. See IsNaN.
 func (v Value) FloatValue() *big.Float {
 	if v.Type != TypeFloat {
 		panic("value is not a real number")
 	}
+	if n, ok := v.RawValue.(Number); ok {
+		f, err := n.bigFloat()
+		if err != nil {
+			panic(err)
+		}
+		return f
+	}
 	return v.RawValue.(*big.Float)
 }
 
+// IsNaN checks if the Value holds a floating point NaN.
+func (v Value) IsNaN() bool {
+	return v.Type == TypeFloat && v.FloatValue() == nil
+}
+
+// Float64Value returns the inner number as the nearest float64 or panics, if the Value is not a number.
+// Unlike FloatValue, it accepts integers too.
+func (v Value) Float64Value() float64 {
+	if v.Type == TypeInteger {
+		f64, _ := new(big.Float).SetInt(v.IntegerValue()).Float64()
+		return f64
+	}
+	f := v.FloatValue()
+	if f == nil {
+		return math.NaN()
+	}
+	f64, _ := f.Float64()
+	return f64
+}
+
+// asString is a non-panicking StringValue for the typed getters on Node.
+func (v Value) asString() (string, bool) {
+	if v.Type != TypeString {
+		return "", false
+	}
+	return v.StringValue(), true
+}
+
+// asInt64 returns the inner integer, if the Value holds one that fits in an int64.
+// Floats are not accepted, even if they are whole, e.g. 1.0.
+func (v Value) asInt64() (int64, bool) {
+	if v.Type != TypeInteger {
+		return 0, false
+	}
+	i, err := v.Int64Value()
+	return i, err == nil
+}
+
+// asFloat64 is like Float64Value, but reports non-numbers instead of panicking.
+func (v Value) asFloat64() (float64, bool) {
+	if v.Type != TypeFloat && v.Type != TypeInteger {
+		return 0, false
+	}
+	return v.Float64Value(), true
+}
+
+// asBool is like BoolValue, but reports other types instead of panicking.
+func (v Value) asBool() (bool, bool) {
+	if v.Type != TypeBool {
+		return false, false
+	}
+	return v.BoolValue(), true
+}
+
+// Clone returns a copy of the Value that shares no mutable state with it,
+// i.e. big.Int and big.Float numbers are copied.
+func (v Value) Clone() Value {
+	switch raw := v.RawValue.(type) {
+	case *big.Int:
+		if raw != nil {
+			v.RawValue = new(big.Int).Set(raw)
+		}
+	case *big.Float:
+		if raw != nil {
+			v.RawValue = new(big.Float).Copy(raw)
+		}
+	}
+	return v
+}
+
 // newInvalidValue constructs a new Value that is in an invalid state.
 func newInvalidValue() Value {
 	return Value{Type: TypeInvalid}
 }
 
 // ValueOf tries to construct a Value from a provided object.
-// The resulting Value, if valid, will not have a type hint.
+//
+// Strings, bools, numbers of any width, big numbers and Numbers are supported, as well as nil, which is a null.
+// A Value is returned as is. The resulting Value, if valid, will not have a type hint, except for:
+//   - a []byte, which becomes a (base64) string,
+//   - a time.Time, which becomes a (date-time) string in the RFC 3339 format.
+//
+// A time.Duration becomes a string like "1h30m", which Prop and Arg can read back.
+// Any other type makes ErrInvalidValueType returned.
 func ValueOf(v interface{}) (Value, error) {
 
 	if v == nil {
@@ -112,13 +310,20 @@ func ValueOf(v interface{}) (Value, error) {
 		return NewIntegerValue(i, NoHint()), nil
 	case uint, uint8, uint16, uint32, uint64:
 		i := new(big.Int)
-		i.SetUint64(uint64(reflect.ValueOf(v).Float()))
+		i.SetUint64(reflect.ValueOf(v).Uint())
 		return NewIntegerValue(i, NoHint()), nil
 	case *big.Float:
 		return NewFloatValue(v, NoHint()), nil
+	case Number:
+		return v.value()
 	case float32, float64:
-		f := big.NewFloat(v.(float64))
-		return NewFloatValue(f, NoHint()), nil
+		return NewFloat64Value(reflect.ValueOf(v).Float(), NoHint()), nil
+	case []byte:
+		return NewStringValue(base64.StdEncoding.EncodeToString(v), Hint("base64")), nil
+	case time.Time:
+		return NewStringValue(v.Format(time.RFC3339Nano), Hint("date-time")), nil
+	case time.Duration:
+		return NewStringValue(v.String(), NoHint()), nil
 	}
 
 	return newInvalidValue(), ErrInvalidValueType
@@ -0,0 +1,256 @@
+package kdl
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueKeepsBigIntegers(t *testing.T) {
+	doc, err := ParseString("node 123456789012345678901 -9223372036854775809 0xFFFF_FFFF_FFFF_FFFF 9223372036854775807\n")
+	assert.NoError(t, err)
+	args := doc.Nodes[0].Args
+
+	assert.Equal(t, "123456789012345678901", args[0].IntegerValue().String())
+	_, err = args[0].Int64Value()
+	assert.ErrorIs(t, err, ErrIntegerOverflow)
+	_, err = args[0].Uint64Value()
+	assert.ErrorIs(t, err, ErrIntegerOverflow)
+
+	_, err = args[1].Int64Value()
+	assert.ErrorIs(t, err, ErrIntegerOverflow)
+	_, err = args[1].Uint64Value()
+	assert.ErrorIs(t, err, ErrIntegerOverflow)
+
+	u, err := args[2].Uint64Value()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), u)
+
+	i, err := args[3].Int64Value()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), i)
+
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, "node 123456789012345678901 -9223372036854775809 0xFFFF_FFFF_FFFF_FFFF 9223372036854775807\n", written)
+}
+
+func TestValueOfUnsigned(t *testing.T) {
+	v, err := ValueOf(uint64(math.MaxUint64))
+	assert.NoError(t, err)
+	u, err := v.Uint64Value()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), u)
+
+	v, err = ValueOf(uint8(7))
+	assert.NoError(t, err)
+	assert.Equal(t, "7", v.IntegerValue().String())
+}
+
+// withLiteral makes the number look like it has been read from the text.
+func withLiteral(v Value, text string) Value {
+	var value interface{}
+	switch raw := v.RawValue.(type) {
+	case *big.Int:
+		value = new(big.Int).Set(raw)
+	case *big.Float:
+		value = new(big.Float).Copy(raw)
+	}
+	v.source = &numberLiteral{text: text, value: value}
+	return v
+}
+
+func TestWritesNumbersAsRead(t *testing.T) {
+	input := "node 3.141592653589793238462643 1_000.000_1 -0.0 +12 1.5E-7 1e10 0o7_7 0b1_0 0.1\n"
+	doc, err := ParseString(input)
+	assert.NoError(t, err)
+
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, input, written)
+}
+
+func TestWritesChangedNumbersAnew(t *testing.T) {
+	doc, err := ParseString("node 1_000 2.50 3 -0.0\n")
+	assert.NoError(t, err)
+	args := doc.Nodes[0].Args
+
+	// Changed in place
+	args[0].IntegerValue().SetInt64(7)
+	// Replaced
+	args[1].RawValue = big.NewFloat(0.25)
+	// Same value, different type
+	args[2] = NewFloat64Value(3, NoHint())
+	// Sign of the zero flipped
+	args[3].FloatValue().Neg(args[3].FloatValue())
+
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, "node 7 0.25 3.0 0.0\n", written)
+}
+
+func TestDistinguishesIntegersAndFloats(t *testing.T) {
+	cases := []struct {
+		text    string
+		integer bool
+		anew    string // How the value is written once its text is forgotten
+	}{
+		{"1", true, "1"},
+		{"1.0", false, "1.0"},
+		{"1e2", false, "100.0"},
+		{"1E+2", false, "100.0"},
+		{"0x10", true, "16"},
+		{"-0", true, "0"},
+		{"-0.0", false, "-0.0"},
+	}
+	for _, c := range cases {
+		input := "node " + c.text + "\n"
+		doc, err := ParseString(input)
+		if !assert.NoError(t, err, c.text) {
+			continue
+		}
+		v := doc.Nodes[0].Args[0]
+		assert.Equal(t, c.integer, v.IsInteger(), c.text)
+		assert.Equal(t, !c.integer, v.IsFloat(), c.text)
+
+		written, err := doc.WriteString()
+		assert.NoError(t, err)
+		assert.Equal(t, input, written)
+
+		v.source = nil
+		doc.Nodes[0].Args[0] = v
+		written, err = doc.WriteString()
+		assert.NoError(t, err)
+		assert.Equal(t, "node "+c.anew+"\n", written, c.text)
+	}
+
+	doc, err := ParseString("node 1.5 1 1.0\n")
+	assert.NoError(t, err)
+	args := doc.Nodes[0].Args
+	assert.Panics(t, func() { args[0].IntegerValue() })
+	_, err = Number("1.0").Int64()
+	assert.ErrorIs(t, err, ErrNotInteger)
+	assert.Equal(t, 1.0, args[1].Float64Value())
+	assert.Equal(t, 1.0, args[2].Float64Value())
+}
+
+func TestValueOfKeepsNegativeZero(t *testing.T) {
+	for _, f := range []interface{}{math.Copysign(0, -1), float32(math.Copysign(0, -1))} {
+		node := NewNode("node")
+		assert.NoError(t, node.AddArg(f))
+		doc := Document{Nodes: []Node{node}}
+		written, err := doc.WriteString()
+		assert.NoError(t, err)
+		assert.Equal(t, "node -0.0\n", written, "%T", f)
+
+		doc, err = ParseString(written)
+		if assert.NoError(t, err) {
+			assert.True(t, math.Signbit(doc.Nodes[0].Args[0].Float64Value()), "%T", f)
+		}
+	}
+}
+
+func TestValueClone(t *testing.T) {
+	for _, v := range []Value{
+		NewNullValue(Hint("h")),
+		NewBoolValue(true, NoHint()),
+		NewStringValue("s", NoHint()),
+		NewFloat64Value(math.NaN(), NoHint()),
+		newInvalidValue(),
+	} {
+		assert.Equal(t, v, v.Clone())
+	}
+
+	i := NewIntegerValue(big.NewInt(1), Hint("u8"))
+	clone := i.Clone()
+	clone.IntegerValue().SetInt64(2)
+	assert.Equal(t, int64(1), i.IntegerValue().Int64())
+	assert.Equal(t, Hint("u8"), clone.TypeHint)
+}
+
+func TestTypeTagsAgree(t *testing.T) {
+	cases := []struct {
+		kdl         string // Argument of a node, in KDL 2.0
+		constructed Value
+		name        string
+	}{
+		{`#null`, NewNullValue(NoHint()), "null"},
+		{`#true`, NewBoolValue(true, NoHint()), "bool"},
+		{`"s"`, NewStringValue("s", NoHint()), "string"},
+		{`1`, NewIntegerValue(big.NewInt(1), NoHint()), "integer"},
+		{`1.5`, NewFloat64Value(1.5, NoHint()), "float"},
+		{`#nan`, NewFloat64Value(math.NaN(), NoHint()), "float"},
+	}
+	for _, c := range cases {
+		parsed := parseValue(t, c.kdl)
+		assert.Equal(t, c.constructed.Type, parsed.Type, c.kdl)
+		assert.Equal(t, c.name, parsed.Type.String(), c.kdl)
+
+		withNumbers, err := ParseString("node "+c.kdl+"\n", WithVersion(Version2), WithNumbers(true))
+		if assert.NoError(t, err, c.kdl) {
+			assert.Equal(t, c.constructed.Type, withNumbers.Nodes[0].Args[0].Type, c.kdl)
+		}
+
+		of, err := ValueOf(c.constructed.RawValue)
+		if c.constructed.Type != TypeNull && !c.constructed.IsNaN() && assert.NoError(t, err, c.kdl) {
+			assert.Equal(t, c.constructed.Type, of.Type, c.kdl)
+		}
+	}
+
+	assert.Equal(t, "invalid", newInvalidValue().Type.String())
+	assert.Equal(t, "TypeTag(42)", TypeTag(42).String())
+}
+
+func TestValueOfSupportedTypes(t *testing.T) {
+	type myInt int
+	moment := time.Date(2024, 2, 29, 13, 14, 15, 5e8, time.UTC)
+
+	cases := []struct {
+		in   interface{}
+		want string // The Value as written, with its type hint
+	}{
+		{nil, "null"},
+		{"s", `"s"`},
+		{true, "true"},
+		{int(-1), "-1"},
+		{int8(-8), "-8"},
+		{int16(-16), "-16"},
+		{int32(-32), "-32"},
+		{int64(math.MinInt64), "-9223372036854775808"},
+		{uint(1), "1"},
+		{uint8(8), "8"},
+		{uint16(16), "16"},
+		{uint32(32), "32"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{float32(1.5), "1.5"},
+		{2.25, "2.25"},
+		{big.NewInt(7), "7"},
+		{big.NewFloat(0.5), "0.5"},
+		{Number("0x10"), "0x10"},
+		{[]byte("hi!"), `(base64)"aGkh"`},
+		{[]byte{}, `(base64)""`},
+		{moment, `(date-time)"2024-02-29T13:14:15.5Z"`},
+		{90 * time.Minute, `"1h30m0s"`},
+		{NewStringValue("v", Hint("t")), `(t)"v"`},
+	}
+	for _, c := range cases {
+		v, err := ValueOf(c.in)
+		if !assert.NoError(t, err, c.in) {
+			continue
+		}
+		n := NewNode("n")
+		n.AddArgValue(v)
+		doc := Document{Nodes: []Node{n}}
+		written, err := doc.WriteString()
+		assert.NoError(t, err, c.in)
+		assert.Equal(t, "n "+c.want+"\n", written, c.in)
+	}
+
+	for _, in := range []interface{}{struct{ A int }{1}, myInt(1), []int{1}, new(int), map[string]int{}} {
+		_, err := ValueOf(in)
+		assert.ErrorIs(t, err, ErrInvalidValueType, in)
+	}
+}
@@ -0,0 +1,64 @@
+package kdl
+
+// WalkAction tells Walk how to continue after visiting a node.
+type WalkAction int
+
+const (
+	WalkContinue     WalkAction = iota // Visit the children of the node, then its next siblings.
+	WalkSkipChildren                   // Do not visit the children of the node.
+	WalkStop                           // Do not visit any more nodes.
+)
+
+// Walk visits this Node and all of its descendants depth-first, in the order of the document.
+//
+// The path passed to fn holds the ancestors of the visited node, starting with this Node,
+// and is empty when visiting this Node itself. The slice is reused between calls,
+// so it must be copied to be retained after fn returns.
+//
+// fn can modify the visited node in place, including its Children, which are then walked as modified.
+// It must not change the Children of any of the nodes in the path. If it does,
+// the walk stops and ErrChildrenModified is returned.
+// Stopping the walk with WalkStop is not an error.
+func (n *Node) Walk(fn func(path []*Node, n *Node) WalkAction) error {
+	_, err := walkNode(nil, n, fn)
+	return err
+}
+
+// Walk visits all the nodes of this Document depth-first, in the order of the document,
+// the same way as Node.Walk does. The path of the top-level nodes is empty.
+//
+// fn must not change the Nodes of the Document either.
+func (d *Document) Walk(fn func(path []*Node, n *Node) WalkAction) error {
+	_, err := walkNodes(nil, &d.Nodes, fn)
+	return err
+}
+
+// walkNode visits a node and its children, reporting if the walk should stop.
+func walkNode(path []*Node, n *Node, fn func([]*Node, *Node) WalkAction) (bool, error) {
+	switch fn(path, n) {
+	case WalkStop:
+		return true, nil
+	case WalkSkipChildren:
+		return false, nil
+	}
+	return walkNodes(append(path, n), &n.Children, fn)
+}
+
+// walkNodes visits the siblings one by one, checking after each of them that the slice is still the same.
+func walkNodes(path []*Node, nodes *[]Node, fn func([]*Node, *Node) WalkAction) (bool, error) {
+	walked := *nodes
+	if len(walked) > 0 && len(path) == cap(path) {
+		// Make room for the siblings to share, instead of each of them growing the path on its own
+		path = append(make([]*Node, 0, 2*len(path)+16), path...)
+	}
+	for i := range walked {
+		stop, err := walkNode(path, &walked[i], fn)
+		if stop || err != nil {
+			return true, err
+		}
+		if current := *nodes; len(current) != len(walked) || &current[0] != &walked[0] {
+			return true, ErrChildrenModified
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,151 @@
+package kdl
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const walkDocument = `a {
+	b {
+		c
+	}
+	d
+}
+e {
+	f
+}
+`
+
+// pathNames joins the names of the ancestors and the node, e.g. "a/b/c".
+func pathNames(path []*Node, n *Node) string {
+	var names []string
+	for _, p := range path {
+		names = append(names, string(p.Name))
+	}
+	return strings.Join(append(names, string(n.Name)), "/")
+}
+
+func TestDocumentWalk(t *testing.T) {
+	doc, err := ParseString(walkDocument)
+	assert.NoError(t, err)
+
+	var visited []string
+	err = doc.Walk(func(path []*Node, n *Node) WalkAction {
+		visited = append(visited, pathNames(path, n))
+		return WalkContinue
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "a/b", "a/b/c", "a/d", "e", "e/f"}, visited)
+}
+
+func TestNodeWalk(t *testing.T) {
+	doc, err := ParseString(walkDocument)
+	assert.NoError(t, err)
+
+	var visited []string
+	err = doc.Nodes[0].Walk(func(path []*Node, n *Node) WalkAction {
+		visited = append(visited, pathNames(path, n))
+		return WalkContinue
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "a/b", "a/b/c", "a/d"}, visited)
+}
+
+func TestWalkActions(t *testing.T) {
+	doc, err := ParseString(walkDocument)
+	assert.NoError(t, err)
+
+	var visited []string
+	err = doc.Walk(func(path []*Node, n *Node) WalkAction {
+		visited = append(visited, pathNames(path, n))
+		if n.Name == "b" {
+			return WalkSkipChildren
+		}
+		return WalkContinue
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "a/b", "a/d", "e", "e/f"}, visited)
+
+	visited = nil
+	err = doc.Walk(func(path []*Node, n *Node) WalkAction {
+		visited = append(visited, pathNames(path, n))
+		if n.Name == "c" {
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "a/b", "a/b/c"}, visited)
+}
+
+func TestWalkModifiesNodes(t *testing.T) {
+	doc, err := ParseString(walkDocument)
+	assert.NoError(t, err)
+
+	err = doc.Walk(func(path []*Node, n *Node) WalkAction {
+		n.SetPropValue("depth", NewIntegerValue(big.NewInt(int64(len(path))), NoHint()))
+		if n.Name == "d" {
+			// The children of the visited node can be replaced
+			n.AddChild(NewNode("added"))
+		}
+		return WalkContinue
+	})
+	assert.NoError(t, err)
+
+	var visited []string
+	err = doc.Walk(func(path []*Node, n *Node) WalkAction {
+		depth, _ := n.PropInt("depth")
+		assert.Equal(t, int64(len(path)), depth, n.Name)
+		visited = append(visited, pathNames(path, n))
+		return WalkContinue
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "a/b", "a/b/c", "a/d", "a/d/added", "e", "e/f"}, visited)
+}
+
+func TestWalkDetectsModifiedAncestors(t *testing.T) {
+	doc, err := ParseString(walkDocument)
+	assert.NoError(t, err)
+
+	var visited []string
+	err = doc.Walk(func(path []*Node, n *Node) WalkAction {
+		visited = append(visited, pathNames(path, n))
+		if n.Name == "c" {
+			path[0].AddChild(NewNode("added"))
+		}
+		return WalkContinue
+	})
+	assert.ErrorIs(t, err, ErrChildrenModified)
+	assert.Equal(t, []string{"a", "a/b", "a/b/c"}, visited)
+
+	err = doc.Walk(func(path []*Node, n *Node) WalkAction {
+		doc.Nodes = doc.Nodes[:1]
+		return WalkContinue
+	})
+	assert.ErrorIs(t, err, ErrChildrenModified)
+}
+
+func TestWalkPathCopies(t *testing.T) {
+	doc, err := ParseString(walkDocument)
+	assert.NoError(t, err)
+
+	var copied [][]*Node
+	err = doc.Walk(func(path []*Node, n *Node) WalkAction {
+		copied = append(copied, append([]*Node(nil), path...))
+		return WalkContinue
+	})
+	assert.NoError(t, err)
+
+	names := func(path []*Node) []Identifier {
+		var out []Identifier
+		for _, n := range path {
+			out = append(out, n.Name)
+		}
+		return out
+	}
+	assert.Equal(t, []Identifier{"a", "b"}, names(copied[2]))
+	assert.Equal(t, []Identifier{"e"}, names(copied[5]))
+}
@@ -4,10 +4,6 @@ import (
 	"bufio"
 	"bytes"
 	"io"
-	"strings"
-
-	"golang.org/x/exp/maps"
-	"golang.org/x/exp/slices"
 )
 
 // writeArgs serializes Node's arguments.
@@ -45,15 +41,13 @@ func writeProps(w *writer, n *Node) error {
 		return nil
 	}
 
-	// Sort properties alphabetically
-	keys := maps.Keys(p)
-	slices.Sort(keys)
+	keys := n.orderedPropKeys()
 
 	for i, key := range keys {
 
 		value := p[key]
 
-		if err := writeIdentifier(w, key); err != nil {
+		if err := writeIdentifierAs(w, key, n.keyQuotes[key]); err != nil {
 			return err
 		}
 		if err := w.writer.WriteByte('='); err != nil {
@@ -76,16 +70,29 @@ func writeProps(w *writer, n *Node) error {
 
 func writeNode(w *writer, n *Node) error {
 
-	indent := strings.Repeat("    ", w.depth)
+	indent := w.indentation()
+	for i := 0; i < n.BlankLinesBefore; i++ {
+		if err := w.writer.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	for _, comment := range n.LeadingComments {
+		if err := writeComment(w, indent, comment); err != nil {
+			return err
+		}
+		if err := w.writer.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
 	if _, err := w.writer.WriteString(indent); err != nil {
 		return err
 	}
 
-	if err := writeTypeHint(w, n.TypeHint); err != nil {
+	if err := writeTypeHint(w, n.TypeHint, n.hintQuotes); err != nil {
 		return err
 	}
 
-	if err := writeIdentifier(w, n.Name); err != nil {
+	if err := writeIdentifierAs(w, n.Name, n.nameQuotes); err != nil {
 		return err
 	}
 
@@ -107,40 +114,80 @@ func writeNode(w *writer, n *Node) error {
 		}
 	}
 
-	if len(n.Children) > 0 {
-
-		if _, err := w.writer.WriteString(" {"); err != nil {
+	if len(n.Children) > 0 || len(n.EndComments) > 0 {
+		if err := writeChildren(w, n); err != nil {
 			return err
 		}
+	}
 
-		w.depth++
-		for i := range n.Children {
-			if err := w.writer.WriteByte('\n'); err != nil {
-				return err
-			}
-			child := &n.Children[i]
-			if err := writeNode(w, child); err != nil {
-				return err
-			}
+	if n.TrailingComment != "" {
+		if err := writeSpace(w); err != nil {
+			return err
+		}
+		if _, err := w.writer.WriteString(n.TrailingComment); err != nil {
+			return err
 		}
-		w.depth--
+	}
+
+	return nil
+}
+
+// writeChildren writes the children block of a node, along with the comments at its end.
+func writeChildren(w *writer, n *Node) error {
 
+	indent := w.indentation()
+	if _, err := w.writer.WriteString(" {"); err != nil {
+		return err
+	}
+
+	w.depth++
+	for i := range n.Children {
 		if err := w.writer.WriteByte('\n'); err != nil {
 			return err
 		}
-
-		if _, err := w.writer.WriteString(indent); err != nil {
+		child := &n.Children[i]
+		if err := writeNode(w, child); err != nil {
 			return err
 		}
-
-		if err := w.writer.WriteByte('}'); err != nil {
+	}
+	for _, comment := range n.EndComments {
+		if err := w.writer.WriteByte('\n'); err != nil {
 			return err
 		}
+		if err := writeComment(w, w.indentation(), comment); err != nil {
+			return err
+		}
+	}
+	w.depth--
+
+	if err := w.writer.WriteByte('\n'); err != nil {
+		return err
+	}
+
+	if _, err := w.writer.WriteString(indent); err != nil {
+		return err
+	}
+
+	if err := w.writer.WriteByte('}'); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// writeComment writes a comment on its own line, without the line break.
+func writeComment(w *writer, indent, comment string) error {
+	if comment == "" {
+		// A blank line between comments
+		return nil
+	}
+	if _, err := w.writer.WriteString(indent); err != nil {
+		return err
+	}
+	_, err := w.writer.WriteString(comment)
+	return err
+}
+
 func writeDocument(w *writer, d *Document) error {
 
 	nodes := d.Nodes
@@ -157,12 +204,57 @@ func writeDocument(w *writer, d *Document) error {
 		}
 	}
 
+	for i, comment := range d.EndComments {
+		if i > 0 || len(nodes) > 0 {
+			if err := w.writer.WriteByte('\n'); err != nil {
+				return err
+			}
+		}
+		if err := writeComment(w, "", comment); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// Write writes the Document to an io.Writer.
+// WriteOptions configures how a Document is written.
+// The zero value is the default configuration, used by Write.
+type WriteOptions struct {
+	// NormalizeNumbers makes the writer write all the numbers in their canonical form,
+	// e.g. 31 for 0x1F and 2.5 for 2.50. By default, the numbers that have been read from a document
+	// and not modified since are written back as they have been written there.
+	NormalizeNumbers bool
+
+	// NormalizeQuotes makes the writer write the strings and the identifiers in the usual way:
+	// the identifiers bare if they can be, and everything else in quotes. By default, the ones that have
+	// been read from a document keep their style, e.g. quotes around "my-node" or a raw string.
+	// The style is kept as long as the text can be written in it; a raw string gets more '#'
+	// around it if it needs them, and is quoted if it cannot be raw any more, e.g. with a line break in KDL 2.0.
+	NormalizeQuotes bool
+
+	// The text kept with ParseOptions.KeepFormat is not used with either option, as it has the values in it.
+}
+
+// Write writes the Document to an io.Writer, in the syntax of its Version, or KDL 1.0 if it has none.
+// A value that the version cannot express, e.g. an infinite float in KDL 1.0, fails with ErrNotInVersion.
 func (d *Document) Write(w io.Writer) error {
-	bw := writer{writer: bufio.NewWriter(w)}
+	return d.WriteWithOptions(w, WriteOptions{})
+}
+
+// WriteWithOptions writes the Document to an io.Writer, configured by the options.
+func (d *Document) WriteWithOptions(w io.Writer, opts WriteOptions) error {
+	bw := writer{writer: bufio.NewWriter(w), version: d.Version, normalizeNumbers: opts.NormalizeNumbers, normalizeQuotes: opts.NormalizeQuotes}
+	if d.format != nil && (opts.NormalizeNumbers || opts.NormalizeQuotes) {
+		// The document is written anew, but in the style of its text
+		bw.unit = d.format.unit
+	} else if d.format != nil {
+		// The text after the last node already ends the document
+		if err := writeFormattedDocument(&bw, d); err != nil {
+			return err
+		}
+		return bw.writer.Flush()
+	}
 	if err := writeDocument(&bw, d); err != nil {
 		return err
 	}
@@ -178,3 +270,30 @@ func (d *Document) WriteString() (string, error) {
 	err := d.Write(&buf)
 	return buf.String(), err
 }
+
+// WriteTo writes the Document to an io.Writer, returning the count of bytes written.
+// It implements io.WriterTo.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	cw := countingWriter{w: w}
+	err := d.Write(&cw)
+	return cw.n, err
+}
+
+// String returns the Document in the KDL format.
+// A Document that cannot be written, e.g. because of a value of an unknown type, is written only partially.
+func (d *Document) String() string {
+	s, _ := d.WriteString()
+	return s
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
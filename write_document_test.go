@@ -1,7 +1,14 @@
 package kdl
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
 	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,3 +47,238 @@ func TestDocumentWritesCorrectly(t *testing.T) {
 "ghi jkl"
 `, s)
 }
+
+func TestDocumentWritesSpecialFloats(t *testing.T) {
+
+	n := NewNode("floats")
+	n.AddArgValue(NewFloat64Value(math.Inf(1), NoHint()))
+	n.AddArgValue(NewFloat64Value(math.Inf(-1), NoHint()))
+	n.AddArgValue(NewFloat64Value(math.NaN(), NoHint()))
+
+	doc := NewDocument()
+	doc.AddChild(n)
+	_, err := doc.WriteString()
+	assert.ErrorIs(t, err, ErrNotInVersion)
+
+	doc.Version = Version2
+	s, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, "floats #inf #-inf #nan\n", s)
+
+	parsed, err := ParseString(s, WithVersion(Version2))
+	assert.NoError(t, err)
+	assert.True(t, parsed.Nodes[0].Args[2].IsNaN())
+}
+
+func TestDocumentWritesItsVersion(t *testing.T) {
+	const input = "n #true #false #null #inf #-inf #nan #\"raw\"# bare\n"
+	doc, err := ParseString(input, WithVersion(Version2))
+	assert.NoError(t, err)
+	assert.Equal(t, input, doc.String())
+
+	again, err := ParseString(doc.String(), WithVersion(Version2))
+	if assert.NoError(t, err) {
+		assert.True(t, again.Equal(&doc))
+	}
+
+	// The keywords of KDL 1.0, as long as it has them
+	doc, err = ParseString("n #true #false #null #\"raw\"#\n", WithVersion(Version2))
+	assert.NoError(t, err)
+	doc.Version = Version1
+	assert.Equal(t, "n true false null r#\"raw\"#\n", doc.String())
+	doc.Nodes[0].AddArgValue(NewFloat64Value(math.Inf(1), NoHint()))
+	_, err = doc.WriteString()
+	assert.ErrorIs(t, err, ErrNotInVersion)
+}
+
+func TestDocumentWriteTo(t *testing.T) {
+	doc, err := ParseString("a 1\nb \"two\" {\n\tc\n}\n")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	var w io.WriterTo = &doc
+	n, err := w.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, "a 1\nb \"two\" {\n    c\n}\n", buf.String())
+
+	assert.Equal(t, buf.String(), doc.String())
+	assert.Equal(t, buf.String(), fmt.Sprint(&doc))
+	assert.Equal(t, "\n", (&Document{}).String())
+}
+
+func TestDocumentWritesPropsInOrder(t *testing.T) {
+	const input = "node k=1 j=2 i=3 h=4 g=5 f=6 e=7 d=8 c=9 b=10\n"
+	for run := 0; run < 10; run++ {
+		doc, err := ParseString(input)
+		assert.NoError(t, err)
+		written, err := doc.WriteString()
+		assert.NoError(t, err)
+		assert.Equal(t, input, written, run)
+	}
+
+	// Duplicates keep the place of the first one
+	for policy, want := range map[DuplicatePropsPolicy]string{
+		DuplicatePropsLastWins:  "node b=3 a=2\n",
+		DuplicatePropsFirstWins: "node b=1 a=2\n",
+	} {
+		doc, err := ParseString("node b=1 a=2 b=3\n", WithDuplicateProps(policy))
+		assert.NoError(t, err)
+		written, err := doc.WriteString()
+		assert.NoError(t, err)
+		assert.Equal(t, want, written)
+	}
+}
+
+// sortProps sorts the properties of all the nodes by their names,
+// as the test suite of the specification expects them to be written.
+func sortProps(d *Document) {
+	_ = d.Walk(func(_ []*Node, n *Node) WalkAction {
+		n.SortProps()
+		return WalkContinue
+	})
+}
+
+func TestSortProps(t *testing.T) {
+	doc, err := ParseString("node c=3 a=1 b=2 {\n\tchild z=1 y=2\n}\n")
+	assert.NoError(t, err)
+	sortProps(&doc)
+	written, err := doc.WriteString()
+	assert.NoError(t, err)
+	assert.Equal(t, "node a=1 b=2 c=3 {\n    child y=2 z=1\n}\n", written)
+}
+
+func TestDocumentWritesComments(t *testing.T) {
+	doc, err := ParseString(commentedDocument, WithComments(true))
+	assert.NoError(t, err)
+
+	written := doc.String()
+	assert.Equal(t, `// Server configuration
+/* applies to
+   all servers */
+server "main" port=80 {
+    // opening
+    // Where to listen
+    listen "0.0.0.0" // all interfaces
+    /* end of server */
+} /* http */ // server done
+// Before last
+last // after semicolon
+// end of document
+`, written)
+
+	// Writing again gives the same document
+	reparsed, err := ParseString(written, WithComments(true))
+	assert.NoError(t, err)
+	assert.Equal(t, written, reparsed.String())
+	assert.True(t, doc.Equal(&reparsed))
+
+	// A children block is written for the comments alone
+	n := NewNode("empty")
+	n.EndComments = []string{"// nothing here yet"}
+	d := Document{Nodes: []Node{n}}
+	assert.Equal(t, "empty {\n    // nothing here yet\n}\n", d.String())
+}
+
+func TestDocumentWritesBlankLines(t *testing.T) {
+	const source = `
+server {
+
+    listen 80
+    listen 443
+
+    // Limits
+    timeout 30
+}
+
+
+client
+`
+	doc, err := ParseString(source, WithBlankLines(true), WithComments(true))
+	assert.NoError(t, err)
+	assert.Equal(t, source, doc.String())
+
+	// Editing a value keeps the layout
+	doc.Nodes[0].Children[2].Args[0] = NewIntegerValue(big.NewInt(60), NoHint())
+	reparsed, err := ParseString(doc.String(), WithBlankLines(true), WithComments(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reparsed.Nodes[0].Children[2].BlankLinesBefore)
+	assert.Equal(t, 2, reparsed.Nodes[1].BlankLinesBefore)
+
+	// New nodes have none
+	doc.AddChild(NewNode("added"))
+	assert.Equal(t, strings.Replace(source, "30", "60", 1)+"added\n", doc.String())
+}
+
+func TestDocumentWritesBlankLinesAroundComments(t *testing.T) {
+	const source = `a
+// section
+
+b
+
+// one
+
+
+// two
+c {
+    x
+    // end
+
+}
+// last
+
+`
+	doc, err := ParseString(source, WithBlankLines(true), WithComments(true))
+	assert.NoError(t, err)
+	assert.Equal(t, source, doc.String())
+
+	// The blank lines after a comment stay after it
+	b := doc.Nodes[1]
+	assert.Equal(t, 0, b.BlankLinesBefore)
+	assert.Equal(t, []string{"// section", ""}, b.LeadingComments)
+	c := doc.Nodes[2]
+	assert.Equal(t, 1, c.BlankLinesBefore)
+	assert.Equal(t, []string{"// one", "", "", "// two"}, c.LeadingComments)
+	assert.Equal(t, []string{"// end", ""}, c.EndComments)
+
+	// Without the blank lines, the comments are as they have been
+	doc, err = ParseString(source, WithComments(true))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"// section"}, doc.Nodes[1].LeadingComments)
+}
+
+func TestDocumentWritesNumbersAsRead(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "numbers.kdl"))
+	assert.NoError(t, err)
+	doc, err := ParseBytes(data, WithVersion(Version2))
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), doc.String())
+
+	// Modified numbers are written anew
+	doc.Nodes[0].Args[0] = NewIntegerValue(big.NewInt(32), NoHint())
+	assert.Equal(t, "hex 32 0xdead_BEEF -0x10\n", strings.SplitAfter(doc.String(), "\n")[0])
+
+	// Unless all of them are
+	const normalized = `hex 32 3735928559 -16
+octal 493 7
+binary 165 -1
+underscores 1E+6 12 12
+exponents 1.0E+10 1.5E-03 -20000.0 6.02214E+23
+zeros 2.5 0.0 -0.0 1.0 7 12
+props mask=255 ratio=0.5 level=(u8)3
+`
+	var buf bytes.Buffer
+	assert.NoError(t, doc.WriteWithOptions(&buf, WriteOptions{NormalizeNumbers: true}))
+	assert.Equal(t, normalized, buf.String())
+
+	// Including the ones kept as a Number, and the ones in the text kept with KeepFormat
+	for _, opt := range []Option{WithNumbers(true), WithFidelity(true)} {
+		doc, err = ParseBytes(data, WithVersion(Version2), opt)
+		assert.NoError(t, err)
+		assert.Equal(t, string(data), doc.String())
+		doc.Nodes[0].Args[0] = NewIntegerValue(big.NewInt(32), NoHint())
+		buf.Reset()
+		assert.NoError(t, doc.WriteWithOptions(&buf, WriteOptions{NormalizeNumbers: true}))
+		assert.Equal(t, normalized, buf.String())
+	}
+}
@@ -1,6 +1,7 @@
 package kdl
 
 import (
+	"fmt"
 	"math"
 	"math/big"
 	"strconv"
@@ -29,6 +30,9 @@ func writeString(w *writer, s string) error {
 }
 
 func writeBool(w *writer, b bool) error {
+	if err := writeKeywordHash(w); err != nil {
+		return err
+	}
 	v := bytesFalse[:]
 	if b {
 		v = bytesTrue[:]
@@ -81,13 +85,20 @@ var bigFloatZero = big.NewFloat(0.0)
 func writeFloat(w *writer, f *big.Float) error {
 
 	if f.Cmp(bigFloatZero) == 0 {
-		_, err := w.writer.WriteString("0.0")
+		text := "0.0"
+		if f.Signbit() {
+			text = "-0.0"
+		}
+		_, err := w.writer.WriteString(text)
 		return err
 	}
 
 	if f.IsInf() {
-		_, err := w.writer.WriteString("Inf")
-		return err
+		keyword := "#inf"
+		if f.Signbit() {
+			keyword = "#-inf"
+		}
+		return writeFloatKeyword(w, keyword)
 	}
 
 	// Mode 'G' switches to sci mode later than we would like
@@ -126,23 +137,51 @@ func writeFloat(w *writer, f *big.Float) error {
 }
 
 func writeNull(w *writer) error {
+	if err := writeKeywordHash(w); err != nil {
+		return err
+	}
 	_, err := w.writer.Write(bytesNull[:])
 	return err
 }
 
+// writeKeywordHash writes the '#' that keywords start with in KDL 2.0, if the writer follows that version.
+func writeKeywordHash(w *writer) error {
+	if w.version < Version2 {
+		return nil
+	}
+	return w.writer.WriteByte('#')
+}
+
+// writeFloatKeyword writes #inf, #-inf or #nan, which only KDL 2.0 has.
+func writeFloatKeyword(w *writer, keyword string) error {
+	if w.version < Version2 {
+		return fmt.Errorf("kdl: %s needs KDL 2.0: %w", keyword, ErrNotInVersion)
+	}
+	_, err := w.writer.WriteString(keyword)
+	return err
+}
+
 func writeValue(w *writer, v *Value) error {
 
-	err := writeTypeHint(w, v.TypeHint)
+	err := writeTypeHint(w, v.TypeHint, v.hintQuotes)
 	if err != nil {
 		return err
 	}
 
+	if text, ok := v.literalText(); ok && !w.normalizeNumbers {
+		_, err := w.writer.WriteString(text)
+		return err
+	}
+
 	switch v.Type {
 	case TypeString:
-		return writeString(w, v.StringValue())
+		return writeStringAs(w, v.StringValue(), v.quotes)
 	case TypeInteger:
 		return writeInteger(w, v.IntegerValue())
 	case TypeFloat:
+		if v.IsNaN() {
+			return writeFloatKeyword(w, "#nan")
+		}
 		return writeFloat(w, v.FloatValue())
 	case TypeBool:
 		return writeBool(w, v.BoolValue())
@@ -163,7 +202,7 @@ func writeIdentifier(w *writer, i Identifier) (err error) {
 }
 
 // writeTypeHint writes a type hint to the output, if the hint is present.
-func writeTypeHint(w *writer, hint TypeHint) error {
+func writeTypeHint(w *writer, hint TypeHint, q quoting) error {
 
 	if hint.IsAbsent() {
 		return nil
@@ -173,7 +212,7 @@ func writeTypeHint(w *writer, hint TypeHint) error {
 		return err
 	}
 
-	if err := writeIdentifier(w, hint.MustGet()); err != nil {
+	if err := writeIdentifierAs(w, hint.MustGet(), q); err != nil {
 		return err
 	}
 
@@ -1,10 +1,31 @@
 package kdl
 
-import "bufio"
+import (
+	"bufio"
+	"strings"
+)
 
 type writer struct {
 	writer *bufio.Writer
 	depth  int
+	indent string // Indentation of the nodes at depth 0.
+	unit   string // Indentation added at each level of nesting, four spaces if empty.
+
+	// version is the version of the specification of the keywords written, e.g. #true.
+	// Zero stands for KDL 1.0.
+	version Version
+
+	normalizeNumbers bool // See WriteOptions.NormalizeNumbers.
+	normalizeQuotes  bool // See WriteOptions.NormalizeQuotes.
+}
+
+// indentation returns the indentation of the nodes at the current depth.
+func (w *writer) indentation() string {
+	unit := w.unit
+	if unit == "" {
+		unit = "    "
+	}
+	return w.indent + strings.Repeat(unit, w.depth)
 }
 
 func writeSpace(w *writer) error {